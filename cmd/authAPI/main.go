@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"runtime"
+	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 
 	"github.com/MarioSimou/authAPI/internal/controllers"
+	"github.com/MarioSimou/authAPI/internal/mail"
+	"github.com/MarioSimou/authAPI/internal/media"
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
 	"github.com/MarioSimou/authAPI/internal/utils"
 	"github.com/MarioSimou/authAPI/internal/utils/middlewares"
 )
@@ -18,38 +27,278 @@ type App struct {
 	Middlewares *middlewares.Middleware
 }
 
-func (a *App) Run() {
+// Router returns the routes of the API wrapped within their middlewares
+func (a *App) Router() http.Handler {
 	m := a.Middlewares
 	c := a.Controller
 
+	admin := m.RequireRole(models.RoleAdmin)
+	// anonymous routes are limited before the request is validated, while the rest are limited per user
+	limit := m.RateLimit
+
 	// routes wrapped within middlewares that check the requests
-	getUsers := middlewares.Handler(m.ValidateRequest(m.Authorization(c.GetUsers)))
+	getUsers := middlewares.Handler(m.ValidateRequest(m.Authorization(admin(c.GetUsers))))
 	getUser := middlewares.Handler(m.ValidateRequest(m.Authorization(c.GetUser)))
-	createUser := middlewares.Handler(m.ValidateRequest(m.ValidateCreateUser(c.CreateUser)))
+	// the verification link is opened by browsers, so its page and the form of the page are not JSON routes
+	verifyEmailPage := middlewares.Handler(limit("verifyEmail")(c.VerifyEmailPage))
+	verifyEmail := middlewares.Handler(limit("verifyEmail")(c.VerifyEmail))
+	resendVerificationEmail := middlewares.Handler(m.ValidateRequest(m.Authorization(limit("resendVerificationEmail")(c.ResendVerificationEmail))))
+	createUser := middlewares.Handler(limit("createUser")(m.ValidateRequest(m.ValidateCreateUser(c.CreateUser))))
 	deleteUser := middlewares.Handler(m.ValidateRequest(m.Authorization(c.DeleteUser)))
 	updateUser := middlewares.Handler(m.ValidateRequest(m.Authorization(c.UpdateUser)))
-	signin := middlewares.Handler(m.ValidateRequest(m.ValidateSignIn(c.SignIn)))
+	changePassword := middlewares.Handler(m.ValidateRequest(m.Authorization(limit("changePassword")(m.ValidateChangePassword(c.ChangePassword)))))
+	updateUserRole := middlewares.Handler(m.ValidateRequest(m.Authorization(admin(c.UpdateUserRole))))
+	signin := middlewares.Handler(limit("signin")(m.ValidateRequest(m.ValidateSignIn(c.SignIn))))
+	signinMFA := middlewares.Handler(limit("signinMFA")(m.ValidateRequest(m.ValidateMFASignIn(c.SignInMFA))))
+	enrollTOTP := middlewares.Handler(m.ValidateRequest(m.Authorization(c.EnrollTOTP)))
+	confirmTOTP := middlewares.Handler(m.ValidateRequest(m.Authorization(limit("confirmTOTP")(m.ValidateTOTPConfirmation(c.ConfirmTOTP)))))
+	forgotPassword := middlewares.Handler(limit("forgotPassword")(m.ValidateRequest(m.ValidateForgotPassword(c.ForgotPassword))))
+	resetPassword := middlewares.Handler(limit("resetPassword")(m.ValidateRequest(m.ValidateResetPassword(c.ResetPassword))))
+	refreshToken := middlewares.Handler(limit("refreshToken")(m.ValidateRequest(m.ValidateRefreshToken(c.RefreshToken))))
+	signout := middlewares.Handler(m.ValidateRequest(m.Authorization(c.SignOut)))
+	uploadPhoto := middlewares.Handler(m.ValidateUpload(m.Authorization(limit("uploadPhoto")(c.UploadPhoto))))
+	getPhoto := middlewares.Handler(m.ValidateRequest(m.Authorization(c.GetPhoto)))
+	updatePhoto := middlewares.Handler(m.ValidateRequest(m.Authorization(c.UpdatePhoto)))
+	deletePhoto := middlewares.Handler(m.ValidateRequest(m.Authorization(c.DeletePhoto)))
+	getPhotoOriginal := middlewares.Handler(m.OptionalAuthorization(c.GetPhotoOriginal))
+	getPhotoRendition := middlewares.Handler(m.OptionalAuthorization(c.GetPhotoRendition))
+	getJWKS := middlewares.Handler(c.GetJWKS)
+	openIDConfiguration := middlewares.Handler(c.OpenIDConfiguration)
+	authorize := middlewares.Handler(c.Authorize)
+	authorizeSignIn := middlewares.Handler(limit("authorize")(c.AuthorizeSignIn))
+	token := middlewares.Handler(limit("token")(c.Token))
+	userInfo := middlewares.Handler(m.ClientAuthorization(c.UserInfo))
+	getClients := middlewares.Handler(m.ValidateRequest(m.Authorization(admin(c.GetClients))))
+	createClient := middlewares.Handler(m.ValidateRequest(m.Authorization(admin(m.ValidateCreateClient(c.CreateClient)))))
+	deleteClient := middlewares.Handler(m.ValidateRequest(m.Authorization(admin(c.DeleteClient))))
+	getPosts := middlewares.Handler(m.ValidateRequest(m.Authorization(c.GetPosts)))
+	getPost := middlewares.Handler(m.ValidateRequest(m.Authorization(c.GetPost)))
+	createPost := middlewares.Handler(m.ValidateRequest(m.Authorization(limit("createPost")(m.ValidateCreatePost(c.CreatePost)))))
+	updatePost := middlewares.Handler(m.ValidateRequest(m.Authorization(c.UpdatePost)))
+	deletePost := middlewares.Handler(m.ValidateRequest(m.Authorization(c.DeletePost)))
+	createAlbum := middlewares.Handler(m.ValidateRequest(m.Authorization(m.ValidateCreateAlbum(c.CreateAlbum))))
+	getAlbum := middlewares.Handler(m.ValidateRequest(m.Authorization(c.GetAlbum)))
+	updateAlbum := middlewares.Handler(m.ValidateRequest(m.Authorization(c.UpdateAlbum)))
+	deleteAlbum := middlewares.Handler(m.ValidateRequest(m.Authorization(c.DeleteAlbum)))
+	addAlbumPhoto := middlewares.Handler(m.ValidateRequest(m.Authorization(c.AddAlbumPhoto)))
+	removeAlbumPhoto := middlewares.Handler(m.ValidateRequest(m.Authorization(c.RemoveAlbumPhoto)))
+	reorderAlbumPhotos := middlewares.Handler(m.ValidateRequest(m.Authorization(c.ReorderAlbumPhotos)))
+	getPostComments := middlewares.Handler(m.ValidateRequest(m.Authorization(c.GetPostComments)))
+	createPostComment := middlewares.Handler(m.ValidateRequest(m.Authorization(limit("createComment")(m.ValidateCreateComment(c.CreatePostComment)))))
+	getPhotoComments := middlewares.Handler(m.ValidateRequest(m.Authorization(c.GetPhotoComments)))
+	createPhotoComment := middlewares.Handler(m.ValidateRequest(m.Authorization(limit("createComment")(m.ValidateCreateComment(c.CreatePhotoComment)))))
+	updateComment := middlewares.Handler(m.ValidateRequest(m.Authorization(c.UpdateComment)))
+	deleteComment := middlewares.Handler(m.ValidateRequest(m.Authorization(c.DeleteComment)))
+	likePhoto := middlewares.Handler(m.ValidateRequest(m.Authorization(c.LikePhoto)))
+	unlikePhoto := middlewares.Handler(m.ValidateRequest(m.Authorization(c.UnlikePhoto)))
+	likePost := middlewares.Handler(m.ValidateRequest(m.Authorization(c.LikePost)))
+	unlikePost := middlewares.Handler(m.ValidateRequest(m.Authorization(c.UnlikePost)))
+	followUser := middlewares.Handler(m.ValidateRequest(m.Authorization(c.FollowUser)))
+	unfollowUser := middlewares.Handler(m.ValidateRequest(m.Authorization(c.UnfollowUser)))
+	getFollowers := middlewares.Handler(m.ValidateRequest(m.Authorization(c.GetFollowers)))
+	getFollowing := middlewares.Handler(m.ValidateRequest(m.Authorization(c.GetFollowing)))
+	getFeed := middlewares.Handler(m.ValidateRequest(m.Authorization(c.GetFeed)))
+	getTags := middlewares.Handler(m.ValidateRequest(m.Authorization(c.GetTags)))
+	getTag := middlewares.Handler(m.ValidateRequest(m.Authorization(c.GetTag)))
+	search := middlewares.Handler(m.ValidateRequest(m.Authorization(limit("search")(c.Search))))
 
 	router := httprouter.New()
 	router.GET("/ping", c.Ping)
+	router.GET("/.well-known/jwks.json", getJWKS)
+	router.GET("/.well-known/openid-configuration", openIDConfiguration)
+	router.GET("/authorize", authorize)
+	router.POST("/authorize", authorizeSignIn)
+	router.POST("/token", token)
+	router.GET("/userinfo", userInfo)
+	router.GET("/api/v1/clients", getClients)
+	router.POST("/api/v1/clients", createClient)
+	router.DELETE("/api/v1/clients/:id", deleteClient)
 	router.GET("/api/v1/users", getUsers)
-	router.GET("/api/v1/users/:id", getUser)
+	// httprouter does not allow /api/v1/users/verify next to /api/v1/users/:id, so the former is dispatched here
+	router.GET("/api/v1/users/:id", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if p.ByName("id") == "verify" {
+			verifyEmailPage(w, r, p)
+			return
+		}
+		getUser(w, r, p)
+	})
+	router.POST("/api/v1/users/verify", resendVerificationEmail)
+	router.POST("/api/v1/users/verify/confirm", verifyEmail)
 	router.POST("/api/v1/users", createUser)
 	router.DELETE("/api/v1/users/:id", deleteUser)
 	router.PUT("/api/v1/users/:id", updateUser)
+	router.PUT("/api/v1/users/:id/password", changePassword)
+	router.PUT("/api/v1/users/:id/role", updateUserRole)
+	router.PUT("/api/v1/users/:id/follow", followUser)
+	router.DELETE("/api/v1/users/:id/follow", unfollowUser)
+	router.GET("/api/v1/users/:id/followers", getFollowers)
+	router.GET("/api/v1/users/:id/following", getFollowing)
 	router.POST("/api/v1/users/signin", signin)
-	log.Fatal(http.ListenAndServe(":8080", router))
+	router.POST("/api/v1/users/signin/mfa", signinMFA)
+	router.POST("/api/v1/users/mfa/totp", enrollTOTP)
+	router.POST("/api/v1/users/mfa/totp/confirm", confirmTOTP)
+	router.POST("/api/v1/users/token/refresh", refreshToken)
+	router.POST("/api/v1/users/password/forgot", forgotPassword)
+	router.POST("/api/v1/users/password/reset", resetPassword)
+	router.POST("/api/v1/users/signout", signout)
+	router.POST("/api/v1/photos", uploadPhoto)
+	router.GET("/api/v1/photos/:id", getPhoto)
+	router.PUT("/api/v1/photos/:id", updatePhoto)
+	router.DELETE("/api/v1/photos/:id", deletePhoto)
+	router.GET("/api/v1/photos/:id/original", getPhotoOriginal)
+	router.GET("/api/v1/photos/:id/renditions/:size", getPhotoRendition)
+	router.GET("/api/v1/posts", getPosts)
+	router.POST("/api/v1/posts", createPost)
+	router.GET("/api/v1/posts/:id", getPost)
+	router.PUT("/api/v1/posts/:id", updatePost)
+	router.DELETE("/api/v1/posts/:id", deletePost)
+	router.POST("/api/v1/albums", createAlbum)
+	router.GET("/api/v1/albums/:id", getAlbum)
+	router.PUT("/api/v1/albums/:id", updateAlbum)
+	router.DELETE("/api/v1/albums/:id", deleteAlbum)
+	router.POST("/api/v1/albums/:id/photos", addAlbumPhoto)
+	router.PUT("/api/v1/albums/:id/photos", reorderAlbumPhotos)
+	router.DELETE("/api/v1/albums/:id/photos/:photoId", removeAlbumPhoto)
+	router.GET("/api/v1/posts/:id/comments", getPostComments)
+	router.POST("/api/v1/posts/:id/comments", createPostComment)
+	router.GET("/api/v1/photos/:id/comments", getPhotoComments)
+	router.POST("/api/v1/photos/:id/comments", createPhotoComment)
+	router.PUT("/api/v1/comments/:id", updateComment)
+	router.DELETE("/api/v1/comments/:id", deleteComment)
+	router.PUT("/api/v1/photos/:id/like", likePhoto)
+	router.DELETE("/api/v1/photos/:id/like", unlikePhoto)
+	router.PUT("/api/v1/posts/:id/like", likePost)
+	router.DELETE("/api/v1/posts/:id/like", unlikePost)
+	router.GET("/api/v1/feed", getFeed)
+	router.GET("/api/v1/tags", getTags)
+	router.GET("/api/v1/tags/:tag", getTag)
+	router.GET("/api/v1/search", search)
+	return router
+}
+
+// Run serves the API
+func (a *App) Run() {
+	log.Fatal(http.ListenAndServe(":8080", a.Router()))
+}
+
+// newMailer returns the mailer selected by the MAILER environment variable, which writes the emails to the console by
+// default
+func newMailer() mail.Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "noreply@localhost"
+	}
+
+	switch mailer := os.Getenv("MAILER"); mailer {
+	case "smtp":
+		return mail.NewSMTPMailer(os.Getenv("SMTP_ADDR"), from, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+	case "file":
+		return mail.NewFileMailer(os.Getenv("MAIL_DIR"), from)
+	case "", "console":
+		return mail.NewConsoleMailer(from)
+	default:
+		log.Fatalf("Unknown mailer %v", mailer)
+		return nil
+	}
+}
+
+// requeueInterval is how often the photos whose renditions are still pending are queued again
+const requeueInterval = time.Minute
+
+// defaultRateLimits are the rate limits of the routes, written as route=requests/duration
+const defaultRateLimits = "createUser=10/1h,signin=20/1m,signinMFA=10/1m,refreshToken=60/1m,verifyEmail=20/1m," +
+	"resendVerificationEmail=5/15m,forgotPassword=5/15m,resetPassword=10/15m,changePassword=5/15m,confirmTOTP=10/15m," +
+	"uploadPhoto=30/1m,createPost=30/1m,createComment=60/1m,search=60/1m,authorize=20/1m,token=60/1m"
+
+// rateLimits returns the default rate limits, overridden by the ones of the RATE_LIMITS environment variable
+func rateLimits() map[string]middlewares.Limit {
+	limits, e := middlewares.ParseLimits(defaultRateLimits)
+	if e != nil {
+		log.Fatal(e)
+	}
+	overrides, e := middlewares.ParseLimits(os.Getenv("RATE_LIMITS"))
+	if e != nil {
+		log.Fatal(e)
+	}
+	for route, limit := range overrides {
+		limits[route] = limit
+	}
+	return limits
+}
+
+// signingKeys returns the keys of the JWT tokens. Tokens are signed by the RS256 or Ed25519 private key of the
+// JWT_PRIVATE_KEY file, while the public keys of the JWT_PUBLIC_KEYS files, written as [kid=]path, still verify the
+// tokens of previous keys. Key ids default to the thumbprints of the keys. Without a private key, tokens are signed by
+// the HS256 secret of JWT_SECRET. When both are set, the secret only verifies the tokens it signed before the rollout
+// of the private key, until they expire and JWT_SECRET is removed.
+func signingKeys() *utils.KeySet {
+	path := os.Getenv("JWT_PRIVATE_KEY")
+	if path == "" {
+		return nil
+	}
+
+	b, e := ioutil.ReadFile(path)
+	if e != nil {
+		log.Fatal(e)
+	}
+	signing, e := utils.ParsePrivateKey(os.Getenv("JWT_KEY_ID"), b)
+	if e != nil {
+		log.Fatalf("Invalid private key %v: %v", path, e)
+	}
+
+	var verification []*utils.Key
+	for _, entry := range strings.Split(os.Getenv("JWT_PUBLIC_KEYS"), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		var kid string
+		path := entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			kid, path = entry[:i], entry[i+1:]
+		}
+		b, e := ioutil.ReadFile(path)
+		if e != nil {
+			log.Fatal(e)
+		}
+		k, e := utils.ParsePublicKey(kid, b)
+		if e != nil {
+			log.Fatalf("Invalid public key %v: %v", path, e)
+		}
+		verification = append(verification, k)
+	}
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		verification = append(verification, utils.NewHMACKey("", []byte(secret)))
+	}
+	return utils.NewKeySet(signing, verification...)
 }
 
 func main() {
 	var app App
 	u := utils.Utils{}
-	m := middlewares.Middleware{&u}
 	envPath := os.Args[1]
 
 	u.LoadDotEnv(envPath)
+	u.Keys = signingKeys()
 	mcli := u.ConnectDatabase(os.Getenv("MONGO_URI"), os.Getenv("DB_NAME"))
-	c := controllers.NewController(mcli, &u)
+	s := store.NewMongoStore(mcli.Client.Database(mcli.Database), store.NewLocalBlobStore(os.Getenv("BLOB_PATH")))
+	if e := s.EnsureIndexes(context.Background()); e != nil {
+		log.Fatal(e)
+	}
+	pool := media.NewPool(s, runtime.NumCPU(), 64)
+	go pool.RequeueEvery(requeueInterval)
+	c := controllers.NewController(s, &u, pool)
+	if window := os.Getenv("COMMENT_EDIT_WINDOW"); window != "" {
+		d, e := time.ParseDuration(window)
+		if e != nil {
+			log.Fatal(e)
+		}
+		c.CommentEditWindow = d
+	}
+	if url := os.Getenv("PUBLIC_URL"); url != "" {
+		c.PublicURL = strings.TrimRight(url, "/")
+	}
+	c.Mailer = newMailer()
+	m := middlewares.Middleware{Utils: &u, Store: s, Limits: rateLimits(), Limiter: middlewares.NewMemoryLimiter()}
 
 	app = App{Controller: c, Utils: &u, Middlewares: &m}
 	app.Run()
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/MarioSimou/authAPI/internal/controllers"
+	"github.com/MarioSimou/authAPI/internal/mail"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/middlewares"
+)
+
+// the Accept header of a browser that opens a link
+const browserAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+
+// recordingMailer is a mail.Mailer that keeps the sent messages
+type recordingMailer struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func newApp() (*App, *store.Store, *recordingMailer) {
+	u := utils.Utils{}
+	u.LoadDotEnv("../../configs/.test.env")
+	s := store.NewMemoryStore()
+	mailer := &recordingMailer{}
+	c := controllers.NewController(s, &u, nil)
+	c.Mailer = mailer
+	m := middlewares.Middleware{Utils: &u, Store: s, Limits: rateLimits(), Limiter: middlewares.NewMemoryLimiter()}
+	return &App{Controller: c, Utils: &u, Middlewares: &m}, s, mailer
+}
+
+var mailedLink = regexp.MustCompile(`https?://\S+`)
+
+func TestVerifyEmailFromBrowser(t *testing.T) {
+	app, s, mailer := newApp()
+	router := app.Router()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/users", strings.NewReader(`{"username":"mick","password":"12345678","email":"mick@gmail.com"}`))
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, r)
+	if w.Code != 201 || len(mailer.messages) != 1 {
+		t.Fatalf("Should have created the user and mailed a verification link rather than %v", w.Code)
+	}
+	link, _ := url.Parse(mailedLink.FindString(mailer.messages[0].Body))
+
+	// opening the link, as a browser or a mail scanner would, only shows the form of the token
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", link.RequestURI(), nil)
+	r.Header.Set("Accept", browserAccept)
+	router.ServeHTTP(w, r)
+	token := link.Query().Get("token")
+	if w.Code != 200 || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), `value="`+token+`"`) {
+		t.Fatalf("Should have rendered the form of the token rather than %v %v", w.Code, w.Body.String())
+	}
+	if user, _ := s.Users.FindByEmail(context.Background(), "mick@gmail.com"); user.EmailVerified {
+		t.Fatalf("Should not have verified the email by opening the link")
+	}
+
+	confirm := func() int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/api/v1/users/verify/confirm", strings.NewReader(url.Values{"token": {token}}.Encode()))
+		r.Header.Set("Accept", browserAccept)
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := confirm(); code != http.StatusOK {
+		t.Fatalf("Should have verified the email by posting the form rather than %v", code)
+	}
+	if user, _ := s.Users.FindByEmail(context.Background(), "mick@gmail.com"); !user.EmailVerified {
+		t.Errorf("Should have verified the email of the user")
+	}
+	if code := confirm(); code != http.StatusBadRequest {
+		t.Errorf("Should have rejected a used token rather than %v", code)
+	}
+}
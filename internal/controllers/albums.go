@@ -0,0 +1,335 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// findAlbum fetches the album of the :id route parameter and writes the error response when it can not be found or is
+// not visible to the user
+func (c Controller) findAlbum(w http.ResponseWriter, r *http.Request, p httprouter.Params, payload *utils.Payload) (*models.Album, bool) {
+	id := p.ByName("id")
+	if id == "" {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid target resource"}.BadRequest())
+		return nil, false
+	}
+
+	oid, _ := primitive.ObjectIDFromHex(id)
+	album, e := c.Store.Albums.FindByID(r.Context(), oid)
+	if e == store.ErrNotFound || (e == nil && !album.IsVisibleTo(*payload.Id, payload.IsAdmin())) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Album does not exists"}.NotFound())
+		return nil, false
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the album"}.InternalServerError())
+		return nil, false
+	}
+	return album, true
+}
+
+// findOwnedAlbum fetches the album of the :id route parameter, when it can be edited by the user
+func (c Controller) findOwnedAlbum(w http.ResponseWriter, r *http.Request, p httprouter.Params, payload *utils.Payload) (*models.Album, bool) {
+	album, ok := c.findAlbum(w, r, p, payload)
+	if !ok {
+		return nil, false
+	}
+	if !payload.CanAccess(album.OwnerId.Hex()) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid operation for the existing album"}.Forbidden())
+		return nil, false
+	}
+	return album, true
+}
+
+// syncPhotoVisibility updates whether photos are private, after the albums that contain them or the visibility of
+// those albums have changed. The search documents and the tag counts of the photos are updated along with them.
+func (c Controller) syncPhotoVisibility(ctx context.Context, ids []primitive.ObjectID) error {
+	for _, id := range ids {
+		albums, e := c.Store.Albums.ListByPhoto(ctx, id)
+		if e != nil {
+			return e
+		}
+		photo, e := c.Store.Photos.FindByID(ctx, id)
+		if e == store.ErrNotFound {
+			continue
+		}
+		if e != nil {
+			return e
+		}
+
+		private := models.IsPrivatePhoto(albums)
+		if photo.Private == private {
+			continue
+		}
+		counted := photo.CountedTags()
+		if photo, e = c.Store.Photos.Update(ctx, id, map[string]interface{}{"private": private}); e != nil {
+			return e
+		}
+		added, removed := models.DiffTags(counted, photo.CountedTags())
+		c.adjustTags(ctx, added, removed)
+		c.indexPhoto(ctx, photo)
+	}
+	return nil
+}
+
+// writeAlbumUpdate updates the visibility of the given photos of an album that has changed, and writes the album as
+// the response of a request
+func (c Controller) writeAlbumUpdate(w http.ResponseWriter, r *http.Request, album *models.Album, payload *utils.Payload, photoIDs []primitive.ObjectID) {
+	if e := c.syncPhotoVisibility(r.Context(), photoIDs); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the visibility of the photos"}.InternalServerError())
+		return
+	}
+	c.writeAlbum(w, r, album, payload, "Successful update")
+}
+
+// expandAlbum returns an album along with its photos, in the order of the album. The GPS coordinates of the photos are
+// only returned to their owner.
+func (c Controller) expandAlbum(ctx context.Context, album *models.Album, payload *utils.Payload) (*models.AlbumWithPhotos, error) {
+	photos, e := c.Store.Photos.FindMany(ctx, album.PhotoIds)
+	if e != nil {
+		return nil, e
+	}
+
+	byID := make(map[primitive.ObjectID]models.Photo, len(photos))
+	for _, photo := range photos {
+		byID[*photo.Id] = photo
+	}
+
+	expanded := models.AlbumWithPhotos{Album: *album, Photos: []models.Photo{}}
+	for _, id := range album.PhotoIds {
+		photo, ok := byID[id]
+		if !ok {
+			continue
+		}
+		if !payload.CanAccess(photo.OwnerId.Hex()) {
+			photo = photo.Public()
+		}
+		expanded.Photos = append(expanded.Photos, photo)
+	}
+	if e := c.setLikedPhotos(ctx, payload, expanded.Photos); e != nil {
+		return nil, e
+	}
+	return &expanded, nil
+}
+
+// writeAlbum writes an album, with its photos expanded, as the response of a request
+func (c Controller) writeAlbum(w http.ResponseWriter, r *http.Request, album *models.Album, payload *utils.Payload, message string) {
+	expanded, e := c.expandAlbum(r.Context(), album, payload)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the photos of the album"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: message, Data: expanded}.Ok())
+}
+
+// CreateAlbum is used to create an empty album owned by the authenticated user
+func (c Controller) CreateAlbum(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	var body models.Album
+	payload := other[0].(*utils.Payload)
+	json.NewDecoder(r.Body).Decode(&body)
+
+	now := time.Now()
+	id := primitive.NewObjectID()
+	album := models.Album{
+		Id:          &id,
+		OwnerId:     *payload.Id,
+		Title:       body.Title,
+		Description: body.Description,
+		Visibility:  body.Visibility,
+		PhotoIds:    []primitive.ObjectID{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if album.Visibility == "" {
+		album.Visibility = models.AlbumPrivate
+	}
+
+	if _, e := c.Store.Albums.Insert(r.Context(), album); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to store the album"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Location", strings.Join([]string{r.URL.Path, id.Hex()}, "/"))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful creation", Data: album}.Created())
+}
+
+// GetAlbum is used to return an album with its photos expanded. Private albums are only returned to their owner and
+// admins.
+func (c Controller) GetAlbum(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	payload := other[0].(*utils.Payload)
+	album, ok := c.findAlbum(w, r, p, payload)
+	if !ok {
+		return
+	}
+	c.writeAlbum(w, r, album, payload, "Successful fetch")
+}
+
+// UpdateAlbum is used by the owner of an album or an admin to edit its details. Only the fields of models.AlbumUpdate
+// can be edited.
+func (c Controller) UpdateAlbum(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	var body models.AlbumUpdate
+	payload := other[0].(*utils.Payload)
+	album, ok := c.findOwnedAlbum(w, r, p, payload)
+	if !ok {
+		return
+	}
+
+	json.NewDecoder(r.Body).Decode(&body)
+	fields := body.Fields()
+	if len(fields) == 0 || !body.Validate() {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Request Body"}.BadRequest())
+		return
+	}
+	if body.CoverPhotoId != nil && !album.HasPhoto(*body.CoverPhotoId) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The cover photo must be part of the album"}.BadRequest())
+		return
+	}
+	fields["updatedAt"] = time.Now()
+
+	album, e := c.Store.Albums.Update(r.Context(), *album.Id, fields)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the album"}.InternalServerError())
+		return
+	}
+	// the photos of the album become public or private along with it
+	var photoIDs []primitive.ObjectID
+	if body.Visibility != nil {
+		photoIDs = album.PhotoIds
+	}
+	c.writeAlbumUpdate(w, r, album, payload, photoIDs)
+}
+
+// DeleteAlbum is used by the owner of an album or an admin to delete it. The photos of the album are kept, and are no
+// longer private unless they are part of another private album.
+func (c Controller) DeleteAlbum(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	payload := other[0].(*utils.Payload)
+	album, ok := c.findOwnedAlbum(w, r, p, payload)
+	if !ok {
+		return
+	}
+
+	e := c.Store.Albums.Delete(r.Context(), *album.Id)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Album does not exists"}.NotFound())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to delete the album"}.InternalServerError())
+		return
+	}
+	if e := c.syncPhotoVisibility(r.Context(), album.PhotoIds); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the visibility of the photos"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(204)
+}
+
+// AddAlbumPhoto is used to append a photo to the end of an album. Only the photos of the owner of the album can be
+// added.
+func (c Controller) AddAlbumPhoto(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	var body models.AlbumPhoto
+	payload := other[0].(*utils.Payload)
+	album, ok := c.findOwnedAlbum(w, r, p, payload)
+	if !ok {
+		return
+	}
+
+	json.NewDecoder(r.Body).Decode(&body)
+	if body.PhotoId == nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Request Body"}.BadRequest())
+		return
+	}
+	photo, e := c.Store.Photos.FindByID(r.Context(), *body.PhotoId)
+	if e == store.ErrNotFound || (e == nil && photo.OwnerId != album.OwnerId) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Photo"}.BadRequest())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the photo"}.InternalServerError())
+		return
+	}
+	if !album.HasPhoto(*photo.Id) && len(album.PhotoIds) >= models.MaxAlbumPhotos {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The album has reached the maximum number of photos"}.BadRequest())
+		return
+	}
+
+	album, e = c.Store.Albums.AddPhoto(r.Context(), *album.Id, *photo.Id)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the album"}.InternalServerError())
+		return
+	}
+	c.writeAlbumUpdate(w, r, album, payload, []primitive.ObjectID{*photo.Id})
+}
+
+// RemoveAlbumPhoto is used to remove a photo from an album. The photo itself is kept.
+func (c Controller) RemoveAlbumPhoto(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	payload := other[0].(*utils.Payload)
+	album, ok := c.findOwnedAlbum(w, r, p, payload)
+	if !ok {
+		return
+	}
+
+	photoID, _ := primitive.ObjectIDFromHex(p.ByName("photoId"))
+	if !album.HasPhoto(photoID) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Photo is not part of the album"}.NotFound())
+		return
+	}
+
+	album, e := c.Store.Albums.RemovePhoto(r.Context(), *album.Id, photoID)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the album"}.InternalServerError())
+		return
+	}
+	c.writeAlbumUpdate(w, r, album, payload, []primitive.ObjectID{photoID})
+}
+
+// ReorderAlbumPhotos is used to change the order of the photos of an album. The request body must list every photo of
+// the album exactly once. The order is only changed when the photos of the album are still in the order that the client
+// sent as previousPhotoIds, or that was read when it is omitted, so concurrent changes are reported as a conflict rather
+// than lost.
+func (c Controller) ReorderAlbumPhotos(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	var body models.AlbumOrder
+	payload := other[0].(*utils.Payload)
+	album, ok := c.findOwnedAlbum(w, r, p, payload)
+	if !ok {
+		return
+	}
+
+	json.NewDecoder(r.Body).Decode(&body)
+	if !album.IsReorderOf(body.PhotoIds) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The photos must match the photos of the album"}.BadRequest())
+		return
+	}
+
+	previous := album.PhotoIds
+	if body.PreviousPhotoIds != nil {
+		previous = body.PreviousPhotoIds
+	}
+	album, e := c.Store.Albums.ReorderPhotos(r.Context(), *album.Id, previous, body.PhotoIds, time.Now())
+	if e == store.ErrConflict {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The photos of the album have changed, fetch the album and try again"}.Conflict())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the album"}.InternalServerError())
+		return
+	}
+	c.writeAlbum(w, r, album, payload, "Successful update")
+}
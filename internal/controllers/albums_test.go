@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/utils"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func insertPhoto(owner *utils.Payload, t *testing.T) string {
+	lat, lng := 37.97, 23.72
+	id, e := c.Store.Photos.Insert(context.Background(), models.Photo{OwnerId: *owner.Id, Exif: &models.Exif{Latitude: &lat, Longitude: &lng}})
+	if e != nil {
+		t.Fatalf("Unable to insert a mock photo: %v", e)
+	}
+	return id.Hex()
+}
+
+func createAlbum(body string, payload *utils.Payload, t *testing.T) string {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/albums", strings.NewReader(body))
+	c.CreateAlbum(w, r, nil, payload)
+
+	res := w.Result()
+	checkStatusCode(res, 201, t)
+	return convertResponseToJson(res).Data.(map[string]interface{})["id"].(string)
+}
+
+func addAlbumPhoto(album string, photo string, payload *utils.Payload) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/albums/"+album+"/photos", strings.NewReader(`{"photoId":"`+photo+`"}`))
+	c.AddAlbumPhoto(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: album}}, payload)
+	return w
+}
+
+func albumPhotoIds(w *httptest.ResponseRecorder) []string {
+	ids := []string{}
+	album := convertResponseToJson(w.Result()).Data.(map[string]interface{})
+	for _, photo := range album["photos"].([]interface{}) {
+		ids = append(ids, photo.(map[string]interface{})["id"].(string))
+	}
+	return ids
+}
+
+func TestAlbumPhotos(t *testing.T) {
+	album := createAlbum(`{"title":"Holidays","visibility":"public"}`, payloads[0], t)
+	first, second := insertPhoto(payloads[0], t), insertPhoto(payloads[0], t)
+
+	checkStatusCode(addAlbumPhoto(album, first, payloads[0]).Result(), 200, t)
+	w := addAlbumPhoto(album, second, payloads[0])
+	if ids := albumPhotoIds(w); strings.Join(ids, ",") != first+","+second {
+		t.Errorf("Should return the photos in the order they were added rather than %v", ids)
+	}
+
+	// adding the same photo again does not duplicate it
+	w = addAlbumPhoto(album, first, payloads[0])
+	if ids := albumPhotoIds(w); len(ids) != 2 {
+		t.Errorf("Should not have added a photo twice rather than returning %v", ids)
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/api/v1/albums/"+album+"/photos", strings.NewReader(`{"photoIds":["`+second+`","`+first+`"]}`))
+	c.ReorderAlbumPhotos(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: album}}, payloads[0])
+	if ids := albumPhotoIds(w); strings.Join(ids, ",") != second+","+first {
+		t.Errorf("Should have reordered the photos rather than returning %v", ids)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/albums/"+album+"/photos", strings.NewReader(`{"photoIds":["`+second+`","`+second+`"]}`))
+	c.ReorderAlbumPhotos(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: album}}, payloads[0])
+	checkStatusCode(w.Result(), 400, t)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/v1/albums/"+album, strings.NewReader(`{"coverPhotoId":"`+first+`"}`))
+	c.UpdateAlbum(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: album}}, payloads[0])
+	checkStatusCode(w.Result(), 200, t)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("DELETE", "/api/v1/albums/"+album+"/photos/"+first, nil)
+	c.RemoveAlbumPhoto(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: album}, httprouter.Param{Key: "photoId", Value: first}}, payloads[0])
+	data := convertResponseToJson(w.Result()).Data.(map[string]interface{})
+	checkJSON(data, []Check{Check{Key: "coverPhotoId", Expected: nil}}, t)
+	if ids := data["photoIds"].([]interface{}); len(ids) != 1 || ids[0] != second {
+		t.Errorf("Should have removed the photo rather than returning %v", ids)
+	}
+}
+
+func TestAddForeignPhotoToAlbum(t *testing.T) {
+	album := createAlbum(`{"title":"Mine"}`, payloads[0], t)
+	checkStatusCode(addAlbumPhoto(album, insertPhoto(payloads[1], t), payloads[0]).Result(), 400, t)
+	checkStatusCode(addAlbumPhoto(album, insertPhoto(payloads[0], t), payloads[1]).Result(), 404, t)
+}
+
+func TestGetAlbumVisibility(t *testing.T) {
+	private := createAlbum(`{"title":"Private"}`, payloads[0], t)
+	public := createAlbum(`{"title":"Public","visibility":"public"}`, payloads[0], t)
+	addAlbumPhoto(public, insertPhoto(payloads[0], t), payloads[0])
+
+	get := func(id string, payload *utils.Payload) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c.GetAlbum(w, httptest.NewRequest("GET", "/api/v1/albums/"+id, nil), httprouter.Params{httprouter.Param{Key: "id", Value: id}}, payload)
+		return w
+	}
+
+	checkStatusCode(get(private, payloads[0]).Result(), 200, t)
+	checkStatusCode(get(private, payloads[1]).Result(), 404, t)
+	checkStatusCode(get(private, generateAdminPayload()).Result(), 200, t)
+
+	w := get(public, payloads[1])
+	checkStatusCode(w.Result(), 200, t)
+	album := convertResponseToJson(w.Result()).Data.(map[string]interface{})
+	exif := album["photos"].([]interface{})[0].(map[string]interface{})["exif"].(map[string]interface{})
+	if exif["latitude"] != nil {
+		t.Errorf("Should not have returned the location of the photos of another user")
+	}
+}
+
+func TestUpdateAlbumPermissions(t *testing.T) {
+	album := createAlbum(`{"title":"Mine","visibility":"public"}`, payloads[0], t)
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: album}}
+
+	w := httptest.NewRecorder()
+	c.UpdateAlbum(w, httptest.NewRequest("PUT", "/api/v1/albums/"+album, strings.NewReader(`{"title":"Theirs"}`)), params, payloads[1])
+	checkStatusCode(w.Result(), 403, t)
+
+	w = httptest.NewRecorder()
+	c.UpdateAlbum(w, httptest.NewRequest("PUT", "/api/v1/albums/"+album, strings.NewReader(`{"coverPhotoId":"`+primitive.NewObjectID().Hex()+`"}`)), params, payloads[0])
+	checkStatusCode(w.Result(), 400, t)
+
+	w = httptest.NewRecorder()
+	c.DeleteAlbum(w, httptest.NewRequest("DELETE", "/api/v1/albums/"+album, nil), params, generateAdminPayload())
+	checkStatusCode(w.Result(), 204, t)
+}
+
+func TestPrivateAlbumHidesPhotos(t *testing.T) {
+	id := insertPhotoWithLocation(t)
+	oid, _ := primitive.ObjectIDFromHex(id)
+	photo, _ := c.Store.Photos.Update(context.Background(), oid, map[string]interface{}{"caption": "Quokka selfie"})
+	c.indexPhoto(context.Background(), photo)
+	album := createAlbum(`{"title":"Family"}`, payloads[0], t)
+	checkStatusCode(addAlbumPhoto(album, id, payloads[0]).Result(), 200, t)
+
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: id}}
+	get := func(payload *utils.Payload) int {
+		w := httptest.NewRecorder()
+		c.GetPhoto(w, httptest.NewRequest("GET", "/api/v1/photos/"+id, nil), params, payload)
+		return w.Code
+	}
+	original := func(other ...interface{}) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c.GetPhotoOriginal(w, httptest.NewRequest("GET", "/api/v1/photos/"+id+"/original", nil), params, other...)
+		return w
+	}
+
+	if get(payloads[1]) != 404 || get(payloads[0]) != 200 || get(generateAdminPayload()) != 200 {
+		t.Errorf("Should have only returned the photo of a private album to its owner and admins")
+	}
+	checkStatusCode(original().Result(), 404, t)
+	checkStatusCode(original(payloads[1]).Result(), 404, t)
+	w := original(payloads[0])
+	checkStatusCode(w.Result(), 200, t)
+	checkHeader(w, "Cache-Control", "private, no-cache", t)
+	checkStatusCode(createPhotoComment(id, `{"body":"Cute!"}`, payloads[1]).Result(), 404, t)
+	if captions := searchTitles("type=photos&q=quokka", "caption", payloads[1], t); len(captions) != 0 {
+		t.Errorf("Should not have found the photo of a private album rather than %v", captions)
+	}
+
+	// the photo is public once the album is
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/api/v1/albums/"+album, strings.NewReader(`{"visibility":"public"}`))
+	c.UpdateAlbum(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: album}}, payloads[0])
+	checkStatusCode(w.Result(), 200, t)
+
+	if get(payloads[1]) != 200 {
+		t.Errorf("Should have returned the photo of a public album")
+	}
+	checkStatusCode(original().Result(), 200, t)
+	if captions := searchTitles("type=photos&q=quokka", "caption", payloads[1], t); len(captions) != 1 {
+		t.Errorf("Should have found the photo of a public album rather than %v", captions)
+	}
+}
+
+func TestReorderAlbumPhotosConflict(t *testing.T) {
+	album := createAlbum(`{"title":"Road trip"}`, payloads[0], t)
+	first, second := insertPhoto(payloads[0], t), insertPhoto(payloads[0], t)
+	addAlbumPhoto(album, first, payloads[0])
+	addAlbumPhoto(album, second, payloads[0])
+
+	reorder := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", "/api/v1/albums/"+album+"/photos", strings.NewReader(body))
+		c.ReorderAlbumPhotos(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: album}}, payloads[0])
+		return w
+	}
+
+	// the photos were reordered by another client after they were read
+	checkStatusCode(reorder(`{"photoIds":["`+second+`","`+first+`"],"previousPhotoIds":["`+first+`","`+second+`"]}`).Result(), 200, t)
+	checkStatusCode(reorder(`{"photoIds":["`+second+`","`+first+`"],"previousPhotoIds":["`+first+`","`+second+`"]}`).Result(), 409, t)
+	checkStatusCode(reorder(`{"photoIds":["`+first+`","`+second+`"],"previousPhotoIds":["`+second+`","`+first+`"]}`).Result(), 200, t)
+}
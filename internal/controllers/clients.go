@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetClients is used by an admin to list the OpenID Connect clients
+func (c Controller) GetClients(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	clients, e := c.Store.Clients.List(r.Context())
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the clients"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful fetch", Data: clients}.Ok())
+}
+
+// CreateClient is used by an admin to register an OpenID Connect client. The secret of a confidential client is only
+// returned by this response, since its hash is stored instead.
+func (c Controller) CreateClient(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	var body models.ClientRegistration
+	json.NewDecoder(r.Body).Decode(&body)
+
+	client := models.Client{ClientName: strings.TrimSpace(body.ClientName), RedirectURIs: body.RedirectURIs, CreatedAt: time.Now()}
+	var secret string
+	if !body.Public {
+		var ok bool
+		if secret, client.SecretHash, ok = c.Utils.GenerateRefreshToken(); !ok {
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to generate a client secret"}.InternalServerError())
+			return
+		}
+	}
+
+	oid, e := c.Store.Clients.Insert(r.Context(), client)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to store the client"}.InternalServerError())
+		return
+	}
+	client.Id = &oid
+
+	data := map[string]interface{}{"client": client, "clientId": oid.Hex()}
+	if secret != "" {
+		data["clientSecret"] = secret
+	}
+	w.Header().Set("Location", strings.Join([]string{r.URL.Path, oid.Hex()}, "/"))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful creation", Data: data}.Created())
+}
+
+// DeleteClient is used by an admin to remove an OpenID Connect client along with the consents of the users to it. The
+// refresh tokens of the client can no longer be exchanged, since the client can not authenticate anymore.
+func (c Controller) DeleteClient(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	oid, _ := primitive.ObjectIDFromHex(p.ByName("id"))
+	e := c.Store.Clients.Delete(r.Context(), oid)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Client does not exists"}.NotFound())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to delete the client"}.InternalServerError())
+		return
+	}
+	if e := c.Store.Consents.DeleteByClient(r.Context(), oid); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to delete the consents to the client"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(204)
+}
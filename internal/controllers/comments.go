@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// defaultCommentEditWindow is the time that the author of a comment can edit it, unless configured otherwise
+const defaultCommentEditWindow = 15 * time.Minute
+
+// findTarget returns the owner of the post or photo that is commented or liked. Posts and photos that are not visible to
+// the user are reported as missing.
+func (c Controller) findTarget(ctx context.Context, targetType string, id primitive.ObjectID, payload *utils.Payload) (primitive.ObjectID, error) {
+	switch targetType {
+	case models.TargetPost:
+		post, e := c.Store.Posts.FindByID(ctx, id)
+		if e != nil {
+			return primitive.NilObjectID, e
+		}
+		if !post.IsVisibleTo(*payload.Id, payload.IsAdmin()) {
+			return primitive.NilObjectID, store.ErrNotFound
+		}
+		return post.AuthorId, nil
+	case models.TargetPhoto:
+		photo, e := c.Store.Photos.FindByID(ctx, id)
+		if e != nil {
+			return primitive.NilObjectID, e
+		}
+		if !photo.IsVisibleTo(*payload.Id, payload.IsAdmin()) {
+			return primitive.NilObjectID, store.ErrNotFound
+		}
+		return photo.OwnerId, nil
+	}
+	return primitive.NilObjectID, store.ErrNotFound
+}
+
+// writeTargetError writes the error response of a post or photo that could not be fetched
+func writeTargetError(w http.ResponseWriter, targetType string, e error) {
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: strings.Title(targetType) + " does not exists"}.NotFound())
+		return
+	}
+	httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the " + targetType}.InternalServerError())
+}
+
+// getComments returns the comments of a post or a photo as threads of replies. Hidden comments are only returned to the
+// owner of the content, their author and admins.
+func (c Controller) getComments(w http.ResponseWriter, r *http.Request, p httprouter.Params, payload *utils.Payload, targetType string) {
+	oid, _ := primitive.ObjectIDFromHex(p.ByName("id"))
+	ownerID, e := c.findTarget(r.Context(), targetType, oid, payload)
+	if e != nil {
+		writeTargetError(w, targetType, e)
+		return
+	}
+
+	comments, e := c.Store.Comments.ListByTarget(r.Context(), targetType, oid)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the comments"}.InternalServerError())
+		return
+	}
+
+	moderator := payload.CanAccess(ownerID.Hex())
+	visible := func(comment models.Comment) bool {
+		return !comment.Hidden || moderator || comment.AuthorId == *payload.Id
+	}
+
+	threads := []*models.CommentThread{}
+	byID := map[primitive.ObjectID]*models.CommentThread{}
+	for _, comment := range comments {
+		if comment.ParentId == nil && visible(comment) {
+			thread := &models.CommentThread{Comment: comment, Replies: []models.Comment{}}
+			threads = append(threads, thread)
+			byID[*comment.Id] = thread
+		}
+	}
+	for _, comment := range comments {
+		if comment.ParentId == nil || !visible(comment) {
+			continue
+		}
+		if thread, ok := byID[*comment.ParentId]; ok {
+			thread.Replies = append(thread.Replies, comment)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful fetch", Data: threads}.Ok())
+}
+
+// createComment stores a comment of the authenticated user on a post or a photo. Replies can only be made to comments
+// that are not replies themselves.
+func (c Controller) createComment(w http.ResponseWriter, r *http.Request, p httprouter.Params, payload *utils.Payload, targetType string) {
+	var body models.Comment
+	oid, _ := primitive.ObjectIDFromHex(p.ByName("id"))
+	if _, e := c.findTarget(r.Context(), targetType, oid, payload); e != nil {
+		writeTargetError(w, targetType, e)
+		return
+	}
+
+	json.NewDecoder(r.Body).Decode(&body)
+	if body.ParentId != nil {
+		parent, e := c.Store.Comments.FindByID(r.Context(), *body.ParentId)
+		if e == store.ErrNotFound || (e == nil && (parent.TargetType != targetType || parent.TargetId != oid || parent.ParentId != nil)) {
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Parent Comment"}.BadRequest())
+			return
+		}
+		if e != nil {
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the parent comment"}.InternalServerError())
+			return
+		}
+	}
+
+	now := time.Now()
+	id := primitive.NewObjectID()
+	comment := models.Comment{
+		Id:         &id,
+		TargetType: targetType,
+		TargetId:   oid,
+		ParentId:   body.ParentId,
+		AuthorId:   *payload.Id,
+		Body:       strings.TrimSpace(body.Body),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if _, e := c.Store.Comments.Insert(r.Context(), comment); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to store the comment"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/comments/"+id.Hex())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful creation", Data: comment}.Created())
+}
+
+// GetPostComments is used to return the comments of a post
+func (c Controller) GetPostComments(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	c.getComments(w, r, p, other[0].(*utils.Payload), models.TargetPost)
+}
+
+// GetPhotoComments is used to return the comments of a photo
+func (c Controller) GetPhotoComments(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	c.getComments(w, r, p, other[0].(*utils.Payload), models.TargetPhoto)
+}
+
+// CreatePostComment is used to comment on a post
+func (c Controller) CreatePostComment(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	c.createComment(w, r, p, other[0].(*utils.Payload), models.TargetPost)
+}
+
+// CreatePhotoComment is used to comment on a photo
+func (c Controller) CreatePhotoComment(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	c.createComment(w, r, p, other[0].(*utils.Payload), models.TargetPhoto)
+}
+
+// findComment fetches the comment of the :id route parameter along with the owner of the commented content, and writes
+// the error response when they can not be found
+func (c Controller) findComment(w http.ResponseWriter, r *http.Request, p httprouter.Params, payload *utils.Payload) (*models.Comment, primitive.ObjectID, bool) {
+	id := p.ByName("id")
+	if id == "" {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid target resource"}.BadRequest())
+		return nil, primitive.NilObjectID, false
+	}
+
+	oid, _ := primitive.ObjectIDFromHex(id)
+	comment, e := c.Store.Comments.FindByID(r.Context(), oid)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Comment does not exists"}.NotFound())
+		return nil, primitive.NilObjectID, false
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the comment"}.InternalServerError())
+		return nil, primitive.NilObjectID, false
+	}
+
+	ownerID, e := c.findTarget(r.Context(), comment.TargetType, comment.TargetId, payload)
+	if e != nil {
+		writeTargetError(w, comment.TargetType, e)
+		return nil, primitive.NilObjectID, false
+	}
+	return comment, ownerID, true
+}
+
+// UpdateComment is used to edit a comment. Its author can edit the body within the configured window, while the owner
+// of the commented content and admins can hide it.
+func (c Controller) UpdateComment(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	var body models.CommentUpdate
+	payload := other[0].(*utils.Payload)
+	comment, ownerID, ok := c.findComment(w, r, p, payload)
+	if !ok {
+		return
+	}
+
+	json.NewDecoder(r.Body).Decode(&body)
+	if !body.Validate() {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Request Body"}.BadRequest())
+		return
+	}
+	if body.Hidden != nil && !payload.CanAccess(ownerID.Hex()) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Only the owner of the content can hide a comment"}.Forbidden())
+		return
+	}
+	if body.Body != nil {
+		if comment.AuthorId != *payload.Id {
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid operation for the existing comment"}.Forbidden())
+			return
+		}
+		if !comment.IsEditable(c.CommentEditWindow, time.Now()) {
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "The comment can no longer be edited"}.Forbidden())
+			return
+		}
+	}
+
+	fields := body.Fields()
+	fields["updatedAt"] = time.Now()
+	comment, e := c.Store.Comments.Update(r.Context(), *comment.Id, fields)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the comment"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful update", Data: comment}.Ok())
+}
+
+// DeleteComment is used by the author of a comment, the owner of the commented content or an admin to delete a comment
+// along with its replies
+func (c Controller) DeleteComment(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	payload := other[0].(*utils.Payload)
+	comment, ownerID, ok := c.findComment(w, r, p, payload)
+	if !ok {
+		return
+	}
+	if comment.AuthorId != *payload.Id && !payload.CanAccess(ownerID.Hex()) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid operation for the existing comment"}.Forbidden())
+		return
+	}
+
+	e := c.Store.Comments.Delete(r.Context(), *comment.Id)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Comment does not exists"}.NotFound())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to delete the comment"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(204)
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/utils"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func createPhotoComment(photo string, body string, payload *utils.Payload) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/photos/"+photo+"/comments", strings.NewReader(body))
+	c.CreatePhotoComment(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: photo}}, payload)
+	return w
+}
+
+func commentID(w *httptest.ResponseRecorder) string {
+	return convertResponseToJson(w.Result()).Data.(map[string]interface{})["id"].(string)
+}
+
+func getPhotoComments(photo string, payload *utils.Payload) []interface{} {
+	w := httptest.NewRecorder()
+	c.GetPhotoComments(w, httptest.NewRequest("GET", "/api/v1/photos/"+photo+"/comments", nil), httprouter.Params{httprouter.Param{Key: "id", Value: photo}}, payload)
+	return convertResponseToJson(w.Result()).Data.([]interface{})
+}
+
+func updateComment(id string, body string, payload *utils.Payload) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/api/v1/comments/"+id, strings.NewReader(body))
+	c.UpdateComment(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: id}}, payload)
+	return w
+}
+
+func deleteComment(id string, payload *utils.Payload) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c.DeleteComment(w, httptest.NewRequest("DELETE", "/api/v1/comments/"+id, nil), httprouter.Params{httprouter.Param{Key: "id", Value: id}}, payload)
+	return w
+}
+
+func TestCommentThreads(t *testing.T) {
+	photo := insertPhoto(payloads[0], t)
+
+	w := createPhotoComment(photo, `{"body":"Nice shot"}`, payloads[1])
+	checkStatusCode(w.Result(), 201, t)
+	parent := commentID(w)
+
+	w = createPhotoComment(photo, `{"body":"Thanks","parentId":"`+parent+`"}`, payloads[0])
+	checkStatusCode(w.Result(), 201, t)
+	reply := commentID(w)
+
+	// replies can not be replied
+	checkStatusCode(createPhotoComment(photo, `{"body":"Nested","parentId":"`+reply+`"}`, payloads[1]).Result(), 400, t)
+
+	threads := getPhotoComments(photo, payloads[1])
+	if len(threads) != 1 {
+		t.Fatalf("Should return a single thread rather than %v", threads)
+	}
+	thread := threads[0].(map[string]interface{})
+	checkJSON(thread, []Check{Check{Key: "id", Expected: parent}, Check{Key: "body", Expected: "Nice shot"}}, t)
+	if replies := thread["replies"].([]interface{}); len(replies) != 1 || replies[0].(map[string]interface{})["id"] != reply {
+		t.Errorf("Should return the reply within the thread rather than %v", replies)
+	}
+
+	checkStatusCode(deleteComment(parent, payloads[1]).Result(), 204, t)
+	if threads := getPhotoComments(photo, payloads[0]); len(threads) != 0 {
+		t.Errorf("Should have deleted the replies of a comment rather than returning %v", threads)
+	}
+}
+
+func TestHideComment(t *testing.T) {
+	photo := insertPhoto(payloads[0], t)
+	comment := commentID(createPhotoComment(photo, `{"body":"Spam"}`, payloads[1]))
+
+	// only the owner of the photo can hide a comment on it
+	checkStatusCode(updateComment(comment, `{"hidden":true}`, payloads[1]).Result(), 403, t)
+	checkStatusCode(updateComment(comment, `{"hidden":true}`, payloads[0]).Result(), 200, t)
+
+	georgeID, _ := primitive.ObjectIDFromHex("5db5b5b06507b38887bedc01")
+	other := generateUserPayload(models.User{Id: &georgeID, Email: "george@gmail.com", Role: models.RoleBasic})
+	if threads := getPhotoComments(photo, other); len(threads) != 0 {
+		t.Errorf("Should not return a hidden comment to other users rather than %v", threads)
+	}
+	if threads := getPhotoComments(photo, payloads[0]); len(threads) != 1 {
+		t.Errorf("Should return a hidden comment to the owner of the photo rather than %v", threads)
+	}
+
+	// the owner of the content can delete any comment on it
+	checkStatusCode(deleteComment(comment, other).Result(), 403, t)
+	checkStatusCode(deleteComment(comment, payloads[0]).Result(), 204, t)
+}
+
+func TestEditCommentWindow(t *testing.T) {
+	photo := insertPhoto(payloads[0], t)
+	comment := commentID(createPhotoComment(photo, `{"body":"Typo"}`, payloads[1]))
+
+	checkStatusCode(updateComment(comment, `{"body":"Edited"}`, payloads[0]).Result(), 403, t)
+	checkStatusCode(updateComment(comment, `{"body":" "}`, payloads[1]).Result(), 400, t)
+	w := updateComment(comment, `{"body":"Fixed"}`, payloads[1])
+	checkStatusCode(w.Result(), 200, t)
+	checkJSON(convertResponseToJson(w.Result()).Data.(map[string]interface{}), []Check{Check{Key: "body", Expected: "Fixed"}}, t)
+
+	oid, _ := primitive.ObjectIDFromHex(comment)
+	c.Store.Comments.Update(context.Background(), oid, map[string]interface{}{"createdAt": time.Now().Add(-c.CommentEditWindow - time.Minute)})
+	checkStatusCode(updateComment(comment, `{"body":"Too late"}`, payloads[1]).Result(), 403, t)
+}
+
+func TestCommentOnDraft(t *testing.T) {
+	post := createPost(`{"title":"Commented draft"}`, payloads[0], t)["id"].(string)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/posts/"+post+"/comments", strings.NewReader(`{"body":"Hi"}`))
+	c.CreatePostComment(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: post}}, payloads[1])
+	checkStatusCode(w.Result(), 404, t)
+}
@@ -2,34 +2,57 @@
 package controllers
 
 import (
-	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/MarioSimou/authAPI/internal/mail"
+	"github.com/MarioSimou/authAPI/internal/media"
 	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
 	"github.com/MarioSimou/authAPI/internal/utils"
 	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
 
 	"github.com/julienschmidt/httprouter"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Controller custom type
 type Controller struct {
-	Mongo *mongo.Database
-	Utils *utils.Utils
+	Store      *store.Store
+	Utils      *utils.Utils
+	Renditions *media.Pool
+	// CommentEditWindow is the time that the author of a comment can edit it
+	CommentEditWindow time.Duration
+	// Mailer delivers the emails sent to the users
+	Mailer mail.Mailer
+	// PublicURL is the URL that the API is reached at, which is used by the links of the emails
+	PublicURL string
 }
 
-// NewController is a function used return an instance of Controller type
-func NewController(mcli *utils.MongoClient, utils *utils.Utils) *Controller {
-	return &Controller{mcli.Client.Database(mcli.Database), utils}
+// defaultPublicURL is the URL of a local instance of the API
+const defaultPublicURL = "http://localhost:8080"
+
+// defaultMailFrom is the sender of the emails when none is configured
+const defaultMailFrom = "noreply@localhost"
+
+// NewController is a function used return an instance of Controller type. The renditions of uploaded photos are
+// generated by the given pool, while emails are written to the console until another mailer is set.
+func NewController(s *store.Store, utils *utils.Utils, renditions *media.Pool) *Controller {
+	return &Controller{
+		Store:             s,
+		Utils:             utils,
+		Renditions:        renditions,
+		CommentEditWindow: defaultCommentEditWindow,
+		Mailer:            mail.NewConsoleMailer(defaultMailFrom),
+		PublicURL:         defaultPublicURL,
+	}
 }
 
 // Ping checks the connection of the API
@@ -38,40 +61,151 @@ func (c Controller) Ping(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 	fmt.Fprintln(w, "alive")
 }
 
-// GetUsers returns the whole collection of users within the database
-func (c Controller) GetUsers(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
-	var users []models.SecureUser
+// maxUserOffset bounds the number of users that can be skipped by an offset, since skipped users are still scanned
+const maxUserOffset = 10000
+
+// userSorts maps the values of the sort query parameter of GetUsers to the field and the direction of the sort
+var userSorts = map[string]struct {
+	field      string
+	descending bool
+}{
+	"id":        {store.UserSortId, false},
+	"-id":       {store.UserSortId, true},
+	"username":  {store.UserSortUsername, false},
+	"-username": {store.UserSortUsername, true},
+}
+
+// encodeUserCursor returns the opaque representation of a position within a list of users
+func encodeUserCursor(user models.User) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(user.Id.Hex() + "." + user.Username))
+}
 
-	cur, e := c.Mongo.Collection("users").Find(context.TODO(), bson.M{})
+// decodeUserCursor parses a cursor that was returned by encodeUserCursor
+func decodeUserCursor(s string) (*store.UserCursor, bool) {
+	b, e := base64.RawURLEncoding.DecodeString(s)
 	if e != nil {
-		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to parse the users"}.InternalServerError())
-		return
+		return nil, false
+	}
+	parts := strings.SplitN(string(b), ".", 2)
+	if len(parts) != 2 {
+		return nil, false
 	}
+	oid, e := primitive.ObjectIDFromHex(parts[0])
+	if e != nil {
+		return nil, false
+	}
+	return &store.UserCursor{Id: oid, Username: parts[1]}, true
+}
 
-	for cur.Next(context.TODO()) {
-		var user models.SecureUser
+// parseUserPage parses the query parameters of GetUsers. An error response is written when they are invalid.
+func parseUserPage(w http.ResponseWriter, query url.Values) (store.UserPage, bool) {
+	var page store.UserPage
+	var ok bool
 
-		e := cur.Decode(&user)
-		if e != nil {
-			log.Fatal(e)
+	if page.Limit, ok = parseLimit(query.Get("limit")); !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid limit"}.BadRequest())
+		return page, false
+	}
+
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = "id"
+	}
+	s, ok := userSorts[sort]
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid sort"}.BadRequest())
+		return page, false
+	}
+	page.SortBy, page.Descending = s.field, s.descending
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		if page.After, ok = decodeUserCursor(cursor); !ok {
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid cursor"}.BadRequest())
+			return page, false
+		}
+	}
+	if offset := query.Get("offset"); offset != "" {
+		n, e := strconv.ParseInt(offset, 10, 64)
+		if e != nil || n < 0 || n > maxUserOffset || page.After != nil {
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid offset"}.BadRequest())
+			return page, false
 		}
-		users = append(users, user)
+		page.Offset = n
+	}
+
+	page.Filter = store.UserFilter{Role: query.Get("role"), UsernamePrefix: query.Get("username")}
+	if page.Filter.Role != "" && page.Filter.Role != models.RoleAdmin && page.Filter.Role != models.RoleBasic {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Role"}.BadRequest())
+		return page, false
+	}
+	return page, true
+}
+
+// pageLinks returns the value of the Link header of a page of users, which points to the first, the previous and the
+// next pages while keeping the rest of the query parameters
+func pageLinks(u *url.URL, page store.UserPage, pagination httpcodes.Pagination) string {
+	link := func(rel string, set func(q url.Values)) string {
+		q := u.Query()
+		q.Del("cursor")
+		q.Del("offset")
+		set(q)
+		return fmt.Sprintf("<%v?%v>; rel=\"%v\"", u.Path, q.Encode(), rel)
+	}
+
+	links := []string{link("first", func(q url.Values) {})}
+	if page.After == nil && page.Offset > 0 {
+		links = append(links, link("prev", func(q url.Values) {
+			if prev := page.Offset - page.Limit; prev > 0 {
+				q.Set("offset", strconv.FormatInt(prev, 10))
+			}
+		}))
+	}
+	if pagination.HasMore {
+		links = append(links, link("next", func(q url.Values) {
+			q.Set("cursor", pagination.NextCursor)
+		}))
 	}
+	return strings.Join(links, ", ")
+}
 
-	if e := cur.Err(); e != nil {
+// GetUsers returns a page of the users within the database. The users can be filtered by their role and the prefix of
+// their username, sorted by their id or username, and paginated with either a cursor or an offset. The route is
+// restricted to admins.
+func (c Controller) GetUsers(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	users := []*models.SecureUser{}
+	page, ok := parseUserPage(w, r.URL.Query())
+	if !ok {
+		return
+	}
+
+	// an extra user is fetched to find out if there is a next page
+	limit := page.Limit
+	page.Limit++
+	result, e := c.Store.Users.ListPage(r.Context(), page)
+	if e != nil {
 		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to parse the users"}.InternalServerError())
 		return
 	}
+	page.Limit = limit
+
+	pagination := httpcodes.Pagination{Limit: limit, Offset: page.Offset}
+	if int64(len(result)) > limit {
+		result = result[:limit]
+		pagination.HasMore = true
+		pagination.NextCursor = encodeUserCursor(result[limit-1])
+	}
+	for _, user := range result {
+		users = append(users, user.MapToSecureUser())
+	}
 
-	cur.Close(context.TODO()) // closes the cursor
+	w.Header().Set("Link", pageLinks(r.URL, page, pagination))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
-	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful fetch", Data: users}.Ok())
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful fetch", Data: users, Pagination: &pagination}.Ok())
 }
 
 // GetUser is used to return a single user, who is identified based on his/her id
 func (c Controller) GetUser(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
-	var user models.User
 	payload := other[0].(*utils.Payload)
 	id := p.ByName("id")
 
@@ -81,50 +215,69 @@ func (c Controller) GetUser(w http.ResponseWriter, r *http.Request, p httprouter
 	}
 
 	oid, _ := primitive.ObjectIDFromHex(id)
-	c.Mongo.Collection("users").FindOne(context.TODO(), bson.M{"_id": oid}).Decode(&user)
-
-	if user.Id == nil {
+	user, e := c.Store.Users.FindByID(r.Context(), oid)
+	if e == store.ErrNotFound {
 		httpcodes.ResponseError(w, httpcodes.Representation{Message: "User does not exists"}.NotFound())
 		return
 	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the user"}.InternalServerError())
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 
-	if payload.Id.Hex() == id {
+	if payload.CanAccess(id) {
 		json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful fetch", Data: user}.Ok())
 	} else {
 		json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful fetch", Data: user.MapToSecureUser()}.Ok())
 	}
 }
 
-// CreateUser is used to store a user within the database
+// CreateUser is used to store a user within the database. A taken email is answered with a 409, which tells that the
+// email is registered, since the response of a new user carries its tokens. The signups are rate limited instead.
 func (c Controller) CreateUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
 	var body models.User
-	var user models.User
 	json.NewDecoder(r.Body).Decode(&body)
 
-	result, e := c.Mongo.Collection("users").InsertOne(context.TODO(), body)
+	oid, e := c.Store.Users.Insert(r.Context(), body)
+	if e == store.ErrDuplicate {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The email is already in use"}.Conflict())
+		return
+	}
 	if e != nil {
 		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to store the user"}.InternalServerError())
 		return
 	}
 
-	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
-		w.Header().Set("Location", strings.Join([]string{r.URL.Path, oid.Hex()}, "/"))
-	}
+	w.Header().Set("Location", strings.Join([]string{r.URL.Path, oid.Hex()}, "/"))
 
-	c.Mongo.Collection("users").FindOne(context.TODO(), bson.M{"_id": result.InsertedID}).Decode(&user)
+	user, e := c.Store.Users.FindByID(r.Context(), oid)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to fetch the user"}.InternalServerError())
+		return
+	}
+	c.indexUser(r.Context(), user)
+	if e := c.sendVerificationEmail(r.Context(), user); e != nil {
+		// the account is created anyway, since the user can ask for another email
+		log.Printf("Unable to send the verification email to %v: %v", user.Id.Hex(), e)
+	}
 
-	token, ok := c.Utils.GenerateToken(user, os.Getenv("JWT_SECRET"), time.Hour)
+	token, ok := c.Utils.IssueToken(*user, accessTokenMaxAge)
 	if !ok {
 		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to generate a token"}.InternalServerError())
 		return
 	}
+	refreshToken, ok := c.issueRefreshToken(r.Context(), oid, primitive.NewObjectID(), nil, nil)
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to generate a refresh token"}.InternalServerError())
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(201)
-	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful creation", Data: user, Token: string(token)}.Created())
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful creation", Data: user, Token: string(token), RefreshToken: refreshToken}.Created())
 }
 
 // DeleteUser is used to delete a user from the database
@@ -136,26 +289,34 @@ func (c Controller) DeleteUser(w http.ResponseWriter, r *http.Request, p httprou
 		return
 	}
 
-	if payload.Id.Hex() != id {
+	if !payload.CanAccess(id) {
 		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid operation for the existing user"}.Forbidden())
 		return
 	}
 
 	oid, _ := primitive.ObjectIDFromHex(id)
-	_, e := c.Mongo.Collection("users").DeleteOne(context.TODO(), bson.M{"_id": oid})
+	e := c.Store.Users.Delete(r.Context(), oid)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "User does not exists"}.NotFound())
+		return
+	}
 	if e != nil {
 		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to delete the user"}.InternalServerError())
 		return
 	}
+	c.unindex(r.Context(), store.SearchUsers, oid)
+	if !c.revokeUserTokens(r.Context(), oid) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to revoke the user tokens"}.InternalServerError())
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(204)
 }
 
-// UpdateUser is used update a document within the users collection
+// UpdateUser is used update a document within the users collection. Only the fields of models.UserUpdate can be edited.
 func (c Controller) UpdateUser(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
-	var body interface{}
-	var user models.User
+	var body models.UserUpdate
 	id := p.ByName("id")
 	payload := other[0].(*utils.Payload)
 
@@ -163,53 +324,174 @@ func (c Controller) UpdateUser(w http.ResponseWriter, r *http.Request, p httprou
 		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid target resource"}.BadRequest())
 		return
 	}
-	if payload.Id.Hex() != id {
+	if !payload.CanAccess(id) {
 		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid operation for the existing user"}.Forbidden())
 		return
 	}
 
 	json.NewDecoder(r.Body).Decode(&body)
+	if body.Password != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The password can only be changed through /api/v1/users/:id/password"}.BadRequest())
+		return
+	}
+	fields := body.Fields()
+	if len(fields) == 0 || !body.Validate() {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Request Body"}.BadRequest())
+		return
+	}
+
 	oid, _ := primitive.ObjectIDFromHex(id)
-	_, e := c.Mongo.Collection("users").UpdateOne(context.TODO(), bson.M{"_id": oid}, bson.M{"$set": body})
+	emailChanged := false
+	if body.Email != nil {
+		current, e := c.Store.Users.FindByID(r.Context(), oid)
+		if e == store.ErrNotFound {
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "User does not exists"}.NotFound())
+			return
+		}
+		if e != nil {
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the user"}.InternalServerError())
+			return
+		}
+		// a new email has to be verified again
+		if emailChanged = current.Email != models.NormalizeEmail(*body.Email); emailChanged {
+			fields["emailVerified"] = false
+		}
+	}
+
+	user, e := c.Store.Users.Update(r.Context(), oid, fields)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "User does not exists"}.NotFound())
+		return
+	}
+	if e == store.ErrDuplicate {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The email is already in use"}.Conflict())
+		return
+	}
 	if e != nil {
 		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the user"}.InternalServerError())
 		return
 	}
-
-	c.Mongo.Collection("users").FindOne(context.TODO(), bson.M{"_id": oid}).Decode(&user)
+	c.indexUser(r.Context(), user)
+	if emailChanged {
+		if e := c.sendVerificationEmail(r.Context(), user); e != nil {
+			log.Printf("Unable to send the verification email to %v: %v", user.Id.Hex(), e)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful update", Data: user}.Ok())
 }
 
-// SignIn is used to login a user in the service
+// SignIn is used to login a user in the service. Users with two-factor authentication receive a pending token instead,
+// which is exchanged for the tokens of a session by SignInMFA.
 func (c Controller) SignIn(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
 	var body models.LoginUser
-	var user models.User
 	json.NewDecoder(r.Body).Decode(&body)
 
-	c.Mongo.Collection("users").FindOne(context.TODO(), bson.M{"email": body.Email}).Decode(&user)
-
-	if user.Id == nil {
-		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The user does not exists"}.NotFound())
+	// the attempts are throttled before the user is fetched, so that locked and unknown emails get the same response
+	keys := c.loginKeys(r, body.Email)
+	wait, e := c.loginRetryAfter(r.Context(), keys)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the sign in attempts"}.InternalServerError())
+		return
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
 		return
 	}
 
-	if !user.ComparePassword(body.Password) {
-		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Password"}.Unauthorized())
+	user, e := c.Store.Users.FindByEmail(r.Context(), body.Email)
+	if e != nil && e != store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the user"}.InternalServerError())
+		return
+	}
+	if e == store.ErrNotFound {
+		// a password is still compared, so that unknown emails can not be told apart by the response time
+		user = &unknownUser
+	}
+	if !user.ComparePassword(body.Password) || user == &unknownUser {
+		c.recordLoginFailure(r.Context(), keys)
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid email or password"}.Unauthorized())
 		return
 	}
+	// the failures are only reset once every factor has been verified, so SignInMFA resets them for two-factor users
+	if user.MFAEnabled() {
+		c.requireSecondFactor(w, r, user)
+		return
+	}
+	c.resetLoginFailures(r.Context(), body.Email)
+	c.startSession(w, r, user)
+}
 
-	// write logic to sign in a user
-	token, ok := c.Utils.GenerateToken(user, os.Getenv("JWT_SECRET"), time.Hour)
-	fmt.Println(string(token))
+// startSession responds with a new access and refresh token for a user that has been authenticated
+func (c Controller) startSession(w http.ResponseWriter, r *http.Request, user *models.User) {
+	token, ok := c.Utils.IssueToken(*user, accessTokenMaxAge)
 	if !ok {
 		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to generate user token"}.InternalServerError())
 		return
 	}
+	refreshToken, ok := c.issueRefreshToken(r.Context(), *user.Id, primitive.NewObjectID(), nil, nil)
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to generate a refresh token"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful login", Data: user, Token: string(token), RefreshToken: refreshToken}.Ok())
+}
+
+// UpdateUserRole is used by an admin to change the role of a user. Every change is recorded in the audit log, while the
+// outstanding tokens of the user are revoked so the new role takes effect.
+func (c Controller) UpdateUserRole(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	var body models.RoleUpdate
+	id := p.ByName("id")
+	payload := other[0].(*utils.Payload)
+
+	if id == "" {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid target resource"}.BadRequest())
+		return
+	}
+	if !payload.IsAdmin() {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid operation for the existing user"}.Forbidden())
+		return
+	}
+
+	json.NewDecoder(r.Body).Decode(&body)
+	if !body.ValidateRole() {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Role"}.BadRequest())
+		return
+	}
+
+	oid, _ := primitive.ObjectIDFromHex(id)
+	current, e := c.Store.Users.FindByID(r.Context(), oid)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "User does not exists"}.NotFound())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the user"}.InternalServerError())
+		return
+	}
+
+	user, e := c.Store.Users.Update(r.Context(), oid, map[string]interface{}{"role": body.Role})
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the user"}.InternalServerError())
+		return
+	}
+
+	entry := models.AuditEntry{Action: models.AuditUserRoleUpdate, ActorId: *payload.Id, TargetId: oid, From: current.Role, To: body.Role, CreatedAt: time.Now()}
+	if _, e := c.Store.Audit.Insert(r.Context(), entry); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to store the audit entry"}.InternalServerError())
+		return
+	}
+	if current.Role != body.Role && !c.revokeUserTokens(r.Context(), oid) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to revoke the user tokens"}.InternalServerError())
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
-	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful login", Data: user, Token: string(token)}.Ok())
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful update", Data: user.MapToSecureUser()}.Ok())
 }
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -14,12 +13,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/MarioSimou/authAPI/internal/media"
 	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
 	"github.com/MarioSimou/authAPI/internal/utils"
 	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
 
 	"github.com/julienschmidt/httprouter"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -27,6 +27,7 @@ var c *Controller
 var users models.Users
 var payloads []*utils.Payload
 var u utils.Utils
+var mailer = &recordingMailer{}
 
 type Check struct {
 	Key      string
@@ -78,35 +79,24 @@ func generateUserPayload(user models.User) *utils.Payload {
 	}
 	return u.ExtractPayload(string(token))
 }
-func mockData(mcli *utils.MongoClient) {
-	usersCollection := mcli.Client.Database(mcli.Database).Collection("users")
-	e := usersCollection.Drop(context.Background())
-	if e != nil {
-		log.Fatal("Unable to drop Users collection")
-	}
-
+func mockData(s *store.Store) {
 	paulID, _ := primitive.ObjectIDFromHex("5db5b5b06507b38887bedc87")
 	paul := models.User{Id: &paulID, Username: "paul", Email: "paul@gmail.com", Password: "$2a$04$DzlgE3dAEEynd4Ed9z0oY.MafLBCoZl815bXXeOjekaZztjwDLcdm", Role: "BASIC"}
 	johnID, _ := primitive.ObjectIDFromHex("5db5b5b06507b38887bedc88")
 	john := models.User{Id: &johnID, Username: "john", Email: "john@gmail.com", Password: "$2a$04$DzlgE3dAEEynd4Ed9z0oY.MafLBCoZl815bXXeOjekaZztjwDLcdm", Role: "BASIC"}
 
-	result, _ := usersCollection.InsertMany(context.Background(), []interface{}{john, paul})
-	if result.InsertedIDs == nil {
-		log.Fatal("Data has not being loaded to the database")
+	for _, user := range []models.User{john, paul} {
+		if _, e := s.Users.Insert(context.Background(), user); e != nil {
+			log.Fatal("Data has not being loaded to the store")
+		}
 	}
 
-	// retrieves the users stored in the database
-	cur, e := usersCollection.Find(context.TODO(), bson.M{})
+	// retrieves the users stored in the store
+	result, e := s.Users.List(context.Background())
 	if e != nil {
 		log.Fatal("Unable to fetch mocked usrs")
 	}
-	for cur.Next(context.Background()) {
-		var user models.User
-		cur.Decode(&user)
-
-		users = append(users, user)
-	}
-	cur.Close(context.Background())
+	users = append(users, result...)
 
 	// mocks a JWT token
 	payloads = []*utils.Payload{
@@ -117,12 +107,12 @@ func mockData(mcli *utils.MongoClient) {
 
 func init() {
 	u = utils.Utils{}
-	dir, _ := os.Getwd()
-	fmt.Println(dir)
 	u.LoadDotEnv("../../configs/.test.env")
-	mcli := u.ConnectDatabase(os.Getenv("MONGO_URI"), os.Getenv("DB_NAME"))
-	c = NewController(mcli, &u)
-	mockData(mcli)
+	s := store.NewMemoryStore()
+	// renditions are processed synchronously by the tests, so the pool has no workers
+	c = NewController(s, &u, media.NewPool(s, 0, 64))
+	c.Mailer = mailer
+	mockData(s)
 }
 
 func TestGetUsers(t *testing.T) {
@@ -135,13 +125,79 @@ func TestGetUsers(t *testing.T) {
 	checkHeader(w, "Content-Type", "application/json", t)
 
 	body, _ := ioutil.ReadAll(res.Body)
-	expected := `{"status":200,"success":true,"message":"Successful fetch","data":[{"username":"john","email":"john@gmail.com","role":"BASIC"},{"username":"paul","email":"paul@gmail.com","role":"BASIC"}]}`
+	expected := `{"status":200,"success":true,"message":"Successful fetch","data":[{"id":"5db5b5b06507b38887bedc87","username":"paul","email":"paul@gmail.com","role":"BASIC"},{"id":"5db5b5b06507b38887bedc88","username":"john","email":"john@gmail.com","role":"BASIC"}],"pagination":{"limit":20,"hasMore":false}}`
 
 	if b := strings.TrimRight(string(body), "\n"); b != expected {
 		t.Errorf("Should return a body of %v rather than %v", expected, b)
 	}
 }
 
+func getUsers(query string, t *testing.T) ([]string, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c.GetUsers(w, httptest.NewRequest("GET", "/api/v1/users?"+query, nil), nil)
+	checkStatusCode(w.Result(), 200, t)
+
+	usernames := []string{}
+	for _, user := range convertResponseToJson(w.Result()).Data.([]interface{}) {
+		usernames = append(usernames, user.(map[string]interface{})["username"].(string))
+	}
+	return usernames, w
+}
+
+func TestGetUsersPagination(t *testing.T) {
+	prefix := uniqueUsername("page") + "-"
+	for _, username := range []string{"c", "a", "e", "b", "d"} {
+		insertUser(prefix+username, t)
+	}
+
+	usernames, w := getUsers("username="+prefix+"&sort=username&limit=2", t)
+	if strings.Join(usernames, ",") != prefix+"a,"+prefix+"b" {
+		t.Fatalf("Should have returned the first page rather than %v", usernames)
+	}
+	var pagination struct {
+		Pagination httpcodes.Pagination `json:"pagination"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &pagination)
+	if !pagination.Pagination.HasMore || pagination.Pagination.NextCursor == "" {
+		t.Fatalf("Should have returned the cursor of the next page rather than %v", pagination.Pagination)
+	}
+	next := `</api/v1/users?cursor=` + pagination.Pagination.NextCursor + `&limit=2&sort=username&username=` + prefix + `>; rel="next"`
+	if link := w.Header().Get("Link"); !strings.Contains(link, next) || !strings.Contains(link, `rel="first"`) {
+		t.Errorf("Should have returned a Link header to the next page rather than %v", link)
+	}
+
+	usernames, _ = getUsers("username="+prefix+"&sort=username&limit=2&cursor="+pagination.Pagination.NextCursor, t)
+	if strings.Join(usernames, ",") != prefix+"c,"+prefix+"d" {
+		t.Errorf("Should have returned the second page rather than %v", usernames)
+	}
+	usernames, w = getUsers("username="+prefix+"&sort=-username&limit=2&offset=2", t)
+	if strings.Join(usernames, ",") != prefix+"c,"+prefix+"b" {
+		t.Errorf("Should have skipped the offset in descending order rather than %v", usernames)
+	}
+	if link := w.Header().Get("Link"); !strings.Contains(link, `rel="prev"`) {
+		t.Errorf("Should have returned a Link header to the previous page rather than %v", link)
+	}
+}
+
+func TestGetUsersFilterByRole(t *testing.T) {
+	prefix := uniqueUsername("role-filter") + "-"
+	admin := insertUser(prefix+"admin", t)
+	insertUser(prefix+"basic", t)
+	c.Store.Users.Update(context.Background(), *admin.Id, map[string]interface{}{"role": models.RoleAdmin})
+
+	if usernames, _ := getUsers("username="+prefix+"&role=ADMIN", t); strings.Join(usernames, ",") != prefix+"admin" {
+		t.Errorf("Should have returned the admins rather than %v", usernames)
+	}
+}
+
+func TestGetUsersInvalidQuery(t *testing.T) {
+	for _, query := range []string{"sort=email", "cursor=invalid", "offset=-1", "limit=1000", "role=ROOT", "offset=1&cursor=" + encodeUserCursor(models.User{Id: payloads[0].Id})} {
+		w := httptest.NewRecorder()
+		c.GetUsers(w, httptest.NewRequest("GET", "/api/v1/users?"+query, nil), nil)
+		checkStatusCode(w.Result(), 400, t)
+	}
+}
+
 func TestGetUserPaulFromPaul(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest("GET", "/api/v1/users/5db5b5b06507b38887bedc87", nil)
@@ -244,6 +300,21 @@ func TestInsertOne(t *testing.T) {
 	}
 }
 
+func TestDuplicateEmail(t *testing.T) {
+	first, second := createUser("kirk", t), createUserWithPassword("lars", t)
+
+	w := httptest.NewRecorder()
+	body := `{"username":"` + uniqueUsername("kirk") + `","password":"12345678","email":"` + strings.ToUpper(first["email"].(string)) + `"}`
+	c.CreateUser(w, httptest.NewRequest("POST", "/api/v1/users", strings.NewReader(body)), nil)
+	checkStatusCode(w.Result(), 409, t)
+
+	w = httptest.NewRecorder()
+	id := second.Id.Hex()
+	r := httptest.NewRequest("PUT", "/api/v1/users/"+id, strings.NewReader(`{"email":"`+first["email"].(string)+`"}`))
+	c.UpdateUser(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: id}}, generateUserPayload(*second))
+	checkStatusCode(w.Result(), 409, t)
+}
+
 func TestUpdateUserPaulFromPaul(t *testing.T) {
 	w := httptest.NewRecorder()
 	body := []byte(`{"username":"paul37","email":"mrpaul@gmail.com"}`)
@@ -391,12 +462,12 @@ func TestInvalidSignIn(t *testing.T) {
 	c.SignIn(w, r, nil)
 
 	res := w.Result()
-	checkStatusCode(res, 404, t)
+	checkStatusCode(res, 401, t)
 	checkHeader(w, "Content-Type", "application/json", t)
 
 	response := convertResponseToJson(res)
-	if response.Status != 404 {
-		t.Errorf("Should return a status of %v rather than %v", 404, response.Status)
+	if response.Status != 401 {
+		t.Errorf("Should return a status of %v rather than %v", 401, response.Status)
 	}
 	if response.Success {
 		t.Errorf("Should return a success of %v rather than %v", false, response.Success)
@@ -421,3 +492,105 @@ func TestInvalidPassword(t *testing.T) {
 		t.Errorf("Should return a success of %v rather than %v", false, response.Success)
 	}
 }
+
+func generateAdminPayload() *utils.Payload {
+	adminID := primitive.NewObjectID()
+	return generateUserPayload(models.User{Id: &adminID, Email: "admin@gmail.com", Role: models.RoleAdmin})
+}
+
+func TestGetUserJohnFromAdmin(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/users/5db5b5b06507b38887bedc88", nil)
+	c.GetUser(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: "5db5b5b06507b38887bedc88"}}, generateAdminPayload())
+
+	res := w.Result()
+	checkStatusCode(res, 200, t)
+	user := convertResponseToJson(res).Data.(map[string]interface{})
+	checkJSON(user, []Check{
+		Check{Key: "username", Expected: "john"},
+		Check{Key: "password", Expected: "$2a$04$DzlgE3dAEEynd4Ed9z0oY.MafLBCoZl815bXXeOjekaZztjwDLcdm"},
+	}, t)
+}
+
+func TestUpdateAndDeleteUserFromAdmin(t *testing.T) {
+	id, _ := c.Store.Users.Insert(context.Background(), models.User{Username: "george", Email: "george@gmail.com", Role: models.RoleBasic})
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: id.Hex()}}
+	admin := generateAdminPayload()
+
+	w := httptest.NewRecorder()
+	body := []byte(`{"username":"george37"}`)
+	c.UpdateUser(w, httptest.NewRequest("PUT", "/api/v1/users/"+id.Hex(), bytes.NewBuffer(body)), params, admin)
+	res := w.Result()
+	checkStatusCode(res, 200, t)
+	checkJSON(convertResponseToJson(res).Data.(map[string]interface{}), []Check{
+		Check{Key: "username", Expected: "george37"},
+	}, t)
+
+	w = httptest.NewRecorder()
+	c.DeleteUser(w, httptest.NewRequest("DELETE", "/api/v1/users/"+id.Hex(), nil), params, admin)
+	checkStatusCode(w.Result(), 204, t)
+}
+
+func TestUpdateUserIgnoresNoneEditableFields(t *testing.T) {
+	w := httptest.NewRecorder()
+	body := []byte(`{"username":"john","role":"ADMIN","_id":"5db5b5b06507b38887bedc99"}`)
+	r := httptest.NewRequest("PUT", "/api/v1/users/5db5b5b06507b38887bedc88", bytes.NewBuffer(body))
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: "5db5b5b06507b38887bedc88"}}
+	c.UpdateUser(w, r, params, payloads[0])
+
+	res := w.Result()
+	checkStatusCode(res, 200, t)
+	checkJSON(convertResponseToJson(res).Data.(map[string]interface{}), []Check{
+		Check{Key: "id", Expected: "5db5b5b06507b38887bedc88"},
+		Check{Key: "role", Expected: "BASIC"},
+	}, t)
+}
+
+func TestUpdateUserWithoutEditableFields(t *testing.T) {
+	w := httptest.NewRecorder()
+	body := []byte(`{"role":"ADMIN"}`)
+	r := httptest.NewRequest("PUT", "/api/v1/users/5db5b5b06507b38887bedc88", bytes.NewBuffer(body))
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: "5db5b5b06507b38887bedc88"}}
+	c.UpdateUser(w, r, params, payloads[0])
+
+	checkStatusCode(w.Result(), 400, t)
+}
+
+func TestUpdateUserRoleFromAdmin(t *testing.T) {
+	id, _ := c.Store.Users.Insert(context.Background(), models.User{Username: "nick", Email: "nick@gmail.com", Role: models.RoleBasic})
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: id.Hex()}}
+	admin := generateAdminPayload()
+
+	w := httptest.NewRecorder()
+	body := []byte(`{"role":"ADMIN"}`)
+	c.UpdateUserRole(w, httptest.NewRequest("PUT", "/api/v1/users/"+id.Hex()+"/role", bytes.NewBuffer(body)), params, admin)
+
+	res := w.Result()
+	checkStatusCode(res, 200, t)
+	checkJSON(convertResponseToJson(res).Data.(map[string]interface{}), []Check{
+		Check{Key: "role", Expected: "ADMIN"},
+	}, t)
+
+	entries, _ := c.Store.Audit.ListByTarget(context.Background(), id)
+	if len(entries) != 1 || entries[0].From != "BASIC" || entries[0].To != "ADMIN" || entries[0].ActorId != *admin.Id {
+		t.Errorf("Should have recorded the role change in the audit log rather than %v", entries)
+	}
+}
+
+func TestUpdateUserRoleInvalidRole(t *testing.T) {
+	w := httptest.NewRecorder()
+	body := []byte(`{"role":"ROOT"}`)
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: "5db5b5b06507b38887bedc88"}}
+	c.UpdateUserRole(w, httptest.NewRequest("PUT", "/api/v1/users/5db5b5b06507b38887bedc88/role", bytes.NewBuffer(body)), params, generateAdminPayload())
+
+	checkStatusCode(w.Result(), 400, t)
+}
+
+func TestUpdateUserRoleFromJohn(t *testing.T) {
+	w := httptest.NewRecorder()
+	body := []byte(`{"role":"ADMIN"}`)
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: "5db5b5b06507b38887bedc88"}}
+	c.UpdateUserRole(w, httptest.NewRequest("PUT", "/api/v1/users/5db5b5b06507b38887bedc88/role", bytes.NewBuffer(body)), params, payloads[0])
+
+	checkStatusCode(w.Result(), 403, t)
+}
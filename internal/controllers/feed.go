@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// defaultPageLimit and maxPageLimit bound the number of items returned by a paginated list
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// parseLimit parses the limit query parameter of a paginated list, which defaults to defaultPageLimit
+func parseLimit(q string) (int64, bool) {
+	if q == "" {
+		return defaultPageLimit, true
+	}
+	limit, e := strconv.ParseInt(q, 10, 64)
+	if e != nil || limit < 1 || limit > maxPageLimit {
+		return 0, false
+	}
+	return limit, true
+}
+
+// encodeCursor returns the opaque representation of a cursor that is sent to clients
+func encodeCursor(c store.Cursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.Time.UnixNano(), 10) + "." + c.Id.Hex()))
+}
+
+// decodeCursor parses a cursor that was returned by encodeCursor
+func decodeCursor(s string) (*store.Cursor, bool) {
+	b, e := base64.RawURLEncoding.DecodeString(s)
+	if e != nil {
+		return nil, false
+	}
+	parts := strings.SplitN(string(b), ".", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
+	ns, e := strconv.ParseInt(parts[0], 10, 64)
+	if e != nil {
+		return nil, false
+	}
+	oid, e := primitive.ObjectIDFromHex(parts[1])
+	if e != nil {
+		return nil, false
+	}
+	return &store.Cursor{Time: time.Unix(0, ns), Id: oid}, true
+}
+
+// parsePage parses the cursor and limit query parameters of a paginated list of content, and writes the error response
+// when they are invalid
+func parsePage(w http.ResponseWriter, r *http.Request) (*store.Cursor, int64, bool) {
+	query := r.URL.Query()
+	limit, ok := parseLimit(query.Get("limit"))
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid limit"}.BadRequest())
+		return nil, 0, false
+	}
+
+	var after *store.Cursor
+	if cursor := query.Get("cursor"); cursor != "" {
+		if after, ok = decodeCursor(cursor); !ok {
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid cursor"}.BadRequest())
+			return nil, 0, false
+		}
+	}
+	return after, limit, true
+}
+
+// GetFeed is used to return the published posts and the photos of the users that the authenticated user follows, with
+// the newest first. The feed is paginated by the limit and cursor query parameters, where the cursor of the next page
+// is returned along with each page.
+func (c Controller) GetFeed(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	payload := other[0].(*utils.Payload)
+	after, limit, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	following, e := c.Store.Follows.Following(r.Context(), *payload.Id)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the followed users"}.InternalServerError())
+		return
+	}
+	c.writeContent(w, r, payload, store.ContentFilter{OwnerIds: following}, after, limit)
+}
+
+// writeContent writes a page of the published posts and the photos that match a filter, with the newest first, as the
+// response of a request
+func (c Controller) writeContent(w http.ResponseWriter, r *http.Request, payload *utils.Payload, filter store.ContentFilter, after *store.Cursor, limit int64) {
+	// one more item than the limit is fetched from each list, to know if there is a next page
+	posts, e := c.Store.Posts.ListPublished(r.Context(), filter, after, limit+1)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the posts"}.InternalServerError())
+		return
+	}
+	photos, e := c.Store.Photos.ListRecent(r.Context(), filter, after, limit+1)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the photos"}.InternalServerError())
+		return
+	}
+	if c.setLikedPosts(r.Context(), payload, posts) != nil || c.setLikedPhotos(r.Context(), payload, photos) != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the likes"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful fetch", Data: mergeContent(posts, photos, limit)}.Ok())
+}
+
+// mergeContent merges lists of posts and photos, which are both sorted with the newest first, into a page of up to
+// limit items. The cursor of the next page is set when there are items left.
+func mergeContent(posts []models.Post, photos []models.Photo, limit int64) models.Feed {
+	feed := models.Feed{Items: []models.FeedItem{}}
+	var last store.Cursor
+	for len(feed.Items) < int(limit) && (len(posts) > 0 || len(photos) > 0) {
+		if len(photos) == 0 || (len(posts) > 0 && (&store.Cursor{Time: *posts[0].PublishedAt, Id: *posts[0].Id}).Includes(photos[0].CreatedAt, *photos[0].Id)) {
+			post := posts[0]
+			renderPost(&post)
+			feed.Items = append(feed.Items, models.FeedItem{Type: models.TargetPost, Post: &post})
+			last = store.Cursor{Time: *post.PublishedAt, Id: *post.Id}
+			posts = posts[1:]
+		} else {
+			photo := photos[0].Public()
+			feed.Items = append(feed.Items, models.FeedItem{Type: models.TargetPhoto, Photo: &photo})
+			last = store.Cursor{Time: photo.CreatedAt, Id: *photo.Id}
+			photos = photos[1:]
+		}
+	}
+	if len(posts) > 0 || len(photos) > 0 {
+		feed.NextCursor = encodeCursor(last)
+	}
+	return feed
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// findUser fetches the user of the :id route parameter and writes the error response when it can not be found
+func (c Controller) findUser(w http.ResponseWriter, r *http.Request, p httprouter.Params) (*models.User, bool) {
+	id := p.ByName("id")
+	if id == "" {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid target resource"}.BadRequest())
+		return nil, false
+	}
+
+	oid, _ := primitive.ObjectIDFromHex(id)
+	user, e := c.Store.Users.FindByID(r.Context(), oid)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "User does not exists"}.NotFound())
+		return nil, false
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the user"}.InternalServerError())
+		return nil, false
+	}
+	return user, true
+}
+
+// setFollow follows or unfollows a user on behalf of the authenticated user. Repeating a request has no effect.
+func (c Controller) setFollow(w http.ResponseWriter, r *http.Request, p httprouter.Params, payload *utils.Payload, following bool) {
+	user, ok := c.findUser(w, r, p)
+	if !ok {
+		return
+	}
+	if *user.Id == *payload.Id {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Users can not follow themselves"}.BadRequest())
+		return
+	}
+
+	var e error
+	if following {
+		_, e = c.Store.Follows.Insert(r.Context(), models.Follow{FollowerId: *payload.Id, FolloweeId: *user.Id, CreatedAt: time.Now()})
+	} else {
+		_, e = c.Store.Follows.Delete(r.Context(), *payload.Id, *user.Id)
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the follow"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful update", Data: models.FollowState{Following: following}}.Ok())
+}
+
+// FollowUser is used to follow a user
+func (c Controller) FollowUser(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	c.setFollow(w, r, p, other[0].(*utils.Payload), true)
+}
+
+// UnfollowUser is used to stop following a user
+func (c Controller) UnfollowUser(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	c.setFollow(w, r, p, other[0].(*utils.Payload), false)
+}
+
+// writeUsers writes the users with the given ids, in the same order, as the response of a request
+func (c Controller) writeUsers(w http.ResponseWriter, r *http.Request, ids []primitive.ObjectID) {
+	users, e := c.Store.Users.FindMany(r.Context(), ids)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the users"}.InternalServerError())
+		return
+	}
+
+	byID := make(map[primitive.ObjectID]models.User, len(users))
+	for _, user := range users {
+		byID[*user.Id] = user
+	}
+	secureUsers := []*models.SecureUser{}
+	for _, id := range ids {
+		// follows of deleted users are skipped
+		if user, ok := byID[id]; ok {
+			secureUsers = append(secureUsers, user.MapToSecureUser())
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful fetch", Data: secureUsers}.Ok())
+}
+
+// GetFollowers is used to return the users that follow a user, with the most recent first
+func (c Controller) GetFollowers(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	user, ok := c.findUser(w, r, p)
+	if !ok {
+		return
+	}
+
+	ids, e := c.Store.Follows.Followers(r.Context(), *user.Id)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the followers"}.InternalServerError())
+		return
+	}
+	c.writeUsers(w, r, ids)
+}
+
+// GetFollowing is used to return the users that a user follows, with the most recent first
+func (c Controller) GetFollowing(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	user, ok := c.findUser(w, r, p)
+	if !ok {
+		return
+	}
+
+	ids, e := c.Store.Follows.Following(r.Context(), *user.Id)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the followed users"}.InternalServerError())
+		return
+	}
+	c.writeUsers(w, r, ids)
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/utils"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func insertUser(username string, t *testing.T) *utils.Payload {
+	id := primitive.NewObjectID()
+	user := models.User{Id: &id, Username: username, Email: username + "@gmail.com", Role: models.RoleBasic}
+	if _, e := c.Store.Users.Insert(context.Background(), user); e != nil {
+		t.Fatalf("Unable to insert a mock user: %v", e)
+	}
+	return generateUserPayload(user)
+}
+
+func follow(followee *utils.Payload, following bool, payload *utils.Payload) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: followee.Id.Hex()}}
+	if following {
+		c.FollowUser(w, httptest.NewRequest("PUT", "/api/v1/users/"+followee.Id.Hex()+"/follow", nil), params, payload)
+	} else {
+		c.UnfollowUser(w, httptest.NewRequest("DELETE", "/api/v1/users/"+followee.Id.Hex()+"/follow", nil), params, payload)
+	}
+	return w
+}
+
+func listUsernames(h func(*httptest.ResponseRecorder, httprouter.Params), user *utils.Payload) []string {
+	w := httptest.NewRecorder()
+	h(w, httprouter.Params{httprouter.Param{Key: "id", Value: user.Id.Hex()}})
+
+	usernames := []string{}
+	for _, u := range convertResponseToJson(w.Result()).Data.([]interface{}) {
+		usernames = append(usernames, u.(map[string]interface{})["username"].(string))
+	}
+	return usernames
+}
+
+func TestFollowUser(t *testing.T) {
+	georgeName, ringoName := uniqueUsername("george"), uniqueUsername("ringo")
+	george, ringo := insertUser(georgeName, t), insertUser(ringoName, t)
+
+	checkStatusCode(follow(george, true, george).Result(), 400, t)
+	checkStatusCode(follow(george, true, ringo).Result(), 200, t)
+	checkStatusCode(follow(george, true, ringo).Result(), 200, t)
+
+	followers := listUsernames(func(w *httptest.ResponseRecorder, p httprouter.Params) {
+		c.GetFollowers(w, httptest.NewRequest("GET", "/", nil), p, george)
+	}, george)
+	if len(followers) != 1 || followers[0] != ringoName {
+		t.Errorf("Should return ringo as the only follower rather than %v", followers)
+	}
+	following := listUsernames(func(w *httptest.ResponseRecorder, p httprouter.Params) {
+		c.GetFollowing(w, httptest.NewRequest("GET", "/", nil), p, george)
+	}, ringo)
+	if len(following) != 1 || following[0] != georgeName {
+		t.Errorf("Should return george as the only followed user rather than %v", following)
+	}
+
+	checkStatusCode(follow(george, false, ringo).Result(), 200, t)
+	checkStatusCode(follow(george, false, ringo).Result(), 200, t)
+	followers = listUsernames(func(w *httptest.ResponseRecorder, p httprouter.Params) {
+		c.GetFollowers(w, httptest.NewRequest("GET", "/", nil), p, george)
+	}, george)
+	if len(followers) != 0 {
+		t.Errorf("Should not return any followers rather than %v", followers)
+	}
+}
+
+func getFeed(query string, payload *utils.Payload) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c.GetFeed(w, httptest.NewRequest("GET", "/api/v1/feed"+query, nil), nil, payload)
+	return w
+}
+
+func TestGetFeed(t *testing.T) {
+	pete, stuart, brian := insertUser(uniqueUsername("pete"), t), insertUser(uniqueUsername("stuart"), t), insertUser(uniqueUsername("brian"), t)
+	follow(pete, true, stuart)
+
+	now := time.Now()
+	expected := []string{}
+	for i := 0; i < 3; i++ {
+		published := now.Add(-time.Duration(2*i) * time.Minute)
+		id, _ := c.Store.Posts.Insert(context.Background(), models.Post{AuthorId: *pete.Id, Title: "Post", Slug: primitive.NewObjectID().Hex(), Status: models.PostPublished, PublishedAt: &published})
+		photoID, _ := c.Store.Photos.Insert(context.Background(), models.Photo{OwnerId: *pete.Id, CreatedAt: published.Add(-time.Minute)})
+		expected = append(expected, id.Hex(), photoID.Hex())
+	}
+	// drafts and the content of users that are not followed are not part of the feed
+	c.Store.Posts.Insert(context.Background(), models.Post{AuthorId: *pete.Id, Title: "Draft", Slug: primitive.NewObjectID().Hex(), Status: models.PostDraft, CreatedAt: now})
+	c.Store.Photos.Insert(context.Background(), models.Photo{OwnerId: *brian.Id, CreatedAt: now})
+
+	ids := []string{}
+	cursor := ""
+	for pages := 0; pages < 10; pages++ {
+		w := getFeed("?limit=4&cursor="+cursor, stuart)
+		checkStatusCode(w.Result(), 200, t)
+		feed := convertResponseToJson(w.Result()).Data.(map[string]interface{})
+		for _, item := range feed["items"].([]interface{}) {
+			item := item.(map[string]interface{})
+			ids = append(ids, item[item["type"].(string)].(map[string]interface{})["id"].(string))
+		}
+		next, ok := feed["nextCursor"].(string)
+		if !ok {
+			break
+		}
+		cursor = next
+	}
+
+	if len(ids) != len(expected) {
+		t.Fatalf("Should return %v items rather than %v", len(expected), ids)
+	}
+	for i := range ids {
+		if ids[i] != expected[i] {
+			t.Errorf("Should return the item %v at position %d rather than %v", expected[i], i, ids[i])
+		}
+	}
+
+	checkStatusCode(getFeed("?cursor=invalid", stuart).Result(), 400, t)
+	checkStatusCode(getFeed("?limit=1000", stuart).Result(), 400, t)
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// incrementLikes adds delta to the like count of a post or a photo and returns the new count. A delta of 0 returns the
+// current count.
+func (c Controller) incrementLikes(ctx context.Context, targetType string, id primitive.ObjectID, delta int64) (int64, error) {
+	if targetType == models.TargetPost {
+		return c.Store.Posts.IncrementLikes(ctx, id, delta)
+	}
+	return c.Store.Photos.IncrementLikes(ctx, id, delta)
+}
+
+// setLike likes or unlikes a post or a photo on behalf of the authenticated user. Repeating a request has no effect, so
+// the like count changes only once per user.
+func (c Controller) setLike(w http.ResponseWriter, r *http.Request, p httprouter.Params, payload *utils.Payload, targetType string, liked bool) {
+	oid, _ := primitive.ObjectIDFromHex(p.ByName("id"))
+	if _, e := c.findTarget(r.Context(), targetType, oid, payload); e != nil {
+		writeTargetError(w, targetType, e)
+		return
+	}
+
+	var changed bool
+	var e error
+	if liked {
+		changed, e = c.Store.Likes.Insert(r.Context(), models.Like{UserId: *payload.Id, TargetType: targetType, TargetId: oid, CreatedAt: time.Now()})
+	} else {
+		changed, e = c.Store.Likes.Delete(r.Context(), *payload.Id, targetType, oid)
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the like"}.InternalServerError())
+		return
+	}
+
+	var delta int64
+	if changed && liked {
+		delta = 1
+	} else if changed {
+		delta = -1
+	}
+	count, e := c.incrementLikes(r.Context(), targetType, oid, delta)
+	if e == store.ErrNotFound {
+		// the target was deleted after it was liked
+		writeTargetError(w, targetType, e)
+		return
+	}
+	if e != nil {
+		log.Printf("Unable to update the like count of %v %v: %v", targetType, oid.Hex(), e)
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the like count"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful update", Data: models.LikeState{Liked: liked, LikeCount: count}}.Ok())
+}
+
+// LikePhoto is used to like a photo
+func (c Controller) LikePhoto(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	c.setLike(w, r, p, other[0].(*utils.Payload), models.TargetPhoto, true)
+}
+
+// UnlikePhoto is used to remove the like of a photo
+func (c Controller) UnlikePhoto(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	c.setLike(w, r, p, other[0].(*utils.Payload), models.TargetPhoto, false)
+}
+
+// LikePost is used to like a post
+func (c Controller) LikePost(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	c.setLike(w, r, p, other[0].(*utils.Payload), models.TargetPost, true)
+}
+
+// UnlikePost is used to remove the like of a post
+func (c Controller) UnlikePost(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	c.setLike(w, r, p, other[0].(*utils.Payload), models.TargetPost, false)
+}
+
+// setLikedPosts sets whether each of the posts is liked by the authenticated user
+func (c Controller) setLikedPosts(ctx context.Context, payload *utils.Payload, posts []models.Post) error {
+	ids := make([]primitive.ObjectID, len(posts))
+	for i, post := range posts {
+		ids[i] = *post.Id
+	}
+
+	liked, e := c.Store.Likes.LikedBy(ctx, *payload.Id, models.TargetPost, ids)
+	if e != nil {
+		return e
+	}
+	for i := range posts {
+		l := liked[*posts[i].Id]
+		posts[i].Liked = &l
+	}
+	return nil
+}
+
+// setLikedPhotos sets whether each of the photos is liked by the authenticated user
+func (c Controller) setLikedPhotos(ctx context.Context, payload *utils.Payload, photos []models.Photo) error {
+	ids := make([]primitive.ObjectID, len(photos))
+	for i, photo := range photos {
+		ids[i] = *photo.Id
+	}
+
+	liked, e := c.Store.Likes.LikedBy(ctx, *payload.Id, models.TargetPhoto, ids)
+	if e != nil {
+		return e
+	}
+	for i := range photos {
+		l := liked[*photos[i].Id]
+		photos[i].Liked = &l
+	}
+	return nil
+}
+
+// isLiked reports if a post or a photo is liked by the authenticated user
+func (c Controller) isLiked(ctx context.Context, payload *utils.Payload, targetType string, id primitive.ObjectID) (*bool, error) {
+	liked, e := c.Store.Likes.LikedBy(ctx, *payload.Id, targetType, []primitive.ObjectID{id})
+	if e != nil {
+		return nil, e
+	}
+	l := liked[id]
+	return &l, nil
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MarioSimou/authAPI/internal/utils"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func likePhoto(photo string, liked bool, payload *utils.Payload) map[string]interface{} {
+	w := httptest.NewRecorder()
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: photo}}
+	if liked {
+		c.LikePhoto(w, httptest.NewRequest("PUT", "/api/v1/photos/"+photo+"/like", nil), params, payload)
+	} else {
+		c.UnlikePhoto(w, httptest.NewRequest("DELETE", "/api/v1/photos/"+photo+"/like", nil), params, payload)
+	}
+	return convertResponseToJson(w.Result()).Data.(map[string]interface{})
+}
+
+func TestLikePhotoIsIdempotent(t *testing.T) {
+	photo := insertPhoto(payloads[0], t)
+
+	checkJSON(likePhoto(photo, true, payloads[1]), []Check{Check{Key: "liked", Expected: true}, Check{Key: "likeCount", Expected: float64(1)}}, t)
+	checkJSON(likePhoto(photo, true, payloads[1]), []Check{Check{Key: "liked", Expected: true}, Check{Key: "likeCount", Expected: float64(1)}}, t)
+	checkJSON(likePhoto(photo, true, payloads[0]), []Check{Check{Key: "liked", Expected: true}, Check{Key: "likeCount", Expected: float64(2)}}, t)
+
+	w := httptest.NewRecorder()
+	c.GetPhoto(w, httptest.NewRequest("GET", "/api/v1/photos/"+photo, nil), httprouter.Params{httprouter.Param{Key: "id", Value: photo}}, payloads[1])
+	checkJSON(convertResponseToJson(w.Result()).Data.(map[string]interface{}), []Check{Check{Key: "liked", Expected: true}, Check{Key: "likeCount", Expected: float64(2)}}, t)
+
+	checkJSON(likePhoto(photo, false, payloads[1]), []Check{Check{Key: "liked", Expected: false}, Check{Key: "likeCount", Expected: float64(1)}}, t)
+	checkJSON(likePhoto(photo, false, payloads[1]), []Check{Check{Key: "liked", Expected: false}, Check{Key: "likeCount", Expected: float64(1)}}, t)
+
+	w = httptest.NewRecorder()
+	c.GetPhoto(w, httptest.NewRequest("GET", "/api/v1/photos/"+photo, nil), httprouter.Params{httprouter.Param{Key: "id", Value: photo}}, payloads[1])
+	checkJSON(convertResponseToJson(w.Result()).Data.(map[string]interface{}), []Check{Check{Key: "liked", Expected: false}}, t)
+}
+
+func TestLikePost(t *testing.T) {
+	draft := createPost(`{"title":"Unliked draft"}`, payloads[0], t)["id"].(string)
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: draft}}
+
+	// drafts are hidden from other users, so they can not be liked
+	w := httptest.NewRecorder()
+	c.LikePost(w, httptest.NewRequest("PUT", "/api/v1/posts/"+draft+"/like", nil), params, payloads[1])
+	checkStatusCode(w.Result(), 404, t)
+
+	published := createPost(`{"title":"Liked post","status":"published"}`, payloads[0], t)["id"].(string)
+	params = httprouter.Params{httprouter.Param{Key: "id", Value: published}}
+	w = httptest.NewRecorder()
+	c.LikePost(w, httptest.NewRequest("PUT", "/api/v1/posts/"+published+"/like", nil), params, payloads[1])
+	checkStatusCode(w.Result(), 200, t)
+
+	w = getPost(published, payloads[1])
+	checkJSON(convertResponseToJson(w.Result()).Data.(map[string]interface{}), []Check{Check{Key: "liked", Expected: true}, Check{Key: "likeCount", Expected: float64(1)}}, t)
+	w = getPost(published, payloads[0])
+	checkJSON(convertResponseToJson(w.Result()).Data.(map[string]interface{}), []Check{Check{Key: "liked", Expected: false}}, t)
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// lockoutPolicy describes how the failed sign ins of a key are throttled. The first Free failures are not delayed,
+// every further failure doubles the delay, starting from Base, and MaxFailures failures lock the key for Lockout.
+type lockoutPolicy struct {
+	Prefix      string
+	Free        int
+	Base        time.Duration
+	MaxFailures int
+	Lockout     time.Duration
+}
+
+var (
+	emailLockout = lockoutPolicy{Prefix: "email:", Free: 3, Base: time.Second, MaxFailures: 10, Lockout: 15 * time.Minute}
+	// clients behind the same NAT share an IP address, so it is given more attempts than an email
+	ipLockout = lockoutPolicy{Prefix: "ip:", Free: 10, Base: time.Second, MaxFailures: 50, Lockout: 15 * time.Minute}
+)
+
+// unknownUser stands in for the user of an unknown email on a sign in. Its password is the hash of a random string.
+var unknownUser = models.User{Password: utils.Utils{}.HashPassword(primitive.NewObjectID().Hex())}
+
+// delay returns the time that has to pass after the last failure before the key can be used again
+func (p lockoutPolicy) delay(failures int) time.Duration {
+	if failures < p.Free {
+		return 0
+	}
+	if failures >= p.MaxFailures {
+		return p.Lockout
+	}
+	d := p.Base << uint(failures-p.Free)
+	if d > p.Lockout {
+		return p.Lockout
+	}
+	return d
+}
+
+// retryAfter returns how long a key has to wait before the next attempt
+func (p lockoutPolicy) retryAfter(attempt *models.LoginAttempt, now time.Time) time.Duration {
+	if wait := attempt.LastFailure.Add(p.delay(attempt.Failures)).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// loginKey is a key whose failed sign ins are throttled by a policy
+type loginKey struct {
+	Key    string
+	Policy lockoutPolicy
+}
+
+// loginKeys returns the keys that are throttled on a sign in with an email
+func (c Controller) loginKeys(r *http.Request, email string) []loginKey {
+	return []loginKey{
+		{Key: emailLockout.Prefix + models.NormalizeEmail(email), Policy: emailLockout},
+		{Key: ipLockout.Prefix + c.Utils.ClientIP(r), Policy: ipLockout},
+	}
+}
+
+// loginRetryAfter returns how long a sign in has to wait, because of the failures of any of its keys
+func (c Controller) loginRetryAfter(ctx context.Context, keys []loginKey) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+	for _, k := range keys {
+		attempt, e := c.Store.LoginAttempts.Find(ctx, k.Key)
+		if e == store.ErrNotFound {
+			continue
+		}
+		if e != nil {
+			return 0, e
+		}
+		if d := k.Policy.retryAfter(attempt, now); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// recordLoginFailure counts a failed sign in against every key. Errors are only logged, since the sign in has already
+// failed.
+func (c Controller) recordLoginFailure(ctx context.Context, keys []loginKey) {
+	now := time.Now()
+	for _, k := range keys {
+		if _, e := c.Store.LoginAttempts.RecordFailure(ctx, k.Key, now, k.Policy.Lockout); e != nil {
+			log.Printf("Unable to record the failed sign in of %v: %v", k.Key, e)
+		}
+	}
+}
+
+// resetLoginFailures forgets the failed sign ins of an email. The failures of the IP address are left to expire, so
+// that signing into one account does not clear the guesses made against others.
+func (c Controller) resetLoginFailures(ctx context.Context, email string) {
+	key := emailLockout.Prefix + models.NormalizeEmail(email)
+	if e := c.Store.LoginAttempts.Reset(ctx, key); e != nil {
+		log.Printf("Unable to reset the failed sign ins of %v: %v", key, e)
+	}
+}
+
+// setRetryAfter sets the seconds to wait, rounded up, in the Retry-After header
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	seconds := int64((wait + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+}
+
+// tooManyAttempts responds with an HTTP 429 Too Many Requests, along with the seconds to wait in the Retry-After header
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	setRetryAfter(w, wait)
+	httpcodes.ResponseError(w, httpcodes.Representation{Message: "Too many failed sign in attempts"}.TooManyRequests())
+}
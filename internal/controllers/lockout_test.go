@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+)
+
+func signInFrom(ip string, email string, password string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/users/signin", strings.NewReader(`{"email":"`+email+`","password":"`+password+`"}`))
+	r.RemoteAddr = ip + ":1234"
+	c.SignIn(w, r, nil)
+	return w
+}
+
+func TestSignInLockout(t *testing.T) {
+	user := createUserWithPassword("syd", t)
+	for i := 0; i < emailLockout.Free; i++ {
+		checkStatusCode(signInFrom("198.51.100.1", user.Email, "wrongpassword").Result(), 401, t)
+	}
+
+	// the correct password is rejected as well, until the delay has passed
+	w := signInFrom("198.51.100.2", user.Email, "12345678")
+	checkStatusCode(w.Result(), 429, t)
+	checkHeader(w, "Retry-After", "1", t)
+
+	// locked and unknown emails can not be told apart
+	for i := 0; i < emailLockout.Free; i++ {
+		signInFrom("198.51.100.3", "nobody@gmail.com", "wrongpassword")
+	}
+	unknown := signInFrom("198.51.100.4", "nobody@gmail.com", "12345678")
+	if unknown.Code != w.Code || unknown.Body.String() != w.Body.String() {
+		t.Errorf("Should have returned the same response for an unknown email rather than %v", unknown.Body.String())
+	}
+}
+
+func TestSignInResetsFailures(t *testing.T) {
+	user := createUserWithPassword("rick", t)
+	for i := 0; i < emailLockout.Free-1; i++ {
+		checkStatusCode(signInFrom("198.51.100.5", user.Email, "wrongpassword").Result(), 401, t)
+	}
+	checkStatusCode(signInFrom("198.51.100.5", user.Email, "12345678").Result(), 200, t)
+	checkStatusCode(signInFrom("198.51.100.5", user.Email, "wrongpassword").Result(), 401, t)
+	checkStatusCode(signInFrom("198.51.100.5", user.Email, "12345678").Result(), 200, t)
+}
+
+func TestSignInLockoutByIP(t *testing.T) {
+	for i := 0; i < ipLockout.Free; i++ {
+		checkStatusCode(signInFrom("198.51.100.6", "guess"+strconv.Itoa(i)+"@gmail.com", "wrongpassword").Result(), 401, t)
+	}
+	checkStatusCode(signInFrom("198.51.100.6", "john@gmail.com", "12345678").Result(), 429, t)
+	checkStatusCode(signInFrom("198.51.100.7", "john@gmail.com", "12345678").Result(), 200, t)
+}
+
+func TestSignInMFALockout(t *testing.T) {
+	user := createUserWithPassword("ian", t)
+	enableTOTP(user, t)
+	for i := 0; i < emailLockout.Free-1; i++ {
+		checkStatusCode(signInFrom("198.51.100.8", user.Email, "wrongpassword").Result(), 401, t)
+	}
+
+	// the password alone does not reset the failures, while a wrong code adds to them
+	mfaToken := pendingSignIn(user.Email, t)
+	checkStatusCode(signInMFA(mfaToken, "not-a-code").Result(), 401, t)
+	checkStatusCode(signInFrom("198.51.100.9", user.Email, "12345678").Result(), 429, t)
+}
+
+func TestSignInKeepsIPFailures(t *testing.T) {
+	user := createUserWithPassword("lemmy", t)
+	for i := 0; i < ipLockout.Free-1; i++ {
+		checkStatusCode(signInFrom("198.51.100.10", "guess"+strconv.Itoa(i)+"@gmail.com", "wrongpassword").Result(), 401, t)
+	}
+	checkStatusCode(signInFrom("198.51.100.10", user.Email, "12345678").Result(), 200, t)
+	checkStatusCode(signInFrom("198.51.100.10", "guess@gmail.com", "wrongpassword").Result(), 401, t)
+	checkStatusCode(signInFrom("198.51.100.10", user.Email, "12345678").Result(), 429, t)
+}
+
+func TestLockoutPolicyDelay(t *testing.T) {
+	p := lockoutPolicy{Free: 3, Base: time.Second, MaxFailures: 10, Lockout: 15 * time.Minute}
+	for failures, expected := range map[int]time.Duration{
+		2:  0,
+		3:  time.Second,
+		5:  4 * time.Second,
+		9:  64 * time.Second,
+		10: 15 * time.Minute,
+	} {
+		if d := p.delay(failures); d != expected {
+			t.Errorf("Should have delayed %v failures by %v rather than %v", failures, expected, d)
+		}
+	}
+
+	now := time.Now()
+	attempt := models.LoginAttempt{Failures: 4, LastFailure: now.Add(-time.Second)}
+	if wait := p.retryAfter(&attempt, now); wait != time.Second {
+		t.Errorf("Should have waited for the rest of the delay rather than %v", wait)
+	}
+}
@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/totp"
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// mfaPendingMaxAge is the lifetime of the token that is issued on a sign in that still needs a second factor
+	mfaPendingMaxAge = 5 * time.Minute
+	// totpIssuer labels the secrets of the API within authenticator apps
+	totpIssuer = "authAPI"
+	// recoveryCodeCount is the number of recovery codes that are issued when two-factor authentication is enabled
+	recoveryCodeCount = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateRecoveryCodes returns a set of random recovery codes along with their hashes
+func (c Controller) generateRecoveryCodes() ([]string, []string, bool) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, e := rand.Read(b); e != nil {
+			return nil, nil, false
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = c.Utils.HashToken(normalizeRecoveryCode(codes[i]))
+	}
+	return codes, hashes, true
+}
+
+// normalizeRecoveryCode allows a recovery code to be typed in any case, with or without the separator
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// verifySecondFactor checks a TOTP or a recovery code of a user, consuming it so that it can not be used again
+func (c Controller) verifySecondFactor(ctx context.Context, user *models.User, code string) (bool, error) {
+	if step, ok := totp.Verify(user.TOTP.Secret, code, time.Now()); ok {
+		return c.Store.Users.UseTOTPStep(ctx, *user.Id, step)
+	}
+	return c.Store.Users.UseRecoveryCode(ctx, *user.Id, c.Utils.HashToken(normalizeRecoveryCode(code)))
+}
+
+// requireSecondFactor responds with a pending token for a user that signed in with the password, but still needs to
+// present a second factor
+func (c Controller) requireSecondFactor(w http.ResponseWriter, r *http.Request, user *models.User) {
+	token, ok := c.issueUserToken(r.Context(), user, models.TokenMFAPending, mfaPendingMaxAge)
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to generate a pending token"}.InternalServerError())
+		return
+	}
+
+	data := map[string]interface{}{"mfaToken": token, "expiresIn": int64(mfaPendingMaxAge / time.Second)}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "A second factor is required", Data: data}.Ok())
+}
+
+// SignInMFA exchanges the pending token of a sign in and a TOTP or recovery code for the tokens of a session. The pending
+// token can be used once, so a wrong code requires the user to sign in again, which limits the codes that can be guessed.
+// Wrong codes are counted as failed sign ins as well, while the failures are only reset once the code is verified.
+func (c Controller) SignInMFA(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	var body models.MFASignIn
+	json.NewDecoder(r.Body).Decode(&body)
+
+	ut, ok, e := c.useUserToken(r.Context(), body.MFAToken, models.TokenMFAPending)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the token"}.InternalServerError())
+		return
+	}
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid token"}.Unauthorized())
+		return
+	}
+
+	user, e := c.Store.Users.FindByID(r.Context(), ut.UserId)
+	if e != nil && e != store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the user"}.InternalServerError())
+		return
+	}
+	if e == store.ErrNotFound || user.Email != ut.Email || !user.MFAEnabled() {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid token"}.Unauthorized())
+		return
+	}
+
+	keys := c.loginKeys(r, user.Email)
+	wait, e := c.loginRetryAfter(r.Context(), keys)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the sign in attempts"}.InternalServerError())
+		return
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return
+	}
+
+	verified, e := c.verifySecondFactor(r.Context(), user, body.Code)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to verify the code"}.InternalServerError())
+		return
+	}
+	if !verified {
+		// wrong codes count as failed sign ins, so that the codes can not be guessed with new pending tokens
+		c.recordLoginFailure(r.Context(), keys)
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid code"}.Unauthorized())
+		return
+	}
+	c.resetLoginFailures(r.Context(), user.Email)
+	c.startSession(w, r, user)
+}
+
+// EnrollTOTP is used by the authenticated user to generate a TOTP secret. The secret stays pending, and can be replaced by
+// enrolling again, until it is confirmed with a first code by ConfirmTOTP.
+func (c Controller) EnrollTOTP(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	payload := other[0].(*utils.Payload)
+
+	user, e := c.Store.Users.FindByID(r.Context(), *payload.Id)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "User does not exists"}.NotFound())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the user"}.InternalServerError())
+		return
+	}
+	if user.MFAEnabled() {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Two-factor authentication is already enabled"}.Conflict())
+		return
+	}
+
+	secret, e := totp.GenerateSecret()
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to generate a secret"}.InternalServerError())
+		return
+	}
+	if _, e := c.Store.Users.Update(r.Context(), *user.Id, map[string]interface{}{"totp": models.TOTP{Secret: secret}}); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the user"}.InternalServerError())
+		return
+	}
+
+	data := map[string]interface{}{"secret": secret, "uri": totp.URI(totpIssuer, user.Email, secret)}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful enrolment", Data: data}.Ok())
+}
+
+// ConfirmTOTP is used by the authenticated user to enable two-factor authentication with the first code of a pending
+// secret. The recovery codes are only returned by this response, since their hashes are stored instead.
+func (c Controller) ConfirmTOTP(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	var body models.TOTPConfirmation
+	payload := other[0].(*utils.Payload)
+	json.NewDecoder(r.Body).Decode(&body)
+
+	user, e := c.Store.Users.FindByID(r.Context(), *payload.Id)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "User does not exists"}.NotFound())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the user"}.InternalServerError())
+		return
+	}
+	if user.MFAEnabled() {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Two-factor authentication is already enabled"}.Conflict())
+		return
+	}
+	if user.TOTP == nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "A TOTP secret has not been enrolled"}.BadRequest())
+		return
+	}
+
+	step, ok := totp.Verify(user.TOTP.Secret, body.Code, time.Now())
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid code"}.BadRequest())
+		return
+	}
+	codes, hashes, ok := c.generateRecoveryCodes()
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to generate the recovery codes"}.InternalServerError())
+		return
+	}
+
+	state := models.TOTP{Secret: user.TOTP.Secret, Enabled: true, LastStep: step, RecoveryCodes: hashes}
+	if _, e := c.Store.Users.Update(r.Context(), *user.Id, map[string]interface{}{"totp": state}); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the user"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful confirmation", Data: map[string]interface{}{"recoveryCodes": codes}}.Ok())
+}
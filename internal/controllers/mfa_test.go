@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/totp"
+	"github.com/MarioSimou/authAPI/internal/utils"
+)
+
+func enrollTOTP(payload *utils.Payload) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c.EnrollTOTP(w, httptest.NewRequest("POST", "/api/v1/users/mfa/totp", nil), nil, payload)
+	return w
+}
+
+func confirmTOTP(code string, payload *utils.Payload) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c.ConfirmTOTP(w, httptest.NewRequest("POST", "/api/v1/users/mfa/totp/confirm", strings.NewReader(`{"code":"`+code+`"}`)), nil, payload)
+	return w
+}
+
+func signInMFA(mfaToken string, code string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	body := `{"mfaToken":"` + mfaToken + `","code":"` + code + `"}`
+	c.SignInMFA(w, httptest.NewRequest("POST", "/api/v1/users/signin/mfa", strings.NewReader(body)), nil)
+	return w
+}
+
+// pendingSignIn signs in a user with two-factor authentication and returns the pending token
+func pendingSignIn(email string, t *testing.T) string {
+	w := trySignIn(email, "12345678")
+	checkStatusCode(w.Result(), 200, t)
+	response := convertResponseToJson(w.Result())
+	if response.Token != nil && response.Token != "" {
+		t.Fatalf("Should have not returned an access token before the second factor")
+	}
+	return response.Data.(map[string]interface{})["mfaToken"].(string)
+}
+
+// enableTOTP enrols and confirms a TOTP secret for a user, returning the secret and the recovery codes
+func enableTOTP(user *models.User, t *testing.T) (string, []interface{}) {
+	payload := generateUserPayload(*user)
+	w := enrollTOTP(payload)
+	checkStatusCode(w.Result(), 200, t)
+	data := convertResponseToJson(w.Result()).Data.(map[string]interface{})
+	secret := data["secret"].(string)
+	if uri := data["uri"].(string); !strings.HasPrefix(uri, "otpauth://totp/") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("Should have returned the otpauth URI of the secret rather than %v", uri)
+	}
+
+	checkStatusCode(confirmTOTP("000000", payload).Result(), 400, t)
+	code, _ := totp.Code(secret, time.Now())
+	w = confirmTOTP(code, payload)
+	checkStatusCode(w.Result(), 200, t)
+	codes := convertResponseToJson(w.Result()).Data.(map[string]interface{})["recoveryCodes"].([]interface{})
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("Should have returned %v recovery codes rather than %v", recoveryCodeCount, codes)
+	}
+	checkStatusCode(enrollTOTP(payload).Result(), 409, t)
+	return secret, codes
+}
+
+func TestSignInWithTOTP(t *testing.T) {
+	user := createUserWithPassword("robert", t)
+	secret, _ := enableTOTP(user, t)
+
+	// the code that confirmed the enrolment can not be replayed
+	mfaToken := pendingSignIn(user.Email, t)
+	user, _ = c.Store.Users.FindByID(context.Background(), *user.Id)
+	code, _ := totp.Code(secret, time.Unix(user.TOTP.LastStep*int64(totp.Period/time.Second), 0))
+	checkStatusCode(signInMFA(mfaToken, code).Result(), 401, t)
+	// the pending token is consumed by a wrong code
+	code, _ = totp.Code(secret, time.Now().Add(totp.Period))
+	checkStatusCode(signInMFA(mfaToken, code).Result(), 401, t)
+
+	mfaToken = pendingSignIn(user.Email, t)
+	w := signInMFA(mfaToken, code)
+	checkStatusCode(w.Result(), 200, t)
+	if response := convertResponseToJson(w.Result()); response.Token == nil || response.RefreshToken == nil {
+		t.Errorf("Should have returned the tokens of a session rather than %v", response)
+	}
+	checkStatusCode(signInMFA(mfaToken, code).Result(), 401, t)
+}
+
+func TestSignInWithRecoveryCode(t *testing.T) {
+	user := createUserWithPassword("kenney", t)
+	_, codes := enableTOTP(user, t)
+	code := strings.ToUpper(codes[0].(string))
+
+	checkStatusCode(signInMFA(pendingSignIn(user.Email, t), code).Result(), 200, t)
+	checkStatusCode(signInMFA(pendingSignIn(user.Email, t), code).Result(), 401, t)
+	checkStatusCode(signInMFA(pendingSignIn(user.Email, t), codes[1].(string)).Result(), 200, t)
+}
+
+func TestConfirmTOTPWithoutEnrolment(t *testing.T) {
+	user := createUserWithPassword("jimi", t)
+	checkStatusCode(confirmTOTP("123456", generateUserPayload(*user)).Result(), 400, t)
+}
@@ -0,0 +1,690 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// authorizationCodeMaxAge is the lifetime of an authorization code, which is exchanged right after the redirect
+const authorizationCodeMaxAge = time.Minute
+
+// idTokenMaxAge is the lifetime of an ID token
+const idTokenMaxAge = accessTokenMaxAge
+
+// maxAuthorizeFormSize bounds the body of the sign in form of the authorization endpoint
+const maxAuthorizeFormSize = 1 << 16
+
+// supportedScopes are the OpenID Connect scopes that can be granted to a client
+var supportedScopes = []string{models.ScopeOpenID, models.ScopeProfile, models.ScopeEmail}
+
+// openIDConfiguration is the discovery document of the OpenID Connect provider
+type openIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// OpenIDConfiguration serves the discovery document, which lets OpenID Connect libraries find the endpoints and the keys
+// of the provider. The issuer is the public URL of the API.
+func (c Controller) OpenIDConfiguration(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	config := openIDConfiguration{
+		Issuer:                            c.PublicURL,
+		AuthorizationEndpoint:             c.PublicURL + "/authorize",
+		TokenEndpoint:                     c.PublicURL + "/token",
+		UserinfoEndpoint:                  c.PublicURL + "/userinfo",
+		JWKSURI:                           c.PublicURL + "/.well-known/jwks.json",
+		ScopesSupported:                   supportedScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{c.Utils.SigningKeys().Algorithm()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "preferred_username", "email", "email_verified"},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(config)
+}
+
+// authorizationRequest is a custom type used to map the parameters of a request to the authorization endpoint
+type authorizationRequest struct {
+	ClientId            string
+	RedirectURI         string
+	ResponseType        string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// parseAuthorizationRequest returns the authorization request of the query or the form of a request
+func parseAuthorizationRequest(values url.Values) authorizationRequest {
+	return authorizationRequest{
+		ClientId:            values.Get("client_id"),
+		RedirectURI:         values.Get("redirect_uri"),
+		ResponseType:        values.Get("response_type"),
+		Scope:               values.Get("scope"),
+		State:               values.Get("state"),
+		Nonce:               values.Get("nonce"),
+		CodeChallenge:       values.Get("code_challenge"),
+		CodeChallengeMethod: values.Get("code_challenge_method"),
+	}
+}
+
+// scopes returns the requested scopes that are supported, in the order of supportedScopes, so that the openid scope is
+// the first one when it has been requested
+func (ar authorizationRequest) scopes() []string {
+	requested := strings.Fields(ar.Scope)
+	scopes := []string{}
+	for _, scope := range supportedScopes {
+		for _, s := range requested {
+			if s == scope {
+				scopes = append(scopes, scope)
+				break
+			}
+		}
+	}
+	return scopes
+}
+
+// validCodeChallenge checks that a PKCE challenge is the base64url encoding of a SHA-256 hash
+func validCodeChallenge(challenge string) bool {
+	b, e := base64.RawURLEncoding.DecodeString(challenge)
+	return e == nil && len(b) == sha256.Size
+}
+
+// verifyCodeVerifier checks that a PKCE verifier of RFC 7636 matches an S256 challenge
+func verifyCodeVerifier(verifier string, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// redirectAuthorization redirects the user agent back to the client with the given parameters along with the state of
+// the request
+func redirectAuthorization(w http.ResponseWriter, r *http.Request, ar authorizationRequest, params url.Values) {
+	u, _ := url.Parse(ar.RedirectURI)
+	q := u.Query()
+	for k := range params {
+		q.Set(k, params.Get(k))
+	}
+	if ar.State != "" {
+		q.Set("state", ar.State)
+	}
+	u.RawQuery = q.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// checkAuthorizationRequest returns the client of an authorization request. A request of an unknown client or of an
+// unregistered redirect URI is answered directly, since it can not be trusted to redirect the user agent, while the
+// other errors are redirected back to the client.
+func (c Controller) checkAuthorizationRequest(w http.ResponseWriter, r *http.Request, ar authorizationRequest) (*models.Client, bool) {
+	oid, e := primitive.ObjectIDFromHex(ar.ClientId)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unknown client"}.BadRequest())
+		return nil, false
+	}
+	client, e := c.Store.Clients.FindByID(r.Context(), oid)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unknown client"}.BadRequest())
+		return nil, false
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the client"}.InternalServerError())
+		return nil, false
+	}
+	if !client.HasRedirectURI(ar.RedirectURI) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unregistered redirect URI"}.BadRequest())
+		return nil, false
+	}
+
+	var code, description string
+	switch {
+	case ar.ResponseType != "code":
+		code, description = "unsupported_response_type", "Only the code response type is supported"
+	case len(ar.scopes()) == 0 || ar.scopes()[0] != models.ScopeOpenID:
+		code, description = "invalid_scope", "The openid scope is required"
+	case ar.CodeChallengeMethod != "S256" || !validCodeChallenge(ar.CodeChallenge):
+		code, description = "invalid_request", "A PKCE code challenge of the S256 method is required"
+	}
+	if code != "" {
+		redirectAuthorization(w, r, ar, url.Values{"error": {code}, "error_description": {description}})
+		return nil, false
+	}
+	return client, true
+}
+
+// authorizePages are the pages of the authorization endpoint. The parameters of the authorization request are kept in
+// hidden fields, so that they are posted along with the credentials or the consent of the user, while the CSRF token of
+// the form is posted along with its cookie.
+var authorizePages = template.Must(template.New("authorize").Parse(`{{define "request"}}
+<input type="hidden" name="client_id" value="{{.Request.ClientId}}">
+<input type="hidden" name="redirect_uri" value="{{.Request.RedirectURI}}">
+<input type="hidden" name="response_type" value="{{.Request.ResponseType}}">
+<input type="hidden" name="scope" value="{{.Request.Scope}}">
+<input type="hidden" name="state" value="{{.Request.State}}">
+<input type="hidden" name="nonce" value="{{.Request.Nonce}}">
+<input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+{{- end}}{{define "signin"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in to {{.Client}}</title>
+</head>
+<body>
+<h1>Sign in to {{.Client}}</h1>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<form method="post" action="/authorize">
+{{- template "request" .}}
+<p><label>Email <input type="email" name="email" value="{{.Email}}" autocomplete="username" required></label></p>
+<p><label>Password <input type="password" name="password" autocomplete="current-password" required></label></p>
+<p><label>Authentication code, if two-factor authentication is enabled <input type="text" name="code" autocomplete="one-time-code"></label></p>
+<p><button type="submit">Sign in</button></p>
+</form>
+</body>
+</html>
+{{end}}{{define "consent"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Allow {{.Client}} to access your account</title>
+</head>
+<body>
+<h1>Allow {{.Client}} to access your account</h1>
+<p>{{.Client}} will be able to:</p>
+<ul>
+{{- range .Scopes}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+<form method="post" action="/authorize">
+{{- template "request" .}}
+<input type="hidden" name="consent_token" value="{{.ConsentToken}}">
+<p><button type="submit" name="consent" value="allow">Allow</button> <button type="submit" name="consent" value="deny">Deny</button></p>
+</form>
+</body>
+</html>
+{{end}}`))
+
+// scopeDescriptions describe the scopes on the consent page
+var scopeDescriptions = map[string]string{
+	models.ScopeOpenID:  "Know who you are",
+	models.ScopeProfile: "See your username",
+	models.ScopeEmail:   "See your email address",
+}
+
+// renderPage responds with an HTML page of a template. The page can not be framed, so that the forms of the page can
+// not be captured by another site.
+func renderPage(w http.ResponseWriter, pages *template.Template, status int, page string, data map[string]interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+	w.WriteHeader(status)
+	pages.ExecuteTemplate(w, page, data)
+}
+
+// renderAuthorizePage responds with a page of the authorization endpoint
+func renderAuthorizePage(w http.ResponseWriter, status int, page string, data map[string]interface{}) {
+	renderPage(w, authorizePages, status, page, data)
+}
+
+// renderSignIn responds with the sign in form of an authorization request
+func renderSignIn(w http.ResponseWriter, status int, client *models.Client, ar authorizationRequest, csrfToken string, email string, message string) {
+	renderAuthorizePage(w, status, "signin", map[string]interface{}{"Client": client.ClientName, "Request": ar, "CSRFToken": csrfToken, "Email": email, "Error": message})
+}
+
+// renderConsent responds with the consent form of an authorization request, which lists the requested scopes
+func renderConsent(w http.ResponseWriter, client *models.Client, ar authorizationRequest, csrfToken string, consentToken string) {
+	scopes := []string{}
+	for _, scope := range ar.scopes() {
+		scopes = append(scopes, scopeDescriptions[scope])
+	}
+	renderAuthorizePage(w, 200, "consent", map[string]interface{}{"Client": client.ClientName, "Request": ar, "CSRFToken": csrfToken, "ConsentToken": consentToken, "Scopes": scopes})
+}
+
+// csrfCookie is the cookie of the token that is submitted along with the forms of the authorization endpoint. Another
+// site can not read the cookie, so it can not post a form with a matching token.
+const csrfCookie = "authorize_csrf"
+
+// setCSRFToken generates the CSRF token of the forms of the authorization endpoint and sets it as a cookie, which is
+// only sent along with the requests of the endpoint
+func (c Controller) setCSRFToken(w http.ResponseWriter) (string, bool) {
+	token, _, ok := c.Utils.GenerateRefreshToken()
+	if !ok {
+		return "", false
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     "/authorize",
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(c.PublicURL, "https://"),
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token, true
+}
+
+// checkCSRFToken returns the CSRF token of a posted form, if it matches the token of its cookie
+func checkCSRFToken(r *http.Request) (string, bool) {
+	cookie, e := r.Cookie(csrfCookie)
+	token := r.PostForm.Get("csrf_token")
+	if e != nil || token == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(token)) != 1 {
+		return "", false
+	}
+	return token, true
+}
+
+// Authorize serves the sign in form of the authorization endpoint, once the request of the client has been validated.
+// Only the authorization code flow with a PKCE challenge is supported.
+func (c Controller) Authorize(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	ar := parseAuthorizationRequest(r.URL.Query())
+	client, ok := c.checkAuthorizationRequest(w, r, ar)
+	if !ok {
+		return
+	}
+	csrfToken, ok := c.setCSRFToken(w)
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to generate a CSRF token"}.InternalServerError())
+		return
+	}
+	renderSignIn(w, 200, client, ar, csrfToken, "", "")
+}
+
+// consentPendingMaxAge is the time that a user has to consent to the scopes of a client after signing in
+const consentPendingMaxAge = 10 * time.Minute
+
+// AuthorizeSignIn signs a user in through the form of the authorization endpoint, and redirects the user agent back to
+// the client with an authorization code. The sign ins are throttled the same way as SignIn, while users with two-factor
+// authentication need to include a code. Users that have not allowed the client the requested scopes are asked for
+// their consent first, which is posted to the same endpoint.
+func (c Controller) AuthorizeSignIn(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAuthorizeFormSize)
+	if e := r.ParseForm(); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Request Body"}.BadRequest())
+		return
+	}
+	ar := parseAuthorizationRequest(r.PostForm)
+	client, ok := c.checkAuthorizationRequest(w, r, ar)
+	if !ok {
+		return
+	}
+	csrfToken, ok := checkCSRFToken(r)
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid CSRF token"}.Forbidden())
+		return
+	}
+	if r.PostForm.Get("consent_token") != "" {
+		c.authorizeConsent(w, r, client, ar, csrfToken)
+		return
+	}
+
+	email := r.PostForm.Get("email")
+	keys := c.loginKeys(r, email)
+	wait, e := c.loginRetryAfter(r.Context(), keys)
+	if e != nil {
+		renderSignIn(w, 500, client, ar, csrfToken, email, "The server was unable to sign you in, please try again")
+		return
+	}
+	if wait > 0 {
+		setRetryAfter(w, wait)
+		renderSignIn(w, 429, client, ar, csrfToken, email, "Too many failed sign in attempts, please try again later")
+		return
+	}
+
+	user, e := c.Store.Users.FindByEmail(r.Context(), email)
+	if e != nil && e != store.ErrNotFound {
+		renderSignIn(w, 500, client, ar, csrfToken, email, "The server was unable to sign you in, please try again")
+		return
+	}
+	if e == store.ErrNotFound {
+		user = &unknownUser
+	}
+	if !user.ComparePassword(r.PostForm.Get("password")) || user == &unknownUser {
+		c.recordLoginFailure(r.Context(), keys)
+		renderSignIn(w, 401, client, ar, csrfToken, email, "Invalid email or password")
+		return
+	}
+	if user.MFAEnabled() {
+		code := r.PostForm.Get("code")
+		if code == "" {
+			renderSignIn(w, 401, client, ar, csrfToken, email, "Enter the code of your authenticator app or a recovery code")
+			return
+		}
+		valid, e := c.verifySecondFactor(r.Context(), user, code)
+		if e != nil {
+			renderSignIn(w, 500, client, ar, csrfToken, email, "The server was unable to sign you in, please try again")
+			return
+		}
+		if !valid {
+			// wrong codes count as failed sign ins, so that the codes can not be guessed
+			c.recordLoginFailure(r.Context(), keys)
+			renderSignIn(w, 401, client, ar, csrfToken, email, "Invalid code")
+			return
+		}
+	}
+	c.resetLoginFailures(r.Context(), email)
+
+	consent, e := c.Store.Consents.Find(r.Context(), *user.Id, *client.Id)
+	if e != nil && e != store.ErrNotFound {
+		renderSignIn(w, 500, client, ar, csrfToken, email, "The server was unable to sign you in, please try again")
+		return
+	}
+	if e == nil && consent.Covers(ar.scopes()) {
+		c.redirectAuthorizationCode(w, r, client, ar, user, time.Now(), csrfToken)
+		return
+	}
+	consentToken, ok := c.issueUserToken(r.Context(), user, models.TokenConsentPending, consentPendingMaxAge)
+	if !ok {
+		renderSignIn(w, 500, client, ar, csrfToken, email, "The server was unable to sign you in, please try again")
+		return
+	}
+	renderConsent(w, client, ar, csrfToken, consentToken)
+}
+
+// authorizeConsent records the consent of a user that signed in through the authorization endpoint, and redirects the
+// user agent back to the client with an authorization code. A denied consent is redirected back with an error instead.
+func (c Controller) authorizeConsent(w http.ResponseWriter, r *http.Request, client *models.Client, ar authorizationRequest, csrfToken string) {
+	ut, ok, e := c.useUserToken(r.Context(), r.PostForm.Get("consent_token"), models.TokenConsentPending)
+	if e != nil {
+		renderSignIn(w, 500, client, ar, csrfToken, "", "The server was unable to sign you in, please try again")
+		return
+	}
+	if !ok {
+		renderSignIn(w, 401, client, ar, csrfToken, "", "Your sign in has expired, please sign in again")
+		return
+	}
+	user, e := c.Store.Users.FindByID(r.Context(), ut.UserId)
+	if e != nil && e != store.ErrNotFound {
+		renderSignIn(w, 500, client, ar, csrfToken, "", "The server was unable to sign you in, please try again")
+		return
+	}
+	if e == store.ErrNotFound || user.Email != ut.Email {
+		renderSignIn(w, 401, client, ar, csrfToken, "", "Your sign in has expired, please sign in again")
+		return
+	}
+
+	if r.PostForm.Get("consent") != "allow" {
+		redirectAuthorization(w, r, ar, url.Values{"error": {"access_denied"}, "error_description": {"The user denied the request"}})
+		return
+	}
+	if e := c.Store.Consents.Grant(r.Context(), *user.Id, *client.Id, ar.scopes(), time.Now()); e != nil {
+		renderSignIn(w, 500, client, ar, csrfToken, user.Email, "The server was unable to sign you in, please try again")
+		return
+	}
+	// the user signed in when the consent token was issued
+	c.redirectAuthorizationCode(w, r, client, ar, user, ut.CreatedAt, csrfToken)
+}
+
+// redirectAuthorizationCode issues an authorization code to a client for a user that signed in at authTime, and
+// redirects the user agent back to the client with the code
+func (c Controller) redirectAuthorizationCode(w http.ResponseWriter, r *http.Request, client *models.Client, ar authorizationRequest, user *models.User, authTime time.Time, csrfToken string) {
+	value, hash, ok := c.Utils.GenerateRefreshToken()
+	if !ok {
+		renderSignIn(w, 500, client, ar, csrfToken, user.Email, "The server was unable to sign you in, please try again")
+		return
+	}
+	now := time.Now()
+	code := models.AuthorizationCode{
+		Hash:          hash,
+		ClientId:      *client.Id,
+		UserId:        *user.Id,
+		RedirectURI:   ar.RedirectURI,
+		Scopes:        ar.scopes(),
+		Nonce:         ar.Nonce,
+		CodeChallenge: ar.CodeChallenge,
+		AuthTime:      authTime,
+		Family:        primitive.NewObjectID(),
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(authorizationCodeMaxAge),
+	}
+	if _, e := c.Store.AuthCodes.Insert(r.Context(), code); e != nil {
+		renderSignIn(w, 500, client, ar, csrfToken, user.Email, "The server was unable to sign you in, please try again")
+		return
+	}
+	redirectAuthorization(w, r, ar, url.Values{"code": {value}})
+}
+
+// tokenError is an error of the token endpoint, as defined by RFC 6749
+type tokenError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// respondToken writes a response of the token endpoint, which is never cached. The endpoint is called by OpenID Connect
+// libraries, so its responses follow RFC 6749 rather than the representations of the API.
+func respondToken(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// tokenResponse is the successful response of the token endpoint
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// authenticateClient returns the client of a token request. Confidential clients authenticate with their secret, either
+// through HTTP Basic authentication or the form, while public clients only send their id.
+func (c Controller) authenticateClient(w http.ResponseWriter, r *http.Request) (*models.Client, bool) {
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		// the credentials are form encoded before they are joined, as RFC 6749 requires
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	invalid := func() (*models.Client, bool) {
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="authAPI"`)
+		}
+		respondToken(w, 401, tokenError{Code: "invalid_client", Description: "Invalid client credentials"})
+		return nil, false
+	}
+
+	oid, e := primitive.ObjectIDFromHex(id)
+	if e != nil {
+		return invalid()
+	}
+	client, e := c.Store.Clients.FindByID(r.Context(), oid)
+	if e == store.ErrNotFound {
+		return invalid()
+	}
+	if e != nil {
+		respondToken(w, 500, tokenError{Code: "server_error", Description: "The server was unable to fetch the client"})
+		return nil, false
+	}
+	if !client.IsPublic() && subtle.ConstantTimeCompare([]byte(c.Utils.HashToken(secret)), []byte(client.SecretHash)) != 1 {
+		return invalid()
+	}
+	return client, true
+}
+
+// Token exchanges an authorization code or a refresh token of a client for the tokens of a user
+func (c Controller) Token(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAuthorizeFormSize)
+	if e := r.ParseForm(); e != nil {
+		respondToken(w, 400, tokenError{Code: "invalid_request", Description: "Invalid form"})
+		return
+	}
+	client, ok := c.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		c.exchangeAuthorizationCode(w, r, client)
+	case "refresh_token":
+		c.exchangeClientRefreshToken(w, r, client)
+	default:
+		respondToken(w, 400, tokenError{Code: "unsupported_grant_type", Description: "Only the authorization_code and refresh_token grants are supported"})
+	}
+}
+
+// exchangeAuthorizationCode exchanges an authorization code, along with the verifier of its PKCE challenge, for an
+// access, a refresh and an ID token. A code can be used once, while using it again revokes the refresh tokens that were
+// issued for it.
+func (c Controller) exchangeAuthorizationCode(w http.ResponseWriter, r *http.Request, client *models.Client) {
+	invalid := tokenError{Code: "invalid_grant", Description: "Invalid authorization code"}
+	ac, e := c.Store.AuthCodes.FindByHash(r.Context(), c.Utils.HashToken(r.PostForm.Get("code")))
+	if e == store.ErrNotFound {
+		respondToken(w, 400, invalid)
+		return
+	}
+	if e != nil {
+		respondToken(w, 500, tokenError{Code: "server_error", Description: "The server was unable to fetch the authorization code"})
+		return
+	}
+
+	now := time.Now()
+	if ac.ClientId != *client.Id || ac.IsExpired(now) || ac.RedirectURI != r.PostForm.Get("redirect_uri") {
+		respondToken(w, 400, invalid)
+		return
+	}
+	if !verifyCodeVerifier(r.PostForm.Get("code_verifier"), ac.CodeChallenge) {
+		respondToken(w, 400, tokenError{Code: "invalid_grant", Description: "Invalid code verifier"})
+		return
+	}
+	used, e := c.Store.AuthCodes.MarkUsed(r.Context(), *ac.Id, now)
+	if e != nil {
+		respondToken(w, 500, tokenError{Code: "server_error", Description: "The server was unable to redeem the authorization code"})
+		return
+	}
+	// a code that has already been redeemed indicates that it has been leaked
+	if !used {
+		c.Store.RefreshTokens.RevokeFamily(r.Context(), ac.Family)
+		respondToken(w, 400, invalid)
+		return
+	}
+
+	user, e := c.Store.Users.FindByID(r.Context(), ac.UserId)
+	if e != nil {
+		respondToken(w, 400, invalid)
+		return
+	}
+	token, ok := c.Utils.IssueClientToken(*user, c.PublicURL, client.Id.Hex(), ac.Scopes, accessTokenMaxAge)
+	if !ok {
+		respondToken(w, 500, tokenError{Code: "server_error", Description: "Unable to generate user token"})
+		return
+	}
+	refreshToken, ok := c.issueRefreshToken(r.Context(), *user.Id, ac.Family, client.Id, ac.Scopes)
+	if !ok {
+		respondToken(w, 500, tokenError{Code: "server_error", Description: "Unable to generate a refresh token"})
+		return
+	}
+	idToken, ok := c.Utils.IssueIDToken(*user, c.PublicURL, client.Id.Hex(), ac.Nonce, ac.AuthTime, ac.Scopes, idTokenMaxAge)
+	if !ok {
+		respondToken(w, 500, tokenError{Code: "server_error", Description: "Unable to generate an ID token"})
+		return
+	}
+
+	respondToken(w, 200, tokenResponse{
+		AccessToken:  string(token),
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(accessTokenMaxAge.Seconds()),
+		RefreshToken: refreshToken,
+		IDToken:      string(idToken),
+		Scope:        strings.Join(ac.Scopes, " "),
+	})
+}
+
+// exchangeClientRefreshToken exchanges a refresh token that was issued to a client for a new access and refresh token
+func (c Controller) exchangeClientRefreshToken(w http.ResponseWriter, r *http.Request, client *models.Client) {
+	user, rt, refreshToken, e := c.rotateRefreshToken(r.Context(), r.PostForm.Get("refresh_token"), client.Id)
+	if e == errInvalidRefreshToken {
+		respondToken(w, 400, tokenError{Code: "invalid_grant", Description: "Invalid refresh token"})
+		return
+	}
+	if e != nil {
+		respondToken(w, 500, tokenError{Code: "server_error", Description: "The server was unable to refresh the token"})
+		return
+	}
+	token, ok := c.Utils.IssueClientToken(*user, c.PublicURL, client.Id.Hex(), rt.Scopes, accessTokenMaxAge)
+	if !ok {
+		respondToken(w, 500, tokenError{Code: "server_error", Description: "Unable to generate user token"})
+		return
+	}
+
+	respondToken(w, 200, tokenResponse{
+		AccessToken:  string(token),
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(accessTokenMaxAge.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(rt.Scopes, " "),
+	})
+}
+
+// UserInfo returns the claims of the authenticated user. The claims of the access tokens of clients are limited to the
+// scopes that were granted to the client, while the tokens of the API are granted every supported claim.
+func (c Controller) UserInfo(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	payload := other[0].(*utils.Payload)
+
+	user, e := c.Store.Users.FindByID(r.Context(), *payload.Id)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid user token"}.Unauthorized())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the user"}.InternalServerError())
+		return
+	}
+
+	scopes := supportedScopes
+	if payload.IsClientToken() {
+		scopes = payload.Scopes()
+	}
+	claims := struct {
+		Subject string `json:"sub"`
+		models.UserClaims
+	}{user.Id.Hex(), user.Claims(scopes)}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(claims)
+}
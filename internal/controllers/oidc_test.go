@@ -0,0 +1,427 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// the example verifier and challenge of RFC 7636, Appendix B
+const (
+	codeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	codeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	redirectURI   = "https://photos.example.com/callback"
+)
+
+// registerClient registers a client, returning its id and its secret, which is empty for public clients
+func registerClient(public bool, t *testing.T) (string, string) {
+	w := httptest.NewRecorder()
+	body := `{"clientName":"Photo Blog","redirectUris":["` + redirectURI + `"],"public":` + strconv.FormatBool(public) + `}`
+	c.CreateClient(w, httptest.NewRequest("POST", "/api/v1/clients", strings.NewReader(body)), nil, generateAdminPayload())
+	checkStatusCode(w.Result(), 201, t)
+
+	data, _ := convertResponseToJson(w.Result()).Data.(map[string]interface{})
+	id, _ := data["clientId"].(string)
+	secret, _ := data["clientSecret"].(string)
+	if id == "" {
+		t.Fatalf("Should have returned the id of the client")
+	}
+	return id, secret
+}
+
+func authorizationParams(clientID string) url.Values {
+	return url.Values{
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURI},
+		"response_type":         {"code"},
+		"scope":                 {"openid email profile"},
+		"state":                 {"af0ifjsldkj"},
+		"nonce":                 {"n-0S6_WzA2Mj"},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+func authorize(params url.Values) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c.Authorize(w, httptest.NewRequest("GET", "/authorize?"+params.Encode(), nil), nil)
+	return w
+}
+
+// csrfToken is the token of the CSRF cookie that the forms of the authorization endpoint are posted along with
+const csrfToken = "Tzi3Ly5nvYfHF3eZ3Rcs0ZlAhqNQh7k2rJNrUfJqC8E"
+
+// postAuthorize posts a form of the authorization endpoint along with the parameters of an authorization request
+func postAuthorize(params url.Values, fields url.Values) *httptest.ResponseRecorder {
+	form := url.Values{"csrf_token": {csrfToken}}
+	for k, v := range params {
+		form[k] = v
+	}
+	for k, v := range fields {
+		form[k] = v
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/authorize", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(&http.Cookie{Name: csrfCookie, Value: csrfToken})
+	c.AuthorizeSignIn(w, r, nil)
+	return w
+}
+
+func authorizeSignIn(params url.Values, email string, password string) *httptest.ResponseRecorder {
+	return postAuthorize(params, url.Values{"email": {email}, "password": {password}})
+}
+
+var consentField = regexp.MustCompile(`name="consent_token" value="([A-Za-z0-9_-]+)"`)
+
+// consentToken returns the token of a rendered consent form
+func consentToken(w *httptest.ResponseRecorder, t *testing.T) string {
+	checkStatusCode(w.Result(), 200, t)
+	match := consentField.FindStringSubmatch(w.Body.String())
+	if match == nil {
+		t.Fatalf("Should have rendered the consent form rather than %v", w.Body.String())
+	}
+	return match[1]
+}
+
+func authorizeConsent(params url.Values, token string, decision string) *httptest.ResponseRecorder {
+	return postAuthorize(params, url.Values{"consent_token": {token}, "consent": {decision}})
+}
+
+// authorizationCode signs a user in through the authorization endpoint, allowing the client its scopes when the user
+// is asked to, and returns the code of the redirect
+func authorizationCode(clientID string, email string, t *testing.T) string {
+	return authorizationCodeOf(authorizationParams(clientID), email, t)
+}
+
+func authorizationCodeOf(params url.Values, email string, t *testing.T) string {
+	w := authorizeSignIn(params, email, "12345678")
+	if w.Code == 200 {
+		w = authorizeConsent(params, consentToken(w, t), "allow")
+	}
+	checkStatusCode(w.Result(), 302, t)
+
+	location, _ := url.Parse(w.Header().Get("Location"))
+	if location.Scheme+"://"+location.Host+location.Path != redirectURI {
+		t.Errorf("Should have redirected to %v rather than %v", redirectURI, location)
+	}
+	if state := location.Query().Get("state"); state != "af0ifjsldkj" {
+		t.Errorf("Should have returned the state of the request rather than %v", state)
+	}
+	code := location.Query().Get("code")
+	if code == "" {
+		t.Fatalf("Should have returned an authorization code")
+	}
+	return code
+}
+
+func exchangeToken(form url.Values, clientID string, secret string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if secret != "" {
+		r.SetBasicAuth(clientID, secret)
+	}
+	c.Token(w, r, nil)
+	return w
+}
+
+func exchangeCode(clientID string, secret string, code string, verifier string) *httptest.ResponseRecorder {
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {redirectURI}, "code_verifier": {verifier}}
+	if secret == "" {
+		form.Set("client_id", clientID)
+	}
+	return exchangeToken(form, clientID, secret)
+}
+
+func decodeTokenResponse(w *httptest.ResponseRecorder) tokenResponse {
+	var response tokenResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	return response
+}
+
+func checkTokenError(w *httptest.ResponseRecorder, status int, expected string, t *testing.T) {
+	checkStatusCode(w.Result(), status, t)
+	var response tokenError
+	json.NewDecoder(w.Body).Decode(&response)
+	if response.Code != expected {
+		t.Errorf("Should have returned an error of %v rather than %v", expected, response.Code)
+	}
+}
+
+func TestOpenIDConfiguration(t *testing.T) {
+	w := httptest.NewRecorder()
+	c.OpenIDConfiguration(w, httptest.NewRequest("GET", "/.well-known/openid-configuration", nil), nil)
+	checkStatusCode(w.Result(), 200, t)
+
+	var config openIDConfiguration
+	json.NewDecoder(w.Body).Decode(&config)
+	if config.Issuer != c.PublicURL || config.TokenEndpoint != c.PublicURL+"/token" || config.JWKSURI != c.PublicURL+"/.well-known/jwks.json" {
+		t.Errorf("Should have returned the endpoints of the issuer rather than %+v", config)
+	}
+	if len(config.CodeChallengeMethodsSupported) != 1 || config.CodeChallengeMethodsSupported[0] != "S256" {
+		t.Errorf("Should have only supported S256 challenges rather than %v", config.CodeChallengeMethodsSupported)
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	user := createUserWithPassword("lemmy", t)
+	clientID, _ := registerClient(true, t)
+
+	w := authorize(authorizationParams(clientID))
+	checkStatusCode(w.Result(), 200, t)
+	checkHeader(w, "X-Frame-Options", "DENY", t)
+	if !strings.Contains(w.Body.String(), "Sign in to Photo Blog") {
+		t.Errorf("Should have rendered the sign in form of the client")
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != csrfCookie || !cookies[0].HttpOnly || !strings.Contains(w.Body.String(), `name="csrf_token" value="`+cookies[0].Value+`"`) {
+		t.Errorf("Should have set the CSRF token of the form as a cookie rather than %v", cookies)
+	}
+
+	code := authorizationCode(clientID, user.Email, t)
+	w = exchangeCode(clientID, "", code, codeVerifier)
+	checkStatusCode(w.Result(), 200, t)
+	checkHeader(w, "Cache-Control", "no-store", t)
+	response := decodeTokenResponse(w)
+	if response.TokenType != "Bearer" || response.AccessToken == "" || response.RefreshToken == "" || response.Scope != "openid profile email" {
+		t.Errorf("Should have returned the tokens of the user rather than %+v", response)
+	}
+
+	var idToken utils.IDToken
+	if e := c.Utils.SigningKeys().Verify([]byte(response.IDToken), &idToken); e != nil {
+		t.Fatalf("Should have returned a valid ID token rather than %v", e)
+	}
+	if idToken.Subject != user.Id.Hex() || idToken.Issuer != c.PublicURL || len(idToken.Audience) != 1 || idToken.Audience[0] != clientID {
+		t.Errorf("Should have issued the ID token of the user to the client rather than %+v", idToken.Payload)
+	}
+	if idToken.Nonce != "n-0S6_WzA2Mj" || idToken.Email != user.Email || idToken.PreferredUsername != user.Username || idToken.AuthTime == 0 {
+		t.Errorf("Should have included the nonce and the claims of the user rather than %+v", idToken)
+	}
+
+	payload, ok := u.ParseToken([]byte(response.AccessToken))
+	if !ok {
+		t.Fatalf("Should have returned a valid access token")
+	}
+	if !payload.IsClientToken() || payload.Audience[0] != clientID || payload.Scope != "openid profile email" {
+		t.Errorf("Should have issued an access token of the client and its scopes rather than %+v", payload)
+	}
+	w = httptest.NewRecorder()
+	c.UserInfo(w, httptest.NewRequest("GET", "/userinfo", nil), nil, payload)
+	checkStatusCode(w.Result(), 200, t)
+	var claims map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&claims)
+	if claims["sub"] != user.Id.Hex() || claims["email"] != user.Email || claims["email_verified"] != false {
+		t.Errorf("Should have returned the claims of the user rather than %v", claims)
+	}
+
+	// the code is replayed, which revokes the refresh token that was issued for it
+	checkTokenError(exchangeCode(clientID, "", code, codeVerifier), 400, "invalid_grant", t)
+	refresh := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {response.RefreshToken}, "client_id": {clientID}}
+	checkTokenError(exchangeToken(refresh, clientID, ""), 400, "invalid_grant", t)
+}
+
+func TestUserInfoOfScopes(t *testing.T) {
+	user := createUserWithPassword("phil", t)
+	clientID, _ := registerClient(true, t)
+
+	params := authorizationParams(clientID)
+	params.Set("scope", "openid email")
+	response := decodeTokenResponse(exchangeCode(clientID, "", authorizationCodeOf(params, user.Email, t), codeVerifier))
+
+	payload, ok := u.ParseToken([]byte(response.AccessToken))
+	if !ok {
+		t.Fatalf("Should have returned a valid access token")
+	}
+	w := httptest.NewRecorder()
+	c.UserInfo(w, httptest.NewRequest("GET", "/userinfo", nil), nil, payload)
+	checkStatusCode(w.Result(), 200, t)
+	var claims map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&claims)
+	if claims["email"] != user.Email || claims["preferred_username"] != nil {
+		t.Errorf("Should have only returned the claims of the granted scopes rather than %v", claims)
+	}
+}
+
+func TestAuthorizeInvalidRequests(t *testing.T) {
+	clientID, _ := registerClient(true, t)
+
+	unknown := authorizationParams("5db5b5b06507b38887bedc87")
+	checkStatusCode(authorize(unknown).Result(), 400, t)
+	unregistered := authorizationParams(clientID)
+	unregistered.Set("redirect_uri", "https://attacker.example.com/callback")
+	checkStatusCode(authorize(unregistered).Result(), 400, t)
+
+	for expected, change := range map[string]func(url.Values){
+		"invalid_request":           func(p url.Values) { p.Del("code_challenge") },
+		"invalid_scope":             func(p url.Values) { p.Set("scope", "email") },
+		"unsupported_response_type": func(p url.Values) { p.Set("response_type", "token") },
+	} {
+		params := authorizationParams(clientID)
+		change(params)
+		w := authorize(params)
+		checkStatusCode(w.Result(), 302, t)
+		location, _ := url.Parse(w.Header().Get("Location"))
+		if e := location.Query().Get("error"); e != expected || location.Query().Get("state") != "af0ifjsldkj" {
+			t.Errorf("Should have redirected with an error of %v rather than %v", expected, location)
+		}
+	}
+}
+
+func TestAuthorizeSignInWithInvalidPassword(t *testing.T) {
+	user := createUserWithPassword("dio", t)
+	clientID, _ := registerClient(true, t)
+
+	w := authorizeSignIn(authorizationParams(clientID), user.Email, "wrongpassword")
+	checkStatusCode(w.Result(), 401, t)
+	if !strings.Contains(w.Body.String(), "Invalid email or password") {
+		t.Errorf("Should have rendered the form along with the error")
+	}
+}
+
+func TestAuthorizeSignInWithoutCSRFToken(t *testing.T) {
+	user := createUserWithPassword("cliff", t)
+	clientID, _ := registerClient(true, t)
+
+	form := authorizationParams(clientID)
+	form.Set("email", user.Email)
+	form.Set("password", "12345678")
+	form.Set("csrf_token", csrfToken)
+	for _, cookie := range []*http.Cookie{nil, {Name: csrfCookie, Value: "another"}} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/authorize", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		c.AuthorizeSignIn(w, r, nil)
+		checkStatusCode(w.Result(), 403, t)
+	}
+}
+
+func TestAuthorizeConsent(t *testing.T) {
+	user := createUserWithPassword("tony", t)
+	clientID, _ := registerClient(true, t)
+	params := authorizationParams(clientID)
+	params.Set("scope", "openid email")
+
+	w := authorizeSignIn(params, user.Email, "12345678")
+	token := consentToken(w, t)
+	if body := w.Body.String(); !strings.Contains(body, "Allow Photo Blog") || !strings.Contains(body, "See your email address") || strings.Contains(body, "See your username") {
+		t.Errorf("Should have listed the requested scopes rather than %v", body)
+	}
+	w = authorizeConsent(params, token, "deny")
+	checkStatusCode(w.Result(), 302, t)
+	if location, _ := url.Parse(w.Header().Get("Location")); location.Query().Get("error") != "access_denied" {
+		t.Errorf("Should have redirected a denied consent with an error rather than %v", location)
+	}
+
+	// a denied consent is not recorded, while a consent token can be used once
+	token = consentToken(authorizeSignIn(params, user.Email, "12345678"), t)
+	checkStatusCode(authorizeConsent(params, token, "allow").Result(), 302, t)
+	checkStatusCode(authorizeConsent(params, token, "allow").Result(), 401, t)
+
+	// the allowed scopes are not asked again, unlike other ones
+	checkStatusCode(authorizeSignIn(params, user.Email, "12345678").Result(), 302, t)
+	consentToken(authorizeSignIn(authorizationParams(clientID), user.Email, "12345678"), t)
+}
+
+func TestTokenWithInvalidCodeVerifier(t *testing.T) {
+	user := createUserWithPassword("axl", t)
+	clientID, _ := registerClient(true, t)
+
+	code := authorizationCode(clientID, user.Email, t)
+	checkTokenError(exchangeCode(clientID, "", code, strings.Repeat("a", 43)), 400, "invalid_grant", t)
+}
+
+// unavailableAuthCodes is an authorization code store that fails to mark the codes as used
+type unavailableAuthCodes struct {
+	store.AuthorizationCodeStore
+}
+
+func (unavailableAuthCodes) MarkUsed(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	return false, errors.New("unavailable")
+}
+
+func TestTokenWhenCodeCanNotBeMarked(t *testing.T) {
+	user := createUserWithPassword("duff", t)
+	clientID, _ := registerClient(true, t)
+	code := authorizationCode(clientID, user.Email, t)
+
+	authCodes := c.Store.AuthCodes
+	c.Store.AuthCodes = unavailableAuthCodes{authCodes}
+	w := exchangeCode(clientID, "", code, codeVerifier)
+	c.Store.AuthCodes = authCodes
+	checkTokenError(w, 500, "server_error", t)
+
+	// the code was not redeemed, so it can still be exchanged
+	checkStatusCode(exchangeCode(clientID, "", code, codeVerifier).Result(), 200, t)
+}
+
+func TestTokenOfConfidentialClient(t *testing.T) {
+	user := createUserWithPassword("slash", t)
+	clientID, secret := registerClient(false, t)
+	if secret == "" {
+		t.Fatalf("Should have returned the secret of a confidential client")
+	}
+
+	code := authorizationCode(clientID, user.Email, t)
+	checkTokenError(exchangeCode(clientID, "", code, codeVerifier), 401, "invalid_client", t)
+	checkTokenError(exchangeCode(clientID, "wrongsecret", code, codeVerifier), 401, "invalid_client", t)
+
+	w := exchangeCode(clientID, secret, code, codeVerifier)
+	checkStatusCode(w.Result(), 200, t)
+	response := decodeTokenResponse(w)
+
+	refresh := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {response.RefreshToken}}
+	w = exchangeToken(refresh, clientID, secret)
+	checkStatusCode(w.Result(), 200, t)
+	refreshed := decodeTokenResponse(w)
+	if payload, ok := u.ParseToken([]byte(refreshed.AccessToken)); !ok || payload.Scope != response.Scope || refreshed.Scope != response.Scope {
+		t.Errorf("Should have kept the scopes of the client on a refresh rather than %+v", refreshed)
+	}
+	rotated := refreshed.RefreshToken
+	if rotated == "" || rotated == response.RefreshToken {
+		t.Errorf("Should have returned a rotated refresh token rather than %v", rotated)
+	}
+
+	// the refresh tokens of a client can not be exchanged by the refresh endpoint of the API
+	w = httptest.NewRecorder()
+	body := []byte(`{"refreshToken":"` + rotated + `"}`)
+	c.RefreshToken(w, httptest.NewRequest("POST", "/api/v1/users/token/refresh", bytes.NewBuffer(body)), nil)
+	checkStatusCode(w.Result(), 401, t)
+}
+
+func TestDeleteClient(t *testing.T) {
+	user := createUserWithPassword("ringo", t)
+	clientID, _ := registerClient(true, t)
+	authorizationCode(clientID, user.Email, t)
+
+	w := httptest.NewRecorder()
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: clientID}}
+	c.DeleteClient(w, httptest.NewRequest("DELETE", "/api/v1/clients/"+clientID, nil), params, generateAdminPayload())
+	checkStatusCode(w.Result(), 204, t)
+
+	// the authorization requests of the client are no longer accepted
+	checkStatusCode(authorize(authorizationParams(clientID)).Result(), 400, t)
+	id, _ := primitive.ObjectIDFromHex(clientID)
+	if _, e := c.Store.Consents.Find(context.Background(), *user.Id, id); e != store.ErrNotFound {
+		t.Errorf("Should have removed the consents to the client rather than %v", e)
+	}
+}
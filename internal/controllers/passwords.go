@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/mail"
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// passwordResetMaxAge is the lifetime of a password reset token
+const passwordResetMaxAge = 30 * time.Minute
+
+// passwordResetTimeout bounds the lookup and the delivery of a password reset email
+const passwordResetTimeout = time.Minute
+
+// sendPasswordResetEmail issues a password reset token and mails it to the user
+func (c Controller) sendPasswordResetEmail(ctx context.Context, user *models.User) error {
+	token, ok := c.issueUserToken(ctx, user, models.TokenPasswordReset, passwordResetMaxAge)
+	if !ok {
+		return errors.New("unable to issue a password reset token")
+	}
+
+	body := fmt.Sprintf("Hi %v,\n\nA password reset was requested for your account. Your password reset token is:\n\ntoken=%v\n\n"+
+		"Send it to POST %v/api/v1/users/password/reset along with your new password. The token expires in %v.\n\n"+
+		"If you did not ask for a password reset, you can ignore this email.\n", user.Username, token, c.PublicURL, passwordResetMaxAge)
+	return c.Mailer.Send(ctx, mail.Message{To: user.Email, Subject: "Reset your password", Body: body})
+}
+
+// ForgotPassword is used to mail a password reset token to a user. The response is the same whether the email belongs
+// to a user or not, so that the accounts can not be enumerated. The user is looked up and mailed once the response is
+// written, so both cases take the same time as well.
+func (c Controller) ForgotPassword(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	var body models.ForgotPassword
+	json.NewDecoder(r.Body).Decode(&body)
+
+	go c.mailPasswordReset(body.Email)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(202)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "If the email belongs to an account, a password reset token has been sent"}.Accepted())
+}
+
+// mailPasswordReset mails a password reset token to the user of an email, if there is one. It outlives the request
+// that asked for it, so it is bounded by passwordResetTimeout instead.
+func (c Controller) mailPasswordReset(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), passwordResetTimeout)
+	defer cancel()
+
+	user, e := c.Store.Users.FindByEmail(ctx, email)
+	if e == store.ErrNotFound {
+		return
+	}
+	if e != nil {
+		log.Printf("Unable to fetch the user of a password reset: %v", e)
+		return
+	}
+	if e := c.sendPasswordResetEmail(ctx, user); e != nil {
+		log.Printf("Unable to send the password reset email to %v: %v", user.Id.Hex(), e)
+	}
+}
+
+// ResetPassword is used to set a new password with a mailed password reset token. Every token can be used once, while
+// the outstanding tokens of the user are revoked, so any session started with the old password ends.
+func (c Controller) ResetPassword(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	var body models.ResetPassword
+	json.NewDecoder(r.Body).Decode(&body)
+
+	ut, ok, e := c.useUserToken(r.Context(), body.Token, models.TokenPasswordReset)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the token"}.InternalServerError())
+		return
+	}
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid token"}.BadRequest())
+		return
+	}
+
+	user, e := c.Store.Users.FindByID(r.Context(), ut.UserId)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid token"}.BadRequest())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the user"}.InternalServerError())
+		return
+	}
+	if user.Email != ut.Email {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid token"}.BadRequest())
+		return
+	}
+
+	// receiving the token proves the ownership of the email as well
+	fields := map[string]interface{}{"password": c.Utils.HashPassword(body.Password), "emailVerified": true}
+	if _, e := c.Store.Users.Update(r.Context(), ut.UserId, fields); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the user"}.InternalServerError())
+		return
+	}
+	if !c.revokeUserTokens(r.Context(), ut.UserId) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to revoke the user tokens"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful reset"}.Ok())
+}
+
+// ChangePassword is used by a user to change his/her password. The current password is required, so that a stolen
+// token can not be used to take over the account, while every outstanding token of the user is revoked.
+func (c Controller) ChangePassword(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	var body models.PasswordChange
+	id := p.ByName("id")
+	payload := other[0].(*utils.Payload)
+
+	if id == "" {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid target resource"}.BadRequest())
+		return
+	}
+	// an admin does not know the current password of another user, who is expected to reset it instead
+	if payload.Id.Hex() != id {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid operation for the existing user"}.Forbidden())
+		return
+	}
+
+	json.NewDecoder(r.Body).Decode(&body)
+	user, e := c.Store.Users.FindByID(r.Context(), *payload.Id)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "User does not exists"}.NotFound())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the user"}.InternalServerError())
+		return
+	}
+	if !user.ComparePassword(body.CurrentPassword) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Password"}.Forbidden())
+		return
+	}
+
+	if _, e := c.Store.Users.Update(r.Context(), *user.Id, map[string]interface{}{"password": c.Utils.HashPassword(body.NewPassword)}); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the user"}.InternalServerError())
+		return
+	}
+	if !c.revokeUserTokens(r.Context(), *user.Id) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to revoke the user tokens"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful password change"}.Ok())
+}
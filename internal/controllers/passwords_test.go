@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/utils"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func forgotPassword(email string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c.ForgotPassword(w, httptest.NewRequest("POST", "/api/v1/users/password/forgot", strings.NewReader(`{"email":"`+email+`"}`)), nil)
+	return w
+}
+
+// forgotPasswordToken asks for the password reset of an existing user and returns the mailed token
+func forgotPasswordToken(email string, t *testing.T) string {
+	sent := mailer.count()
+	checkStatusCode(forgotPassword(email).Result(), 202, t)
+	mailer.waitFor(sent+1, t)
+	return mailer.lastToken(email, t)
+}
+
+func resetPassword(token string, password string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	body := `{"token":"` + token + `","password":"` + password + `"}`
+	c.ResetPassword(w, httptest.NewRequest("POST", "/api/v1/users/password/reset", strings.NewReader(body)), nil)
+	return w
+}
+
+func trySignIn(email string, password string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	body := `{"email":"` + email + `","password":"` + password + `"}`
+	c.SignIn(w, httptest.NewRequest("POST", "/api/v1/users/signin", strings.NewReader(body)), nil)
+	return w
+}
+
+// createUserWithPassword creates a user that can sign in, since the password is hashed by the middleware rather than by
+// CreateUser
+func createUserWithPassword(name string, t *testing.T) *models.User {
+	user, _ := c.Store.Users.FindByEmail(context.Background(), createUser(name, t)["email"].(string))
+	user, e := c.Store.Users.Update(context.Background(), *user.Id, map[string]interface{}{"password": c.Utils.HashPassword("12345678")})
+	if e != nil {
+		t.Fatalf("Unable to hash the password of the user: %v", e)
+	}
+	return user
+}
+
+func changePassword(id string, body string, payload *utils.Payload) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/api/v1/users/"+id+"/password", strings.NewReader(body))
+	c.ChangePassword(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: id}}, payload)
+	return w
+}
+
+func TestResetPassword(t *testing.T) {
+	user := createUserWithPassword("mick", t)
+	refreshToken := signIn(user.Email, "12345678", t)
+	issuedAt := time.Now().Add(-time.Second)
+
+	token := forgotPasswordToken(user.Email, t)
+
+	checkStatusCode(resetPassword(token, "87654321").Result(), 200, t)
+	checkStatusCode(trySignIn(user.Email, "12345678").Result(), 401, t)
+	checkStatusCode(trySignIn(user.Email, "87654321").Result(), 200, t)
+
+	// the sessions that were started with the old password have ended
+	checkStatusCode(refresh(refreshToken).Result(), 401, t)
+	if revoked, _ := c.Store.Revocations.IsRevoked(context.Background(), "", *user.Id, issuedAt); !revoked {
+		t.Errorf("Should have revoked the access tokens of the user")
+	}
+
+	// a token can only be used once
+	checkStatusCode(resetPassword(token, "11111111").Result(), 400, t)
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	sent := mailer.count()
+
+	w := forgotPassword("nobody@gmail.com")
+	checkStatusCode(w.Result(), 202, t)
+	if known := forgotPassword("john@gmail.com"); known.Body.String() != w.Body.String() {
+		t.Errorf("Should have returned the same response for a known email rather than %v", known.Body.String())
+	}
+
+	mailer.waitFor(sent+1, t)
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+	if len(mailer.messages) != sent+1 || mailer.messages[sent].To != "john@gmail.com" {
+		t.Errorf("Should have only mailed the known user rather than %v", mailer.messages[sent:])
+	}
+}
+
+func TestResetPasswordReplacesOutstandingToken(t *testing.T) {
+	email := createUser("charlie", t)["email"].(string)
+	first := forgotPasswordToken(email, t)
+	second := forgotPasswordToken(email, t)
+
+	checkStatusCode(resetPassword(first, "87654321").Result(), 400, t)
+	checkStatusCode(resetPassword(second, "87654321").Result(), 200, t)
+}
+
+func TestChangePassword(t *testing.T) {
+	user := createUserWithPassword("jimmy", t)
+	payload := generateUserPayload(*user)
+	refreshToken := signIn(user.Email, "12345678", t)
+
+	checkStatusCode(changePassword(user.Id.Hex(), `{"currentPassword":"wrongpassword","newPassword":"87654321"}`, payload).Result(), 403, t)
+	checkStatusCode(changePassword(user.Id.Hex(), `{"currentPassword":"12345678","newPassword":"87654321"}`, payload).Result(), 200, t)
+
+	checkStatusCode(trySignIn(user.Email, "12345678").Result(), 401, t)
+	checkStatusCode(trySignIn(user.Email, "87654321").Result(), 200, t)
+	checkStatusCode(refresh(refreshToken).Result(), 401, t)
+}
+
+func TestChangePasswordOfAnotherUser(t *testing.T) {
+	body := `{"currentPassword":"12345678","newPassword":"87654321"}`
+	checkStatusCode(changePassword(payloads[1].Id.Hex(), body, payloads[0]).Result(), 403, t)
+	checkStatusCode(changePassword(payloads[1].Id.Hex(), body, generateAdminPayload()).Result(), 403, t)
+}
+
+func TestUpdateUserRejectsPassword(t *testing.T) {
+	user := createUserWithPassword("bill", t)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/api/v1/users/"+user.Id.Hex(), strings.NewReader(`{"username":"bill2","password":"87654321"}`))
+	c.UpdateUser(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: user.Id.Hex()}}, generateUserPayload(*user))
+	checkStatusCode(w.Result(), 400, t)
+
+	checkStatusCode(trySignIn(user.Email, "12345678").Result(), 200, t)
+}
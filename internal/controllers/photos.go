@@ -0,0 +1,395 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/media"
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxPhotoSize is the maximum size of an uploaded photo in bytes
+const maxPhotoSize = 10 << 20
+
+// maxFormOverhead is the size allowed for the rest of the fields of a multipart/form-data body
+const maxFormOverhead = 1 << 20
+
+// renditionMaxAge is the time, in seconds, that clients cache a rendition. Renditions never change once generated.
+const renditionMaxAge = 365 * 24 * 60 * 60
+
+// UploadPhoto is used to store a photo of the authenticated user. The photo is sent as the "photo" field of a
+// multipart/form-data body, while its type is detected from its content. The GPS coordinates found in its EXIF metadata
+// are only shared when the "shareLocation" field is true.
+func (c Controller) UploadPhoto(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	payload := other[0].(*utils.Payload)
+
+	if r.ContentLength > maxPhotoSize+maxFormOverhead {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The photo exceeds the maximum size"}.PayloadTooLarge())
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxPhotoSize+maxFormOverhead)
+	if e := r.ParseMultipartForm(maxFormOverhead); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid multipart/form-data body"}.BadRequest())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, e := r.FormFile("photo")
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "A photo is required"}.BadRequest())
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxPhotoSize {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The photo exceeds the maximum size"}.PayloadTooLarge())
+		return
+	}
+
+	b, e := ioutil.ReadAll(file)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to read the photo"}.InternalServerError())
+		return
+	}
+	contentType, ok := media.DetectContentType(b)
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Only JPEG, PNG, WebP and GIF photos are supported"}.UnsupportedMediaType())
+		return
+	}
+
+	tags, ok := models.NormalizeTags(strings.Split(r.FormValue("tags"), ","))
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Tags"}.BadRequest())
+		return
+	}
+
+	id, now := primitive.NewObjectID(), time.Now()
+	photo := models.Photo{
+		Id:              &id,
+		OwnerId:         *payload.Id,
+		Caption:         r.FormValue("caption"),
+		Filename:        filepath.Base(header.Filename),
+		ContentType:     contentType,
+		Size:            header.Size,
+		Key:             media.BlobKey(id, "original"),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		RenditionStatus: models.RenditionsPending,
+		Exif:            media.ParseExif(b, contentType),
+		ShareLocation:   r.FormValue("shareLocation") == "true",
+		Tags:            tags,
+	}
+
+	if e := c.Store.Blobs.Put(r.Context(), photo.Key, bytes.NewReader(b)); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to store the photo"}.InternalServerError())
+		return
+	}
+	if _, e := c.Store.Photos.Insert(r.Context(), photo); e != nil {
+		c.Store.Blobs.Delete(r.Context(), photo.Key)
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to store the photo"}.InternalServerError())
+		return
+	}
+	c.adjustTags(r.Context(), photo.CountedTags(), nil)
+	c.indexPhoto(r.Context(), &photo)
+	// a photo that can not be queued remains pending and is queued again once the workers catch up
+	if e := c.Renditions.Submit(id); e != nil {
+		log.Printf("Unable to queue the renditions of photo %v: %v", id.Hex(), e)
+	}
+
+	w.Header().Set("Location", strings.Join([]string{r.URL.Path, id.Hex()}, "/"))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful creation", Data: photo}.Created())
+}
+
+// GetPhoto is used to return the document of a photo. The GPS coordinates are only returned to the owner, unless they
+// have opted in to share them.
+func (c Controller) GetPhoto(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	payload := other[0].(*utils.Payload)
+	id := p.ByName("id")
+	if id == "" {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid target resource"}.BadRequest())
+		return
+	}
+
+	oid, _ := primitive.ObjectIDFromHex(id)
+	photo, e := c.Store.Photos.FindByID(r.Context(), oid)
+	if e == store.ErrNotFound || (e == nil && !photo.IsVisibleTo(*payload.Id, payload.IsAdmin())) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Photo does not exists"}.NotFound())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the photo"}.InternalServerError())
+		return
+	}
+	if photo.Liked, e = c.isLiked(r.Context(), payload, models.TargetPhoto, oid); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the likes"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	if payload.CanAccess(photo.OwnerId.Hex()) {
+		json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful fetch", Data: photo}.Ok())
+	} else {
+		json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful fetch", Data: photo.Public()}.Ok())
+	}
+}
+
+// UpdatePhoto is used by the owner of a photo to edit its caption and whether its location is shared
+func (c Controller) UpdatePhoto(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	var body models.PhotoUpdate
+	payload := other[0].(*utils.Payload)
+	id := p.ByName("id")
+	if id == "" {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid target resource"}.BadRequest())
+		return
+	}
+
+	oid, _ := primitive.ObjectIDFromHex(id)
+	photo, e := c.Store.Photos.FindByID(r.Context(), oid)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Photo does not exists"}.NotFound())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the photo"}.InternalServerError())
+		return
+	}
+	if !payload.CanAccess(photo.OwnerId.Hex()) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid operation for the existing photo"}.Forbidden())
+		return
+	}
+
+	json.NewDecoder(r.Body).Decode(&body)
+	fields := body.Fields()
+	if len(fields) == 0 || !body.Validate() {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Request Body"}.BadRequest())
+		return
+	}
+	fields["updatedAt"] = time.Now()
+
+	counted := photo.CountedTags()
+	photo, e = c.Store.Photos.Update(r.Context(), oid, fields)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the photo"}.InternalServerError())
+		return
+	}
+	added, removed := models.DiffTags(counted, photo.CountedTags())
+	c.adjustTags(r.Context(), added, removed)
+	c.indexPhoto(r.Context(), photo)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful update", Data: photo}.Ok())
+}
+
+// DeletePhoto is used by the owner of a photo or an admin to delete it. The photo is removed from its albums, the tag
+// counts and the search index, along with its comments, likes, original and renditions.
+func (c Controller) DeletePhoto(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	payload := other[0].(*utils.Payload)
+	id := p.ByName("id")
+	if id == "" {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid target resource"}.BadRequest())
+		return
+	}
+
+	oid, _ := primitive.ObjectIDFromHex(id)
+	photo, e := c.Store.Photos.FindByID(r.Context(), oid)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Photo does not exists"}.NotFound())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the photo"}.InternalServerError())
+		return
+	}
+	if !payload.CanAccess(photo.OwnerId.Hex()) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid operation for the existing photo"}.Forbidden())
+		return
+	}
+
+	e = c.Store.Photos.Delete(r.Context(), oid)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Photo does not exists"}.NotFound())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to delete the photo"}.InternalServerError())
+		return
+	}
+	c.adjustTags(r.Context(), nil, photo.CountedTags())
+	c.unindex(r.Context(), store.SearchPhotos, oid)
+	if e := c.Store.Comments.DeleteByTarget(r.Context(), models.TargetPhoto, oid); e != nil {
+		log.Printf("Unable to delete the comments of photo %v: %v", id, e)
+	}
+	if e := c.Store.Likes.DeleteByTarget(r.Context(), models.TargetPhoto, oid); e != nil {
+		log.Printf("Unable to delete the likes of photo %v: %v", id, e)
+	}
+	c.removeAlbumPhoto(r.Context(), oid)
+	c.deletePhotoBlobs(r.Context(), photo)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(204)
+}
+
+// removeAlbumPhoto removes a deleted photo from the albums that contain it. Failures are logged, since the photo has
+// already been deleted.
+func (c Controller) removeAlbumPhoto(ctx context.Context, photoID primitive.ObjectID) {
+	albums, e := c.Store.Albums.ListByPhoto(ctx, photoID)
+	if e != nil {
+		log.Printf("Unable to fetch the albums of photo %v: %v", photoID.Hex(), e)
+		return
+	}
+	for _, album := range albums {
+		if _, e := c.Store.Albums.RemovePhoto(ctx, *album.Id, photoID); e != nil {
+			log.Printf("Unable to remove photo %v from album %v: %v", photoID.Hex(), album.Id.Hex(), e)
+		}
+	}
+}
+
+// deletePhotoBlobs removes the original and the renditions of a deleted photo. Every rendition is removed, since the
+// renditions of a pending photo may have been stored without being listed yet.
+func (c Controller) deletePhotoBlobs(ctx context.Context, photo *models.Photo) {
+	keys := []string{photo.Key}
+	for _, rendition := range media.Renditions {
+		keys = append(keys, media.BlobKey(*photo.Id, rendition.Name))
+	}
+	for _, key := range keys {
+		if e := c.Store.Blobs.Delete(ctx, key); e != nil && e != store.ErrNotFound {
+			log.Printf("Unable to delete the blob %v of photo %v: %v", key, photo.Id.Hex(), e)
+		}
+	}
+}
+
+// findServedPhoto fetches the photo of the :id route parameter for the routes that serve its bytes, and writes the
+// error response when it can not be found. These routes do not require a user token, so private photos are only served
+// to their owner and admins when they send one along.
+func (c Controller) findServedPhoto(w http.ResponseWriter, r *http.Request, p httprouter.Params, other []interface{}) (*models.Photo, bool) {
+	oid, _ := primitive.ObjectIDFromHex(p.ByName("id"))
+	photo, e := c.Store.Photos.FindByID(r.Context(), oid)
+	if e == nil && photo.Private {
+		payload, ok := optionalPayload(other)
+		if !ok || !photo.IsVisibleTo(*payload.Id, payload.IsAdmin()) {
+			e = store.ErrNotFound
+		}
+	}
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Photo does not exists"}.NotFound())
+		return nil, false
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the photo"}.InternalServerError())
+		return nil, false
+	}
+	return photo, true
+}
+
+// optionalPayload returns the payload of a route whose user token is optional
+func optionalPayload(other []interface{}) (*utils.Payload, bool) {
+	if len(other) == 0 {
+		return nil, false
+	}
+	payload, ok := other[0].(*utils.Payload)
+	return payload, ok && payload != nil
+}
+
+// cacheScope returns the scope of the Cache-Control header of the bytes of a photo. Private photos are never kept by
+// shared caches.
+func cacheScope(photo *models.Photo) string {
+	if photo.Private {
+		return "private"
+	}
+	return "public"
+}
+
+// GetPhotoOriginal is used to serve the original of a photo. Its GPS metadata are removed, unless the owner has opted
+// in to share the location of the photo.
+func (c Controller) GetPhotoOriginal(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	id := p.ByName("id")
+	if id == "" {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid target resource"}.BadRequest())
+		return
+	}
+	photo, ok := c.findServedPhoto(w, r, p, other)
+	if !ok {
+		return
+	}
+
+	rc, e := c.Store.Blobs.Get(r.Context(), photo.Key)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the photo"}.InternalServerError())
+		return
+	}
+	defer rc.Close()
+	b, e := ioutil.ReadAll(rc)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the photo"}.InternalServerError())
+		return
+	}
+	if !photo.ShareLocation {
+		b = media.StripLocation(b, photo.ContentType)
+	}
+
+	w.Header().Set("Content-Type", photo.ContentType)
+	// the owner may change whether the location is shared, so clients revalidate their copy
+	w.Header().Set("Cache-Control", cacheScope(photo)+", no-cache")
+	w.Header().Set("ETag", fmt.Sprintf(`"%s-original-%t"`, id, photo.ShareLocation))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// the served bytes change along with the photo, so it is last modified when the photo was last updated
+	http.ServeContent(w, r, "", photo.UpdatedAt, bytes.NewReader(b))
+}
+
+// GetPhotoRendition is used to serve a rendition of a photo. Renditions are immutable, so they are cached by clients.
+func (c Controller) GetPhotoRendition(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	id := p.ByName("id")
+	size := p.ByName("size")
+	if id == "" || size == "" {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid target resource"}.BadRequest())
+		return
+	}
+	photo, ok := c.findServedPhoto(w, r, p, other)
+	if !ok {
+		return
+	}
+
+	rendition, ok := photo.FindRendition(size)
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Rendition does not exists"}.NotFound())
+		return
+	}
+
+	rc, e := c.Store.Blobs.Get(r.Context(), rendition.Key)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the rendition"}.InternalServerError())
+		return
+	}
+	defer rc.Close()
+	b, e := ioutil.ReadAll(rc)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the rendition"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", rendition.ContentType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d, immutable", cacheScope(photo), renditionMaxAge))
+	w.Header().Set("ETag", fmt.Sprintf(`"%s-%s-%d"`, id, rendition.Name, rendition.Size))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// ServeContent handles conditional and range requests
+	http.ServeContent(w, r, "", photo.CreatedAt, bytes.NewReader(b))
+}
@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/media"
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/utils"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func encodePNG(width int, height int) []byte {
+	var bf bytes.Buffer
+	png.Encode(&bf, image.NewRGBA(image.Rect(0, 0, width, height)))
+	return bf.Bytes()
+}
+
+func newUploadRequest(field string, filename string, content []byte) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("caption", "Sunset")
+	fw, _ := mw.CreateFormFile(field, filename)
+	fw.Write(content)
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/api/v1/photos", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestUploadPhoto(t *testing.T) {
+	w := httptest.NewRecorder()
+	c.UploadPhoto(w, newUploadRequest("photo", "sunset.png", encodePNG(4, 4)), nil, payloads[0])
+
+	res := w.Result()
+	checkStatusCode(res, 201, t)
+	checkHeader(w, "Content-Type", "application/json", t)
+
+	photo := convertResponseToJson(res).Data.(map[string]interface{})
+	checkJSON(photo, []Check{
+		Check{Key: "ownerId", Expected: payloads[0].Id.Hex()},
+		Check{Key: "caption", Expected: "Sunset"},
+		Check{Key: "filename", Expected: "sunset.png"},
+		Check{Key: "contentType", Expected: "image/png"},
+	}, t)
+	if loc := w.Header().Get("Location"); loc != "/api/v1/photos/"+photo["id"].(string) {
+		t.Errorf("Should have returned a Location header for the photo rather than %v", loc)
+	}
+
+	oid, _ := primitive.ObjectIDFromHex(photo["id"].(string))
+	rc, e := c.Store.Blobs.Get(context.Background(), media.BlobKey(oid, "original"))
+	if e != nil {
+		t.Fatalf("Should have stored the photo in the blob store rather than returning %v", e)
+	}
+	defer rc.Close()
+	if b, _ := ioutil.ReadAll(rc); !bytes.Equal(b, encodePNG(4, 4)) {
+		t.Errorf("Should have stored the bytes of the photo")
+	}
+}
+
+func TestUploadPhotoUnsupportedContent(t *testing.T) {
+	w := httptest.NewRecorder()
+	c.UploadPhoto(w, newUploadRequest("photo", "photo.png", []byte("<script>alert(1)</script>")), nil, payloads[0])
+
+	res := w.Result()
+	checkStatusCode(res, 415, t)
+	if response := convertResponseToJson(res); response.Success {
+		t.Errorf("Should return a success of %v rather than %v", false, response.Success)
+	}
+}
+
+func TestUploadPhotoWithoutPhoto(t *testing.T) {
+	w := httptest.NewRecorder()
+	c.UploadPhoto(w, newUploadRequest("file", "sunset.png", encodePNG(4, 4)), nil, payloads[0])
+
+	checkStatusCode(w.Result(), 400, t)
+}
+
+func TestUploadPhotoTooLarge(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := newUploadRequest("photo", "sunset.png", encodePNG(4, 4))
+	r.ContentLength = maxPhotoSize + maxFormOverhead + 1
+	c.UploadPhoto(w, r, nil, payloads[0])
+
+	checkStatusCode(w.Result(), 413, t)
+}
+
+func TestGetPhoto(t *testing.T) {
+	w := httptest.NewRecorder()
+	c.UploadPhoto(w, newUploadRequest("photo", "sunset.png", encodePNG(4, 4)), nil, payloads[0])
+	id := convertResponseToJson(w.Result()).Data.(map[string]interface{})["id"].(string)
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/photos/"+id, nil)
+	c.GetPhoto(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: id}}, payloads[0])
+	checkStatusCode(w.Result(), 200, t)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/v1/photos/5db5b5b06507b38887bedc99", nil)
+	c.GetPhoto(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: "5db5b5b06507b38887bedc99"}}, payloads[0])
+	checkStatusCode(w.Result(), 404, t)
+}
+
+func TestGetPhotoRendition(t *testing.T) {
+	w := httptest.NewRecorder()
+	c.UploadPhoto(w, newUploadRequest("photo", "sunset.png", encodePNG(300, 200)), nil, payloads[0])
+	id := convertResponseToJson(w.Result()).Data.(map[string]interface{})["id"].(string)
+	oid, _ := primitive.ObjectIDFromHex(id)
+	c.Renditions.Process(context.Background(), oid)
+
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: id}, httprouter.Param{Key: "size", Value: "thumbnail"}}
+	w = httptest.NewRecorder()
+	c.GetPhotoRendition(w, httptest.NewRequest("GET", "/api/v1/photos/"+id+"/renditions/thumbnail", nil), params)
+
+	res := w.Result()
+	checkStatusCode(res, 200, t)
+	checkHeader(w, "Content-Type", "image/png", t)
+	checkHeader(w, "Cache-Control", "public, max-age=31536000, immutable", t)
+	img, e := png.Decode(res.Body)
+	if e != nil || img.Bounds().Dx() != 150 || img.Bounds().Dy() != 150 {
+		t.Errorf("Should have returned a 150x150 thumbnail rather than %v (%v)", img, e)
+	}
+
+	// a client with a cached copy receives an HTTP/x.x 304 Not Modified
+	w2 := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/photos/"+id+"/renditions/thumbnail", nil)
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	c.GetPhotoRendition(w2, r, params)
+	checkStatusCode(w2.Result(), 304, t)
+}
+
+func TestGetPhotoUnknownRendition(t *testing.T) {
+	w := httptest.NewRecorder()
+	c.UploadPhoto(w, newUploadRequest("photo", "sunset.png", encodePNG(4, 4)), nil, payloads[0])
+	id := convertResponseToJson(w.Result()).Data.(map[string]interface{})["id"].(string)
+
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: id}, httprouter.Param{Key: "size", Value: "4096"}}
+	w = httptest.NewRecorder()
+	c.GetPhotoRendition(w, httptest.NewRequest("GET", "/api/v1/photos/"+id+"/renditions/4096", nil), params)
+	checkStatusCode(w.Result(), 404, t)
+}
+
+func insertPhotoWithLocation(t *testing.T) string {
+	lat, lng := 51.5, -0.125
+	id := primitive.NewObjectID()
+	key := media.BlobKey(id, "original")
+	c.Store.Blobs.Put(context.Background(), key, bytes.NewReader(encodePNG(4, 4)))
+	photo := models.Photo{
+		Id:          &id,
+		OwnerId:     *payloads[0].Id,
+		ContentType: media.PNG,
+		Key:         key,
+		Exif:        &models.Exif{Make: "Canon", Latitude: &lat, Longitude: &lng},
+	}
+	if _, e := c.Store.Photos.Insert(context.Background(), photo); e != nil {
+		t.Fatalf("Should have inserted the photo rather than returning %v", e)
+	}
+	return id.Hex()
+}
+
+func getPhotoExif(id string, payload *utils.Payload) map[string]interface{} {
+	w := httptest.NewRecorder()
+	c.GetPhoto(w, httptest.NewRequest("GET", "/api/v1/photos/"+id, nil), httprouter.Params{httprouter.Param{Key: "id", Value: id}}, payload)
+	photo := convertResponseToJson(w.Result()).Data.(map[string]interface{})
+	return photo["exif"].(map[string]interface{})
+}
+
+func TestGetPhotoHidesLocation(t *testing.T) {
+	id := insertPhotoWithLocation(t)
+
+	if exif := getPhotoExif(id, payloads[0]); exif["latitude"] != 51.5 {
+		t.Errorf("Should have returned the location to the owner rather than %v", exif["latitude"])
+	}
+	other := generateUserPayload(models.User{Id: &primitive.ObjectID{1}, Email: "other@gmail.com"})
+	exif := getPhotoExif(id, other)
+	if exif["latitude"] != nil || exif["longitude"] != nil {
+		t.Errorf("Should have hidden the location from other users rather than %v", exif)
+	}
+	if exif["make"] != "Canon" {
+		t.Errorf("Should have returned the rest of the metadata rather than %v", exif)
+	}
+}
+
+func TestUpdatePhotoShareLocation(t *testing.T) {
+	id := insertPhotoWithLocation(t)
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: id}}
+
+	w := httptest.NewRecorder()
+	other := generateUserPayload(models.User{Id: &primitive.ObjectID{1}, Email: "other@gmail.com"})
+	c.UpdatePhoto(w, httptest.NewRequest("PUT", "/api/v1/photos/"+id, bytes.NewBufferString(`{"shareLocation":true}`)), params, other)
+	checkStatusCode(w.Result(), 403, t)
+
+	w = httptest.NewRecorder()
+	c.UpdatePhoto(w, httptest.NewRequest("PUT", "/api/v1/photos/"+id, bytes.NewBufferString(`{"shareLocation":true}`)), params, payloads[0])
+	checkStatusCode(w.Result(), 200, t)
+
+	if exif := getPhotoExif(id, other); exif["latitude"] != 51.5 {
+		t.Errorf("Should have shared the location once the owner opted in rather than %v", exif["latitude"])
+	}
+}
+
+func TestGetPhotoOriginal(t *testing.T) {
+	id := insertPhotoWithLocation(t)
+
+	w := httptest.NewRecorder()
+	c.GetPhotoOriginal(w, httptest.NewRequest("GET", "/api/v1/photos/"+id+"/original", nil), httprouter.Params{httprouter.Param{Key: "id", Value: id}})
+
+	res := w.Result()
+	checkStatusCode(res, 200, t)
+	checkHeader(w, "Content-Type", "image/png", t)
+	if b, _ := ioutil.ReadAll(res.Body); !bytes.Equal(b, encodePNG(4, 4)) {
+		t.Errorf("Should have returned the bytes of the original")
+	}
+}
+
+func TestGetPhotoOriginalLastModified(t *testing.T) {
+	id := insertPhotoWithLocation(t)
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: id}}
+	oid, _ := primitive.ObjectIDFromHex(id)
+	c.Store.Photos.Update(context.Background(), oid, map[string]interface{}{"updatedAt": time.Now().Add(-time.Hour)})
+
+	w := httptest.NewRecorder()
+	c.UpdatePhoto(w, httptest.NewRequest("PUT", "/api/v1/photos/"+id, bytes.NewBufferString(`{"shareLocation":true}`)), params, payloads[0])
+	checkStatusCode(w.Result(), 200, t)
+
+	// the original changes along with the location being shared, so it is modified when the photo was updated
+	w = httptest.NewRecorder()
+	c.GetPhotoOriginal(w, httptest.NewRequest("GET", "/api/v1/photos/"+id+"/original", nil), params)
+	modified, e := http.ParseTime(w.Header().Get("Last-Modified"))
+	if e != nil || time.Since(modified) > time.Minute {
+		t.Errorf("Should have returned the time the photo was updated rather than %v", w.Header().Get("Last-Modified"))
+	}
+}
+
+func deletePhoto(id string, payload *utils.Payload) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c.DeletePhoto(w, httptest.NewRequest("DELETE", "/api/v1/photos/"+id, nil), httprouter.Params{httprouter.Param{Key: "id", Value: id}}, payload)
+	return w
+}
+
+func TestDeletePhoto(t *testing.T) {
+	id := insertPhotoWithLocation(t)
+	oid, _ := primitive.ObjectIDFromHex(id)
+	photo, _ := c.Store.Photos.Update(context.Background(), oid, map[string]interface{}{"tags": []string{"wombat"}})
+	c.adjustTags(context.Background(), photo.CountedTags(), nil)
+	rendition := media.BlobKey(oid, media.Renditions[0].Name)
+	c.Store.Blobs.Put(context.Background(), rendition, bytes.NewReader(encodePNG(1, 1)))
+	album := createAlbum(`{"title":"Outback","visibility":"public"}`, payloads[0], t)
+	checkStatusCode(addAlbumPhoto(album, id, payloads[0]).Result(), 200, t)
+	checkStatusCode(createPhotoComment(id, `{"body":"Fluffy"}`, payloads[1]).Result(), 201, t)
+	likePhoto(id, true, payloads[1])
+
+	checkStatusCode(deletePhoto(id, payloads[1]).Result(), 403, t)
+	checkStatusCode(deletePhoto(id, payloads[0]).Result(), 204, t)
+	checkStatusCode(deletePhoto(id, payloads[0]).Result(), 404, t)
+
+	if counts := tagCounts(payloads[1]); counts["wombat"] != 0 {
+		t.Errorf("Should have removed the tags of a deleted photo rather than %v", counts["wombat"])
+	}
+	if albums, _ := c.Store.Albums.ListByPhoto(context.Background(), oid); len(albums) != 0 {
+		t.Errorf("Should have removed the photo from its albums rather than %v", albums)
+	}
+	if comments, _ := c.Store.Comments.ListByTarget(context.Background(), models.TargetPhoto, oid); len(comments) != 0 {
+		t.Errorf("Should have deleted the comments of the photo rather than %v", comments)
+	}
+	if liked, _ := c.Store.Likes.LikedBy(context.Background(), *payloads[1].Id, models.TargetPhoto, []primitive.ObjectID{oid}); liked[oid] {
+		t.Errorf("Should have deleted the likes of the photo")
+	}
+	for _, key := range []string{photo.Key, rendition} {
+		if _, e := c.Store.Blobs.Get(context.Background(), key); e == nil {
+			t.Errorf("Should have deleted the blob %v of the photo", key)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/markdown"
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// postPhotoRendition is the rendition of the photos embedded within the body of a post
+const postPhotoRendition = "1280"
+
+// maxSlugAttempts is the number of suffixes tried before giving up on a unique slug
+const maxSlugAttempts = 10
+
+// photoRenditionURL returns the URL of the rendition of a photo that is embedded within a post
+func photoRenditionURL(id primitive.ObjectID) string {
+	return strings.Join([]string{"/api/v1/photos", id.Hex(), "renditions", postPhotoRendition}, "/")
+}
+
+// renderPost sets the sanitised HTML of the Markdown body of a post
+func renderPost(post *models.Post) {
+	post.BodyHTML = markdown.Render(post.Body, photoRenditionURL)
+}
+
+// insertPost stores a new post under a unique slug derived from its title. Clashing slugs get a numeric suffix.
+func (c Controller) insertPost(ctx context.Context, post *models.Post) error {
+	base := models.Slugify(post.Title)
+	if base == "" {
+		base = post.Id.Hex()
+	}
+
+	post.Slug = base
+	for i := 2; i <= maxSlugAttempts+1; i++ {
+		_, e := c.Store.Posts.Insert(ctx, *post)
+		if e != store.ErrDuplicate {
+			return e
+		}
+		post.Slug = fmt.Sprintf("%v-%d", base, i)
+	}
+	return store.ErrDuplicate
+}
+
+// isValidCover checks that a cover photo exists and belongs to the author of a post
+func (c Controller) isValidCover(ctx context.Context, id primitive.ObjectID, authorID primitive.ObjectID) bool {
+	photo, e := c.Store.Photos.FindByID(ctx, id)
+	return e == nil && photo.OwnerId == authorID
+}
+
+// findPost fetches the post of the :id route parameter and writes the error response when it can not be found
+func (c Controller) findPost(w http.ResponseWriter, r *http.Request, p httprouter.Params) (*models.Post, bool) {
+	id := p.ByName("id")
+	if id == "" {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid target resource"}.BadRequest())
+		return nil, false
+	}
+
+	oid, _ := primitive.ObjectIDFromHex(id)
+	post, e := c.Store.Posts.FindByID(r.Context(), oid)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Post does not exists"}.NotFound())
+		return nil, false
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the post"}.InternalServerError())
+		return nil, false
+	}
+	return post, true
+}
+
+// CreatePost is used to create a post authored by the authenticated user. Posts are drafts unless another status is
+// requested.
+func (c Controller) CreatePost(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	var body models.Post
+	payload := other[0].(*utils.Payload)
+	json.NewDecoder(r.Body).Decode(&body)
+
+	if body.CoverPhotoId != nil && !c.isValidCover(r.Context(), *body.CoverPhotoId, *payload.Id) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Cover Photo"}.BadRequest())
+		return
+	}
+
+	now := time.Now()
+	id := primitive.NewObjectID()
+	post := models.Post{
+		Id:           &id,
+		AuthorId:     *payload.Id,
+		Title:        body.Title,
+		Body:         body.Body,
+		CoverPhotoId: body.CoverPhotoId,
+		Tags:         body.Tags,
+		Status:       body.Status,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if post.Status == "" {
+		post.Status = models.PostDraft
+	}
+	if post.Status == models.PostPublished {
+		post.PublishedAt = &now
+	}
+
+	if !post.ValidateTags() {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Tags"}.BadRequest())
+		return
+	}
+
+	if e := c.insertPost(r.Context(), &post); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to store the post"}.InternalServerError())
+		return
+	}
+	c.adjustTags(r.Context(), post.CountedTags(), nil)
+	c.indexPost(r.Context(), &post)
+
+	renderPost(&post)
+	w.Header().Set("Location", strings.Join([]string{r.URL.Path, id.Hex()}, "/"))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful creation", Data: post}.Created())
+}
+
+// GetPosts is used to list the published posts together with the posts of the authenticated user, with the newest
+// first. The list can be filtered by the author and status query parameters, and is paginated by the limit and cursor
+// query parameters.
+func (c Controller) GetPosts(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	payload := other[0].(*utils.Payload)
+	after, limit, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	filter := store.PostFilter{Status: query.Get("status"), VisibleTo: payload.Id}
+
+	if author := query.Get("author"); author != "" {
+		oid, e := primitive.ObjectIDFromHex(author)
+		if e != nil {
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid author"}.BadRequest())
+			return
+		}
+		filter.AuthorId = &oid
+	}
+
+	// an extra post is fetched to find out if there is a next page
+	posts, e := c.Store.Posts.List(r.Context(), filter, after, limit+1)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the posts"}.InternalServerError())
+		return
+	}
+	pagination := httpcodes.Pagination{Limit: limit}
+	if int64(len(posts)) > limit {
+		posts = posts[:limit]
+		last := posts[limit-1]
+		pagination.HasMore = true
+		pagination.NextCursor = encodeCursor(store.Cursor{Time: last.CreatedAt, Id: *last.Id})
+	}
+	for i := range posts {
+		renderPost(&posts[i])
+	}
+	if e := c.setLikedPosts(r.Context(), payload, posts); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the likes"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful fetch", Data: posts, Pagination: &pagination}.Ok())
+}
+
+// GetPost is used to return a post. Drafts are only returned to their author.
+func (c Controller) GetPost(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	payload := other[0].(*utils.Payload)
+	post, ok := c.findPost(w, r, p)
+	if !ok {
+		return
+	}
+	if !post.IsVisibleTo(*payload.Id, payload.IsAdmin()) {
+		// a hidden post is reported as missing, so that its existence is not leaked
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Post does not exists"}.NotFound())
+		return
+	}
+	renderPost(post)
+
+	liked, e := c.isLiked(r.Context(), payload, models.TargetPost, *post.Id)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the likes"}.InternalServerError())
+		return
+	}
+	post.Liked = liked
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful fetch", Data: post}.Ok())
+}
+
+// UpdatePost is used by the author of a post or an admin to edit it. The publishing date is set the first time that the
+// post is published and kept afterwards.
+func (c Controller) UpdatePost(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	var body models.PostUpdate
+	payload := other[0].(*utils.Payload)
+	post, ok := c.findPost(w, r, p)
+	if !ok {
+		return
+	}
+	if !payload.CanAccess(post.AuthorId.Hex()) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid operation for the existing post"}.Forbidden())
+		return
+	}
+
+	json.NewDecoder(r.Body).Decode(&body)
+	fields := body.Fields()
+	if len(fields) == 0 || !body.Validate() {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Request Body"}.BadRequest())
+		return
+	}
+	if body.CoverPhotoId != nil && !c.isValidCover(r.Context(), *body.CoverPhotoId, post.AuthorId) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Cover Photo"}.BadRequest())
+		return
+	}
+
+	now := time.Now()
+	fields["updatedAt"] = now
+	if body.Status != nil && *body.Status == models.PostPublished && post.PublishedAt == nil {
+		fields["publishedAt"] = now
+	}
+
+	counted := post.CountedTags()
+	post, e := c.Store.Posts.Update(r.Context(), *post.Id, fields)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to update the post"}.InternalServerError())
+		return
+	}
+	added, removed := models.DiffTags(counted, post.CountedTags())
+	c.adjustTags(r.Context(), added, removed)
+	c.indexPost(r.Context(), post)
+	renderPost(post)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful update", Data: post}.Ok())
+}
+
+// DeletePost is used by the author of a post or an admin to delete it
+func (c Controller) DeletePost(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	payload := other[0].(*utils.Payload)
+	post, ok := c.findPost(w, r, p)
+	if !ok {
+		return
+	}
+	if !payload.CanAccess(post.AuthorId.Hex()) {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid operation for the existing post"}.Forbidden())
+		return
+	}
+
+	e := c.Store.Posts.Delete(r.Context(), *post.Id)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Post does not exists"}.NotFound())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The db was unable to delete the post"}.InternalServerError())
+		return
+	}
+	c.adjustTags(r.Context(), nil, post.CountedTags())
+	c.unindex(r.Context(), store.SearchPosts, *post.Id)
+	if e := c.Store.Comments.DeleteByTarget(r.Context(), models.TargetPost, *post.Id); e != nil {
+		log.Printf("Unable to delete the comments of post %v: %v", post.Id.Hex(), e)
+	}
+	if e := c.Store.Likes.DeleteByTarget(r.Context(), models.TargetPost, *post.Id); e != nil {
+		log.Printf("Unable to delete the likes of post %v: %v", post.Id.Hex(), e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(204)
+}
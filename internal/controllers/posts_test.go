@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/utils"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func createPost(body string, payload *utils.Payload, t *testing.T) map[string]interface{} {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/posts", strings.NewReader(body))
+	c.CreatePost(w, r, nil, payload)
+
+	res := w.Result()
+	checkStatusCode(res, 201, t)
+	return convertResponseToJson(res).Data.(map[string]interface{})
+}
+
+func updatePost(id string, body string, payload *utils.Payload) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/api/v1/posts/"+id, strings.NewReader(body))
+	c.UpdatePost(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: id}}, payload)
+	return w
+}
+
+func getPost(id string, payload *utils.Payload) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/posts/"+id, nil)
+	c.GetPost(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: id}}, payload)
+	return w
+}
+
+func TestCreatePostDefaultsToDraft(t *testing.T) {
+	post := createPost(`{"title":"Hello, World!","body":"# Hi"}`, payloads[0], t)
+	checkJSON(post, []Check{
+		Check{Key: "title", Expected: "Hello, World!"},
+		Check{Key: "slug", Expected: "hello-world"},
+		Check{Key: "status", Expected: "draft"},
+		Check{Key: "authorId", Expected: payloads[0].Id.Hex()},
+		Check{Key: "publishedAt", Expected: nil},
+	}, t)
+
+	other := createPost(`{"title":"Hello world"}`, payloads[0], t)
+	if slug := other["slug"]; slug != "hello-world-2" {
+		t.Errorf("Should have suffixed a clashing slug rather than returning %v", slug)
+	}
+}
+
+func TestDraftIsVisibleOnlyToItsAuthor(t *testing.T) {
+	post := createPost(`{"title":"Secret draft"}`, payloads[0], t)
+	id := post["id"].(string)
+
+	checkStatusCode(getPost(id, payloads[0]).Result(), 200, t)
+	checkStatusCode(getPost(id, payloads[1]).Result(), 404, t)
+	checkStatusCode(getPost(id, generateAdminPayload()).Result(), 404, t)
+
+	w := httptest.NewRecorder()
+	c.GetPosts(w, httptest.NewRequest("GET", "/api/v1/posts", nil), nil, payloads[1])
+	for _, p := range convertResponseToJson(w.Result()).Data.([]interface{}) {
+		if p.(map[string]interface{})["id"] == id {
+			t.Errorf("Should not have listed the draft of another user")
+		}
+	}
+}
+
+func TestGetPostsPagination(t *testing.T) {
+	id := primitive.NewObjectID()
+	author := generateUserPayload(models.User{Id: &id, Email: "author@gmail.com"})
+	for _, title := range []string{"First", "Second", "Third"} {
+		createPost(`{"title":"`+title+`"}`, author, t)
+	}
+
+	var titles []string
+	path := "/api/v1/posts?limit=2&author=" + id.Hex()
+	for pages := 0; path != ""; pages++ {
+		if pages == 2 {
+			t.Fatalf("Should have listed the posts in two pages")
+		}
+		w := httptest.NewRecorder()
+		c.GetPosts(w, httptest.NewRequest("GET", path, nil), nil, author)
+		checkStatusCode(w.Result(), 200, t)
+
+		res := convertResponseToJson(w.Result())
+		for _, p := range res.Data.([]interface{}) {
+			titles = append(titles, p.(map[string]interface{})["title"].(string))
+		}
+		path = ""
+		if res.Pagination != nil && res.Pagination.HasMore {
+			path = "/api/v1/posts?limit=2&author=" + id.Hex() + "&cursor=" + res.Pagination.NextCursor
+		}
+	}
+	if strings.Join(titles, ",") != "Third,Second,First" {
+		t.Errorf("Should have listed the posts with the newest first rather than %v", titles)
+	}
+
+	w := httptest.NewRecorder()
+	c.GetPosts(w, httptest.NewRequest("GET", "/api/v1/posts?cursor=invalid", nil), nil, author)
+	checkStatusCode(w.Result(), 400, t)
+}
+
+func TestPublishPostSetsPublishedAtOnce(t *testing.T) {
+	post := createPost(`{"title":"Going live"}`, payloads[0], t)
+	id := post["id"].(string)
+
+	w := updatePost(id, `{"status":"published"}`, payloads[0])
+	checkStatusCode(w.Result(), 200, t)
+	published := convertResponseToJson(w.Result()).Data.(map[string]interface{})
+	if published["publishedAt"] == nil {
+		t.Fatalf("Should have set the publishedAt of a published post")
+	}
+	checkStatusCode(getPost(id, payloads[1]).Result(), 200, t)
+
+	updatePost(id, `{"status":"archived"}`, payloads[0])
+	w = updatePost(id, `{"status":"published"}`, payloads[0])
+	republished := convertResponseToJson(w.Result()).Data.(map[string]interface{})
+	if republished["publishedAt"] != published["publishedAt"] {
+		t.Errorf("Should have kept the publishedAt %v rather than %v", published["publishedAt"], republished["publishedAt"])
+	}
+}
+
+func TestUpdatePostPermissions(t *testing.T) {
+	post := createPost(`{"title":"Mine","status":"published"}`, payloads[0], t)
+	id := post["id"].(string)
+
+	checkStatusCode(updatePost(id, `{"title":"Theirs"}`, payloads[1]).Result(), 403, t)
+	checkStatusCode(updatePost(id, `{"status":"deleted"}`, payloads[0]).Result(), 400, t)
+	checkStatusCode(updatePost(id, `{"title":"Edited"}`, generateAdminPayload()).Result(), 200, t)
+}
+
+func TestDeletePost(t *testing.T) {
+	post := createPost(`{"title":"Short lived"}`, payloads[0], t)
+	id := post["id"].(string)
+	params := httprouter.Params{httprouter.Param{Key: "id", Value: id}}
+
+	w := httptest.NewRecorder()
+	c.DeletePost(w, httptest.NewRequest("DELETE", "/api/v1/posts/"+id, nil), params, payloads[1])
+	checkStatusCode(w.Result(), 403, t)
+
+	w = httptest.NewRecorder()
+	c.DeletePost(w, httptest.NewRequest("DELETE", "/api/v1/posts/"+id, nil), params, payloads[0])
+	checkStatusCode(w.Result(), 204, t)
+	checkStatusCode(getPost(id, payloads[0]).Result(), 404, t)
+}
+
+func TestCreatePostWithForeignCover(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/posts", strings.NewReader(`{"title":"Cover","coverPhotoId":"5db5b5b06507b38887bedc99"}`))
+	c.CreatePost(w, r, nil, payloads[0])
+	checkStatusCode(w.Result(), 400, t)
+}
+
+func TestPostBodyHTML(t *testing.T) {
+	photoID := "5db5b5b06507b38887bedc99"
+	post := createPost(`{"title":"Rendered","body":"Hello **there**\n\n![](photo:`+photoID+`)\n\n<script>alert(1)</script>","bodyHtml":"<script></script>"}`, payloads[0], t)
+
+	html := post["bodyHtml"].(string)
+	for _, expected := range []string{"<strong>there</strong>", `<img src="/api/v1/photos/` + photoID + `/renditions/1280"`} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Should return a bodyHtml containing %v rather than %v", expected, html)
+		}
+	}
+	if strings.Contains(html, "<script") {
+		t.Errorf("Should have sanitised the bodyHtml rather than returning %v", html)
+	}
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxQueryLength is the maximum number of characters of a search query
+const maxQueryLength = 200
+
+// index adds a document to the search index. Failures are logged, since the document has already been stored.
+func (c Controller) index(ctx context.Context, doc store.SearchDocument) {
+	if e := c.Store.Search.Index(ctx, doc); e != nil {
+		log.Printf("Unable to index the %v %v: %v", doc.Kind, doc.Id.Hex(), e)
+	}
+}
+
+// unindex removes a document from the search index. Failures are logged, since the document has already been deleted.
+func (c Controller) unindex(ctx context.Context, kind string, id primitive.ObjectID) {
+	if e := c.Store.Search.Remove(ctx, kind, id); e != nil {
+		log.Printf("Unable to remove the %v %v from the search index: %v", kind, id.Hex(), e)
+	}
+}
+
+func (c Controller) indexUser(ctx context.Context, user *models.User) {
+	c.index(ctx, store.SearchDocument{Kind: store.SearchUsers, Id: *user.Id, OwnerId: *user.Id, Public: true, Title: user.Username})
+}
+
+func (c Controller) indexPost(ctx context.Context, post *models.Post) {
+	c.index(ctx, store.SearchDocument{
+		Kind:    store.SearchPosts,
+		Id:      *post.Id,
+		OwnerId: post.AuthorId,
+		Public:  post.Status == models.PostPublished,
+		Title:   post.Title,
+		Tags:    post.Tags,
+		Body:    post.Body,
+	})
+}
+
+func (c Controller) indexPhoto(ctx context.Context, photo *models.Photo) {
+	c.index(ctx, store.SearchDocument{Kind: store.SearchPhotos, Id: *photo.Id, OwnerId: photo.OwnerId, Public: !photo.Private, Title: photo.Caption, Tags: photo.Tags})
+}
+
+// searchHitIds returns the ids of the search hits, along with their rank
+func searchHitIds(hits []store.SearchHit) ([]primitive.ObjectID, map[primitive.ObjectID]int) {
+	ids := []primitive.ObjectID{}
+	rank := map[primitive.ObjectID]int{}
+	for i, hit := range hits {
+		ids = append(ids, hit.Id)
+		rank[hit.Id] = i
+	}
+	return ids, rank
+}
+
+// searchUsers returns the users that match a search, with the most relevant first
+func (c Controller) searchUsers(ctx context.Context, hits []store.SearchHit) (interface{}, error) {
+	ids, rank := searchHitIds(hits)
+	result, e := c.Store.Users.FindMany(ctx, ids)
+	if e != nil {
+		return nil, e
+	}
+
+	ranked := make([]*models.SecureUser, len(hits))
+	for _, user := range result {
+		ranked[rank[*user.Id]] = user.MapToSecureUser()
+	}
+	users := []*models.SecureUser{}
+	for _, user := range ranked {
+		if user != nil {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// searchPosts returns the posts that match a search, with the most relevant first. The visibility of each post is
+// checked again, in case the index is behind the posts collection.
+func (c Controller) searchPosts(ctx context.Context, hits []store.SearchHit, payload *utils.Payload) (interface{}, error) {
+	ids, rank := searchHitIds(hits)
+	result, e := c.Store.Posts.FindMany(ctx, ids)
+	if e != nil {
+		return nil, e
+	}
+
+	ranked := make([]*models.Post, len(hits))
+	for i := range result {
+		if result[i].Status == models.PostPublished || result[i].AuthorId == *payload.Id {
+			ranked[rank[*result[i].Id]] = &result[i]
+		}
+	}
+	posts := []models.Post{}
+	for _, post := range ranked {
+		if post != nil {
+			renderPost(post)
+			posts = append(posts, *post)
+		}
+	}
+	return posts, c.setLikedPosts(ctx, payload, posts)
+}
+
+// searchPhotos returns the photos that match a search, with the most relevant first
+func (c Controller) searchPhotos(ctx context.Context, hits []store.SearchHit, payload *utils.Payload) (interface{}, error) {
+	ids, rank := searchHitIds(hits)
+	result, e := c.Store.Photos.FindMany(ctx, ids)
+	if e != nil {
+		return nil, e
+	}
+
+	ranked := make([]*models.Photo, len(hits))
+	for i := range result {
+		ranked[rank[*result[i].Id]] = &result[i]
+	}
+	photos := []models.Photo{}
+	for _, photo := range ranked {
+		// the index may lag behind the visibility of a photo, which is checked again
+		if photo == nil || !photo.IsVisibleTo(*payload.Id, false) {
+			continue
+		}
+		if photo.OwnerId != *payload.Id {
+			*photo = photo.Public()
+		}
+		photos = append(photos, *photo)
+	}
+	return photos, c.setLikedPhotos(ctx, payload, photos)
+}
+
+// Search is used to run a full-text search on the users, the posts or the photos, as selected by the type query
+// parameter. Results are ranked by relevance and only include the content that the authenticated user can see.
+func (c Controller) Search(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	payload := other[0].(*utils.Payload)
+	query := r.URL.Query()
+
+	text := strings.TrimSpace(query.Get("q"))
+	if text == "" || len(text) > maxQueryLength {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid query"}.BadRequest())
+		return
+	}
+	kind := query.Get("type")
+	if kind != store.SearchUsers && kind != store.SearchPosts && kind != store.SearchPhotos {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid type"}.BadRequest())
+		return
+	}
+	limit, ok := parseLimit(query.Get("limit"))
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid limit"}.BadRequest())
+		return
+	}
+
+	hits, e := c.Store.Search.Search(r.Context(), store.SearchQuery{Kind: kind, Text: text, VisibleTo: *payload.Id, Limit: limit})
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to search the " + kind}.InternalServerError())
+		return
+	}
+
+	var results interface{}
+	switch kind {
+	case store.SearchUsers:
+		results, e = c.searchUsers(r.Context(), hits)
+	case store.SearchPosts:
+		results, e = c.searchPosts(r.Context(), hits, payload)
+	case store.SearchPhotos:
+		results, e = c.searchPhotos(r.Context(), hits, payload)
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the " + kind}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful fetch", Data: results}.Ok())
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MarioSimou/authAPI/internal/utils"
+)
+
+func search(query string, payload *utils.Payload) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c.Search(w, httptest.NewRequest("GET", "/api/v1/search?"+query, nil), nil, payload)
+	return w
+}
+
+func searchTitles(query string, key string, payload *utils.Payload, t *testing.T) []string {
+	w := search(query, payload)
+	checkStatusCode(w.Result(), 200, t)
+
+	titles := []string{}
+	for _, item := range convertResponseToJson(w.Result()).Data.([]interface{}) {
+		titles = append(titles, item.(map[string]interface{})[key].(string))
+	}
+	return titles
+}
+
+func TestSearchPosts(t *testing.T) {
+	createPost(`{"title":"Notes","body":"A zeppelin flew over the park","status":"published"}`, payloads[0], t)
+	createPost(`{"title":"Zeppelin sightings","status":"published"}`, payloads[0], t)
+	draft := createPost(`{"title":"Zeppelin draft"}`, payloads[0], t)
+
+	titles := searchTitles("type=posts&q=zeppelin", "title", payloads[1], t)
+	if strings.Join(titles, ",") != "Zeppelin sightings,Notes" {
+		t.Errorf("Should have ranked the published posts by relevance rather than returning %v", titles)
+	}
+	if titles := searchTitles("type=posts&q=zeppelin", "title", payloads[0], t); len(titles) != 3 {
+		t.Errorf("Should have returned the drafts of the author rather than %v", titles)
+	}
+
+	checkStatusCode(updatePost(draft["id"].(string), `{"title":"Airships"}`, payloads[0]).Result(), 200, t)
+	if titles := searchTitles("type=posts&q=zeppelin", "title", payloads[0], t); len(titles) != 2 {
+		t.Errorf("Should have reindexed an edited post rather than returning %v", titles)
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/users", strings.NewReader(`{"username":"freddie","password":"12345678","email":"freddie@gmail.com"}`))
+	c.CreateUser(w, r, nil)
+	checkStatusCode(w.Result(), 201, t)
+
+	w = search("type=users&q=Freddie", payloads[1])
+	checkStatusCode(w.Result(), 200, t)
+	users := convertResponseToJson(w.Result()).Data.([]interface{})
+	if len(users) != 1 {
+		t.Fatalf("Should have found the new user rather than returning %v", users)
+	}
+	checkJSON(users[0].(map[string]interface{}), []Check{
+		Check{Key: "username", Expected: "freddie"},
+		Check{Key: "password", Expected: nil},
+	}, t)
+	if titles := searchTitles("type=users&q=freddie@gmail.com", "username", payloads[1], t); len(titles) != 1 {
+		t.Errorf("Should have matched the username rather than returning %v", titles)
+	}
+}
+
+func TestSearchInvalidQuery(t *testing.T) {
+	checkStatusCode(search("type=posts&q=+", payloads[0]).Result(), 400, t)
+	checkStatusCode(search("type=comments&q=zeppelin", payloads[0]).Result(), 400, t)
+	checkStatusCode(search("q=zeppelin", payloads[0]).Result(), 400, t)
+	checkStatusCode(search("type=posts&q=zeppelin&limit=0", payloads[0]).Result(), 400, t)
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// adjustTags updates the tag counts when tags are added to or removed from content. Failures are logged, since the
+// content has already been stored.
+func (c Controller) adjustTags(ctx context.Context, added []string, removed []string) {
+	if e := c.Store.Tags.Adjust(ctx, added, removed); e != nil {
+		log.Printf("Unable to adjust the counts of tags %v and %v: %v", added, removed, e)
+	}
+}
+
+// GetTags is used to return the most popular tags, along with the number of published posts and public photos that use
+// them
+func (c Controller) GetTags(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	limit, ok := parseLimit(r.URL.Query().Get("limit"))
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid limit"}.BadRequest())
+		return
+	}
+
+	tags, e := c.Store.Tags.Popular(r.Context(), limit)
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the tags"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful fetch", Data: tags}.Ok())
+}
+
+// GetTag is used to return the published posts and the photos with a tag, with the newest first. The content is
+// paginated the same way as the feed.
+func (c Controller) GetTag(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+	payload := other[0].(*utils.Payload)
+	tag := models.Slugify(p.ByName("tag"))
+	if tag == "" {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid tag"}.BadRequest())
+		return
+	}
+
+	after, limit, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+	c.writeContent(w, r, payload, store.ContentFilter{Tag: tag}, after, limit)
+}
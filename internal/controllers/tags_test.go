@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/MarioSimou/authAPI/internal/utils"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func tagCounts(payload *utils.Payload) map[string]float64 {
+	w := httptest.NewRecorder()
+	c.GetTags(w, httptest.NewRequest("GET", "/api/v1/tags?limit=100", nil), nil, payload)
+
+	counts := map[string]float64{}
+	for _, tag := range convertResponseToJson(w.Result()).Data.([]interface{}) {
+		tag := tag.(map[string]interface{})
+		counts[tag["tag"].(string)] = tag["count"].(float64)
+	}
+	return counts
+}
+
+func getTag(tag string, query string, payload *utils.Payload) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/tags/"+url.PathEscape(tag)+query, nil)
+	c.GetTag(w, r, httprouter.Params{httprouter.Param{Key: "tag", Value: tag}}, payload)
+	return w
+}
+
+func TestTagCounts(t *testing.T) {
+	post := createPost(`{"title":"Tagged draft","tags":["Street Photography","london","London"]}`, payloads[0], t)
+	id := post["id"].(string)
+	if tags := post["tags"].([]interface{}); len(tags) != 2 || tags[0] != "street-photography" || tags[1] != "london" {
+		t.Fatalf("Should have normalized the tags rather than returning %v", tags)
+	}
+	if counts := tagCounts(payloads[1]); counts["street-photography"] != 0 {
+		t.Errorf("Should not have counted the tags of a draft rather than %v", counts["street-photography"])
+	}
+
+	checkStatusCode(updatePost(id, `{"status":"published"}`, payloads[0]).Result(), 200, t)
+	if counts := tagCounts(payloads[1]); counts["street-photography"] != 1 || counts["london"] != 1 {
+		t.Errorf("Should have counted the tags once the post was published rather than %v", counts)
+	}
+
+	checkStatusCode(updatePost(id, `{"tags":["london","night"]}`, payloads[0]).Result(), 200, t)
+	counts := tagCounts(payloads[1])
+	if counts["street-photography"] != 0 || counts["london"] != 1 || counts["night"] != 1 {
+		t.Errorf("Should have adjusted the counts of the edited tags rather than %v", counts)
+	}
+
+	w := httptest.NewRecorder()
+	c.DeletePost(w, httptest.NewRequest("DELETE", "/api/v1/posts/"+id, nil), httprouter.Params{httprouter.Param{Key: "id", Value: id}}, payloads[0])
+	checkStatusCode(w.Result(), 204, t)
+	if counts := tagCounts(payloads[1]); counts["london"] != 0 || counts["night"] != 0 {
+		t.Errorf("Should have removed the tags of a deleted post rather than %v", counts)
+	}
+}
+
+func TestPhotoTagCounts(t *testing.T) {
+	id := insertPhoto(payloads[0], t)
+	update := func(body string) {
+		w := httptest.NewRecorder()
+		c.UpdatePhoto(w, httptest.NewRequest("PUT", "/api/v1/photos/"+id, strings.NewReader(body)), httprouter.Params{httprouter.Param{Key: "id", Value: id}}, payloads[0])
+		checkStatusCode(w.Result(), 200, t)
+	}
+	update(`{"tags":["Quokka"]}`)
+	if counts := tagCounts(payloads[1]); counts["quokka"] != 1 {
+		t.Errorf("Should have counted the tags of a public photo rather than %v", counts["quokka"])
+	}
+
+	// the tags of a photo are not counted while it is part of a private album only
+	album := createAlbum(`{"title":"Wildlife"}`, payloads[0], t)
+	checkStatusCode(addAlbumPhoto(album, id, payloads[0]).Result(), 200, t)
+	update(`{"tags":["quokka","rottnest"]}`)
+	if counts := tagCounts(payloads[1]); counts["quokka"] != 0 || counts["rottnest"] != 0 {
+		t.Errorf("Should not have counted the tags of a private photo rather than %v", counts)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/api/v1/albums/"+album, strings.NewReader(`{"visibility":"public"}`))
+	c.UpdateAlbum(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: album}}, payloads[0])
+	checkStatusCode(w.Result(), 200, t)
+	if counts := tagCounts(payloads[1]); counts["quokka"] != 1 || counts["rottnest"] != 1 {
+		t.Errorf("Should have counted the tags of a photo once its album was public rather than %v", counts)
+	}
+}
+
+func TestUpdatePostWithTooManyTags(t *testing.T) {
+	id := createPost(`{"title":"Too many tags"}`, payloads[0], t)["id"].(string)
+	tags := `["a","b","c","d","e","f","g","h","i","j","k","l","m","n","o","p","q","r","s","t","u"]`
+	checkStatusCode(updatePost(id, `{"tags":`+tags+`}`, payloads[0]).Result(), 400, t)
+}
+
+func TestGetTag(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		post := createPost(`{"title":"Browsing tags","status":"published","tags":["Tag Browsing"]}`, payloads[0], t)
+		if i == 0 {
+			checkStatusCode(updatePost(post["id"].(string), `{"tags":[]}`, payloads[0]).Result(), 200, t)
+		}
+	}
+	createPost(`{"title":"Unpublished tag","tags":["tag-browsing"]}`, payloads[0], t)
+
+	w := getTag("Tag Browsing", "?limit=1", payloads[1])
+	checkStatusCode(w.Result(), 200, t)
+	feed := convertResponseToJson(w.Result()).Data.(map[string]interface{})
+	if items := feed["items"].([]interface{}); len(items) != 1 || feed["nextCursor"] == nil {
+		t.Fatalf("Should have returned the first page of the tag rather than %v", feed)
+	}
+
+	w = getTag("tag-browsing", "?limit=10&cursor="+feed["nextCursor"].(string), payloads[1])
+	feed = convertResponseToJson(w.Result()).Data.(map[string]interface{})
+	if items := feed["items"].([]interface{}); len(items) != 1 || feed["nextCursor"] != nil {
+		t.Errorf("Should have returned the rest of the published posts with the tag rather than %v", feed)
+	}
+
+	checkStatusCode(getTag("!!", "", payloads[1]).Result(), 400, t)
+}
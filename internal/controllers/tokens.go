@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// accessTokenMaxAge is the lifetime of a JWT access token
+const accessTokenMaxAge = time.Hour
+
+// refreshTokenMaxAge is the lifetime of a refresh token
+const refreshTokenMaxAge = 30 * 24 * time.Hour
+
+// issueRefreshToken stores a new refresh token that belongs to a family and returns its opaque value. The token is bound
+// to an OpenID Connect client and the scopes granted to it, unless clientID is nil.
+func (c Controller) issueRefreshToken(ctx context.Context, userID primitive.ObjectID, family primitive.ObjectID, clientID *primitive.ObjectID, scopes []string) (string, bool) {
+	token, hash, ok := c.Utils.GenerateRefreshToken()
+	if !ok {
+		return "", false
+	}
+
+	now := time.Now()
+	rt := models.RefreshToken{UserId: userID, Family: family, ClientId: clientID, Scopes: scopes, Hash: hash, CreatedAt: now, ExpiresAt: now.Add(refreshTokenMaxAge)}
+	if _, e := c.Store.RefreshTokens.Insert(ctx, rt); e != nil {
+		return "", false
+	}
+	return token, true
+}
+
+// revokeUserTokens revokes every outstanding access and refresh token of a user
+func (c Controller) revokeUserTokens(ctx context.Context, userID primitive.ObjectID) bool {
+	// JWT timestamps have a precision of a second, so the tokens issued within the second of the revocation are revoked
+	// as well. The sessions that start within that second get a new access token once they refresh it.
+	now := time.Now().Truncate(time.Second)
+	if e := c.Store.Revocations.RevokeUser(ctx, userID, now, now.Add(accessTokenMaxAge)); e != nil {
+		return false
+	}
+	if e := c.Store.RefreshTokens.RevokeUser(ctx, userID); e != nil {
+		return false
+	}
+	return true
+}
+
+// errInvalidRefreshToken is returned when a refresh token can not be exchanged
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+// rotateRefreshToken exchanges a refresh token of a client for the next token of its family, returning the user and the
+// exchanged token along with the new token. Every refresh token can be used once, while presenting an already used
+// token revokes the whole family that was issued from the same sign in.
+func (c Controller) rotateRefreshToken(ctx context.Context, value string, clientID *primitive.ObjectID) (*models.User, *models.RefreshToken, string, error) {
+	rt, e := c.Store.RefreshTokens.FindByHash(ctx, c.Utils.HashToken(value))
+	if e == store.ErrNotFound {
+		return nil, nil, "", errInvalidRefreshToken
+	}
+	if e != nil {
+		return nil, nil, "", e
+	}
+
+	now := time.Now()
+	if rt.Revoked || rt.IsExpired(now) || !sameClient(rt.ClientId, clientID) {
+		return nil, nil, "", errInvalidRefreshToken
+	}
+
+	used, e := c.Store.RefreshTokens.MarkUsed(ctx, *rt.Id, now)
+	if e != nil {
+		return nil, nil, "", e
+	}
+	// a token that has already been exchanged indicates that it has been leaked
+	if !used {
+		c.Store.RefreshTokens.RevokeFamily(ctx, rt.Family)
+		return nil, nil, "", errInvalidRefreshToken
+	}
+
+	user, e := c.Store.Users.FindByID(ctx, rt.UserId)
+	if e != nil {
+		return nil, nil, "", errInvalidRefreshToken
+	}
+	refreshToken, ok := c.issueRefreshToken(ctx, rt.UserId, rt.Family, rt.ClientId, rt.Scopes)
+	if !ok {
+		return nil, nil, "", errors.New("unable to generate a refresh token")
+	}
+	return user, rt, refreshToken, nil
+}
+
+// sameClient checks if two optional client ids are equal
+func sameClient(a *primitive.ObjectID, b *primitive.ObjectID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token. The refresh tokens of OpenID Connect
+// clients are exchanged through the token endpoint instead.
+func (c Controller) RefreshToken(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	var body models.RefreshRequest
+	json.NewDecoder(r.Body).Decode(&body)
+
+	user, _, refreshToken, e := c.rotateRefreshToken(r.Context(), body.RefreshToken, nil)
+	if e == errInvalidRefreshToken {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid refresh token"}.Unauthorized())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to refresh the token"}.InternalServerError())
+		return
+	}
+
+	token, ok := c.Utils.IssueToken(*user, accessTokenMaxAge)
+	if !ok {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to generate user token"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "Successful refresh", Token: string(token), RefreshToken: refreshToken}.Ok())
+}
+
+// SignOut revokes the access token of the request. When a refresh token is included in the request body, the refresh
+// tokens of the same sign in are revoked as well.
+func (c Controller) SignOut(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	var body models.RefreshRequest
+	payload := other[0].(*utils.Payload)
+	json.NewDecoder(r.Body).Decode(&body)
+
+	expiresAt := time.Now().Add(accessTokenMaxAge)
+	if payload.ExpirationTime != nil {
+		expiresAt = payload.ExpirationTime.Time
+	}
+	if e := c.Store.Revocations.Revoke(r.Context(), payload.JWTID, expiresAt); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to revoke the user token"}.InternalServerError())
+		return
+	}
+
+	if body.ValidateRefreshToken() {
+		rt, e := c.Store.RefreshTokens.FindByHash(r.Context(), c.Utils.HashToken(body.RefreshToken))
+		if e == nil && rt.UserId == *payload.Id {
+			if e := c.Store.RefreshTokens.RevokeFamily(r.Context(), rt.Family); e != nil {
+				httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to revoke the refresh token"}.InternalServerError())
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(204)
+}
+
+// jwksMaxAge is how long clients may cache the JWKS, which bounds how early a new key must be published before it is
+// used to sign tokens
+const jwksMaxAge = 5 * time.Minute
+
+// GetJWKS serves the public keys that verify the access tokens, so that other services can verify the tokens without
+// sharing a secret with the API
+func (c Controller) GetJWKS(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	b, e := json.Marshal(c.Utils.SigningKeys().JWKS())
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to encode the keys"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwksMaxAge.Seconds())))
+	w.WriteHeader(200)
+	w.Write(b)
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/utils"
+)
+
+func signIn(email string, password string, t *testing.T) string {
+	w := httptest.NewRecorder()
+	body := []byte(`{"email":"` + email + `","password":"` + password + `"}`)
+	r := httptest.NewRequest("POST", "/api/v1/users/signin", bytes.NewBuffer(body))
+	c.SignIn(w, r, nil)
+
+	response := convertResponseToJson(w.Result())
+	refreshToken, _ := response.RefreshToken.(string)
+	if refreshToken == "" {
+		t.Fatalf("Should have returned a refresh token on sign in")
+	}
+	return refreshToken
+}
+
+func refresh(refreshToken string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	body := []byte(`{"refreshToken":"` + refreshToken + `"}`)
+	r := httptest.NewRequest("POST", "/api/v1/users/token/refresh", bytes.NewBuffer(body))
+	c.RefreshToken(w, r, nil)
+	return w
+}
+
+func TestRefreshToken(t *testing.T) {
+	refreshToken := signIn("john@gmail.com", "12345678", t)
+
+	w := refresh(refreshToken)
+	res := w.Result()
+	checkStatusCode(res, 200, t)
+	checkHeader(w, "Content-Type", "application/json", t)
+
+	response := convertResponseToJson(res)
+	if response.Token == nil {
+		t.Errorf("Should have returned a new access token")
+	}
+	if rotated, _ := response.RefreshToken.(string); rotated == "" || rotated == refreshToken {
+		t.Errorf("Should have returned a rotated refresh token rather than %v", rotated)
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	refreshToken := signIn("john@gmail.com", "12345678", t)
+	response := convertResponseToJson(refresh(refreshToken).Result())
+	rotated := response.RefreshToken.(string)
+
+	// the already used token is presented again
+	checkStatusCode(refresh(refreshToken).Result(), 401, t)
+	// the token issued from the rotation belongs to the same family, so it is revoked as well
+	checkStatusCode(refresh(rotated).Result(), 401, t)
+}
+
+func TestRefreshTokenUnknown(t *testing.T) {
+	res := refresh("unknown").Result()
+	checkStatusCode(res, 401, t)
+	response := convertResponseToJson(res)
+	if response.Success {
+		t.Errorf("Should return a success of %v rather than %v", false, response.Success)
+	}
+}
+
+func TestSignOut(t *testing.T) {
+	w := httptest.NewRecorder()
+	body := []byte(`{"email":"john@gmail.com","password":"12345678"}`)
+	c.SignIn(w, httptest.NewRequest("POST", "/api/v1/users/signin", bytes.NewBuffer(body)), nil)
+	response := convertResponseToJson(w.Result())
+	refreshToken := response.RefreshToken.(string)
+	payload, _ := u.VerifyToken([]byte(response.Token.(string)), os.Getenv("JWT_SECRET"))
+
+	w = httptest.NewRecorder()
+	body = []byte(`{"refreshToken":"` + refreshToken + `"}`)
+	r := httptest.NewRequest("POST", "/api/v1/users/signout", bytes.NewBuffer(body))
+	c.SignOut(w, r, nil, payload)
+	checkStatusCode(w.Result(), 204, t)
+
+	revoked, _ := c.Store.Revocations.IsRevoked(r.Context(), payload.JWTID, *payload.Id, payload.IssuedAt.Time)
+	if !revoked {
+		t.Errorf("Should have revoked the access token")
+	}
+	checkStatusCode(refresh(refreshToken).Result(), 401, t)
+}
+
+func TestGetJWKS(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	key, _ := utils.NewEd25519Key("", priv)
+	c.Utils.Keys = utils.NewKeySet(key)
+	defer func() { c.Utils.Keys = nil }()
+
+	user := createUserWithPassword("ozzy", t)
+	w := httptest.NewRecorder()
+	body := []byte(`{"email":"` + user.Email + `","password":"12345678"}`)
+	c.SignIn(w, httptest.NewRequest("POST", "/api/v1/users/signin", bytes.NewBuffer(body)), nil)
+	token := convertResponseToJson(w.Result()).Token.(string)
+
+	w = httptest.NewRecorder()
+	c.GetJWKS(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil), nil)
+	checkStatusCode(w.Result(), 200, t)
+	checkHeader(w, "Cache-Control", "public, max-age=300", t)
+
+	var set utils.JWKS
+	json.NewDecoder(w.Body).Decode(&set)
+	if len(set.Keys) != 1 || set.Keys[0].Kid != key.Id || set.Keys[0].Alg != "EdDSA" {
+		t.Fatalf("Should have published the signing key rather than %+v", set.Keys)
+	}
+
+	// another service verifies the token with nothing but the published key
+	x, _ := base64.RawURLEncoding.DecodeString(set.Keys[0].X)
+	pub, _ := utils.NewPublicKey(set.Keys[0].Kid, ed25519.PublicKey(x))
+	payload, ok := utils.Utils{Keys: utils.NewKeySet(pub)}.ParseToken([]byte(token))
+	if !ok || payload.Email != user.Email {
+		t.Errorf("Should have verified the token with the published key")
+	}
+}
+
+func TestRevokeUserTokensWithinSecond(t *testing.T) {
+	user := createUserWithPassword("bill", t)
+	token, _ := u.IssueToken(*user, time.Hour)
+	payload, _ := u.ParseToken(token)
+
+	// the token is issued within the second of the revocation, or before it
+	if !c.revokeUserTokens(context.Background(), *user.Id) {
+		t.Fatalf("Should have revoked the tokens of the user")
+	}
+	if revoked, _ := c.Store.Revocations.IsRevoked(context.Background(), "", *user.Id, payload.IssuedAt.Time); !revoked {
+		t.Errorf("Should have revoked a token issued within the second of the revocation")
+	}
+}
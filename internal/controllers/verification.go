@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/mail"
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// emailVerificationMaxAge is the lifetime of an email verification token
+const emailVerificationMaxAge = 24 * time.Hour
+
+// issueUserToken replaces the outstanding tokens of a user for a purpose with a new one and returns its opaque value
+func (c Controller) issueUserToken(ctx context.Context, user *models.User, purpose string, maxAge time.Duration) (string, bool) {
+	token, hash, ok := c.Utils.GenerateRefreshToken()
+	if !ok {
+		return "", false
+	}
+	if e := c.Store.UserTokens.DeleteByUser(ctx, *user.Id, purpose); e != nil {
+		return "", false
+	}
+
+	now := time.Now()
+	ut := models.UserToken{UserId: *user.Id, Purpose: purpose, Email: user.Email, Hash: hash, CreatedAt: now, ExpiresAt: now.Add(maxAge)}
+	if _, e := c.Store.UserTokens.Insert(ctx, ut); e != nil {
+		return "", false
+	}
+	return token, true
+}
+
+// useUserToken marks a user token that was issued for a purpose as used and returns it. It returns false when the token
+// is unknown, expired or already used.
+func (c Controller) useUserToken(ctx context.Context, token string, purpose string) (*models.UserToken, bool, error) {
+	ut, e := c.Store.UserTokens.FindByHash(ctx, c.Utils.HashToken(token))
+	if e == store.ErrNotFound {
+		return nil, false, nil
+	}
+	if e != nil {
+		return nil, false, e
+	}
+
+	now := time.Now()
+	if !ut.IsValid(purpose, now) {
+		return nil, false, nil
+	}
+	// the token is marked first, so that it can not be used by two concurrent requests
+	used, e := c.Store.UserTokens.MarkUsed(ctx, *ut.Id, now)
+	if e != nil || !used {
+		return nil, false, e
+	}
+	return ut, true, nil
+}
+
+// sendVerificationEmail issues an email verification token and mails a link that verifies the email to the user
+func (c Controller) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, ok := c.issueUserToken(ctx, user, models.TokenEmailVerification, emailVerificationMaxAge)
+	if !ok {
+		return errors.New("unable to issue an email verification token")
+	}
+
+	link := c.PublicURL + "/api/v1/users/verify?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %v,\n\nPlease confirm your email address by visiting the link below:\n\n%v\n\nThe link expires in %v.\n", user.Username, link, emailVerificationMaxAge)
+	return c.Mailer.Send(ctx, mail.Message{To: user.Email, Subject: "Verify your email address", Body: body})
+}
+
+// verificationPages are the pages of the verification link. The link only shows a form that posts its token, since
+// mail scanners that fetch the links of the emails would otherwise use the token up.
+var verificationPages = template.Must(template.New("verification").Parse(`{{define "confirm"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Verify your email address</title>
+</head>
+<body>
+<h1>Verify your email address</h1>
+<form method="post" action="/api/v1/users/verify/confirm">
+<input type="hidden" name="token" value="{{.Token}}">
+<p><button type="submit">Verify</button></p>
+</form>
+</body>
+</html>
+{{end}}{{define "result"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Verify your email address</title>
+</head>
+<body>
+<h1>Verify your email address</h1>
+<p>{{.Message}}</p>
+</body>
+</html>
+{{end}}`))
+
+// VerifyEmailPage is the page of the link that is mailed to a user, whose form posts the token of the link to
+// VerifyEmail
+func (c Controller) VerifyEmailPage(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	renderPage(w, verificationPages, 200, "confirm", map[string]interface{}{"Token": r.URL.Query().Get("token")})
+}
+
+// VerifyEmail is used to verify the email of a user with the token that was mailed to him/her. Every token can be
+// used once, until it expires. The token is posted by the form of VerifyEmailPage, so the outcome is an HTML page.
+func (c Controller) VerifyEmail(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	ut, ok, e := c.useUserToken(r.Context(), r.PostFormValue("token"), models.TokenEmailVerification)
+	if e != nil {
+		renderVerificationResult(w, 500, "The server was unable to fetch the token")
+		return
+	}
+	if !ok {
+		renderVerificationResult(w, 400, "The link is invalid or has expired")
+		return
+	}
+
+	user, e := c.Store.Users.FindByID(r.Context(), ut.UserId)
+	if e == store.ErrNotFound {
+		renderVerificationResult(w, 404, "User does not exists")
+		return
+	}
+	if e != nil {
+		renderVerificationResult(w, 500, "The server was unable to fetch the user")
+		return
+	}
+	if user.Email != ut.Email {
+		renderVerificationResult(w, 400, "The link is invalid or has expired")
+		return
+	}
+
+	if _, e := c.Store.Users.Update(r.Context(), ut.UserId, map[string]interface{}{"emailVerified": true}); e != nil {
+		renderVerificationResult(w, 500, "The db was unable to update the user")
+		return
+	}
+	renderVerificationResult(w, 200, "Your email address is verified")
+}
+
+// renderVerificationResult responds with the outcome of an email verification
+func renderVerificationResult(w http.ResponseWriter, status int, message string) {
+	renderPage(w, verificationPages, status, "result", map[string]interface{}{"Message": message})
+}
+
+// ResendVerificationEmail is used to mail a new verification link to the authenticated user. Any link that was sent
+// before stops working.
+func (c Controller) ResendVerificationEmail(w http.ResponseWriter, r *http.Request, _ httprouter.Params, other ...interface{}) {
+	payload := other[0].(*utils.Payload)
+
+	user, e := c.Store.Users.FindByID(r.Context(), *payload.Id)
+	if e == store.ErrNotFound {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "User does not exists"}.NotFound())
+		return
+	}
+	if e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to fetch the user"}.InternalServerError())
+		return
+	}
+	if user.EmailVerified {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The email is already verified"}.Conflict())
+		return
+	}
+
+	if e := c.sendVerificationEmail(r.Context(), user); e != nil {
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "The server was unable to send the email"}.InternalServerError())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(202)
+	json.NewEncoder(w).Encode(httpcodes.Representation{Message: "The verification email has been sent"}.Accepted())
+}
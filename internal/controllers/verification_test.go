@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/mail"
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/utils"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// recordingMailer is a mail.Mailer that keeps the sent messages, so the tests can follow the mailed links
+type recordingMailer struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func (m *recordingMailer) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.messages)
+}
+
+// waitFor waits until n messages have been sent, since some emails are sent after the response is written
+func (m *recordingMailer) waitFor(n int, t *testing.T) {
+	for deadline := time.Now().Add(5 * time.Second); m.count() < n; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Should have sent %v messages rather than %v", n, m.count())
+		}
+	}
+}
+
+var mailedToken = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// lastToken returns the token of the last message that was sent to an email
+func (m *recordingMailer) lastToken(to string, t *testing.T) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			if match := mailedToken.FindStringSubmatch(m.messages[i].Body); match != nil {
+				return match[1]
+			}
+		}
+	}
+	t.Fatalf("Should have mailed a token to %v", to)
+	return ""
+}
+
+// uniqueUsername appends a generated suffix to a name, so the users of different tests never share a username or
+// an email
+func uniqueUsername(name string) string {
+	return name + "-" + primitive.NewObjectID().Hex()
+}
+
+// createUser creates a user through the API, whose username is generated from the given name
+func createUser(name string, t *testing.T) map[string]interface{} {
+	username := uniqueUsername(name)
+	w := httptest.NewRecorder()
+	body := `{"username":"` + username + `","password":"12345678","email":"` + username + `@gmail.com"}`
+	c.CreateUser(w, httptest.NewRequest("POST", "/api/v1/users", strings.NewReader(body)), nil)
+
+	res := w.Result()
+	checkStatusCode(res, 201, t)
+	return convertResponseToJson(res).Data.(map[string]interface{})
+}
+
+func verifyEmail(token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/users/verify/confirm", strings.NewReader(url.Values{"token": {token}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c.VerifyEmail(w, r, nil)
+	return w
+}
+
+func TestVerifyEmailPage(t *testing.T) {
+	user := createUser("charlie", t)
+	token := mailer.lastToken(user["email"].(string), t)
+
+	w := httptest.NewRecorder()
+	c.VerifyEmailPage(w, httptest.NewRequest("GET", "/api/v1/users/verify?token="+token, nil), httprouter.Params{httprouter.Param{Key: "id", Value: "verify"}})
+	checkStatusCode(w.Result(), 200, t)
+	checkHeader(w, "Content-Type", "text/html; charset=utf-8", t)
+	if !strings.Contains(w.Body.String(), `name="token" value="`+token+`"`) {
+		t.Errorf("Should have rendered a form that posts the token rather than %v", w.Body.String())
+	}
+
+	// the page does not use the token up
+	checkStatusCode(verifyEmail(token).Result(), 200, t)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	user := createUser("roger", t)
+	email := user["email"].(string)
+	if user["emailVerified"] != false {
+		t.Errorf("Should have created an unverified user rather than %v", user["emailVerified"])
+	}
+
+	token := mailer.lastToken(email, t)
+	w := verifyEmail(token)
+	checkStatusCode(w.Result(), 200, t)
+
+	id := user["id"].(string)
+	stored, _ := c.Store.Users.FindByEmail(context.Background(), email)
+	if !stored.EmailVerified || stored.Id.Hex() != id {
+		t.Errorf("Should have verified the email of the user rather than %v", stored)
+	}
+
+	// a token can only be used once
+	checkStatusCode(verifyEmail(token).Result(), 400, t)
+	checkStatusCode(verifyEmail("invalid").Result(), 400, t)
+}
+
+func TestVerifyEmailExpiredToken(t *testing.T) {
+	user, _ := c.Store.Users.FindByEmail(context.Background(), createUser("brian", t)["email"].(string))
+
+	token, hash, _ := u.GenerateRefreshToken()
+	expired := time.Now().Add(-time.Minute)
+	c.Store.UserTokens.Insert(context.Background(), models.UserToken{UserId: *user.Id, Purpose: models.TokenEmailVerification, Email: user.Email, Hash: hash, ExpiresAt: expired})
+	checkStatusCode(verifyEmail(token).Result(), 400, t)
+}
+
+func TestChangeEmailRequiresVerification(t *testing.T) {
+	user, _ := c.Store.Users.FindByEmail(context.Background(), createUser("keith", t)["email"].(string))
+	payload := generateUserPayload(*user)
+	old := mailer.lastToken(user.Email, t)
+	checkStatusCode(verifyEmail(old).Result(), 200, t)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/api/v1/users/"+user.Id.Hex(), strings.NewReader(`{"email":"keith@stones.com"}`))
+	c.UpdateUser(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: user.Id.Hex()}}, payload)
+	checkStatusCode(w.Result(), 200, t)
+	if updated := convertResponseToJson(w.Result()).Data.(map[string]interface{}); updated["emailVerified"] != false {
+		t.Errorf("Should have reset the verification of a changed email rather than %v", updated["emailVerified"])
+	}
+
+	checkStatusCode(verifyEmail(mailer.lastToken("keith@stones.com", t)).Result(), 200, t)
+	checkStatusCode(resendVerificationEmail(payload).Result(), 409, t)
+}
+
+func resendVerificationEmail(payload *utils.Payload) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c.ResendVerificationEmail(w, httptest.NewRequest("POST", "/api/v1/users/verify", nil), nil, payload)
+	return w
+}
+
+func TestResendVerificationEmail(t *testing.T) {
+	user, _ := c.Store.Users.FindByEmail(context.Background(), createUser("ronnie", t)["email"].(string))
+	first := mailer.lastToken(user.Email, t)
+
+	checkStatusCode(resendVerificationEmail(generateUserPayload(*user)).Result(), 202, t)
+	second := mailer.lastToken(user.Email, t)
+	if first == second {
+		t.Fatalf("Should have mailed a new token")
+	}
+	checkStatusCode(verifyEmail(first).Result(), 400, t)
+	checkStatusCode(verifyEmail(second).Result(), 200, t)
+}
@@ -0,0 +1,134 @@
+// Package mail contains the mailers used to send emails to the users of the API
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a custom type used to represent a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer is an interface that describes a service that delivers emails
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// encode returns the RFC 5322 representation of a message
+func encode(from string, msg Message, now time.Time) []byte {
+	var bf bytes.Buffer
+	fmt.Fprintf(&bf, "From: %v\r\n", from)
+	fmt.Fprintf(&bf, "To: %v\r\n", msg.To)
+	fmt.Fprintf(&bf, "Subject: %v\r\n", msg.Subject)
+	fmt.Fprintf(&bf, "Date: %v\r\n", now.Format(time.RFC1123Z))
+	bf.WriteString("MIME-Version: 1.0\r\n")
+	bf.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	bf.WriteString(strings.Replace(msg.Body, "\n", "\r\n", -1))
+	bf.WriteString("\r\n")
+	return bf.Bytes()
+}
+
+// validHeader checks that a value can be written within a header, so that a message can not inject headers
+func validHeader(v string) bool {
+	return !strings.ContainsAny(v, "\r\n")
+}
+
+func validate(msg Message) error {
+	if !validHeader(msg.To) || !validHeader(msg.Subject) {
+		return fmt.Errorf("mail: invalid header in message to %q", msg.To)
+	}
+	return nil
+}
+
+// SMTPMailer is a Mailer that delivers emails through an SMTP server
+type SMTPMailer struct {
+	// Addr is the host:port of the server
+	Addr string
+	From string
+	// Auth is used to authenticate with the server when it is not nil
+	Auth smtp.Auth
+}
+
+// NewSMTPMailer returns an SMTPMailer that authenticates with the server when a username is given
+func NewSMTPMailer(addr string, from string, username string, password string) *SMTPMailer {
+	m := &SMTPMailer{Addr: addr, From: from}
+	if username != "" {
+		host := strings.Split(addr, ":")[0]
+		m.Auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+// Send delivers a message through the SMTP server
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if e := validate(msg); e != nil {
+		return e
+	}
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{msg.To}, encode(m.From, msg, time.Now()))
+}
+
+// WriterMailer is a Mailer that writes emails to a writer, such as the console, rather than delivering them
+type WriterMailer struct {
+	mu   sync.Mutex
+	W    io.Writer
+	From string
+}
+
+// NewConsoleMailer returns a WriterMailer that writes emails to the standard output
+func NewConsoleMailer(from string) *WriterMailer {
+	return &WriterMailer{W: os.Stdout, From: from}
+}
+
+// Send writes a message to the writer
+func (m *WriterMailer) Send(ctx context.Context, msg Message) error {
+	if e := validate(msg); e != nil {
+		return e
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, e := m.W.Write(append(encode(m.From, msg, time.Now()), "\r\n"...))
+	return e
+}
+
+// FileMailer is a Mailer that stores every email as an .eml file within a directory rather than delivering it
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+// NewFileMailer returns a FileMailer that stores emails within a directory
+func NewFileMailer(dir string, from string) *FileMailer {
+	return &FileMailer{Dir: dir, From: from}
+}
+
+// Send stores a message within the directory of the mailer
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if e := validate(msg); e != nil {
+		return e
+	}
+	if e := os.MkdirAll(m.Dir, 0755); e != nil {
+		return e
+	}
+
+	now := time.Now()
+	f, e := ioutil.TempFile(m.Dir, now.Format("20060102T150405")+"-*.eml")
+	if e != nil {
+		return e
+	}
+	defer f.Close()
+	_, e = f.Write(encode(m.From, msg, now))
+	return e
+}
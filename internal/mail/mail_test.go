@@ -0,0 +1,54 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriterMailer(t *testing.T) {
+	var bf bytes.Buffer
+	m := &WriterMailer{W: &bf, From: "noreply@example.com"}
+	if e := m.Send(context.Background(), Message{To: "paul@gmail.com", Subject: "Hello", Body: "line one\nline two"}); e != nil {
+		t.Fatalf("Should have written the message rather than returning %v", e)
+	}
+
+	for _, expected := range []string{"From: noreply@example.com\r\n", "To: paul@gmail.com\r\n", "Subject: Hello\r\n", "\r\n\r\nline one\r\nline two\r\n"} {
+		if !strings.Contains(bf.String(), expected) {
+			t.Errorf("Should have written %q within %q", expected, bf.String())
+		}
+	}
+}
+
+func TestMailerRejectsHeaderInjection(t *testing.T) {
+	var bf bytes.Buffer
+	m := &WriterMailer{W: &bf, From: "noreply@example.com"}
+	if e := m.Send(context.Background(), Message{To: "paul@gmail.com\r\nBcc: john@gmail.com", Subject: "Hello"}); e == nil {
+		t.Errorf("Should have rejected a recipient with a line break")
+	}
+	if bf.Len() != 0 {
+		t.Errorf("Should not have written the message rather than %q", bf.String())
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mail")
+	defer os.RemoveAll(dir)
+
+	m := NewFileMailer(filepath.Join(dir, "outbox"), "noreply@example.com")
+	if e := m.Send(context.Background(), Message{To: "paul@gmail.com", Subject: "Hello", Body: "Hi"}); e != nil {
+		t.Fatalf("Should have stored the message rather than returning %v", e)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "outbox", "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("Should have stored a single .eml file rather than %v", files)
+	}
+	if b, _ := ioutil.ReadFile(files[0]); !bytes.Contains(b, []byte("To: paul@gmail.com\r\n")) {
+		t.Errorf("Should have stored the message rather than %q", b)
+	}
+}
@@ -0,0 +1,51 @@
+// Package markdown renders the Markdown written by users to HTML that is safe to embed within a page
+package markdown
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PhotoScheme is the scheme of the image links that reference an uploaded photo, e.g. ![](photo:<id>)
+const PhotoScheme = "photo:"
+
+// PhotoURL resolves the id of an uploaded photo to the URL that an image is served from
+type PhotoURL func(id primitive.ObjectID) string
+
+// policy is the allow-list of the elements and attributes kept in the rendered HTML. It is safe for concurrent use.
+var policy = bluemonday.UGCPolicy()
+
+// Render converts a Markdown text to sanitised HTML. Images that reference an uploaded photo are resolved through
+// photoURL, while references to invalid ids are dropped by the sanitiser.
+func Render(src string, photoURL PhotoURL) string {
+	r := blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{Flags: blackfriday.CommonHTMLFlags})
+	md := blackfriday.New(blackfriday.WithRenderer(r), blackfriday.WithExtensions(blackfriday.CommonExtensions))
+	ast := md.Parse([]byte(src))
+
+	var bf bytes.Buffer
+	ast.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if entering && node.Type == blackfriday.Image {
+			node.LinkData.Destination = resolvePhoto(node.LinkData.Destination, photoURL)
+		}
+		return r.RenderNode(&bf, node, entering)
+	})
+	return policy.Sanitize(bf.String())
+}
+
+// resolvePhoto rewrites the destination of an image that references an uploaded photo
+func resolvePhoto(dest []byte, photoURL PhotoURL) []byte {
+	d := string(dest)
+	if !strings.HasPrefix(strings.ToLower(d), PhotoScheme) {
+		return dest
+	}
+
+	oid, e := primitive.ObjectIDFromHex(d[len(PhotoScheme):])
+	if e != nil {
+		return dest
+	}
+	return []byte(photoURL(oid))
+}
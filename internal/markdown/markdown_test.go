@@ -0,0 +1,52 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func photoURL(id primitive.ObjectID) string {
+	return "/api/v1/photos/" + id.Hex() + "/renditions/1280"
+}
+
+func TestRender(t *testing.T) {
+	html := Render("# Title\n\nSome *emphasis* and a [link](https://example.com).", photoURL)
+
+	for _, expected := range []string{"<h1>Title</h1>", "<em>emphasis</em>", `<a href="https://example.com" rel="nofollow">link</a>`} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Should have rendered %v within %v", expected, html)
+		}
+	}
+}
+
+func TestRenderSanitises(t *testing.T) {
+	for _, src := range []string{
+		"<script>alert(1)</script>",
+		`<img src="x" onerror="alert(1)">`,
+		"[click](javascript:alert(1))",
+		`<a href="#" onclick="alert(1)">click</a>`,
+	} {
+		html := Render(src, photoURL)
+		for _, unsafe := range []string{"<script", "onerror", "onclick", "javascript:"} {
+			if strings.Contains(html, unsafe) {
+				t.Errorf("Should have sanitised %v rather than returning %v", src, html)
+			}
+		}
+	}
+}
+
+func TestRenderResolvesPhotos(t *testing.T) {
+	id := primitive.NewObjectID()
+	html := Render("![A sunset](photo:"+id.Hex()+")", photoURL)
+
+	if expected := `<img src="/api/v1/photos/` + id.Hex() + `/renditions/1280" alt="A sunset"/>`; !strings.Contains(html, expected) {
+		t.Errorf("Should have resolved the photo to %v rather than %v", expected, html)
+	}
+
+	html = Render("![](photo:invalid)", photoURL)
+	if strings.Contains(html, "photo:") {
+		t.Errorf("Should have dropped an invalid photo reference rather than returning %v", html)
+	}
+}
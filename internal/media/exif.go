@@ -0,0 +1,622 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+)
+
+// ErrInvalidExif is returned when the EXIF metadata of a photo can not be parsed
+var ErrInvalidExif = errors.New("media: invalid EXIF metadata")
+
+// EXIF tags read from the photos
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagExposureTime     = 0x829A
+	tagFNumber          = 0x829D
+	tagISO              = 0x8827
+	tagDateTimeOriginal = 0x9003
+	tagFocalLength      = 0x920A
+	tagLensMake         = 0xA433
+	tagLensModel        = 0xA434
+	tagGPSLatitudeRef   = 0x0001
+	tagGPSLatitude      = 0x0002
+	tagGPSLongitudeRef  = 0x0003
+	tagGPSLongitude     = 0x0004
+)
+
+// exifDateLayout is the layout of the dates stored in EXIF metadata
+const exifDateLayout = "2006:01:02 15:04:05"
+
+// exifHeader prefixes the TIFF structure within a JPEG APP1 segment
+var exifHeader = []byte("Exif\x00\x00")
+
+// xmpHeader prefixes the XMP metadata within a JPEG APP1 segment, which may repeat the GPS coordinates
+var xmpHeader = []byte("http://ns.adobe.com/xap/1.0/\x00")
+
+// xmpExtensionHeader prefixes the segments of extended XMP metadata, which does not fit in a single APP1 segment
+var xmpExtensionHeader = []byte("http://ns.adobe.com/xmp/extension/\x00")
+
+// xmpKeyword is the keyword of the PNG text chunks that hold XMP metadata
+var xmpKeyword = []byte("XML:com.adobe.xmp\x00")
+
+// typeSizes maps a TIFF field type to the size of a single value
+var typeSizes = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// tiff is a TIFF structure, which holds the EXIF metadata of a photo
+type tiff struct {
+	b  []byte
+	bo binary.ByteOrder
+}
+
+// entry is a field of an image file directory (IFD). pos is the offset of the value, or of the offset to the value
+// when it does not fit in 4 bytes.
+type entry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	pos   uint32
+}
+
+func newTIFF(b []byte) (*tiff, error) {
+	if len(b) < 8 {
+		return nil, ErrInvalidExif
+	}
+	switch string(b[:4]) {
+	case "II*\x00":
+		return &tiff{b, binary.LittleEndian}, nil
+	case "MM\x00*":
+		return &tiff{b, binary.BigEndian}, nil
+	}
+	return nil, ErrInvalidExif
+}
+
+func (t *tiff) u16(off uint32) (uint16, bool) {
+	if uint64(off)+2 > uint64(len(t.b)) {
+		return 0, false
+	}
+	return t.bo.Uint16(t.b[off:]), true
+}
+
+func (t *tiff) u32(off uint32) (uint32, bool) {
+	if uint64(off)+4 > uint64(len(t.b)) {
+		return 0, false
+	}
+	return t.bo.Uint32(t.b[off:]), true
+}
+
+// ifd0 returns the offset of the first image file directory
+func (t *tiff) ifd0() uint32 {
+	off, _ := t.u32(4)
+	return off
+}
+
+// entries returns the fields of the image file directory at off
+func (t *tiff) entries(off uint32) ([]entry, error) {
+	n, ok := t.u16(off)
+	if !ok || uint64(off)+2+uint64(n)*12+4 > uint64(len(t.b)) {
+		return nil, ErrInvalidExif
+	}
+
+	entries := make([]entry, 0, n)
+	for i := uint32(0); i < uint32(n); i++ {
+		p := off + 2 + i*12
+		entries = append(entries, entry{
+			tag:   t.bo.Uint16(t.b[p:]),
+			typ:   t.bo.Uint16(t.b[p+2:]),
+			count: t.bo.Uint32(t.b[p+4:]),
+			pos:   p + 8,
+		})
+	}
+	return entries, nil
+}
+
+// value returns the offset and size of the value of a field
+func (t *tiff) value(e entry) (uint32, uint32, bool) {
+	size := uint64(typeSizes[e.typ]) * uint64(e.count)
+	off := e.pos
+	if size > 4 {
+		o, ok := t.u32(e.pos)
+		if !ok {
+			return 0, 0, false
+		}
+		off = o
+	}
+	if size == 0 || uint64(off)+size > uint64(len(t.b)) {
+		return 0, 0, false
+	}
+	return off, uint32(size), true
+}
+
+func (t *tiff) ascii(e entry) string {
+	off, size, ok := t.value(e)
+	if !ok || e.typ != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(t.b[off:off+size]), "\x00"))
+}
+
+func (t *tiff) uint(e entry) (uint32, bool) {
+	off, _, ok := t.value(e)
+	if !ok {
+		return 0, false
+	}
+	switch e.typ {
+	case 3:
+		v, ok := t.u16(off)
+		return uint32(v), ok
+	case 4:
+		return t.u32(off)
+	}
+	return 0, false
+}
+
+// rational returns the i-th rational of a field as its numerator and denominator
+func (t *tiff) rational(e entry, i uint32) (uint32, uint32, bool) {
+	off, _, ok := t.value(e)
+	if !ok || (e.typ != 5 && e.typ != 10) || i >= e.count {
+		return 0, 0, false
+	}
+	num, _ := t.u32(off + i*8)
+	den, _ := t.u32(off + i*8 + 4)
+	if den == 0 {
+		return 0, 0, false
+	}
+	return num, den, true
+}
+
+func (t *tiff) float(e entry, i uint32) (float64, bool) {
+	num, den, ok := t.rational(e, i)
+	if !ok {
+		return 0, false
+	}
+	return float64(num) / float64(den), true
+}
+
+// coordinate converts degrees, minutes and seconds to a signed decimal coordinate
+func (t *tiff) coordinate(e entry, ref string) (*float64, bool) {
+	var dms [3]float64
+	for i := range dms {
+		v, ok := t.float(e, uint32(i))
+		if !ok {
+			return nil, false
+		}
+		dms[i] = v
+	}
+	c := dms[0] + dms[1]/60 + dms[2]/3600
+	if ref == "S" || ref == "W" {
+		c = -c
+	}
+	c = math.Round(c*1e6) / 1e6
+	return &c, true
+}
+
+func formatExposure(num uint32, den uint32) string {
+	if num == 0 {
+		return ""
+	}
+	if num < den {
+		return fmt.Sprintf("1/%d", uint32(math.Round(float64(den)/float64(num))))
+	}
+	return fmt.Sprintf("%gs", math.Round(float64(num)/float64(den)*10)/10)
+}
+
+// parse extracts the EXIF fields shown next to the photos
+func (t *tiff) parse() (*models.Exif, error) {
+	ifd0, e := t.entries(t.ifd0())
+	if e != nil {
+		return nil, e
+	}
+
+	exif := models.Exif{}
+	var exifIFD, gpsIFD uint32
+	var dateTime string
+	for _, en := range ifd0 {
+		switch en.tag {
+		case tagMake:
+			exif.Make = t.ascii(en)
+		case tagModel:
+			exif.Model = t.ascii(en)
+		case tagDateTime:
+			dateTime = t.ascii(en)
+		case tagExifIFD:
+			exifIFD, _ = t.uint(en)
+		case tagGPSIFD:
+			gpsIFD, _ = t.uint(en)
+		}
+	}
+
+	if exifIFD != 0 {
+		entries, e := t.entries(exifIFD)
+		if e != nil {
+			return nil, e
+		}
+		for _, en := range entries {
+			switch en.tag {
+			case tagExposureTime:
+				if num, den, ok := t.rational(en, 0); ok {
+					exif.ExposureTime = formatExposure(num, den)
+				}
+			case tagFNumber:
+				if v, ok := t.float(en, 0); ok {
+					exif.FNumber = math.Round(v*10) / 10
+				}
+			case tagFocalLength:
+				if v, ok := t.float(en, 0); ok {
+					exif.FocalLength = math.Round(v*10) / 10
+				}
+			case tagISO:
+				if v, ok := t.uint(en); ok {
+					exif.ISO = int(v)
+				}
+			case tagDateTimeOriginal:
+				if v := t.ascii(en); v != "" {
+					dateTime = v
+				}
+			case tagLensMake:
+				exif.LensMake = t.ascii(en)
+			case tagLensModel:
+				exif.LensModel = t.ascii(en)
+			}
+		}
+	}
+	if d, e := time.Parse(exifDateLayout, dateTime); e == nil {
+		exif.CapturedAt = &d
+	}
+
+	if gpsIFD != 0 {
+		entries, e := t.entries(gpsIFD)
+		if e != nil {
+			return nil, e
+		}
+		refs := map[uint16]string{}
+		for _, en := range entries {
+			if en.tag == tagGPSLatitudeRef || en.tag == tagGPSLongitudeRef {
+				refs[en.tag] = t.ascii(en)
+			}
+		}
+		for _, en := range entries {
+			switch en.tag {
+			case tagGPSLatitude:
+				exif.Latitude, _ = t.coordinate(en, refs[tagGPSLatitudeRef])
+			case tagGPSLongitude:
+				exif.Longitude, _ = t.coordinate(en, refs[tagGPSLongitudeRef])
+			}
+		}
+	}
+	return &exif, nil
+}
+
+// stripGPS removes the GPS directory from the TIFF structure in place, so offsets used by the rest of the metadata
+// remain valid. The directory and its values are zeroed, while its field is removed from the first directory. An error
+// is returned when the GPS directory can not be parsed, in which case the coordinates may remain.
+func (t *tiff) stripGPS() error {
+	off := t.ifd0()
+	ifd0, e := t.entries(off)
+	if e != nil {
+		return e
+	}
+
+	for i, en := range ifd0 {
+		if en.tag != tagGPSIFD {
+			continue
+		}
+
+		gpsIFD, ok := t.uint(en)
+		if !ok {
+			return ErrInvalidExif
+		}
+		entries, e := t.entries(gpsIFD)
+		if e != nil {
+			return e
+		}
+		for _, g := range entries {
+			if v, size, ok := t.value(g); ok && size > 4 {
+				zero(t.b[v : v+size])
+			}
+		}
+		zero(t.b[gpsIFD : gpsIFD+2+uint32(len(entries))*12+4])
+
+		// the following fields and the offset of the next directory are shifted over the removed field
+		start := off + 2 + uint32(i)*12
+		end := off + 2 + uint32(len(ifd0))*12 + 4
+		copy(t.b[start:], t.b[start+12:end])
+		zero(t.b[end-12 : end])
+		t.bo.PutUint16(t.b[off:], uint16(len(ifd0)-1))
+		return nil
+	}
+	return nil
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// exifLocation is the position of the TIFF structure within a photo
+type exifLocation struct {
+	start int
+	end   int
+}
+
+// locateExif returns the position of the first TIFF structure within a JPEG, PNG or WebP photo
+func locateExif(b []byte, contentType string) (exifLocation, bool) {
+	switch contentType {
+	case JPEG:
+		for _, s := range jpegSegments(b) {
+			if s.marker == 0xE1 && bytes.HasPrefix(b[s.start:s.end], exifHeader) {
+				return exifLocation{s.start + len(exifHeader), s.end}, true
+			}
+		}
+	case PNG:
+		for _, c := range pngChunks(b) {
+			if c.typ == "eXIf" {
+				return exifLocation{c.start, c.end}, true
+			}
+		}
+	case WebP:
+		for _, c := range webpChunks(b) {
+			if c.typ == "EXIF" {
+				return exifLocation{c.start + exifOffset(b[c.start:c.end]), c.end}, true
+			}
+		}
+	}
+	return exifLocation{}, false
+}
+
+// exifOffset returns the length of the optional EXIF header that precedes the TIFF structure of a WebP chunk
+func exifOffset(b []byte) int {
+	if bytes.HasPrefix(b, exifHeader) {
+		return len(exifHeader)
+	}
+	return 0
+}
+
+// chunk is a PNG or WebP chunk, whose data lies between start and end. The chunk, along with its CRC or padding, lies
+// between offset and next.
+type chunk struct {
+	typ    string
+	offset int
+	start  int
+	end    int
+	next   int
+}
+
+// pngChunks returns the chunks of a PNG photo, up to the first chunk that is truncated
+func pngChunks(b []byte) []chunk {
+	var chunks []chunk
+	for p := 8; p+12 <= len(b); {
+		n := int(binary.BigEndian.Uint32(b[p:]))
+		if n < 0 || p+12+n > len(b) {
+			break
+		}
+		chunks = append(chunks, chunk{string(b[p+4 : p+8]), p, p + 8, p + 8 + n, p + 12 + n})
+		p += 12 + n
+	}
+	return chunks
+}
+
+// webpChunks returns the chunks of a WebP photo, up to the first chunk that is truncated
+func webpChunks(b []byte) []chunk {
+	var chunks []chunk
+	for p := 12; p+8 <= len(b); {
+		n := int(binary.LittleEndian.Uint32(b[p+4:]))
+		if n < 0 || p+8+n > len(b) {
+			break
+		}
+		next := p + 8 + n + n%2
+		if next > len(b) {
+			next = len(b)
+		}
+		chunks = append(chunks, chunk{string(b[p : p+4]), p, p + 8, p + 8 + n, next})
+		p = next
+	}
+	return chunks
+}
+
+// segment is a JPEG marker segment, whose data lies between start and end
+type segment struct {
+	marker byte
+	offset int
+	start  int
+	end    int
+}
+
+// jpegSegments returns the marker segments of a JPEG photo that precede the image data
+func jpegSegments(b []byte) []segment {
+	var segments []segment
+	for p := 2; p+4 <= len(b) && b[p] == 0xFF; {
+		marker := b[p+1]
+		if marker == 0xFF {
+			p++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		n := int(binary.BigEndian.Uint16(b[p+2:]))
+		if n < 2 || p+2+n > len(b) {
+			break
+		}
+		segments = append(segments, segment{marker, p, p + 4, p + 2 + n})
+		p += 2 + n
+	}
+	return segments
+}
+
+// ParseExif extracts the EXIF metadata of a photo. It returns nil when the photo does not contain any.
+func ParseExif(b []byte, contentType string) *models.Exif {
+	loc, ok := locateExif(b, contentType)
+	if !ok {
+		return nil
+	}
+	t, e := newTIFF(b[loc.start:loc.end])
+	if e != nil {
+		return nil
+	}
+	exif, e := t.parse()
+	if e != nil {
+		return nil
+	}
+	return exif
+}
+
+// ParseOrientation returns the value of the EXIF Orientation tag of a photo, which tells how the stored pixels are
+// turned upright. It returns 1, the value of upright photos, when the photo does not have the tag.
+func ParseOrientation(b []byte, contentType string) int {
+	loc, ok := locateExif(b, contentType)
+	if !ok {
+		return 1
+	}
+	t, e := newTIFF(b[loc.start:loc.end])
+	if e != nil {
+		return 1
+	}
+	entries, e := t.entries(t.ifd0())
+	if e != nil {
+		return 1
+	}
+	for _, en := range entries {
+		if en.tag == tagOrientation {
+			if v, ok := t.uint(en); ok && v >= 1 && v <= 8 {
+				return int(v)
+			}
+		}
+	}
+	return 1
+}
+
+// StripLocation returns a copy of a photo without its GPS metadata. The GPS directory is removed from every EXIF block
+// of the photo, while blocks whose GPS directory can not be parsed are dropped as a whole. XMP metadata, which may
+// repeat the coordinates, is dropped as well.
+func StripLocation(b []byte, contentType string) []byte {
+	switch contentType {
+	case JPEG:
+		return stripJPEG(b)
+	case PNG:
+		return stripPNG(b)
+	case WebP:
+		return stripWebP(b)
+	}
+	return append([]byte{}, b...)
+}
+
+// withoutGPS removes the GPS directory from a TIFF structure in place. It returns false when the structure can not be
+// parsed, so the block that contains it is dropped.
+func withoutGPS(b []byte) bool {
+	t, e := newTIFF(b)
+	return e == nil && t.stripGPS() == nil
+}
+
+func stripJPEG(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	p := 0
+	for _, s := range jpegSegments(b) {
+		if s.marker != 0xE1 {
+			continue
+		}
+
+		// the segments that precede an APP1 segment are copied as is
+		out = append(out, b[p:s.offset]...)
+		p = s.end
+		data := b[s.start:s.end]
+		switch {
+		case bytes.HasPrefix(data, exifHeader):
+			segment := append([]byte{}, b[s.offset:s.end]...)
+			if withoutGPS(segment[s.start-s.offset+len(exifHeader):]) {
+				out = append(out, segment...)
+			}
+		case bytes.HasPrefix(data, xmpHeader), bytes.HasPrefix(data, xmpExtensionHeader):
+			// XMP segments are dropped
+		default:
+			out = append(out, b[s.offset:s.end]...)
+		}
+	}
+	return append(out, b[p:]...)
+}
+
+func stripPNG(b []byte) []byte {
+	chunks := pngChunks(b)
+	if len(chunks) == 0 {
+		return append([]byte{}, b...)
+	}
+
+	out := append(make([]byte, 0, len(b)), b[:chunks[0].offset]...)
+	for _, c := range chunks {
+		switch c.typ {
+		case "eXIf":
+			ch := append([]byte{}, b[c.offset:c.next]...)
+			if !withoutGPS(ch[8 : 8+c.end-c.start]) {
+				continue
+			}
+			// the CRC of a chunk covers its type and data
+			binary.BigEndian.PutUint32(ch[len(ch)-4:], crc32.ChecksumIEEE(ch[4:len(ch)-4]))
+			out = append(out, ch...)
+		case "iTXt", "tEXt", "zTXt":
+			if !bytes.HasPrefix(b[c.start:c.end], xmpKeyword) {
+				out = append(out, b[c.offset:c.next]...)
+			}
+		default:
+			out = append(out, b[c.offset:c.next]...)
+		}
+	}
+	return append(out, b[chunks[len(chunks)-1].next:]...)
+}
+
+// VP8X flags that announce the metadata of a WebP photo
+const (
+	webpFlagXMP  = 0x04
+	webpFlagEXIF = 0x08
+)
+
+func stripWebP(b []byte) []byte {
+	chunks := webpChunks(b)
+	if len(chunks) == 0 {
+		return append([]byte{}, b...)
+	}
+
+	out := append(make([]byte, 0, len(b)), b[:chunks[0].offset]...)
+	var cleared byte
+	vp8x := -1
+	for _, c := range chunks {
+		switch c.typ {
+		case "VP8X":
+			vp8x = len(out) + 8
+			out = append(out, b[c.offset:c.next]...)
+		case "EXIF":
+			ch := append([]byte{}, b[c.offset:c.next]...)
+			if !withoutGPS(ch[8+exifOffset(b[c.start:c.end]) : 8+c.end-c.start]) {
+				cleared |= webpFlagEXIF
+				continue
+			}
+			out = append(out, ch...)
+		case "XMP ":
+			cleared |= webpFlagXMP
+		default:
+			out = append(out, b[c.offset:c.next]...)
+		}
+	}
+	out = append(out, b[chunks[len(chunks)-1].next:]...)
+
+	// the flags announce only the metadata that is left, while the size of the RIFF container covers the new chunks
+	if cleared != 0 {
+		if vp8x >= 0 && vp8x < len(out) {
+			out[vp8x] &^= cleared
+		}
+		binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	}
+	return out
+}
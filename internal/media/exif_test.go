@@ -0,0 +1,302 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+type field struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+func ascii(tag uint16, s string) field {
+	return field{tag, 2, uint32(len(s) + 1), append([]byte(s), 0)}
+}
+
+func rationals(tag uint16, v ...uint32) field {
+	b := make([]byte, 4*len(v))
+	for i, n := range v {
+		binary.LittleEndian.PutUint32(b[i*4:], n)
+	}
+	return field{tag, 5, uint32(len(v) / 2), b}
+}
+
+func short(tag uint16, v uint16) field {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, v)
+	return field{tag, 3, 1, b}
+}
+
+func long(tag uint16, v uint32) field {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return field{tag, 4, 1, b}
+}
+
+// buildTIFF returns a little-endian TIFF structure with a first directory that points to an EXIF and a GPS directory
+func buildTIFF(ifd0 []field, exif []field, gps []field) []byte {
+	size := func(n int) uint32 { return uint32(2 + 12*n + 4) }
+	o0 := uint32(8)
+	oExif := o0 + size(len(ifd0)+2)
+	oGPS := oExif + size(len(exif))
+	data := oGPS + size(len(gps))
+	ifd0 = append(ifd0, long(tagExifIFD, oExif), long(tagGPSIFD, oGPS))
+
+	b := make([]byte, data)
+	copy(b, "II*\x00")
+	binary.LittleEndian.PutUint32(b[4:], o0)
+	for _, ifd := range []struct {
+		off    uint32
+		fields []field
+	}{{o0, ifd0}, {oExif, exif}, {oGPS, gps}} {
+		binary.LittleEndian.PutUint16(b[ifd.off:], uint16(len(ifd.fields)))
+		for i, f := range ifd.fields {
+			p := ifd.off + 2 + uint32(i)*12
+			binary.LittleEndian.PutUint16(b[p:], f.tag)
+			binary.LittleEndian.PutUint16(b[p+2:], f.typ)
+			binary.LittleEndian.PutUint32(b[p+4:], f.count)
+			if len(f.data) <= 4 {
+				copy(b[p+8:], f.data)
+			} else {
+				binary.LittleEndian.PutUint32(b[p+8:], uint32(len(b)))
+				b = append(b, f.data...)
+			}
+		}
+	}
+	return b
+}
+
+func newExifTIFF() []byte {
+	return buildTIFF(
+		[]field{ascii(tagMake, "Canon"), ascii(tagModel, "EOS R5")},
+		[]field{
+			rationals(tagExposureTime, 1, 250),
+			rationals(tagFNumber, 28, 10),
+			short(tagISO, 400),
+			rationals(tagFocalLength, 50, 1),
+			ascii(tagDateTimeOriginal, "2019:10:27 18:30:00"),
+			ascii(tagLensModel, "RF50mm F1.2 L USM"),
+		},
+		[]field{
+			ascii(tagGPSLatitudeRef, "N"),
+			rationals(tagGPSLatitude, 51, 1, 30, 1, 0, 1),
+			ascii(tagGPSLongitudeRef, "W"),
+			rationals(tagGPSLongitude, 0, 1, 7, 1, 30, 1),
+		},
+	)
+}
+
+func newExifJPEG() []byte {
+	var bf bytes.Buffer
+	jpeg.Encode(&bf, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil)
+	encoded := bf.Bytes()
+
+	app1 := append(append([]byte{}, exifHeader...), newExifTIFF()...)
+	xmp := append(append([]byte{}, xmpHeader...), []byte(`<exif:GPSLatitude>51,30.0N</exif:GPSLatitude>`)...)
+
+	out := []byte{0xFF, 0xD8}
+	for _, seg := range [][]byte{app1, xmp} {
+		out = append(out, 0xFF, 0xE1, byte((len(seg)+2)>>8), byte(len(seg)+2))
+		out = append(out, seg...)
+	}
+	return append(out, encoded[2:]...)
+}
+
+func TestParseExif(t *testing.T) {
+	exif := ParseExif(newExifJPEG(), JPEG)
+	if exif == nil {
+		t.Fatalf("Should have parsed the EXIF metadata")
+	}
+	if exif.Make != "Canon" || exif.Model != "EOS R5" || exif.LensModel != "RF50mm F1.2 L USM" {
+		t.Errorf("Should have parsed the camera and lens rather than %v", exif)
+	}
+	if exif.ExposureTime != "1/250" || exif.FNumber != 2.8 || exif.ISO != 400 || exif.FocalLength != 50 {
+		t.Errorf("Should have parsed the exposure rather than %v", exif)
+	}
+	if exif.CapturedAt == nil || exif.CapturedAt.Format("2006-01-02 15:04") != "2019-10-27 18:30" {
+		t.Errorf("Should have parsed the capture date rather than %v", exif.CapturedAt)
+	}
+	if !exif.HasLocation() || *exif.Latitude != 51.5 || *exif.Longitude != -0.125 {
+		t.Errorf("Should have parsed the GPS coordinates rather than %v, %v", exif.Latitude, exif.Longitude)
+	}
+}
+
+func TestParseExifWithoutMetadata(t *testing.T) {
+	var bf bytes.Buffer
+	jpeg.Encode(&bf, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil)
+	if exif := ParseExif(bf.Bytes(), JPEG); exif != nil {
+		t.Errorf("Should have returned nil for a photo without metadata rather than %v", exif)
+	}
+}
+
+func TestParseOrientation(t *testing.T) {
+	var bf bytes.Buffer
+	png.Encode(&bf, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	if o := ParseOrientation(bf.Bytes(), PNG); o != 1 {
+		t.Errorf("Should have returned 1 for a photo without metadata rather than %v", o)
+	}
+	photo := insertPNGChunk(bf.Bytes(), "eXIf", buildTIFF([]field{short(tagOrientation, 6)}, nil, nil))
+	if o := ParseOrientation(photo, PNG); o != 6 {
+		t.Errorf("Should have returned the orientation of the photo rather than %v", o)
+	}
+}
+
+func TestStripLocation(t *testing.T) {
+	original := newExifJPEG()
+	stripped := StripLocation(original, JPEG)
+
+	exif := ParseExif(stripped, JPEG)
+	if exif == nil || exif.Make != "Canon" || exif.ISO != 400 {
+		t.Fatalf("Should have kept the rest of the metadata rather than %v", exif)
+	}
+	if exif.HasLocation() {
+		t.Errorf("Should have removed the GPS coordinates")
+	}
+	if bytes.Contains(stripped, []byte("GPSLatitude")) {
+		t.Errorf("Should have removed the XMP metadata")
+	}
+	if _, e := jpeg.Decode(bytes.NewReader(stripped)); e != nil {
+		t.Errorf("Should have returned a valid JPEG rather than %v", e)
+	}
+	if !ParseExif(original, JPEG).HasLocation() {
+		t.Errorf("Should not have modified the original")
+	}
+}
+
+// insertPNGChunk returns a copy of a PNG photo with a chunk inserted right after its IHDR chunk
+func insertPNGChunk(encoded []byte, typ string, data []byte) []byte {
+	chunk := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	copy(chunk[4:], typ)
+	chunk = append(chunk, data...)
+	chunk = append(chunk, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(chunk[8+len(data):], crc32.ChecksumIEEE(chunk[4:8+len(data)]))
+	return append(append(append([]byte{}, encoded[:33]...), chunk...), encoded[33:]...)
+}
+
+func TestStripLocationPNG(t *testing.T) {
+	var bf bytes.Buffer
+	png.Encode(&bf, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	photo := insertPNGChunk(bf.Bytes(), "eXIf", newExifTIFF())
+
+	if !ParseExif(photo, PNG).HasLocation() {
+		t.Fatalf("Should have parsed the GPS coordinates of a PNG photo")
+	}
+	xmp := append(append([]byte{}, xmpKeyword...), []byte("\x00\x00\x00\x00<exif:GPSLatitude>51,30.0N</exif:GPSLatitude>")...)
+	photo = insertPNGChunk(photo, "iTXt", xmp)
+
+	stripped := StripLocation(photo, PNG)
+	if ParseExif(stripped, PNG).HasLocation() {
+		t.Errorf("Should have removed the GPS coordinates of a PNG photo")
+	}
+	if bytes.Contains(stripped, []byte("GPSLatitude")) {
+		t.Errorf("Should have removed the XMP metadata of a PNG photo")
+	}
+	if _, e := png.Decode(bytes.NewReader(stripped)); e != nil {
+		t.Errorf("Should have returned a valid PNG rather than %v", e)
+	}
+}
+
+// newExifWebP returns a WebP container with an extended header, an EXIF chunk and an XMP chunk. The image data is
+// omitted, as only the metadata is parsed.
+func newExifWebP() []byte {
+	tiff := newExifTIFF()
+	xmp := []byte(`<exif:GPSLatitude>51,30.0N</exif:GPSLatitude>`)
+	vp8x := make([]byte, 10)
+	vp8x[0] = webpFlagEXIF | webpFlagXMP
+
+	out := []byte("RIFF\x00\x00\x00\x00WEBP")
+	for _, c := range []struct {
+		typ  string
+		data []byte
+	}{{"VP8X", vp8x}, {"EXIF", tiff}, {"XMP ", xmp}} {
+		size := make([]byte, 4)
+		binary.LittleEndian.PutUint32(size, uint32(len(c.data)))
+		out = append(append(append(out, c.typ...), size...), c.data...)
+		if len(c.data)%2 == 1 {
+			out = append(out, 0)
+		}
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out
+}
+
+func TestStripLocationWebP(t *testing.T) {
+	photo := newExifWebP()
+	if !ParseExif(photo, WebP).HasLocation() {
+		t.Fatalf("Should have parsed the GPS coordinates of a WebP photo")
+	}
+
+	stripped := StripLocation(photo, WebP)
+	if exif := ParseExif(stripped, WebP); exif == nil || exif.Make != "Canon" || exif.HasLocation() {
+		t.Errorf("Should have only removed the GPS coordinates of a WebP photo rather than %v", exif)
+	}
+	if bytes.Contains(stripped, []byte("GPSLatitude")) {
+		t.Errorf("Should have removed the XMP metadata of a WebP photo")
+	}
+	if size := binary.LittleEndian.Uint32(stripped[4:]); int(size) != len(stripped)-8 {
+		t.Errorf("Should have updated the size of the container rather than %v", size)
+	}
+	if flags := stripped[20]; flags != webpFlagEXIF {
+		t.Errorf("Should have only announced the EXIF metadata rather than %b", flags)
+	}
+}
+
+func TestStripLocationInvalidMetadata(t *testing.T) {
+	photo := newExifJPEG()
+	// the offset of the first directory points outside the metadata
+	i := bytes.Index(photo, []byte("II*\x00"))
+	binary.LittleEndian.PutUint32(photo[i+4:], 0xFFFFFF)
+
+	stripped := StripLocation(photo, JPEG)
+	if bytes.Contains(stripped, []byte("Canon")) {
+		t.Errorf("Should have dropped metadata that can not be parsed")
+	}
+}
+
+func TestStripLocationInvalidGPS(t *testing.T) {
+	photo := newExifJPEG()
+	// the offset of the GPS directory, which is the last field of the first directory, points outside the metadata
+	i := bytes.Index(photo, []byte("II*\x00"))
+	binary.LittleEndian.PutUint32(photo[i+8+2+3*12+8:], 0xFFFFFF)
+
+	stripped := StripLocation(photo, JPEG)
+	if ParseExif(stripped, JPEG) != nil || bytes.Contains(stripped, []byte("Canon")) {
+		t.Errorf("Should have dropped the metadata whose GPS directory can not be parsed")
+	}
+	if _, e := jpeg.Decode(bytes.NewReader(stripped)); e != nil {
+		t.Errorf("Should have returned a valid JPEG rather than %v", e)
+	}
+}
+
+func TestStripLocationEverySegment(t *testing.T) {
+	photo := newExifJPEG()
+	// a second EXIF segment is inserted ahead of the first
+	app1 := append(append([]byte{}, exifHeader...), newExifTIFF()...)
+	segment := append([]byte{0xFF, 0xE1, byte((len(app1) + 2) >> 8), byte(len(app1) + 2)}, app1...)
+	photo = append(append(append([]byte{}, photo[:2]...), segment...), photo[2:]...)
+
+	stripped := StripLocation(photo, JPEG)
+	n := 0
+	for _, s := range jpegSegments(stripped) {
+		if s.marker != 0xE1 || !bytes.HasPrefix(stripped[s.start:s.end], exifHeader) {
+			continue
+		}
+		n++
+		tiff, _ := newTIFF(stripped[s.start+len(exifHeader) : s.end])
+		if exif, e := tiff.parse(); e != nil || exif.HasLocation() {
+			t.Errorf("Should have removed the GPS coordinates of every segment rather than %v, %v", exif, e)
+		}
+	}
+	if n != 2 {
+		t.Errorf("Should have kept both EXIF segments rather than %v", n)
+	}
+}
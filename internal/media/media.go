@@ -0,0 +1,43 @@
+// Package media contains functionalities used to inspect and process the images uploaded to the API
+package media
+
+import "bytes"
+
+// Content types of the images accepted by the API
+const (
+	JPEG = "image/jpeg"
+	PNG  = "image/png"
+	GIF  = "image/gif"
+	WebP = "image/webp"
+)
+
+// DetectContentType returns the content type of an image based on its magic bytes. The client provided
+// Content-Type is never trusted, so any other content is rejected.
+func DetectContentType(b []byte) (string, bool) {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xFF, 0xD8, 0xFF}):
+		return JPEG, true
+	case bytes.HasPrefix(b, []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}):
+		return PNG, true
+	case bytes.HasPrefix(b, []byte("GIF87a")), bytes.HasPrefix(b, []byte("GIF89a")):
+		return GIF, true
+	case len(b) >= 12 && bytes.Equal(b[:4], []byte("RIFF")) && bytes.Equal(b[8:12], []byte("WEBP")):
+		return WebP, true
+	}
+	return "", false
+}
+
+// Extension returns the file extension of a content type
+func Extension(contentType string) string {
+	switch contentType {
+	case JPEG:
+		return ".jpg"
+	case PNG:
+		return ".png"
+	case GIF:
+		return ".gif"
+	case WebP:
+		return ".webp"
+	}
+	return ""
+}
@@ -0,0 +1,28 @@
+package media
+
+import "testing"
+
+func TestDetectContentType(t *testing.T) {
+	cases := []struct {
+		b        []byte
+		expected string
+	}{
+		{[]byte{0xFF, 0xD8, 0xFF, 0xE0, 0, 0x10, 'J', 'F', 'I', 'F'}, JPEG},
+		{[]byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n', 0, 0, 0, 0x0D}, PNG},
+		{[]byte("GIF89a\x01\x00\x01\x00"), GIF},
+		{[]byte("RIFF\x24\x00\x00\x00WEBPVP8 "), WebP},
+	}
+	for _, c := range cases {
+		if ct, ok := DetectContentType(c.b); !ok || ct != c.expected {
+			t.Errorf("Should have returned a content type of %v rather than %v", c.expected, ct)
+		}
+	}
+}
+
+func TestDetectContentTypeUnknown(t *testing.T) {
+	for _, b := range [][]byte{nil, []byte("<html>"), []byte("RIFF\x24\x00\x00\x00WAVE")} {
+		if ct, ok := DetectContentType(b); ok {
+			t.Errorf("Should have rejected %q rather than returning %v", b, ct)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"io/ioutil"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxPixels is the largest photo, in pixels, that is decoded to generate renditions. A photo is held in memory twice
+// while its renditions are generated, once as decoded and once converted to NRGBA, so every worker may use up to
+// about 8 bytes per pixel.
+const maxPixels = 24 << 20
+
+// jobTimeout is the maximum time spent on the renditions of a single photo
+const jobTimeout = 2 * time.Minute
+
+// ErrTooLarge is returned when the dimensions of a photo exceed the pixels that can be decoded
+var ErrTooLarge = errors.New("media: photo dimensions are too large")
+
+// ErrQueueFull is returned when a photo can not be queued because every worker is busy and the queue is full
+var ErrQueueFull = errors.New("media: the renditions queue is full")
+
+// BlobKey returns the key of a rendition of a photo within the blob store
+func BlobKey(id primitive.ObjectID, rendition string) string {
+	return strings.Join([]string{"photos", id.Hex(), rendition}, "/")
+}
+
+// Pool is a custom type that represents a pool of workers, which generate the renditions of uploaded photos
+type Pool struct {
+	Store *store.Store
+	jobs  chan primitive.ObjectID
+	wg    sync.WaitGroup
+
+	// queued holds the photos that are queued or processed, so a photo is never queued twice
+	mu     sync.Mutex
+	queued map[primitive.ObjectID]bool
+	closed bool
+	done   chan struct{}
+}
+
+// NewPool returns a Pool with the given number of workers, which are started immediately. Up to queue photos wait for
+// a worker before Submit gives up on a photo.
+func NewPool(s *store.Store, workers int, queue int) *Pool {
+	p := &Pool{
+		Store:  s,
+		jobs:   make(chan primitive.ObjectID, queue),
+		queued: map[primitive.ObjectID]bool{},
+		done:   make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for id := range p.jobs {
+		ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+		if e := p.Process(ctx, id); e != nil {
+			log.Printf("Unable to generate the renditions of photo %v: %v", id.Hex(), e)
+		}
+		cancel()
+
+		p.mu.Lock()
+		delete(p.queued, id)
+		p.mu.Unlock()
+	}
+}
+
+// Submit queues a photo so its renditions are generated by a worker. It never waits for room in the queue: when the
+// queue is full ErrQueueFull is returned and the photo remains pending until it is queued again by Requeue.
+func (p *Pool) Submit(id primitive.ObjectID) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrQueueFull
+	}
+	if p.queued[id] {
+		return nil
+	}
+	select {
+	case p.jobs <- id:
+		p.queued[id] = true
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Requeue queues the photos whose renditions are still pending, such as the photos uploaded while the queue was full
+// or before a restart, until the queue is full. It returns the number of queued photos.
+func (p *Pool) Requeue(ctx context.Context) (int, error) {
+	photos, e := p.Store.Photos.ListPending(ctx, int64(cap(p.jobs)))
+	if e != nil {
+		return 0, e
+	}
+
+	n := 0
+	for _, photo := range photos {
+		if e := p.Submit(*photo.Id); e != nil {
+			break
+		}
+		n++
+	}
+	return n, nil
+}
+
+// RequeueEvery calls Requeue immediately and then at every interval, until the pool is closed
+func (p *Pool) RequeueEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if _, e := p.Requeue(ctx); e != nil {
+			log.Printf("Unable to queue the pending renditions: %v", e)
+		}
+		cancel()
+
+		select {
+		case <-ticker.C:
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Close stops accepting photos and waits for the queued ones to be processed
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	close(p.jobs)
+	close(p.done)
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// Process generates and stores the renditions of a photo, listing them on the photo document. The photo is marked
+// as failed when its original can not be decoded.
+func (p *Pool) Process(ctx context.Context, id primitive.ObjectID) error {
+	photo, e := p.Store.Photos.FindByID(ctx, id)
+	if e != nil {
+		return e
+	}
+
+	renditions, bounds, e := p.render(ctx, photo)
+	if e != nil {
+		p.Store.Photos.Update(ctx, id, map[string]interface{}{"renditionStatus": models.RenditionsFailed})
+		return e
+	}
+
+	_, e = p.Store.Photos.Update(ctx, id, map[string]interface{}{
+		"width":           bounds.Dx(),
+		"height":          bounds.Dy(),
+		"renditions":      renditions,
+		"renditionStatus": models.RenditionsReady,
+	})
+	if e == store.ErrNotFound {
+		// the photo was deleted while its renditions were rendered
+		for _, r := range renditions {
+			p.Store.Blobs.Delete(ctx, r.Key)
+		}
+	}
+	return e
+}
+
+func (p *Pool) render(ctx context.Context, photo *models.Photo) ([]models.PhotoRendition, image.Rectangle, error) {
+	rc, e := p.Store.Blobs.Get(ctx, photo.Key)
+	if e != nil {
+		return nil, image.Rectangle{}, e
+	}
+	original, e := ioutil.ReadAll(rc)
+	rc.Close()
+	if e != nil {
+		return nil, image.Rectangle{}, e
+	}
+
+	// the dimensions are checked before decoding, so a small file can not allocate a huge image
+	cfg, _, e := image.DecodeConfig(bytes.NewReader(original))
+	if e != nil {
+		return nil, image.Rectangle{}, e
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, image.Rectangle{}, ErrTooLarge
+	}
+	decoded, e := Decode(bytes.NewReader(original))
+	if e != nil {
+		return nil, image.Rectangle{}, e
+	}
+	// the photo is converted once, turned upright, and shared by its renditions
+	img := Orient(decoded, ParseOrientation(original, photo.ContentType))
+
+	var renditions []models.PhotoRendition
+	for _, r := range Renditions {
+		b, contentType, bounds, e := Render(img, photo.ContentType, r)
+		if e != nil {
+			return nil, image.Rectangle{}, e
+		}
+
+		key := BlobKey(*photo.Id, r.Name)
+		if e := p.Store.Blobs.Put(ctx, key, bytes.NewReader(b)); e != nil {
+			return nil, image.Rectangle{}, e
+		}
+		renditions = append(renditions, models.PhotoRendition{
+			Name:        r.Name,
+			Width:       bounds.Dx(),
+			Height:      bounds.Dy(),
+			ContentType: contentType,
+			Size:        int64(len(b)),
+			Key:         key,
+		})
+	}
+	return renditions, img.Bounds(), nil
+}
@@ -0,0 +1,135 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func storePhoto(s *store.Store, b []byte, contentType string) primitive.ObjectID {
+	id := primitive.NewObjectID()
+	key := BlobKey(id, "original")
+	s.Blobs.Put(context.Background(), key, bytes.NewReader(b))
+	s.Photos.Insert(context.Background(), models.Photo{Id: &id, ContentType: contentType, Key: key, RenditionStatus: models.RenditionsPending})
+	return id
+}
+
+func TestPoolProcess(t *testing.T) {
+	var bf bytes.Buffer
+	png.Encode(&bf, image.NewNRGBA(image.Rect(0, 0, 800, 600)))
+	s := store.NewMemoryStore()
+	id := storePhoto(s, bf.Bytes(), PNG)
+
+	if e := NewPool(s, 0, 1).Process(context.Background(), id); e != nil {
+		t.Fatalf("Should have generated the renditions rather than returning %v", e)
+	}
+
+	photo, _ := s.Photos.FindByID(context.Background(), id)
+	if photo.RenditionStatus != models.RenditionsReady || photo.Width != 800 || photo.Height != 600 {
+		t.Errorf("Should have updated the photo rather than %v", photo)
+	}
+	expected := map[string][2]int{"thumbnail": {150, 150}, "640": {640, 480}, "1280": {800, 600}}
+	for name, size := range expected {
+		r, ok := photo.FindRendition(name)
+		if !ok {
+			t.Errorf("Should have generated the %v rendition", name)
+			continue
+		}
+		if r.Width != size[0] || r.Height != size[1] || r.ContentType != PNG {
+			t.Errorf("Should have generated a %v rendition of %vx%v rather than %v", name, size[0], size[1], r)
+		}
+		if _, e := s.Blobs.Get(context.Background(), r.Key); e != nil {
+			t.Errorf("Should have stored the %v rendition rather than returning %v", name, e)
+		}
+	}
+}
+
+func TestPoolProcessInvalidPhoto(t *testing.T) {
+	s := store.NewMemoryStore()
+	id := storePhoto(s, []byte{0xFF, 0xD8, 0xFF, 0x00}, JPEG)
+
+	if e := NewPool(s, 0, 1).Process(context.Background(), id); e == nil {
+		t.Errorf("Should have returned an error for a corrupted photo")
+	}
+	if photo, _ := s.Photos.FindByID(context.Background(), id); photo.RenditionStatus != models.RenditionsFailed {
+		t.Errorf("Should have marked the photo as failed rather than %v", photo.RenditionStatus)
+	}
+}
+
+func TestPoolWorkers(t *testing.T) {
+	var bf bytes.Buffer
+	png.Encode(&bf, image.NewNRGBA(image.Rect(0, 0, 10, 10)))
+	s := store.NewMemoryStore()
+	id := storePhoto(s, bf.Bytes(), PNG)
+
+	p := NewPool(s, 2, 1)
+	if e := p.Submit(id); e != nil {
+		t.Fatalf("Should have queued the photo rather than returning %v", e)
+	}
+	p.Close()
+
+	if photo, _ := s.Photos.FindByID(context.Background(), id); photo.RenditionStatus != models.RenditionsReady {
+		t.Errorf("Should have processed the queued photo before closing rather than %v", photo.RenditionStatus)
+	}
+}
+
+func TestPoolSubmitWhenFull(t *testing.T) {
+	var bf bytes.Buffer
+	png.Encode(&bf, image.NewNRGBA(image.Rect(0, 0, 10, 10)))
+	s := store.NewMemoryStore()
+	first, second := storePhoto(s, bf.Bytes(), PNG), storePhoto(s, bf.Bytes(), PNG)
+
+	// without workers, the queue is full after a single photo
+	p := NewPool(s, 0, 1)
+	if e := p.Submit(first); e != nil {
+		t.Fatalf("Should have queued the photo rather than returning %v", e)
+	}
+	if e := p.Submit(first); e != nil {
+		t.Errorf("Should have ignored a photo that is already queued rather than returning %v", e)
+	}
+	if e := p.Submit(second); e != ErrQueueFull {
+		t.Errorf("Should have returned %v without waiting rather than %v", ErrQueueFull, e)
+	}
+}
+
+func TestPoolRequeue(t *testing.T) {
+	var bf bytes.Buffer
+	png.Encode(&bf, image.NewNRGBA(image.Rect(0, 0, 10, 10)))
+	s := store.NewMemoryStore()
+	ids := []primitive.ObjectID{storePhoto(s, bf.Bytes(), PNG), storePhoto(s, bf.Bytes(), PNG)}
+
+	p := NewPool(s, 1, 2)
+	if n, e := p.Requeue(context.Background()); e != nil || n != 2 {
+		t.Fatalf("Should have queued the pending photos rather than %v, %v", n, e)
+	}
+	p.Close()
+
+	for _, id := range ids {
+		if photo, _ := s.Photos.FindByID(context.Background(), id); photo.RenditionStatus != models.RenditionsReady {
+			t.Errorf("Should have processed the pending photo rather than %v", photo.RenditionStatus)
+		}
+	}
+}
+
+func TestPoolProcessOrientation(t *testing.T) {
+	var bf bytes.Buffer
+	png.Encode(&bf, image.NewNRGBA(image.Rect(0, 0, 40, 20)))
+	// the photo is stored sideways, so it is rotated by 90 degrees to be shown upright
+	exif := buildTIFF([]field{short(tagOrientation, 6)}, nil, nil)
+	s := store.NewMemoryStore()
+	id := storePhoto(s, insertPNGChunk(bf.Bytes(), "eXIf", exif), PNG)
+
+	if e := NewPool(s, 0, 1).Process(context.Background(), id); e != nil {
+		t.Fatalf("Should have generated the renditions rather than returning %v", e)
+	}
+	if photo, _ := s.Photos.FindByID(context.Background(), id); photo.Width != 20 || photo.Height != 40 {
+		t.Errorf("Should have turned the photo upright rather than returning %vx%v", photo.Width, photo.Height)
+	}
+}
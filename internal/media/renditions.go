@@ -0,0 +1,75 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	// registers the decoders of the supported formats
+	_ "image/gif"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Rendition describes a resized version of a photo generated after its upload. Square renditions are cropped to
+// Width, while the rest fit within Width and Height.
+type Rendition struct {
+	Name   string
+	Width  int
+	Height int
+	Square bool
+}
+
+// Renditions is the list of the renditions generated for every photo
+var Renditions = []Rendition{
+	{Name: "thumbnail", Width: 150, Square: true},
+	{Name: "640", Width: 640, Height: 640},
+	{Name: "1280", Width: 1280, Height: 1280},
+}
+
+// FindRendition returns the rendition with the given name
+func FindRendition(name string) (Rendition, bool) {
+	for _, r := range Renditions {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Rendition{}, false
+}
+
+// jpegQuality is the quality of the JPEG encoded renditions
+const jpegQuality = 85
+
+// Render generates a rendition of an image. Renditions of PNG and GIF photos are encoded as PNG so that transparency
+// is preserved, while the rest are encoded as JPEG. It returns the encoded bytes, their content type and the
+// dimensions of the rendition.
+func Render(img *image.NRGBA, contentType string, r Rendition) ([]byte, string, image.Rectangle, error) {
+	var dst *image.NRGBA
+	if r.Square {
+		dst = Square(img, r.Width)
+	} else {
+		dst = Fit(img, r.Width, r.Height)
+	}
+
+	var bf bytes.Buffer
+	switch contentType {
+	case PNG, GIF:
+		if e := png.Encode(&bf, dst); e != nil {
+			return nil, "", image.Rectangle{}, e
+		}
+		return bf.Bytes(), PNG, dst.Bounds(), nil
+	default:
+		if e := jpeg.Encode(&bf, dst, &jpeg.Options{Quality: jpegQuality}); e != nil {
+			return nil, "", image.Rectangle{}, e
+		}
+		return bf.Bytes(), JPEG, dst.Bounds(), nil
+	}
+}
+
+// Decode decodes an image of any of the supported formats
+func Decode(r io.Reader) (image.Image, error) {
+	img, _, e := image.Decode(r)
+	return img, e
+}
@@ -0,0 +1,111 @@
+package media
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// toNRGBA converts an image to *image.NRGBA with bounds that start at the origin. Images that are already such are
+// returned as is.
+func toNRGBA(src image.Image) *image.NRGBA {
+	b := src.Bounds()
+	if img, ok := src.(*image.NRGBA); ok && b.Min == (image.Point{}) {
+		return img
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// Orient converts an image to *image.NRGBA and turns it upright according to the value of its EXIF Orientation tag.
+// Values other than 2 to 8 leave the image as stored.
+func Orient(src image.Image, orientation int) *image.NRGBA {
+	s := toNRGBA(src)
+	if orientation < 2 || orientation > 8 {
+		return s
+	}
+
+	w, h := s.Bounds().Dx(), s.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	// every stored pixel is copied to its position in the upright image
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated by 180 degrees
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // mirrored along the main diagonal
+				dx, dy = y, x
+			case 6: // rotated clockwise by 90 degrees
+				dx, dy = h-1-y, x
+			case 7: // mirrored along the anti-diagonal
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated counterclockwise by 90 degrees
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], s.Pix[s.PixOffset(x, y):s.PixOffset(x, y)+4])
+		}
+	}
+	return dst
+}
+
+// Resize scales an image to the given dimensions with a Catmull-Rom filter, whose support grows with the scale so
+// downscaled photos remain free of aliasing
+func Resize(src image.Image, width int, height int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	if src.Bounds().Empty() || width <= 0 || height <= 0 {
+		return dst
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// Fit scales an image so it is no wider than width and no taller than height, keeping its aspect ratio. Images are
+// never upscaled.
+func Fit(src image.Image, width int, height int) *image.NRGBA {
+	b := src.Bounds()
+	if b.Dx() <= width && b.Dy() <= height {
+		return toNRGBA(src)
+	}
+
+	w, h := width, b.Dy()*width/b.Dx()
+	if h > height {
+		w, h = b.Dx()*height/b.Dy(), height
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return Resize(src, w, h)
+}
+
+// Square crops the centre of an image to a square and scales it to size. Images are never upscaled.
+func Square(src image.Image, size int) *image.NRGBA {
+	b := src.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	min := image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2)
+	crop := image.Rectangle{Min: min, Max: min.Add(image.Pt(side, side))}
+
+	if side <= size {
+		dst := image.NewNRGBA(image.Rect(0, 0, side, side))
+		draw.Draw(dst, dst.Bounds(), src, min, draw.Src)
+		return dst
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+	return dst
+}
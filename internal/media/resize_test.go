@@ -0,0 +1,73 @@
+package media
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func newUniform(width int, height int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestResize(t *testing.T) {
+	img := Resize(newUniform(100, 50, color.NRGBA{200, 100, 50, 255}), 10, 5)
+	if b := img.Bounds(); b.Dx() != 10 || b.Dy() != 5 {
+		t.Errorf("Should have returned an image of 10x5 rather than %vx%v", b.Dx(), b.Dy())
+	}
+	if c := img.NRGBAAt(3, 3); c != (color.NRGBA{200, 100, 50, 255}) {
+		t.Errorf("Should have preserved the colour of the image rather than %v", c)
+	}
+}
+
+func TestFit(t *testing.T) {
+	if b := Fit(newUniform(1280, 960, color.NRGBA{A: 255}), 640, 640).Bounds(); b.Dx() != 640 || b.Dy() != 480 {
+		t.Errorf("Should have returned an image of 640x480 rather than %vx%v", b.Dx(), b.Dy())
+	}
+	if b := Fit(newUniform(100, 4000, color.NRGBA{A: 255}), 640, 640).Bounds(); b.Dx() != 16 || b.Dy() != 640 {
+		t.Errorf("Should have limited the height of the image rather than returning %vx%v", b.Dx(), b.Dy())
+	}
+	if b := Fit(newUniform(320, 240, color.NRGBA{A: 255}), 640, 640).Bounds(); b.Dx() != 320 || b.Dy() != 240 {
+		t.Errorf("Should not have upscaled the image rather than returning %vx%v", b.Dx(), b.Dy())
+	}
+}
+
+func TestSquare(t *testing.T) {
+	img := newUniform(400, 200, color.NRGBA{255, 0, 0, 255})
+	// the left and right edges are cropped
+	for y := 0; y < 200; y++ {
+		img.SetNRGBA(0, y, color.NRGBA{0, 0, 255, 255})
+		img.SetNRGBA(399, y, color.NRGBA{0, 0, 255, 255})
+	}
+
+	sq := Square(img, 150)
+	if b := sq.Bounds(); b.Dx() != 150 || b.Dy() != 150 {
+		t.Errorf("Should have returned an image of 150x150 rather than %vx%v", b.Dx(), b.Dy())
+	}
+	if c := sq.NRGBAAt(0, 0); c != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("Should have cropped the centre of the image rather than returning %v", c)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	img := newUniform(4, 2, color.NRGBA{255, 0, 0, 255})
+	img.SetNRGBA(0, 0, color.NRGBA{0, 0, 255, 255})
+
+	// the top left pixel of a photo rotated clockwise ends up at the top right
+	rotated := Orient(img, 6)
+	if b := rotated.Bounds(); b.Dx() != 2 || b.Dy() != 4 {
+		t.Errorf("Should have returned an image of 2x4 rather than %vx%v", b.Dx(), b.Dy())
+	}
+	if c := rotated.NRGBAAt(1, 0); c != (color.NRGBA{0, 0, 255, 255}) {
+		t.Errorf("Should have rotated the image clockwise rather than returning %v", c)
+	}
+	if Orient(img, 1) != img {
+		t.Errorf("Should have returned an upright image as is")
+	}
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Visibilities of an album
+const (
+	AlbumPublic  = "public"
+	AlbumPrivate = "private"
+)
+
+// MaxAlbumPhotos is the maximum number of photos within an album
+const MaxAlbumPhotos = 1000
+
+// Album is a custom type used to represent a document in the albums collection. PhotoIds keeps the photos in the order
+// that they are displayed.
+type Album struct {
+	Id           *primitive.ObjectID  `json:"id,omitempty" bson:"_id,omitempty"`
+	OwnerId      primitive.ObjectID   `json:"ownerId" bson:"ownerId"`
+	Title        string               `json:"title" bson:"title"`
+	Description  string               `json:"description" bson:"description"`
+	Visibility   string               `json:"visibility" bson:"visibility"`
+	CoverPhotoId *primitive.ObjectID  `json:"coverPhotoId,omitempty" bson:"coverPhotoId,omitempty"`
+	PhotoIds     []primitive.ObjectID `json:"photoIds" bson:"photoIds"`
+	CreatedAt    time.Time            `json:"createdAt" bson:"createdAt"`
+	UpdatedAt    time.Time            `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Name returns the name of the document
+func (a Album) Name() string {
+	return "album"
+}
+
+// ValidateTitle is a method used to validate the title of an album
+func (a *Album) ValidateTitle() bool {
+	if strings.TrimSpace(a.Title) == "" {
+		return false
+	}
+	return true
+}
+
+// ValidateVisibility is a method used to validate the visibility of an album. An empty visibility defaults to private.
+func (a *Album) ValidateVisibility() bool {
+	switch a.Visibility {
+	case "":
+		a.Visibility = AlbumPrivate
+	case AlbumPublic, AlbumPrivate:
+	default:
+		return false
+	}
+	return true
+}
+
+// IsVisibleTo is a method used to check if an album can be read by a user. Private albums are only visible to their
+// owner and admins.
+func (a *Album) IsVisibleTo(userID primitive.ObjectID, admin bool) bool {
+	return a.Visibility == AlbumPublic || a.OwnerId == userID || admin
+}
+
+// HasPhoto is a method used to check if a photo belongs to an album
+func (a *Album) HasPhoto(id primitive.ObjectID) bool {
+	for _, pid := range a.PhotoIds {
+		if pid == id {
+			return true
+		}
+	}
+	return false
+}
+
+// IsReorderOf is a method used to check if a list of photo ids contains exactly the photos of an album
+func (a *Album) IsReorderOf(ids []primitive.ObjectID) bool {
+	if len(ids) != len(a.PhotoIds) {
+		return false
+	}
+	seen := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		if seen[id] || !a.HasPhoto(id) {
+			return false
+		}
+		seen[id] = true
+	}
+	return true
+}
+
+// IsPrivatePhoto reports if a photo that is part of the given albums is private, which is the case when at least one of
+// them is private and none of them is public
+func IsPrivatePhoto(albums []Album) bool {
+	private := false
+	for _, album := range albums {
+		if album.Visibility == AlbumPublic {
+			return false
+		}
+		private = true
+	}
+	return private
+}
+
+// Albums is a custom type used to represent a collection of albums (collection)
+type Albums []Album
+
+// Name is a method user to return the name of the collection
+func (a Albums) Name() string {
+	return "albums"
+}
+
+// AlbumWithPhotos is a custom type used to return an album with its photos expanded in the order of the album
+type AlbumWithPhotos struct {
+	Album
+	Photos []Photo `json:"photos"`
+}
+
+// AlbumUpdate is a custom type used to map the fields of an album that can be edited by its owner. The cover photo
+// must be one of the photos of the album.
+type AlbumUpdate struct {
+	Title        *string             `json:"title,omitempty"`
+	Description  *string             `json:"description,omitempty"`
+	Visibility   *string             `json:"visibility,omitempty"`
+	CoverPhotoId *primitive.ObjectID `json:"coverPhotoId,omitempty"`
+}
+
+// Validate is a method used to validate the fields of an AlbumUpdate that have been set
+func (au AlbumUpdate) Validate() bool {
+	ta := Album{Title: "title", Visibility: AlbumPrivate}
+	if au.Title != nil {
+		ta.Title = *au.Title
+	}
+	if au.Visibility != nil {
+		if *au.Visibility == "" {
+			return false
+		}
+		ta.Visibility = *au.Visibility
+	}
+	return ta.ValidateTitle() && ta.ValidateVisibility()
+}
+
+// Fields is a method used to return the fields of an AlbumUpdate that have been set, keyed by their BSON names
+func (au AlbumUpdate) Fields() map[string]interface{} {
+	fields := map[string]interface{}{}
+	if au.Title != nil {
+		fields["title"] = *au.Title
+	}
+	if au.Description != nil {
+		fields["description"] = *au.Description
+	}
+	if au.Visibility != nil {
+		fields["visibility"] = *au.Visibility
+	}
+	if au.CoverPhotoId != nil {
+		fields["coverPhotoId"] = *au.CoverPhotoId
+	}
+	return fields
+}
+
+// AlbumPhoto is a custom type used to map the request body that adds a photo to an album
+type AlbumPhoto struct {
+	PhotoId *primitive.ObjectID `json:"photoId,omitempty"`
+}
+
+// AlbumOrder is a custom type used to map the request body that reorders the photos of an album. PreviousPhotoIds is
+// the order of the photos that the client has reordered, when it is known.
+type AlbumOrder struct {
+	PhotoIds         []primitive.ObjectID `json:"photoIds"`
+	PreviousPhotoIds []primitive.ObjectID `json:"previousPhotoIds,omitempty"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// LoginAttempt is a custom type used to represent a document in the loginAttempts collection. It counts the recent
+// failed sign ins of a key, such as an email or an IP address.
+type LoginAttempt struct {
+	Key         string    `json:"key" bson:"_id"`
+	Failures    int       `json:"failures" bson:"failures"`
+	LastFailure time.Time `json:"lastFailure" bson:"lastFailure"`
+	ExpiresAt   time.Time `json:"expiresAt" bson:"expiresAt"`
+}
+
+// Name returns the name of the document
+func (la LoginAttempt) Name() string {
+	return "loginAttempt"
+}
+
+// LoginAttempts is a custom type used to represent a collection of login attempts (collection)
+type LoginAttempts []LoginAttempt
+
+// Name is a method user to return the name of the collection
+func (las LoginAttempts) Name() string {
+	return "loginAttempts"
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Actions recorded in the audit log
+const (
+	AuditUserRoleUpdate = "user.role.update"
+)
+
+// AuditEntry is a custom type used to represent a document in the auditEntries collection
+type AuditEntry struct {
+	Id        *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Action    string              `json:"action" bson:"action"`
+	ActorId   primitive.ObjectID  `json:"actorId" bson:"actorId"`
+	TargetId  primitive.ObjectID  `json:"targetId" bson:"targetId"`
+	From      string              `json:"from,omitempty" bson:"from,omitempty"`
+	To        string              `json:"to,omitempty" bson:"to,omitempty"`
+	CreatedAt time.Time           `json:"createdAt" bson:"createdAt"`
+}
+
+// Name returns the name of the document
+func (ae AuditEntry) Name() string {
+	return "auditEntry"
+}
+
+// AuditEntries is a custom type used to represent a collection of audit entries (collection)
+type AuditEntries []AuditEntry
+
+// Name is a method user to return the name of the collection
+func (aes AuditEntries) Name() string {
+	return "auditEntries"
+}
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Client is a custom type used to represent a document in the clients collection. A client is an application that signs
+// users in through OpenID Connect. Public clients, such as mobile and browser applications, can not keep a secret, so
+// only confidential clients have the hash of a secret.
+type Client struct {
+	Id           *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	ClientName   string              `json:"clientName" bson:"clientName"`
+	RedirectURIs []string            `json:"redirectUris" bson:"redirectUris"`
+	SecretHash   string              `json:"-" bson:"secretHash,omitempty"`
+	CreatedAt    time.Time           `json:"createdAt" bson:"createdAt"`
+}
+
+// Name returns the name of the document
+func (c Client) Name() string {
+	return "client"
+}
+
+// IsPublic is a method that checks if the client authenticates without a secret
+func (c *Client) IsPublic() bool {
+	return c.SecretHash == ""
+}
+
+// HasRedirectURI is a method that checks if a redirect URI has been registered by the client. URIs are compared as
+// strings, so that a client can not be redirected to a URI that it has not registered.
+func (c *Client) HasRedirectURI(uri string) bool {
+	for _, registered := range c.RedirectURIs {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// Clients is a custom type used to represent a collection of clients (collection)
+type Clients []Client
+
+// Name is a method user to return the name of the collection
+func (cs Clients) Name() string {
+	return "clients"
+}
+
+// ClientRegistration is a custom type used to map the body of a request that registers a client
+type ClientRegistration struct {
+	ClientName   string   `json:"clientName,omitempty"`
+	RedirectURIs []string `json:"redirectUris,omitempty"`
+	// Public registers a client without a secret
+	Public bool `json:"public,omitempty"`
+}
+
+// Validate is a method used to validate the fields of a ClientRegistration
+func (cr ClientRegistration) Validate() bool {
+	if strings.TrimSpace(cr.ClientName) == "" || len(cr.RedirectURIs) == 0 {
+		return false
+	}
+	for _, uri := range cr.RedirectURIs {
+		if !ValidateRedirectURI(uri) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateRedirectURI checks that a redirect URI is absolute and has no fragment, as RFC 6749 requires. Plain HTTP is
+// only accepted for the loopback interface, while custom schemes are accepted for native applications.
+func ValidateRedirectURI(uri string) bool {
+	u, e := url.Parse(uri)
+	if e != nil || !u.IsAbs() || u.Fragment != "" || strings.Contains(uri, "#") {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		return u.Host != ""
+	case "http":
+		if u.Hostname() == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(u.Hostname())
+		return ip != nil && ip.IsLoopback()
+	case "javascript", "data", "file", "vbscript":
+		return false
+	}
+	return true
+}
+
+// AuthorizationCode is a custom type used to represent a document in the authorizationCodes collection. A code is
+// issued to a client once a user signs in, and is exchanged for the tokens of the user along with the verifier of its
+// PKCE challenge. Only the hash of the code is stored.
+type AuthorizationCode struct {
+	Id            *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Hash          string              `json:"-" bson:"hash"`
+	ClientId      primitive.ObjectID  `json:"clientId" bson:"clientId"`
+	UserId        primitive.ObjectID  `json:"userId" bson:"userId"`
+	RedirectURI   string              `json:"redirectUri" bson:"redirectUri"`
+	Scopes        []string            `json:"scopes" bson:"scopes"`
+	Nonce         string              `json:"nonce,omitempty" bson:"nonce,omitempty"`
+	CodeChallenge string              `json:"-" bson:"codeChallenge"`
+	// AuthTime is the time that the user signed in
+	AuthTime time.Time `json:"authTime" bson:"authTime"`
+	// Family is the family of the refresh tokens issued for the code, which are revoked when the code is used twice
+	Family    primitive.ObjectID `json:"family" bson:"family"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time          `json:"expiresAt" bson:"expiresAt"`
+	UsedAt    *time.Time         `json:"usedAt,omitempty" bson:"usedAt,omitempty"`
+}
+
+// Name returns the name of the document
+func (ac AuthorizationCode) Name() string {
+	return "authorizationCode"
+}
+
+// IsExpired is a method that checks if an authorization code has expired at a given time
+func (ac *AuthorizationCode) IsExpired(now time.Time) bool {
+	return !now.Before(ac.ExpiresAt)
+}
+
+// AuthorizationCodes is a custom type used to represent a collection of authorization codes (collection)
+type AuthorizationCodes []AuthorizationCode
+
+// Name is a method user to return the name of the collection
+func (acs AuthorizationCodes) Name() string {
+	return "authorizationCodes"
+}
+
+// Consent is a custom type used to represent a document in the consents collection. It records the scopes that a user
+// has allowed a client to be granted, so the user is only asked again when the client requests other scopes.
+type Consent struct {
+	Id        *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	UserId    primitive.ObjectID  `json:"userId" bson:"userId"`
+	ClientId  primitive.ObjectID  `json:"clientId" bson:"clientId"`
+	Scopes    []string            `json:"scopes" bson:"scopes"`
+	UpdatedAt time.Time           `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Name returns the name of the document
+func (c Consent) Name() string {
+	return "consent"
+}
+
+// Covers is a method that checks if every one of the given scopes has been allowed
+func (c *Consent) Covers(scopes []string) bool {
+	for _, scope := range scopes {
+		allowed := false
+		for _, s := range c.Scopes {
+			if s == scope {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// Consents is a custom type used to represent a collection of consents (collection)
+type Consents []Consent
+
+// Name is a method user to return the name of the collection
+func (cs Consents) Name() string {
+	return "consents"
+}
+
+// OpenID Connect scopes that are supported
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// UserClaims is a custom type used to represent the OpenID Connect claims of a user, other than its subject
+type UserClaims struct {
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+}
+
+// Claims is a method that returns the claims of a user that are granted by the given scopes
+func (u User) Claims(scopes []string) UserClaims {
+	var claims UserClaims
+	for _, scope := range scopes {
+		switch scope {
+		case ScopeProfile:
+			claims.PreferredUsername = u.Username
+		case ScopeEmail:
+			verified := u.EmailVerified
+			claims.Email = u.Email
+			claims.EmailVerified = &verified
+		}
+	}
+	return claims
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestValidateRedirectURI(t *testing.T) {
+	for uri, expected := range map[string]bool{
+		"https://photos.example.com/callback": true,
+		"http://localhost:3000/callback":      true,
+		"http://127.0.0.1:3000/callback":      true,
+		"com.example.photos:/callback":        true,
+		"http://photos.example.com/callback":  false,
+		"https://photos.example.com/cb#frag":  false,
+		"/callback":                           false,
+		"javascript:alert(1)":                 false,
+	} {
+		if b := ValidateRedirectURI(uri); b != expected {
+			t.Errorf("ValidateRedirectURI() of %v should have returned %v rather than %v", uri, expected, b)
+		}
+	}
+}
+
+func TestClientRegistrationValidate(t *testing.T) {
+	if b := (ClientRegistration{ClientName: "Photo Blog", RedirectURIs: []string{"https://photos.example.com/callback"}}).Validate(); !b {
+		t.Errorf("The method Validate() for a valid registration should have returned 'true' rather than %v", b)
+	}
+	if b := (ClientRegistration{ClientName: "Photo Blog"}).Validate(); b {
+		t.Errorf("The method Validate() for a registration without redirect URIs should have returned 'false' rather than %v", b)
+	}
+	if b := (ClientRegistration{RedirectURIs: []string{"https://photos.example.com/callback"}}).Validate(); b {
+		t.Errorf("The method Validate() for a registration without a name should have returned 'false' rather than %v", b)
+	}
+}
+
+func TestUserClaims(t *testing.T) {
+	claims := user.Claims([]string{ScopeOpenID})
+	if claims.Email != "" || claims.EmailVerified != nil || claims.PreferredUsername != "" {
+		t.Errorf("The openid scope should not have granted any claim rather than %+v", claims)
+	}
+
+	claims = user.Claims([]string{ScopeOpenID, ScopeEmail, ScopeProfile})
+	if claims.Email != user.Email || claims.EmailVerified == nil || *claims.EmailVerified || claims.PreferredUsername != user.Username {
+		t.Errorf("The email and profile scopes should have granted the claims of the user rather than %+v", claims)
+	}
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxCommentLength is the maximum number of characters of a comment
+const MaxCommentLength = 5000
+
+// Comment is a custom type used to represent a document in the comments collection. A comment replies to another one
+// when it has a ParentId, while replies can not be replied.
+type Comment struct {
+	Id         *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	TargetType string              `json:"targetType" bson:"targetType"`
+	TargetId   primitive.ObjectID  `json:"targetId" bson:"targetId"`
+	ParentId   *primitive.ObjectID `json:"parentId,omitempty" bson:"parentId,omitempty"`
+	AuthorId   primitive.ObjectID  `json:"authorId" bson:"authorId"`
+	Body       string              `json:"body" bson:"body"`
+	Hidden     bool                `json:"hidden" bson:"hidden"`
+	CreatedAt  time.Time           `json:"createdAt" bson:"createdAt"`
+	UpdatedAt  time.Time           `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Name returns the name of the document
+func (c Comment) Name() string {
+	return "comment"
+}
+
+// ValidateBody is a method used to validate the body of a comment
+func (c *Comment) ValidateBody() bool {
+	b := strings.TrimSpace(c.Body)
+	return b != "" && len([]rune(b)) <= MaxCommentLength
+}
+
+// IsEditable is a method used to check if the author of a comment can still edit it
+func (c *Comment) IsEditable(window time.Duration, now time.Time) bool {
+	return now.Sub(c.CreatedAt) <= window
+}
+
+// Comments is a custom type used to represent a collection of comments (collection)
+type Comments []Comment
+
+// Name is a method user to return the name of the collection
+func (c Comments) Name() string {
+	return "comments"
+}
+
+// CommentThread is a custom type used to return a comment together with its replies
+type CommentThread struct {
+	Comment
+	Replies []Comment `json:"replies"`
+}
+
+// CommentUpdate is a custom type used to map the fields of a comment that can be edited. The body is edited by the
+// author of the comment, while it is hidden by the owner of the commented content.
+type CommentUpdate struct {
+	Body   *string `json:"body,omitempty"`
+	Hidden *bool   `json:"hidden,omitempty"`
+}
+
+// Validate is a method used to validate the fields of a CommentUpdate that have been set
+func (cu CommentUpdate) Validate() bool {
+	if cu.Body == nil {
+		return cu.Hidden != nil
+	}
+	tc := Comment{Body: *cu.Body}
+	return tc.ValidateBody()
+}
+
+// Fields is a method used to return the fields of a CommentUpdate that have been set, keyed by their BSON names
+func (cu CommentUpdate) Fields() map[string]interface{} {
+	fields := map[string]interface{}{}
+	if cu.Body != nil {
+		fields["body"] = strings.TrimSpace(*cu.Body)
+	}
+	if cu.Hidden != nil {
+		fields["hidden"] = *cu.Hidden
+	}
+	return fields
+}
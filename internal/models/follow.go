@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Follow is a custom type used to represent a document in the follows collection. A user follows another one at most
+// once.
+type Follow struct {
+	Id         *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	FollowerId primitive.ObjectID  `json:"followerId" bson:"followerId"`
+	FolloweeId primitive.ObjectID  `json:"followeeId" bson:"followeeId"`
+	CreatedAt  time.Time           `json:"createdAt" bson:"createdAt"`
+}
+
+// Name returns the name of the document
+func (f Follow) Name() string {
+	return "follow"
+}
+
+// Follows is a custom type used to represent a collection of follows (collection)
+type Follows []Follow
+
+// Name is a method user to return the name of the collection
+func (f Follows) Name() string {
+	return "follows"
+}
+
+// FollowState is a custom type used to return whether a user follows another one
+type FollowState struct {
+	Following bool `json:"following"`
+}
+
+// FeedItem is a custom type used to represent a post or a photo within the feed of a user
+type FeedItem struct {
+	Type  string `json:"type"`
+	Post  *Post  `json:"post,omitempty"`
+	Photo *Photo `json:"photo,omitempty"`
+}
+
+// Feed is a custom type used to return a page of the feed of a user. NextCursor is empty on the last page.
+type Feed struct {
+	Items      []FeedItem `json:"items"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
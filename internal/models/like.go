@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Types of the content that users interact with, such as comments and likes
+const (
+	TargetPost  = "post"
+	TargetPhoto = "photo"
+)
+
+// Like is a custom type used to represent a document in the likes collection. A user likes a post or a photo at most
+// once.
+type Like struct {
+	Id         *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	UserId     primitive.ObjectID  `json:"userId" bson:"userId"`
+	TargetType string              `json:"targetType" bson:"targetType"`
+	TargetId   primitive.ObjectID  `json:"targetId" bson:"targetId"`
+	CreatedAt  time.Time           `json:"createdAt" bson:"createdAt"`
+}
+
+// Name returns the name of the document
+func (l Like) Name() string {
+	return "like"
+}
+
+// Likes is a custom type used to represent a collection of likes (collection)
+type Likes []Like
+
+// Name is a method user to return the name of the collection
+func (l Likes) Name() string {
+	return "likes"
+}
+
+// LikeState is a custom type used to return whether a user likes a post or a photo, along with its number of likes
+type LikeState struct {
+	Liked     bool  `json:"liked"`
+	LikeCount int64 `json:"likeCount"`
+}
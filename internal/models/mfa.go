@@ -0,0 +1,33 @@
+package models
+
+// TOTP is a custom type used to represent the two-factor authentication state of a user. A secret is kept pending until
+// the user confirms it with a first code, while only the hashes of the recovery codes are stored.
+type TOTP struct {
+	Secret  string `json:"-" bson:"secret"`
+	Enabled bool   `json:"enabled" bson:"enabled"`
+	// LastStep is the step of the last code that was accepted, so that a code can not be used twice
+	LastStep      int64    `json:"-" bson:"lastStep"`
+	RecoveryCodes []string `json:"-" bson:"recoveryCodes"`
+}
+
+// TOTPConfirmation is a custom type used to map the body of a request that confirms the enrolment of a TOTP secret
+type TOTPConfirmation struct {
+	Code string `json:"code,omitempty"`
+}
+
+// Validate is a method used to validate the fields of a TOTPConfirmation
+func (tc TOTPConfirmation) Validate() bool {
+	return tc.Code != ""
+}
+
+// MFASignIn is a custom type used to map the body of a request that completes a sign in with a second factor. The
+// code is either a TOTP code or a recovery code.
+type MFASignIn struct {
+	MFAToken string `json:"mfaToken,omitempty"`
+	Code     string `json:"code,omitempty"`
+}
+
+// Validate is a method used to validate the fields of a MFASignIn
+func (ms MFASignIn) Validate() bool {
+	return ms.MFAToken != "" && ms.Code != ""
+}
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Statuses of the renditions of a photo
+const (
+	RenditionsPending = "pending"
+	RenditionsReady   = "ready"
+	RenditionsFailed  = "failed"
+)
+
+// PhotoRendition is a custom type used to represent a resized version of a photo, which is kept in a blob store
+// next to the original
+type PhotoRendition struct {
+	Name        string `json:"name" bson:"name"`
+	Width       int    `json:"width" bson:"width"`
+	Height      int    `json:"height" bson:"height"`
+	ContentType string `json:"contentType" bson:"contentType"`
+	Size        int64  `json:"size" bson:"size"`
+	Key         string `json:"-" bson:"key"`
+}
+
+// Photo is a custom type used to represent a document in the photos collection. The bytes of the photo are kept in
+// a blob store under Key. A photo is Private when it is part of a private album and of no public album.
+type Photo struct {
+	Id              *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	OwnerId         primitive.ObjectID  `json:"ownerId" bson:"ownerId"`
+	Caption         string              `json:"caption,omitempty" bson:"caption,omitempty"`
+	Filename        string              `json:"filename,omitempty" bson:"filename,omitempty"`
+	ContentType     string              `json:"contentType" bson:"contentType"`
+	Size            int64               `json:"size" bson:"size"`
+	Key             string              `json:"-" bson:"key"`
+	Width           int                 `json:"width,omitempty" bson:"width,omitempty"`
+	Height          int                 `json:"height,omitempty" bson:"height,omitempty"`
+	CreatedAt       time.Time           `json:"createdAt" bson:"createdAt"`
+	UpdatedAt       time.Time           `json:"updatedAt" bson:"updatedAt"`
+	RenditionStatus string              `json:"renditionStatus,omitempty" bson:"renditionStatus,omitempty"`
+	Renditions      []PhotoRendition    `json:"renditions,omitempty" bson:"renditions,omitempty"`
+	Exif            *Exif               `json:"exif,omitempty" bson:"exif,omitempty"`
+	ShareLocation   bool                `json:"shareLocation" bson:"shareLocation"`
+	Private         bool                `json:"private" bson:"private"`
+	Tags            []string            `json:"tags" bson:"tags"`
+	LikeCount       int64               `json:"likeCount" bson:"likeCount"`
+	// Liked reports if the photo is liked by the user that fetched it and is never stored
+	Liked *bool `json:"liked,omitempty" bson:"-"`
+}
+
+// Name returns the name of the document
+func (p Photo) Name() string {
+	return "photo"
+}
+
+// Public is a method used to return a copy of a photo as seen by users other than its owner. The GPS coordinates are
+// removed unless the owner has opted in to share them.
+func (p Photo) Public() Photo {
+	if p.Exif != nil && !p.ShareLocation {
+		exif := *p.Exif
+		exif.Latitude, exif.Longitude = nil, nil
+		p.Exif = &exif
+	}
+	return p
+}
+
+// IsVisibleTo is a method used to check if a photo can be read by a user. Private photos are only visible to their
+// owner and admins.
+func (p *Photo) IsVisibleTo(userID primitive.ObjectID, admin bool) bool {
+	return !p.Private || p.OwnerId == userID || admin
+}
+
+// CountedTags is a method used to return the tags of a photo that are included in the tag counts. Private photos are
+// not counted.
+func (p *Photo) CountedTags() []string {
+	if p.Private {
+		return nil
+	}
+	return p.Tags
+}
+
+// Photos is a custom type used to represent a collection of photos (collection)
+type Photos []Photo
+
+// Name is a method user to return the name of the collection
+func (p Photos) Name() string {
+	return "photos"
+}
+
+// FindRendition is a method used to return a rendition of a photo by its name
+func (p *Photo) FindRendition(name string) (*PhotoRendition, bool) {
+	for i := range p.Renditions {
+		if p.Renditions[i].Name == name {
+			return &p.Renditions[i], true
+		}
+	}
+	return nil, false
+}
+
+// Exif is a custom type used to represent the camera metadata of a photo
+type Exif struct {
+	Make         string     `json:"make,omitempty" bson:"make,omitempty"`
+	Model        string     `json:"model,omitempty" bson:"model,omitempty"`
+	LensMake     string     `json:"lensMake,omitempty" bson:"lensMake,omitempty"`
+	LensModel    string     `json:"lensModel,omitempty" bson:"lensModel,omitempty"`
+	ExposureTime string     `json:"exposureTime,omitempty" bson:"exposureTime,omitempty"`
+	FNumber      float64    `json:"fNumber,omitempty" bson:"fNumber,omitempty"`
+	FocalLength  float64    `json:"focalLength,omitempty" bson:"focalLength,omitempty"`
+	ISO          int        `json:"iso,omitempty" bson:"iso,omitempty"`
+	CapturedAt   *time.Time `json:"capturedAt,omitempty" bson:"capturedAt,omitempty"`
+	Latitude     *float64   `json:"latitude,omitempty" bson:"latitude,omitempty"`
+	Longitude    *float64   `json:"longitude,omitempty" bson:"longitude,omitempty"`
+}
+
+// HasLocation is a method used to check if the metadata include GPS coordinates
+func (e *Exif) HasLocation() bool {
+	return e != nil && e.Latitude != nil && e.Longitude != nil
+}
+
+// PhotoUpdate is a custom type used to map the fields of a photo that can be edited by its owner
+type PhotoUpdate struct {
+	Caption       *string   `json:"caption,omitempty"`
+	ShareLocation *bool     `json:"shareLocation,omitempty"`
+	Tags          *[]string `json:"tags,omitempty"`
+}
+
+// Validate is a method used to validate the fields of a PhotoUpdate that have been set
+func (pu PhotoUpdate) Validate() bool {
+	if pu.Tags != nil {
+		_, ok := NormalizeTags(*pu.Tags)
+		return ok
+	}
+	return true
+}
+
+// Fields is a method used to return the fields of a PhotoUpdate that have been set, keyed by their BSON names
+func (pu PhotoUpdate) Fields() map[string]interface{} {
+	fields := map[string]interface{}{}
+	if pu.Caption != nil {
+		fields["caption"] = *pu.Caption
+	}
+	if pu.ShareLocation != nil {
+		fields["shareLocation"] = *pu.ShareLocation
+	}
+	if pu.Tags != nil {
+		fields["tags"], _ = NormalizeTags(*pu.Tags)
+	}
+	return fields
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Statuses of a post
+const (
+	PostDraft     = "draft"
+	PostPublished = "published"
+	PostArchived  = "archived"
+)
+
+// Post is a custom type used to represent a document in the posts collection. The body of a post is written in
+// Markdown, while BodyHTML is rendered from it when the post is returned and never stored.
+type Post struct {
+	Id           *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	AuthorId     primitive.ObjectID  `json:"authorId" bson:"authorId"`
+	Title        string              `json:"title" bson:"title"`
+	Slug         string              `json:"slug" bson:"slug"`
+	Body         string              `json:"body" bson:"body"`
+	BodyHTML     string              `json:"bodyHtml" bson:"-"`
+	CoverPhotoId *primitive.ObjectID `json:"coverPhotoId,omitempty" bson:"coverPhotoId,omitempty"`
+	Tags         []string            `json:"tags" bson:"tags"`
+	Status       string              `json:"status" bson:"status"`
+	CreatedAt    time.Time           `json:"createdAt" bson:"createdAt"`
+	UpdatedAt    time.Time           `json:"updatedAt" bson:"updatedAt"`
+	PublishedAt  *time.Time          `json:"publishedAt,omitempty" bson:"publishedAt,omitempty"`
+	LikeCount    int64               `json:"likeCount" bson:"likeCount"`
+	// Liked reports if the post is liked by the user that fetched it and is never stored
+	Liked *bool `json:"liked,omitempty" bson:"-"`
+}
+
+// Name returns the name of the document
+func (p Post) Name() string {
+	return "post"
+}
+
+// ValidateTitle is a method used to validate the title of a post
+func (p *Post) ValidateTitle() bool {
+	if strings.TrimSpace(p.Title) == "" {
+		return false
+	}
+	return true
+}
+
+// ValidateStatus is a method used to validate the status of a post. An empty status defaults to draft.
+func (p *Post) ValidateStatus() bool {
+	switch p.Status {
+	case "":
+		p.Status = PostDraft
+	case PostDraft, PostPublished, PostArchived:
+	default:
+		return false
+	}
+	return true
+}
+
+// ValidateTags is a method used to validate the tags of a post, which are normalised to lowercase slugs
+func (p *Post) ValidateTags() bool {
+	tags, ok := NormalizeTags(p.Tags)
+	if !ok {
+		return false
+	}
+	p.Tags = tags
+	return true
+}
+
+// CountedTags is a method used to return the tags of a post that are included in the tag counts. Only published posts
+// are counted.
+func (p *Post) CountedTags() []string {
+	if p.Status != PostPublished {
+		return nil
+	}
+	return p.Tags
+}
+
+// IsVisibleTo is a method used to check if a post can be read by a user. Drafts are only visible to their author,
+// archived posts to their author and admins, while published posts to everyone.
+func (p *Post) IsVisibleTo(userID primitive.ObjectID, admin bool) bool {
+	switch p.Status {
+	case PostPublished:
+		return true
+	case PostArchived:
+		return p.AuthorId == userID || admin
+	}
+	return p.AuthorId == userID
+}
+
+// Posts is a custom type used to represent a collection of posts (collection)
+type Posts []Post
+
+// Name is a method user to return the name of the collection
+func (p Posts) Name() string {
+	return "posts"
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slugify converts a text to a lowercase, hyphen separated slug
+func Slugify(s string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// PostUpdate is a custom type used to map the fields of a post that can be edited
+type PostUpdate struct {
+	Title        *string             `json:"title,omitempty"`
+	Body         *string             `json:"body,omitempty"`
+	CoverPhotoId *primitive.ObjectID `json:"coverPhotoId,omitempty"`
+	Tags         *[]string           `json:"tags,omitempty"`
+	Status       *string             `json:"status,omitempty"`
+}
+
+// Validate is a method used to validate the fields of a PostUpdate that have been set
+func (pu PostUpdate) Validate() bool {
+	tp := Post{Title: "title", Status: PostDraft}
+	if pu.Title != nil {
+		tp.Title = *pu.Title
+	}
+	if pu.Status != nil {
+		if *pu.Status == "" {
+			return false
+		}
+		tp.Status = *pu.Status
+	}
+	if pu.Tags != nil {
+		if _, ok := NormalizeTags(*pu.Tags); !ok {
+			return false
+		}
+	}
+	return tp.ValidateTitle() && tp.ValidateStatus()
+}
+
+// Fields is a method used to return the fields of a PostUpdate that have been set, keyed by their BSON names
+func (pu PostUpdate) Fields() map[string]interface{} {
+	fields := map[string]interface{}{}
+	if pu.Title != nil {
+		fields["title"] = *pu.Title
+	}
+	if pu.Body != nil {
+		fields["body"] = *pu.Body
+	}
+	if pu.CoverPhotoId != nil {
+		fields["coverPhotoId"] = *pu.CoverPhotoId
+	}
+	if pu.Tags != nil {
+		fields["tags"], _ = NormalizeTags(*pu.Tags)
+	}
+	if pu.Status != nil {
+		fields["status"] = *pu.Status
+	}
+	return fields
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSlugify(t *testing.T) {
+	for in, expected := range map[string]string{
+		"Hello, World!":       "hello-world",
+		"  Trailing spaces  ": "trailing-spaces",
+		"Photos from 2019/10": "photos-from-2019-10",
+		"Ελληνικά":            "",
+	} {
+		if s := Slugify(in); s != expected {
+			t.Errorf("Should slugify %q to %q rather than %q", in, expected, s)
+		}
+	}
+}
+
+func TestPostValidateStatus(t *testing.T) {
+	p := Post{}
+	if !p.ValidateStatus() || p.Status != PostDraft {
+		t.Errorf("Should default the status to %v rather than %v", PostDraft, p.Status)
+	}
+	p.Status = "deleted"
+	if p.ValidateStatus() {
+		t.Errorf("Should have rejected an unknown status")
+	}
+}
+
+func TestPostIsVisibleTo(t *testing.T) {
+	author := primitive.NewObjectID()
+	other := primitive.NewObjectID()
+
+	draft := Post{AuthorId: author, Status: PostDraft}
+	if !draft.IsVisibleTo(author, false) || draft.IsVisibleTo(other, true) {
+		t.Errorf("Should only show a draft to its author")
+	}
+	archived := Post{AuthorId: author, Status: PostArchived}
+	if !archived.IsVisibleTo(other, true) || archived.IsVisibleTo(other, false) {
+		t.Errorf("Should only show an archived post to its author and admins")
+	}
+}
+
+func TestPostUpdateValidate(t *testing.T) {
+	empty := ""
+	if (PostUpdate{Title: &empty}).Validate() {
+		t.Errorf("Should have rejected an empty title")
+	}
+	if (PostUpdate{Status: &empty}).Validate() {
+		t.Errorf("Should have rejected an empty status")
+	}
+	published := PostPublished
+	if !(PostUpdate{Status: &published}).Validate() {
+		t.Errorf("Should have accepted the %v status", published)
+	}
+}
@@ -0,0 +1,68 @@
+package models
+
+// MaxTags is the maximum number of tags of a post or a photo
+const MaxTags = 20
+
+// MaxTagLength is the maximum number of characters of a tag
+const MaxTagLength = 50
+
+// TagCount is a custom type used to represent a document in the tags collection, which counts the published posts and
+// photos with a tag
+type TagCount struct {
+	Tag   string `json:"tag" bson:"_id"`
+	Count int64  `json:"count" bson:"count"`
+}
+
+// TagCounts is a custom type used to represent a collection of tag counts (collection)
+type TagCounts []TagCount
+
+// Name is a method user to return the name of the collection
+func (t TagCounts) Name() string {
+	return "tags"
+}
+
+// NormalizeTags converts free-form tags to lowercase slugs, dropping empty and duplicate ones. It returns false when
+// there are too many tags or a tag is too long.
+func NormalizeTags(tags []string) ([]string, bool) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		slug := Slugify(tag)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		if len(slug) > MaxTagLength {
+			return nil, false
+		}
+		seen[slug] = true
+		normalized = append(normalized, slug)
+	}
+	if len(normalized) > MaxTags {
+		return nil, false
+	}
+	return normalized, true
+}
+
+// DiffTags returns the tags that were added and removed when a list of tags is replaced
+func DiffTags(old []string, new []string) (added []string, removed []string) {
+	in := func(tags []string, tag string) bool {
+		for _, t := range tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, tag := range new {
+		if !in(old, tag) {
+			added = append(added, tag)
+		}
+	}
+	for _, tag := range old {
+		if !in(new, tag) {
+			removed = append(removed, tag)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, ok := NormalizeTags([]string{"Street Photography", " london", "London", "", "!!"})
+	if !ok || !reflect.DeepEqual(tags, []string{"street-photography", "london"}) {
+		t.Errorf("Should have normalized the tags rather than returning %v", tags)
+	}
+
+	if _, ok := NormalizeTags([]string{strings.Repeat("a", MaxTagLength+1)}); ok {
+		t.Errorf("Should have rejected a tag longer than %v characters", MaxTagLength)
+	}
+
+	many := []string{}
+	for i := 0; i <= MaxTags; i++ {
+		many = append(many, strings.Repeat("a", i+1))
+	}
+	if _, ok := NormalizeTags(many); ok {
+		t.Errorf("Should have rejected more than %v tags", MaxTags)
+	}
+}
+
+func TestDiffTags(t *testing.T) {
+	added, removed := DiffTags([]string{"london", "night"}, []string{"night", "rain"})
+	if !reflect.DeepEqual(added, []string{"rain"}) || !reflect.DeepEqual(removed, []string{"london"}) {
+		t.Errorf("Should have returned the added and removed tags rather than %v and %v", added, removed)
+	}
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken is a custom type used to represent a document in the refreshTokens collection. Only the hash
+// of the opaque token is stored, while tokens issued from the same sign in share a family.
+type RefreshToken struct {
+	Id        *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	UserId    primitive.ObjectID  `json:"userId" bson:"userId"`
+	Family    primitive.ObjectID  `json:"family" bson:"family"`
+	Hash      string              `json:"-" bson:"hash"`
+	CreatedAt time.Time           `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time           `json:"expiresAt" bson:"expiresAt"`
+	UsedAt    *time.Time          `json:"usedAt,omitempty" bson:"usedAt,omitempty"`
+	Revoked   bool                `json:"revoked" bson:"revoked"`
+	// ClientId is the OpenID Connect client that the token was issued to, which is nil for the sign ins of the API
+	ClientId *primitive.ObjectID `json:"clientId,omitempty" bson:"clientId,omitempty"`
+	// Scopes are the scopes granted to the client, which are kept by the access tokens issued on a refresh
+	Scopes []string `json:"scopes,omitempty" bson:"scopes,omitempty"`
+}
+
+// Name returns the name of the document
+func (rt RefreshToken) Name() string {
+	return "refreshToken"
+}
+
+// IsUsed is a method that checks if a refresh token has already been exchanged
+func (rt *RefreshToken) IsUsed() bool {
+	return rt.UsedAt != nil
+}
+
+// IsExpired is a method that checks if a refresh token has expired at a given time
+func (rt *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(rt.ExpiresAt)
+}
+
+// RefreshTokens is a custom type used to represent a collection of refresh tokens (collection)
+type RefreshTokens []RefreshToken
+
+// Name is a method user to return the name of the collection
+func (rts RefreshTokens) Name() string {
+	return "refreshTokens"
+}
+
+// RefreshRequest is a custom type used to map the body of a refresh token request
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken,omitempty"`
+}
+
+// ValidateRefreshToken is a method used to validate the refresh token of a RefreshRequest
+func (rr RefreshRequest) ValidateRefreshToken() bool {
+	if rr.RefreshToken == "" {
+		return false
+	}
+	return true
+}
+
+// RevokedToken is a custom type used to represent a document in the revokedTokens collection. A document either
+// revokes a single access token by its jti, or every access token of a user issued before RevokedAt.
+type RevokedToken struct {
+	Id        *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	JTI       string              `json:"jti,omitempty" bson:"jti,omitempty"`
+	UserId    *primitive.ObjectID `json:"userId,omitempty" bson:"userId,omitempty"`
+	RevokedAt time.Time           `json:"revokedAt" bson:"revokedAt"`
+	ExpiresAt time.Time           `json:"expiresAt" bson:"expiresAt"`
+}
+
+// Name returns the name of the document
+func (rt RevokedToken) Name() string {
+	return "revokedToken"
+}
+
+// RevokedTokens is a custom type used to represent a collection of revoked tokens (collection)
+type RevokedTokens []RevokedToken
+
+// Name is a method user to return the name of the collection
+func (rts RevokedTokens) Name() string {
+	return "revokedTokens"
+}
+
+// Purposes of a UserToken
+const (
+	TokenEmailVerification = "emailVerification"
+	TokenPasswordReset     = "passwordReset"
+	// TokenMFAPending is issued on a sign in that still needs a second factor
+	TokenMFAPending = "mfaPending"
+	// TokenConsentPending is issued on a sign in through the authorization endpoint that still needs the consent of the
+	// user to the scopes of the client
+	TokenConsentPending = "consentPending"
+)
+
+// UserToken is a custom type used to represent a document in the userTokens collection. A user token is an opaque,
+// single-use token that is sent to the email of a user to confirm an action. Only the hash of the token is stored.
+type UserToken struct {
+	Id      *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	UserId  primitive.ObjectID  `json:"userId" bson:"userId"`
+	Purpose string              `json:"purpose" bson:"purpose"`
+	// Email is the address that the token was sent to, so the token is invalidated when the email changes
+	Email     string     `json:"email" bson:"email"`
+	Hash      string     `json:"-" bson:"hash"`
+	CreatedAt time.Time  `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt" bson:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty" bson:"usedAt,omitempty"`
+}
+
+// Name returns the name of the document
+func (ut UserToken) Name() string {
+	return "userToken"
+}
+
+// IsValid is a method that checks if a user token can be used for a purpose at a given time
+func (ut *UserToken) IsValid(purpose string, now time.Time) bool {
+	return ut.Purpose == purpose && ut.UsedAt == nil && now.Before(ut.ExpiresAt)
+}
+
+// UserTokens is a custom type used to represent a collection of user tokens (collection)
+type UserTokens []UserToken
+
+// Name is a method user to return the name of the collection
+func (uts UserTokens) Name() string {
+	return "userTokens"
+}
@@ -2,13 +2,22 @@
 package models
 
 import (
+	"net/mail"
+	"strings"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Roles that can be assigned to a user
+const (
+	RoleAdmin = "ADMIN"
+	RoleBasic = "BASIC"
+)
+
 // SecureUser is a custom type used to display none-private information of a user
 type SecureUser struct {
-	Id       *primitive.ObjectID `json:"id,omitempty", bson:"_id,omitempty"`
+	Id       *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
 	Username string              `json:"username,omitempty" bson:"username"`
 	Email    string              `json:"email,omitempty" bson:"email"`
 	Role     string              `json:"role,omitempty" bson:"role"`
@@ -26,6 +35,10 @@ type User struct {
 	Email    string              `json:"email,omitempty" bson:"email"`
 	Password string              `json:"password,omitempty" bson:"password"`
 	Role     string              `json:"role,omitempty" bson:"role"`
+	// EmailVerified reports if the user has confirmed the ownership of the email
+	EmailVerified bool `json:"emailVerified" bson:"emailVerified"`
+	// TOTP is the two-factor authentication state of the user, which is never set through the request body
+	TOTP *TOTP `json:"-" bson:"totp,omitempty"`
 }
 
 // Name returns the name of the document
@@ -33,6 +46,11 @@ func (u User) Name() string {
 	return "user"
 }
 
+// MFAEnabled is a method that checks if the user signs in with a second factor
+func (u *User) MFAEnabled() bool {
+	return u.TOTP != nil && u.TOTP.Enabled
+}
+
 // ValidateUsername is a method used to validate the username of the document
 func (u *User) ValidateUsername() bool {
 	if u.Username == "" {
@@ -41,14 +59,21 @@ func (u *User) ValidateUsername() bool {
 	return true
 }
 
-// ValidateEmail is a method used to validate the email of the document
+// ValidateEmail is a method used to validate the email of the document, which must be a bare RFC 5322 address
 func (u *User) ValidateEmail() bool {
-	if u.Email == "" {
+	addr, e := mail.ParseAddress(u.Email)
+	if e != nil || addr.Name != "" || addr.Address != u.Email {
 		return false
 	}
 	return true
 }
 
+// NormalizeEmail returns the form of an email that users are stored and looked up by, so that an email can not be
+// registered twice with a different case
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 // ValidatePassword is a method used to validate the password of a document
 func (u *User) ValidatePassword() bool {
 	if len(u.Password) < 8 {
@@ -60,13 +85,18 @@ func (u *User) ValidatePassword() bool {
 // ValidateRole is a method used to validate the role of a document
 func (u *User) ValidateRole() {
 	switch role := u.Role; role {
-	case "ADMIN":
-	case "BASIC":
+	case RoleAdmin:
+	case RoleBasic:
 	default:
-		u.Role = "BASIC"
+		u.Role = RoleBasic
 	}
 }
 
+// IsAdmin is a method used to check if a user has the ADMIN role
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
 // ComparePassword is a method used to validate a given password with the hashed password of a user
 func (u *User) ComparePassword(s string) bool {
 	if e := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(s)); e != nil {
@@ -105,3 +135,86 @@ func (cu LoginUser) ValidatePassword() bool {
 	tu := User{Password: cu.Password}
 	return tu.ValidatePassword()
 }
+
+// UserUpdate is a custom type used to map the fields of a user that can be edited by the user. Any other field of the
+// request body, such as the id or the role, is ignored.
+type UserUpdate struct {
+	Username *string `json:"username,omitempty"`
+	Email    *string `json:"email,omitempty"`
+	// Password is only mapped so that a request that tries to change the password can be rejected, since the password
+	// is changed through a PasswordChange
+	Password *string `json:"password,omitempty"`
+}
+
+// Validate is a method used to validate the fields of a UserUpdate that have been set
+func (uu UserUpdate) Validate() bool {
+	tu := User{Username: "username", Email: "email@example.com"}
+	if uu.Username != nil {
+		tu.Username = *uu.Username
+	}
+	if uu.Email != nil {
+		tu.Email = *uu.Email
+	}
+	return uu.Password == nil && tu.ValidateUsername() && tu.ValidateEmail()
+}
+
+// Fields is a method used to return the fields of a UserUpdate that have been set, keyed by their BSON names
+func (uu UserUpdate) Fields() map[string]interface{} {
+	fields := map[string]interface{}{}
+	if uu.Username != nil {
+		fields["username"] = *uu.Username
+	}
+	if uu.Email != nil {
+		fields["email"] = *uu.Email
+	}
+	return fields
+}
+
+// RoleUpdate is a custom type used to map the body of a request that changes the role of a user
+type RoleUpdate struct {
+	Role string `json:"role,omitempty"`
+}
+
+// ValidateRole is a method used to validate the role of a RoleUpdate
+func (ru RoleUpdate) ValidateRole() bool {
+	switch ru.Role {
+	case RoleAdmin, RoleBasic:
+		return true
+	}
+	return false
+}
+
+// PasswordChange is a custom type used to map the body of a request that changes the password of a user
+type PasswordChange struct {
+	CurrentPassword string `json:"currentPassword,omitempty"`
+	NewPassword     string `json:"newPassword,omitempty"`
+}
+
+// Validate is a method used to validate the fields of a PasswordChange
+func (pc PasswordChange) Validate() bool {
+	tu := User{Password: pc.NewPassword}
+	return pc.CurrentPassword != "" && tu.ValidatePassword()
+}
+
+// ForgotPassword is a custom type used to map the body of a request that asks for a password reset
+type ForgotPassword struct {
+	Email string `json:"email,omitempty"`
+}
+
+// ValidateEmail is a method used to validate the email of a ForgotPassword
+func (fp ForgotPassword) ValidateEmail() bool {
+	tu := User{Email: fp.Email}
+	return tu.ValidateEmail()
+}
+
+// ResetPassword is a custom type used to map the body of a request that resets a password with a mailed token
+type ResetPassword struct {
+	Token    string `json:"token,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Validate is a method used to validate the fields of a ResetPassword
+func (rp ResetPassword) Validate() bool {
+	tu := User{Password: rp.Password}
+	return rp.Token != "" && tu.ValidatePassword()
+}
@@ -130,3 +130,72 @@ func TestLoginUserPassword(t *testing.T) {
 		t.Errorf("Should have returned 'true' rather than %v", b)
 	}
 }
+
+func TestUserUpdateFields(t *testing.T) {
+	username := "paul37"
+	uu := UserUpdate{Username: &username}
+	fields := uu.Fields()
+	if len(fields) != 1 || fields["username"] != "paul37" {
+		t.Errorf("Should have only returned the username rather than %v", fields)
+	}
+	if !uu.Validate() {
+		t.Errorf("Should have returned 'true' for a valid update")
+	}
+}
+
+func TestInvalidUserUpdate(t *testing.T) {
+	email := ""
+	if uu := (UserUpdate{Email: &email}); uu.Validate() {
+		t.Errorf("Should have returned 'false' for an empty email")
+	}
+}
+
+func TestUserUpdateWithPassword(t *testing.T) {
+	password := "87654321"
+	uu := UserUpdate{Password: &password}
+	if uu.Validate() {
+		t.Errorf("Should have returned 'false' for an update of the password")
+	}
+	if fields := uu.Fields(); len(fields) != 0 {
+		t.Errorf("Should have not returned the password rather than %v", fields)
+	}
+}
+
+func TestPasswordChangeValidate(t *testing.T) {
+	if b := (PasswordChange{CurrentPassword: "12345678", NewPassword: "87654321"}).Validate(); !b {
+		t.Errorf("Should have returned 'true' for a valid password change")
+	}
+	if b := (PasswordChange{NewPassword: "87654321"}).Validate(); b {
+		t.Errorf("Should have returned 'false' without the current password")
+	}
+	if b := (PasswordChange{CurrentPassword: "12345678", NewPassword: "1234"}).Validate(); b {
+		t.Errorf("Should have returned 'false' for a short new password")
+	}
+}
+
+func TestRoleUpdateValidateRole(t *testing.T) {
+	if b := (RoleUpdate{Role: RoleAdmin}).ValidateRole(); !b {
+		t.Errorf("Should have returned 'true' for the ADMIN role")
+	}
+	if b := (RoleUpdate{Role: "ROOT"}).ValidateRole(); b {
+		t.Errorf("Should have returned 'false' for an unknown role")
+	}
+}
+
+func TestValidateEmailAddresses(t *testing.T) {
+	for email, expected := range map[string]bool{
+		"paul@gmail.com":              true,
+		"paul.mccartney+b@beatles.uk": true,
+		"paul":                        false,
+		"paul@":                       false,
+		"@gmail.com":                  false,
+		"Paul <paul@gmail.com>":       false,
+		"paul@gmail.com, john@a.com":  false,
+		" paul@gmail.com":             false,
+	} {
+		u := User{Email: email}
+		if b := u.ValidateEmail(); b != expected {
+			t.Errorf("The method ValidateEmail() for %q should have returned %v rather than %v", email, expected, b)
+		}
+	}
+}
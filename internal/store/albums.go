@@ -0,0 +1,308 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AlbumStore is an interface that describes the operations performed on the albums collection
+type AlbumStore interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Album, error)
+	// ListByPhoto returns the albums that contain a photo
+	ListByPhoto(ctx context.Context, photoID primitive.ObjectID) ([]models.Album, error)
+	Insert(ctx context.Context, album models.Album) (primitive.ObjectID, error)
+	Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) (*models.Album, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// AddPhoto appends a photo to the end of an album, unless it is already part of it
+	AddPhoto(ctx context.Context, id primitive.ObjectID, photoID primitive.ObjectID) (*models.Album, error)
+	// RemovePhoto removes a photo from an album, along with the cover photo when they are the same
+	RemovePhoto(ctx context.Context, id primitive.ObjectID, photoID primitive.ObjectID) (*models.Album, error)
+	// ReorderPhotos replaces the photos of an album with photoIDs, provided that they are still the previous ones in
+	// the same order. ErrConflict is returned when they have changed.
+	ReorderPhotos(ctx context.Context, id primitive.ObjectID, previous []primitive.ObjectID, photoIDs []primitive.ObjectID, updatedAt time.Time) (*models.Album, error)
+}
+
+// MongoAlbumStore is an AlbumStore backed by a MongoDB collection
+type MongoAlbumStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoAlbumStore returns a MongoAlbumStore that uses the albums collection of a database
+func NewMongoAlbumStore(db *mongo.Database) *MongoAlbumStore {
+	return &MongoAlbumStore{db.Collection(models.Albums{}.Name())}
+}
+
+// EnsureIndexes creates the indexes used to fetch the albums of an owner and the albums that contain a photo
+func (s *MongoAlbumStore) EnsureIndexes(ctx context.Context) error {
+	_, e := s.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"ownerId": 1}},
+		{Keys: bson.M{"photoIds": 1}},
+	})
+	return e
+}
+
+// FindByID returns the album with the given id
+func (s *MongoAlbumStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Album, error) {
+	var album models.Album
+	if e := s.Collection.FindOne(ctx, bson.M{"_id": id}).Decode(&album); e != nil {
+		if e == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, e
+	}
+	return &album, nil
+}
+
+// ListByPhoto returns the albums that contain a photo
+func (s *MongoAlbumStore) ListByPhoto(ctx context.Context, photoID primitive.ObjectID) ([]models.Album, error) {
+	albums := []models.Album{}
+
+	cur, e := s.Collection.Find(ctx, bson.M{"photoIds": photoID})
+	if e != nil {
+		return nil, e
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var album models.Album
+		if e := cur.Decode(&album); e != nil {
+			return nil, e
+		}
+		albums = append(albums, album)
+	}
+	return albums, cur.Err()
+}
+
+// Insert stores an album and returns its id
+func (s *MongoAlbumStore) Insert(ctx context.Context, album models.Album) (primitive.ObjectID, error) {
+	if album.PhotoIds == nil {
+		album.PhotoIds = []primitive.ObjectID{}
+	}
+	result, e := s.Collection.InsertOne(ctx, album)
+	if e != nil {
+		return primitive.NilObjectID, e
+	}
+	oid, _ := result.InsertedID.(primitive.ObjectID)
+	return oid, nil
+}
+
+func (s *MongoAlbumStore) update(ctx context.Context, id primitive.ObjectID, update bson.M) (*models.Album, error) {
+	result, e := s.Collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if e != nil {
+		return nil, e
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrNotFound
+	}
+	return s.FindByID(ctx, id)
+}
+
+// Update sets the given fields of an album and returns the updated document
+func (s *MongoAlbumStore) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) (*models.Album, error) {
+	return s.update(ctx, id, bson.M{"$set": fields})
+}
+
+// AddPhoto appends a photo to the end of an album, unless it is already part of it
+func (s *MongoAlbumStore) AddPhoto(ctx context.Context, id primitive.ObjectID, photoID primitive.ObjectID) (*models.Album, error) {
+	return s.update(ctx, id, bson.M{"$addToSet": bson.M{"photoIds": photoID}})
+}
+
+// RemovePhoto removes a photo from an album, along with the cover photo when they are the same
+func (s *MongoAlbumStore) RemovePhoto(ctx context.Context, id primitive.ObjectID, photoID primitive.ObjectID) (*models.Album, error) {
+	if _, e := s.Collection.UpdateOne(ctx, bson.M{"_id": id, "coverPhotoId": photoID}, bson.M{"$unset": bson.M{"coverPhotoId": ""}}); e != nil {
+		return nil, e
+	}
+	return s.update(ctx, id, bson.M{"$pull": bson.M{"photoIds": photoID}})
+}
+
+// ReorderPhotos replaces the photos of an album, provided that they are still the previous ones in the same order
+func (s *MongoAlbumStore) ReorderPhotos(ctx context.Context, id primitive.ObjectID, previous []primitive.ObjectID, photoIDs []primitive.ObjectID, updatedAt time.Time) (*models.Album, error) {
+	// an array matches a query on its field only when it holds the same elements in the same order
+	if previous == nil {
+		previous = []primitive.ObjectID{}
+	}
+	result, e := s.Collection.UpdateOne(ctx, bson.M{"_id": id, "photoIds": previous}, bson.M{"$set": bson.M{"photoIds": photoIDs, "updatedAt": updatedAt}})
+	if e != nil {
+		return nil, e
+	}
+	if result.MatchedCount == 0 {
+		if _, e := s.FindByID(ctx, id); e != nil {
+			return nil, e
+		}
+		return nil, ErrConflict
+	}
+	return s.FindByID(ctx, id)
+}
+
+// Delete removes the album with the given id
+func (s *MongoAlbumStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, e := s.Collection.DeleteOne(ctx, bson.M{"_id": id})
+	if e != nil {
+		return e
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MemoryAlbumStore is a thread-safe AlbumStore that keeps the albums in memory
+type MemoryAlbumStore struct {
+	mu     sync.RWMutex
+	albums map[primitive.ObjectID]models.Album
+}
+
+// NewMemoryAlbumStore returns an empty MemoryAlbumStore
+func NewMemoryAlbumStore() *MemoryAlbumStore {
+	return &MemoryAlbumStore{albums: map[primitive.ObjectID]models.Album{}}
+}
+
+// copyAlbum returns an album that does not share its list of photos with the stored one
+func copyAlbum(album models.Album) *models.Album {
+	album.PhotoIds = append([]primitive.ObjectID{}, album.PhotoIds...)
+	return &album
+}
+
+// FindByID returns the album with the given id
+func (s *MemoryAlbumStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Album, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	album, ok := s.albums[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyAlbum(album), nil
+}
+
+// ListByPhoto returns the albums that contain a photo
+func (s *MemoryAlbumStore) ListByPhoto(ctx context.Context, photoID primitive.ObjectID) ([]models.Album, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	albums := []models.Album{}
+	for _, album := range s.albums {
+		if album.HasPhoto(photoID) {
+			albums = append(albums, *copyAlbum(album))
+		}
+	}
+	return albums, nil
+}
+
+// Insert stores an album and returns its id. A new id is generated when the album does not have one.
+func (s *MemoryAlbumStore) Insert(ctx context.Context, album models.Album) (primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if album.Id == nil {
+		id := primitive.NewObjectID()
+		album.Id = &id
+	}
+	if _, ok := s.albums[*album.Id]; ok {
+		return primitive.NilObjectID, ErrDuplicate
+	}
+	s.albums[*album.Id] = *copyAlbum(album)
+	return *album.Id, nil
+}
+
+// Update sets the given fields of an album and returns the updated document
+func (s *MemoryAlbumStore) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) (*models.Album, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	album, ok := s.albums[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var updated models.Album
+	if e := setFields(album, fields, &updated); e != nil {
+		return nil, e
+	}
+	s.albums[id] = updated
+	return copyAlbum(updated), nil
+}
+
+// AddPhoto appends a photo to the end of an album, unless it is already part of it
+func (s *MemoryAlbumStore) AddPhoto(ctx context.Context, id primitive.ObjectID, photoID primitive.ObjectID) (*models.Album, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	album, ok := s.albums[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !album.HasPhoto(photoID) {
+		album = *copyAlbum(album)
+		album.PhotoIds = append(album.PhotoIds, photoID)
+		s.albums[id] = album
+	}
+	return copyAlbum(album), nil
+}
+
+// RemovePhoto removes a photo from an album, along with the cover photo when they are the same
+func (s *MemoryAlbumStore) RemovePhoto(ctx context.Context, id primitive.ObjectID, photoID primitive.ObjectID) (*models.Album, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	album, ok := s.albums[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	ids := []primitive.ObjectID{}
+	for _, pid := range album.PhotoIds {
+		if pid != photoID {
+			ids = append(ids, pid)
+		}
+	}
+	album.PhotoIds = ids
+	if album.CoverPhotoId != nil && *album.CoverPhotoId == photoID {
+		album.CoverPhotoId = nil
+	}
+	s.albums[id] = album
+	return copyAlbum(album), nil
+}
+
+// ReorderPhotos replaces the photos of an album, provided that they are still the previous ones in the same order
+func (s *MemoryAlbumStore) ReorderPhotos(ctx context.Context, id primitive.ObjectID, previous []primitive.ObjectID, photoIDs []primitive.ObjectID, updatedAt time.Time) (*models.Album, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	album, ok := s.albums[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if len(album.PhotoIds) != len(previous) {
+		return nil, ErrConflict
+	}
+	for i := range previous {
+		if album.PhotoIds[i] != previous[i] {
+			return nil, ErrConflict
+		}
+	}
+
+	album.PhotoIds = append([]primitive.ObjectID{}, photoIDs...)
+	album.UpdatedAt = updatedAt
+	s.albums[id] = album
+	return copyAlbum(album), nil
+}
+
+// Delete removes the album with the given id
+func (s *MemoryAlbumStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.albums[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.albums, id)
+	return nil
+}
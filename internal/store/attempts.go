@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoginAttemptStore is an interface that describes the operations performed on the counters of failed sign ins.
+// Failures are forgotten once a window has passed since the last one.
+type LoginAttemptStore interface {
+	Find(ctx context.Context, key string) (*models.LoginAttempt, error)
+	// RecordFailure counts a failed sign in of a key and returns the updated counter
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempt, error)
+	Reset(ctx context.Context, key string) error
+}
+
+// MongoLoginAttemptStore is a LoginAttemptStore backed by a MongoDB collection
+type MongoLoginAttemptStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoLoginAttemptStore returns a MongoLoginAttemptStore that uses the loginAttempts collection of a database
+func NewMongoLoginAttemptStore(db *mongo.Database) *MongoLoginAttemptStore {
+	return &MongoLoginAttemptStore{db.Collection(models.LoginAttempts{}.Name())}
+}
+
+// EnsureIndexes creates a TTL index that removes the counters once their window has passed
+func (s *MongoLoginAttemptStore) EnsureIndexes(ctx context.Context) error {
+	_, e := s.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"expiresAt": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return e
+}
+
+// Find returns the counter of a key
+func (s *MongoLoginAttemptStore) Find(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	if e := s.Collection.FindOne(ctx, bson.M{"_id": key, "expiresAt": bson.M{"$gt": time.Now()}}).Decode(&attempt); e != nil {
+		if e == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, e
+	}
+	return &attempt, nil
+}
+
+// RecordFailure counts a failed sign in of a key and returns the updated counter
+func (s *MongoLoginAttemptStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	filter := bson.M{"_id": key, "lastFailure": bson.M{"$gt": now.Add(-window)}}
+	update := bson.M{"$inc": bson.M{"failures": 1}, "$set": bson.M{"lastFailure": now, "expiresAt": now.Add(window)}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	for {
+		var attempt models.LoginAttempt
+		e := s.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&attempt)
+		if e == nil {
+			return &attempt, nil
+		}
+		if e != mongo.ErrNoDocuments {
+			return nil, e
+		}
+
+		// the key does not have recent failures, so its counter starts over
+		attempt = models.LoginAttempt{Key: key, Failures: 1, LastFailure: now, ExpiresAt: now.Add(window)}
+		_, e = s.Collection.ReplaceOne(ctx, bson.M{"_id": key, "lastFailure": bson.M{"$lte": now.Add(-window)}}, attempt, options.Replace().SetUpsert(true))
+		if e == nil {
+			return &attempt, nil
+		}
+		// a concurrent failure inserted the counter first, so it is incremented instead
+		if !isDuplicateKey(e) {
+			return nil, e
+		}
+	}
+}
+
+// Reset removes the counter of a key
+func (s *MongoLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	_, e := s.Collection.DeleteOne(ctx, bson.M{"_id": key})
+	return e
+}
+
+// MemoryLoginAttemptStore is a thread-safe LoginAttemptStore that keeps the counters in memory
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempt
+}
+
+// NewMemoryLoginAttemptStore returns an empty MemoryLoginAttemptStore
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{attempts: map[string]models.LoginAttempt{}}
+}
+
+// Find returns the counter of a key
+func (s *MemoryLoginAttemptStore) Find(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok || !time.Now().Before(attempt.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return &attempt, nil
+}
+
+// RecordFailure counts a failed sign in of a key and returns the updated counter
+func (s *MemoryLoginAttemptStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok || !attempt.LastFailure.After(now.Add(-window)) {
+		attempt = models.LoginAttempt{Key: key}
+	}
+	attempt.Failures++
+	attempt.LastFailure = now
+	attempt.ExpiresAt = now.Add(window)
+	s.attempts[key] = attempt
+	return &attempt, nil
+}
+
+// Reset removes the counter of a key
+func (s *MemoryLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
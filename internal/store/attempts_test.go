@@ -0,0 +1,26 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryLoginAttemptStoreRecordFailure(t *testing.T) {
+	s := NewMemoryLoginAttemptStore()
+	ctx := context.Background()
+	now := time.Now()
+
+	s.RecordFailure(ctx, "email:paul@gmail.com", now.Add(-2*time.Hour), time.Hour)
+	if attempt, _ := s.RecordFailure(ctx, "email:paul@gmail.com", now.Add(-time.Minute), time.Hour); attempt.Failures != 1 {
+		t.Errorf("Should have forgotten the failures outside of the window rather than counting %v", attempt.Failures)
+	}
+	if attempt, _ := s.RecordFailure(ctx, "email:paul@gmail.com", now, time.Hour); attempt.Failures != 2 || !attempt.LastFailure.Equal(now) {
+		t.Errorf("Should have counted the recent failures rather than %v", attempt)
+	}
+
+	s.Reset(ctx, "email:paul@gmail.com")
+	if _, e := s.Find(ctx, "email:paul@gmail.com"); e != ErrNotFound {
+		t.Errorf("Should have removed the counter rather than returning %v", e)
+	}
+}
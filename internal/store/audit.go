@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditStore is an interface that describes the operations performed on the audit log
+type AuditStore interface {
+	Insert(ctx context.Context, entry models.AuditEntry) (primitive.ObjectID, error)
+	ListByTarget(ctx context.Context, targetID primitive.ObjectID) ([]models.AuditEntry, error)
+}
+
+// MongoAuditStore is an AuditStore backed by a MongoDB collection
+type MongoAuditStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoAuditStore returns a MongoAuditStore that uses the auditEntries collection of a database
+func NewMongoAuditStore(db *mongo.Database) *MongoAuditStore {
+	return &MongoAuditStore{db.Collection(models.AuditEntries{}.Name())}
+}
+
+// EnsureIndexes creates an index used to fetch the entries of a target
+func (s *MongoAuditStore) EnsureIndexes(ctx context.Context) error {
+	_, e := s.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "targetId", Value: 1}, {Key: "createdAt", Value: 1}}})
+	return e
+}
+
+// Insert stores an audit entry and returns its id
+func (s *MongoAuditStore) Insert(ctx context.Context, entry models.AuditEntry) (primitive.ObjectID, error) {
+	result, e := s.Collection.InsertOne(ctx, entry)
+	if e != nil {
+		return primitive.NilObjectID, e
+	}
+	oid, _ := result.InsertedID.(primitive.ObjectID)
+	return oid, nil
+}
+
+// ListByTarget returns the entries of a target in chronological order
+func (s *MongoAuditStore) ListByTarget(ctx context.Context, targetID primitive.ObjectID) ([]models.AuditEntry, error) {
+	var entries []models.AuditEntry
+
+	cur, e := s.Collection.Find(ctx, bson.M{"targetId": targetID}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if e != nil {
+		return nil, e
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var entry models.AuditEntry
+		if e := cur.Decode(&entry); e != nil {
+			return nil, e
+		}
+		entries = append(entries, entry)
+	}
+	return entries, cur.Err()
+}
+
+// MemoryAuditStore is a thread-safe AuditStore that keeps the entries in memory
+type MemoryAuditStore struct {
+	mu      sync.RWMutex
+	entries []models.AuditEntry
+}
+
+// NewMemoryAuditStore returns an empty MemoryAuditStore
+func NewMemoryAuditStore() *MemoryAuditStore {
+	return &MemoryAuditStore{}
+}
+
+// Insert stores an audit entry and returns its id
+func (s *MemoryAuditStore) Insert(ctx context.Context, entry models.AuditEntry) (primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := primitive.NewObjectID()
+	entry.Id = &id
+	s.entries = append(s.entries, entry)
+	return id, nil
+}
+
+// ListByTarget returns the entries of a target in chronological order
+func (s *MemoryAuditStore) ListByTarget(ctx context.Context, targetID primitive.ObjectID) ([]models.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []models.AuditEntry
+	for _, entry := range s.entries {
+		if entry.TargetId == targetID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// BlobStore is an interface that describes the operations performed on the binary content of the API, such as photos.
+// Keys are slash separated paths.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// cleanKey normalises a key so it can not escape the root of a store
+func cleanKey(key string) string {
+	return strings.TrimPrefix(path.Clean("/"+key), "/")
+}
+
+// LocalBlobStore is a BlobStore that keeps the blobs in a directory of the local filesystem
+type LocalBlobStore struct {
+	Root string
+}
+
+// NewLocalBlobStore returns a LocalBlobStore that stores the blobs under the root directory
+func NewLocalBlobStore(root string) *LocalBlobStore {
+	return &LocalBlobStore{root}
+}
+
+func (s *LocalBlobStore) path(key string) string {
+	return filepath.Join(s.Root, filepath.FromSlash(cleanKey(key)))
+}
+
+// Put writes a blob. The content is written to a temporary file first, so readers never see a partial blob.
+func (s *LocalBlobStore) Put(ctx context.Context, key string, r io.Reader) error {
+	p := s.path(key)
+	if e := os.MkdirAll(filepath.Dir(p), 0755); e != nil {
+		return e
+	}
+
+	f, e := ioutil.TempFile(filepath.Dir(p), ".upload-")
+	if e != nil {
+		return e
+	}
+	defer os.Remove(f.Name())
+
+	if _, e := io.Copy(f, r); e != nil {
+		f.Close()
+		return e
+	}
+	if e := f.Close(); e != nil {
+		return e
+	}
+	return os.Rename(f.Name(), p)
+}
+
+// Get opens a blob for reading
+func (s *LocalBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, e := os.Open(s.path(key))
+	if os.IsNotExist(e) {
+		return nil, ErrNotFound
+	}
+	return f, e
+}
+
+// Delete removes a blob
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	e := os.Remove(s.path(key))
+	if os.IsNotExist(e) {
+		return ErrNotFound
+	}
+	return e
+}
+
+// MemoryBlobStore is a thread-safe BlobStore that keeps the blobs in memory
+type MemoryBlobStore struct {
+	mu    sync.RWMutex
+	blobs map[string][]byte
+}
+
+// NewMemoryBlobStore returns an empty MemoryBlobStore
+func NewMemoryBlobStore() *MemoryBlobStore {
+	return &MemoryBlobStore{blobs: map[string][]byte{}}
+}
+
+// Put writes a blob
+func (s *MemoryBlobStore) Put(ctx context.Context, key string, r io.Reader) error {
+	b, e := ioutil.ReadAll(r)
+	if e != nil {
+		return e
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[cleanKey(key)] = b
+	return nil
+}
+
+// Get opens a blob for reading
+func (s *MemoryBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, ok := s.blobs[cleanKey(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+// Delete removes a blob
+func (s *MemoryBlobStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.blobs[cleanKey(key)]; !ok {
+		return ErrNotFound
+	}
+	delete(s.blobs, cleanKey(key))
+	return nil
+}
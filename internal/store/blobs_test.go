@@ -0,0 +1,57 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func checkBlobStore(s BlobStore, t *testing.T) {
+	ctx := context.Background()
+	if e := s.Put(ctx, "photos/1/original", strings.NewReader("content")); e != nil {
+		t.Fatalf("Should have stored the blob rather than returning %v", e)
+	}
+
+	rc, e := s.Get(ctx, "photos/1/original")
+	if e != nil {
+		t.Fatalf("Should have returned the blob rather than %v", e)
+	}
+	b, _ := ioutil.ReadAll(rc)
+	rc.Close()
+	if !bytes.Equal(b, []byte("content")) {
+		t.Errorf("Should have returned the content of the blob rather than %s", b)
+	}
+
+	if e := s.Delete(ctx, "photos/1/original"); e != nil {
+		t.Errorf("Should have deleted the blob rather than returning %v", e)
+	}
+	if _, e := s.Get(ctx, "photos/1/original"); e != ErrNotFound {
+		t.Errorf("Should have returned %v rather than %v", ErrNotFound, e)
+	}
+}
+
+func TestMemoryBlobStore(t *testing.T) {
+	checkBlobStore(NewMemoryBlobStore(), t)
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "blobs")
+	defer os.RemoveAll(dir)
+	checkBlobStore(NewLocalBlobStore(dir), t)
+}
+
+func TestLocalBlobStoreKeysStayWithinRoot(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "blobs")
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "root")
+	s := NewLocalBlobStore(root)
+
+	s.Put(context.Background(), "../../escaped", strings.NewReader("content"))
+	if _, e := os.Stat(filepath.Join(root, "escaped")); e != nil {
+		t.Errorf("Should have stored the blob within the root directory")
+	}
+}
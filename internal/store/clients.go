@@ -0,0 +1,406 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ClientStore is an interface that describes the operations performed on the clients collection
+type ClientStore interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Client, error)
+	// List returns every client, with the oldest first
+	List(ctx context.Context) ([]models.Client, error)
+	Insert(ctx context.Context, client models.Client) (primitive.ObjectID, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// MongoClientStore is a ClientStore backed by a MongoDB collection
+type MongoClientStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoClientStore returns a MongoClientStore that uses the clients collection of a database
+func NewMongoClientStore(db *mongo.Database) *MongoClientStore {
+	return &MongoClientStore{db.Collection(models.Clients{}.Name())}
+}
+
+// FindByID returns the client with the given id
+func (s *MongoClientStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Client, error) {
+	var client models.Client
+	if e := s.Collection.FindOne(ctx, bson.M{"_id": id}).Decode(&client); e != nil {
+		if e == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, e
+	}
+	return &client, nil
+}
+
+// List returns every client, with the oldest first
+func (s *MongoClientStore) List(ctx context.Context) ([]models.Client, error) {
+	cursor, e := s.Collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if e != nil {
+		return nil, e
+	}
+	defer cursor.Close(ctx)
+
+	clients := []models.Client{}
+	for cursor.Next(ctx) {
+		var client models.Client
+		if e := cursor.Decode(&client); e != nil {
+			return nil, e
+		}
+		clients = append(clients, client)
+	}
+	return clients, cursor.Err()
+}
+
+// Insert stores a client and returns its id
+func (s *MongoClientStore) Insert(ctx context.Context, client models.Client) (primitive.ObjectID, error) {
+	result, e := s.Collection.InsertOne(ctx, client)
+	if e != nil {
+		return primitive.NilObjectID, e
+	}
+	oid, _ := result.InsertedID.(primitive.ObjectID)
+	return oid, nil
+}
+
+// Delete removes the client with the given id
+func (s *MongoClientStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, e := s.Collection.DeleteOne(ctx, bson.M{"_id": id})
+	if e != nil {
+		return e
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MemoryClientStore is a thread-safe ClientStore that keeps the clients in memory
+type MemoryClientStore struct {
+	mu      sync.RWMutex
+	clients map[primitive.ObjectID]models.Client
+}
+
+// NewMemoryClientStore returns an empty MemoryClientStore
+func NewMemoryClientStore() *MemoryClientStore {
+	return &MemoryClientStore{clients: map[primitive.ObjectID]models.Client{}}
+}
+
+// copyClient returns a client that does not share its redirect URIs with the stored one
+func copyClient(client models.Client) *models.Client {
+	client.RedirectURIs = append([]string{}, client.RedirectURIs...)
+	return &client
+}
+
+// FindByID returns the client with the given id
+func (s *MemoryClientStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Client, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	client, ok := s.clients[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyClient(client), nil
+}
+
+// List returns every client, with the oldest first
+func (s *MemoryClientStore) List(ctx context.Context) ([]models.Client, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	clients := []models.Client{}
+	for _, client := range s.clients {
+		clients = append(clients, *copyClient(client))
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].Id.Hex() < clients[j].Id.Hex()
+	})
+	return clients, nil
+}
+
+// Insert stores a client and returns its id. A new id is generated when the client does not have one.
+func (s *MemoryClientStore) Insert(ctx context.Context, client models.Client) (primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if client.Id == nil {
+		id := primitive.NewObjectID()
+		client.Id = &id
+	}
+	if _, ok := s.clients[*client.Id]; ok {
+		return primitive.NilObjectID, ErrDuplicate
+	}
+	s.clients[*client.Id] = *copyClient(client)
+	return *client.Id, nil
+}
+
+// Delete removes the client with the given id
+func (s *MemoryClientStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.clients, id)
+	return nil
+}
+
+// AuthorizationCodeStore is an interface that describes the operations performed on the authorization codes collection
+type AuthorizationCodeStore interface {
+	Insert(ctx context.Context, code models.AuthorizationCode) (primitive.ObjectID, error)
+	FindByHash(ctx context.Context, hash string) (*models.AuthorizationCode, error)
+	// MarkUsed flags a code as exchanged. It returns false when the code had already been used, which allows callers to
+	// detect concurrent use.
+	MarkUsed(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error)
+}
+
+// MongoAuthorizationCodeStore is an AuthorizationCodeStore backed by a MongoDB collection
+type MongoAuthorizationCodeStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoAuthorizationCodeStore returns a MongoAuthorizationCodeStore that uses the authorizationCodes collection of a
+// database
+func NewMongoAuthorizationCodeStore(db *mongo.Database) *MongoAuthorizationCodeStore {
+	return &MongoAuthorizationCodeStore{db.Collection(models.AuthorizationCodes{}.Name())}
+}
+
+// EnsureIndexes creates a unique index on the code hash and a TTL index that removes expired codes
+func (s *MongoAuthorizationCodeStore) EnsureIndexes(ctx context.Context) error {
+	_, e := s.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"hash": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"expiresAt": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return e
+}
+
+// Insert stores an authorization code and returns its id
+func (s *MongoAuthorizationCodeStore) Insert(ctx context.Context, code models.AuthorizationCode) (primitive.ObjectID, error) {
+	result, e := s.Collection.InsertOne(ctx, code)
+	if e != nil {
+		if isDuplicateKey(e) {
+			return primitive.NilObjectID, ErrDuplicate
+		}
+		return primitive.NilObjectID, e
+	}
+	oid, _ := result.InsertedID.(primitive.ObjectID)
+	return oid, nil
+}
+
+// FindByHash returns the authorization code with the given hash
+func (s *MongoAuthorizationCodeStore) FindByHash(ctx context.Context, hash string) (*models.AuthorizationCode, error) {
+	var code models.AuthorizationCode
+	if e := s.Collection.FindOne(ctx, bson.M{"hash": hash}).Decode(&code); e != nil {
+		if e == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, e
+	}
+	return &code, nil
+}
+
+// MarkUsed flags a code as exchanged, only if it has not been used before
+func (s *MongoAuthorizationCodeStore) MarkUsed(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	filter := bson.M{"_id": id, "usedAt": bson.M{"$exists": false}}
+	result, e := s.Collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"usedAt": at}})
+	if e != nil {
+		return false, e
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// MemoryAuthorizationCodeStore is a thread-safe AuthorizationCodeStore that keeps the codes in memory
+type MemoryAuthorizationCodeStore struct {
+	mu    sync.Mutex
+	codes map[primitive.ObjectID]models.AuthorizationCode
+}
+
+// NewMemoryAuthorizationCodeStore returns an empty MemoryAuthorizationCodeStore
+func NewMemoryAuthorizationCodeStore() *MemoryAuthorizationCodeStore {
+	return &MemoryAuthorizationCodeStore{codes: map[primitive.ObjectID]models.AuthorizationCode{}}
+}
+
+// Insert stores an authorization code and returns its id
+func (s *MemoryAuthorizationCodeStore) Insert(ctx context.Context, code models.AuthorizationCode) (primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.codes {
+		if c.Hash == code.Hash {
+			return primitive.NilObjectID, ErrDuplicate
+		}
+	}
+	id := primitive.NewObjectID()
+	code.Id = &id
+	code.Scopes = append([]string{}, code.Scopes...)
+	s.codes[id] = code
+	return id, nil
+}
+
+// FindByHash returns the authorization code with the given hash
+func (s *MemoryAuthorizationCodeStore) FindByHash(ctx context.Context, hash string) (*models.AuthorizationCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.codes {
+		if c.Hash == hash {
+			c.Scopes = append([]string{}, c.Scopes...)
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// MarkUsed flags a code as exchanged, only if it has not been used before
+func (s *MemoryAuthorizationCodeStore) MarkUsed(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.codes[id]
+	if !ok || c.UsedAt != nil {
+		return false, nil
+	}
+	c.UsedAt = &at
+	s.codes[id] = c
+	return true, nil
+}
+
+// ConsentStore is an interface that describes the operations performed on the consents collection
+type ConsentStore interface {
+	// Find returns the consent of a user to a client
+	Find(ctx context.Context, userID primitive.ObjectID, clientID primitive.ObjectID) (*models.Consent, error)
+	// Grant adds scopes to the consent of a user to a client, which is created when the user had not consented yet
+	Grant(ctx context.Context, userID primitive.ObjectID, clientID primitive.ObjectID, scopes []string, at time.Time) error
+	// DeleteByClient removes the consents to a client
+	DeleteByClient(ctx context.Context, clientID primitive.ObjectID) error
+}
+
+// MongoConsentStore is a ConsentStore backed by a MongoDB collection
+type MongoConsentStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoConsentStore returns a MongoConsentStore that uses the consents collection of a database
+func NewMongoConsentStore(db *mongo.Database) *MongoConsentStore {
+	return &MongoConsentStore{db.Collection(models.Consents{}.Name())}
+}
+
+// EnsureIndexes creates a unique index on the user and the client, along with an index used to remove the consents of
+// a client
+func (s *MongoConsentStore) EnsureIndexes(ctx context.Context) error {
+	_, e := s.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "clientId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.M{"clientId": 1}},
+	})
+	return e
+}
+
+// Find returns the consent of a user to a client
+func (s *MongoConsentStore) Find(ctx context.Context, userID primitive.ObjectID, clientID primitive.ObjectID) (*models.Consent, error) {
+	var consent models.Consent
+	if e := s.Collection.FindOne(ctx, bson.M{"userId": userID, "clientId": clientID}).Decode(&consent); e != nil {
+		if e == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, e
+	}
+	return &consent, nil
+}
+
+// Grant adds scopes to the consent of a user to a client, which is created when the user had not consented yet
+func (s *MongoConsentStore) Grant(ctx context.Context, userID primitive.ObjectID, clientID primitive.ObjectID, scopes []string, at time.Time) error {
+	update := bson.M{
+		"$addToSet": bson.M{"scopes": bson.M{"$each": scopes}},
+		"$set":      bson.M{"updatedAt": at},
+	}
+	_, e := s.Collection.UpdateOne(ctx, bson.M{"userId": userID, "clientId": clientID}, update, options.Update().SetUpsert(true))
+	return e
+}
+
+// DeleteByClient removes the consents to a client
+func (s *MongoConsentStore) DeleteByClient(ctx context.Context, clientID primitive.ObjectID) error {
+	_, e := s.Collection.DeleteMany(ctx, bson.M{"clientId": clientID})
+	return e
+}
+
+// consentKey identifies a consent, the same way as the unique index of MongoConsentStore
+type consentKey struct {
+	UserId   primitive.ObjectID
+	ClientId primitive.ObjectID
+}
+
+// MemoryConsentStore is a thread-safe ConsentStore that keeps the consents in memory
+type MemoryConsentStore struct {
+	mu       sync.Mutex
+	consents map[consentKey]models.Consent
+}
+
+// NewMemoryConsentStore returns an empty MemoryConsentStore
+func NewMemoryConsentStore() *MemoryConsentStore {
+	return &MemoryConsentStore{consents: map[consentKey]models.Consent{}}
+}
+
+// Find returns the consent of a user to a client
+func (s *MemoryConsentStore) Find(ctx context.Context, userID primitive.ObjectID, clientID primitive.ObjectID) (*models.Consent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	consent, ok := s.consents[consentKey{userID, clientID}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	consent.Scopes = append([]string{}, consent.Scopes...)
+	return &consent, nil
+}
+
+// Grant adds scopes to the consent of a user to a client, which is created when the user had not consented yet
+func (s *MemoryConsentStore) Grant(ctx context.Context, userID primitive.ObjectID, clientID primitive.ObjectID, scopes []string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := consentKey{userID, clientID}
+	consent, ok := s.consents[k]
+	if !ok {
+		id := primitive.NewObjectID()
+		consent = models.Consent{Id: &id, UserId: userID, ClientId: clientID}
+	}
+	scopes = append(append([]string{}, consent.Scopes...), scopes...)
+	consent.Scopes = []string{}
+	for _, scope := range scopes {
+		if !consent.Covers([]string{scope}) {
+			consent.Scopes = append(consent.Scopes, scope)
+		}
+	}
+	consent.UpdatedAt = at
+	s.consents[k] = consent
+	return nil
+}
+
+// DeleteByClient removes the consents to a client
+func (s *MemoryConsentStore) DeleteByClient(ctx context.Context, clientID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k := range s.consents {
+		if k.ClientId == clientID {
+			delete(s.consents, k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMemoryClientStore(t *testing.T) {
+	s := NewMemoryClientStore()
+	ctx := context.Background()
+
+	id, _ := s.Insert(ctx, models.Client{ClientName: "Photo Blog", RedirectURIs: []string{"https://photos.example.com/callback"}})
+	client, e := s.FindByID(ctx, id)
+	if e != nil || !client.HasRedirectURI("https://photos.example.com/callback") || !client.IsPublic() {
+		t.Fatalf("Should have returned the stored client rather than %v", e)
+	}
+	client.RedirectURIs[0] = "https://attacker.example.com/callback"
+	if stored, _ := s.FindByID(ctx, id); !stored.HasRedirectURI("https://photos.example.com/callback") {
+		t.Errorf("Should not have shared the redirect URIs with the stored client")
+	}
+
+	if e := s.Delete(ctx, id); e != nil {
+		t.Errorf("Should have deleted the client rather than returning %v", e)
+	}
+	if clients, _ := s.List(ctx); len(clients) != 0 {
+		t.Errorf("Should have listed no clients rather than %v", len(clients))
+	}
+}
+
+func TestMemoryAuthorizationCodeStoreMarkUsed(t *testing.T) {
+	s := NewMemoryAuthorizationCodeStore()
+	ctx := context.Background()
+	now := time.Now()
+
+	id, _ := s.Insert(ctx, models.AuthorizationCode{Hash: "hash", ClientId: primitive.NewObjectID(), ExpiresAt: now.Add(time.Minute)})
+	if _, e := s.Insert(ctx, models.AuthorizationCode{Hash: "hash"}); e != ErrDuplicate {
+		t.Errorf("Should have rejected a code with the same hash rather than returning %v", e)
+	}
+	if used, _ := s.MarkUsed(ctx, id, now); !used {
+		t.Errorf("Should have marked the code as used")
+	}
+	if used, _ := s.MarkUsed(ctx, id, now); used {
+		t.Errorf("Should not have marked a used code twice")
+	}
+	if code, _ := s.FindByHash(ctx, "hash"); code.UsedAt == nil {
+		t.Errorf("Should have returned the time that the code was used")
+	}
+}
+
+func TestMemoryConsentStore(t *testing.T) {
+	s := NewMemoryConsentStore()
+	ctx := context.Background()
+	userID, clientID := primitive.NewObjectID(), primitive.NewObjectID()
+
+	if _, e := s.Find(ctx, userID, clientID); e != ErrNotFound {
+		t.Errorf("Should have returned %v before the user consented rather than %v", ErrNotFound, e)
+	}
+	s.Grant(ctx, userID, clientID, []string{models.ScopeOpenID, models.ScopeEmail}, time.Now())
+	s.Grant(ctx, userID, clientID, []string{models.ScopeOpenID, models.ScopeProfile}, time.Now())
+	consent, e := s.Find(ctx, userID, clientID)
+	if e != nil || len(consent.Scopes) != 3 || !consent.Covers([]string{models.ScopeEmail, models.ScopeProfile}) {
+		t.Errorf("Should have added the scopes to the consent rather than %+v (%v)", consent, e)
+	}
+
+	s.DeleteByClient(ctx, clientID)
+	if _, e := s.Find(ctx, userID, clientID); e != ErrNotFound {
+		t.Errorf("Should have removed the consents to the client rather than returning %v", e)
+	}
+}
@@ -0,0 +1,221 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CommentStore is an interface that describes the operations performed on the comments collection
+type CommentStore interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Comment, error)
+	// ListByTarget returns the comments of a post or a photo, with the oldest first
+	ListByTarget(ctx context.Context, targetType string, targetID primitive.ObjectID) ([]models.Comment, error)
+	Insert(ctx context.Context, comment models.Comment) (primitive.ObjectID, error)
+	Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) (*models.Comment, error)
+	// Delete removes a comment along with its replies
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// DeleteByTarget removes the comments of a post or a photo
+	DeleteByTarget(ctx context.Context, targetType string, targetID primitive.ObjectID) error
+}
+
+// MongoCommentStore is a CommentStore backed by a MongoDB collection
+type MongoCommentStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoCommentStore returns a MongoCommentStore that uses the comments collection of a database
+func NewMongoCommentStore(db *mongo.Database) *MongoCommentStore {
+	return &MongoCommentStore{db.Collection(models.Comments{}.Name())}
+}
+
+// EnsureIndexes creates the indexes used to list the comments of a target and the replies of a comment
+func (s *MongoCommentStore) EnsureIndexes(ctx context.Context) error {
+	_, e := s.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "targetType", Value: 1}, {Key: "targetId", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.M{"parentId": 1}},
+	})
+	return e
+}
+
+// FindByID returns the comment with the given id
+func (s *MongoCommentStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Comment, error) {
+	var comment models.Comment
+	if e := s.Collection.FindOne(ctx, bson.M{"_id": id}).Decode(&comment); e != nil {
+		if e == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, e
+	}
+	return &comment, nil
+}
+
+// ListByTarget returns the comments of a post or a photo, with the oldest first
+func (s *MongoCommentStore) ListByTarget(ctx context.Context, targetType string, targetID primitive.ObjectID) ([]models.Comment, error) {
+	comments := []models.Comment{}
+
+	cur, e := s.Collection.Find(ctx, bson.M{"targetType": targetType, "targetId": targetID}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if e != nil {
+		return nil, e
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var comment models.Comment
+		if e := cur.Decode(&comment); e != nil {
+			return nil, e
+		}
+		comments = append(comments, comment)
+	}
+	return comments, cur.Err()
+}
+
+// Insert stores a comment and returns its id
+func (s *MongoCommentStore) Insert(ctx context.Context, comment models.Comment) (primitive.ObjectID, error) {
+	result, e := s.Collection.InsertOne(ctx, comment)
+	if e != nil {
+		return primitive.NilObjectID, e
+	}
+	oid, _ := result.InsertedID.(primitive.ObjectID)
+	return oid, nil
+}
+
+// Update sets the given fields of a comment and returns the updated document
+func (s *MongoCommentStore) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) (*models.Comment, error) {
+	result, e := s.Collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": fields})
+	if e != nil {
+		return nil, e
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrNotFound
+	}
+	return s.FindByID(ctx, id)
+}
+
+// Delete removes a comment along with its replies
+func (s *MongoCommentStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, e := s.Collection.DeleteMany(ctx, bson.M{"$or": bson.A{bson.M{"_id": id}, bson.M{"parentId": id}}})
+	if e != nil {
+		return e
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteByTarget removes the comments of a post or a photo
+func (s *MongoCommentStore) DeleteByTarget(ctx context.Context, targetType string, targetID primitive.ObjectID) error {
+	_, e := s.Collection.DeleteMany(ctx, bson.M{"targetType": targetType, "targetId": targetID})
+	return e
+}
+
+// MemoryCommentStore is a thread-safe CommentStore that keeps the comments in memory
+type MemoryCommentStore struct {
+	mu       sync.RWMutex
+	comments map[primitive.ObjectID]models.Comment
+}
+
+// NewMemoryCommentStore returns an empty MemoryCommentStore
+func NewMemoryCommentStore() *MemoryCommentStore {
+	return &MemoryCommentStore{comments: map[primitive.ObjectID]models.Comment{}}
+}
+
+// FindByID returns the comment with the given id
+func (s *MemoryCommentStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comment, ok := s.comments[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &comment, nil
+}
+
+// ListByTarget returns the comments of a post or a photo, with the oldest first
+func (s *MemoryCommentStore) ListByTarget(ctx context.Context, targetType string, targetID primitive.ObjectID) ([]models.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comments := []models.Comment{}
+	for _, comment := range s.comments {
+		if comment.TargetType == targetType && comment.TargetId == targetID {
+			comments = append(comments, comment)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		return comments[i].CreatedAt.Before(comments[j].CreatedAt)
+	})
+	return comments, nil
+}
+
+// Insert stores a comment and returns its id. A new id is generated when the comment does not have one.
+func (s *MemoryCommentStore) Insert(ctx context.Context, comment models.Comment) (primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if comment.Id == nil {
+		id := primitive.NewObjectID()
+		comment.Id = &id
+	}
+	if _, ok := s.comments[*comment.Id]; ok {
+		return primitive.NilObjectID, ErrDuplicate
+	}
+	s.comments[*comment.Id] = comment
+	return *comment.Id, nil
+}
+
+// Update sets the given fields of a comment and returns the updated document
+func (s *MemoryCommentStore) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) (*models.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.comments[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var updated models.Comment
+	if e := setFields(comment, fields, &updated); e != nil {
+		return nil, e
+	}
+	s.comments[id] = updated
+	return &updated, nil
+}
+
+// Delete removes a comment along with its replies
+func (s *MemoryCommentStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.comments[id]; !ok {
+		return ErrNotFound
+	}
+	for cid, comment := range s.comments {
+		if cid == id || (comment.ParentId != nil && *comment.ParentId == id) {
+			delete(s.comments, cid)
+		}
+	}
+	return nil
+}
+
+// DeleteByTarget removes the comments of a post or a photo
+func (s *MemoryCommentStore) DeleteByTarget(ctx context.Context, targetType string, targetID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, comment := range s.comments {
+		if comment.TargetType == targetType && comment.TargetId == targetID {
+			delete(s.comments, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FollowStore is an interface that describes the operations performed on the follows collection
+type FollowStore interface {
+	// Insert stores a follow, unless the follower already follows the followee, and reports if it was stored
+	Insert(ctx context.Context, follow models.Follow) (bool, error)
+	// Delete removes a follow and reports if it existed
+	Delete(ctx context.Context, followerID primitive.ObjectID, followeeID primitive.ObjectID) (bool, error)
+	// Followers returns the ids of the users that follow a user, with the most recent first
+	Followers(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error)
+	// Following returns the ids of the users that a user follows, with the most recent first
+	Following(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error)
+}
+
+// MongoFollowStore is a FollowStore backed by a MongoDB collection
+type MongoFollowStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoFollowStore returns a MongoFollowStore that uses the follows collection of a database
+func NewMongoFollowStore(db *mongo.Database) *MongoFollowStore {
+	return &MongoFollowStore{db.Collection(models.Follows{}.Name())}
+}
+
+// EnsureIndexes creates a unique index on the follower and the followee, along with an index used to list followers
+func (s *MongoFollowStore) EnsureIndexes(ctx context.Context) error {
+	_, e := s.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "followerId", Value: 1}, {Key: "followeeId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "followeeId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	return e
+}
+
+// Insert stores a follow, unless the follower already follows the followee, and reports if it was stored
+func (s *MongoFollowStore) Insert(ctx context.Context, follow models.Follow) (bool, error) {
+	if _, e := s.Collection.InsertOne(ctx, follow); e != nil {
+		if isDuplicateKey(e) {
+			return false, nil
+		}
+		return false, e
+	}
+	return true, nil
+}
+
+// Delete removes a follow and reports if it existed
+func (s *MongoFollowStore) Delete(ctx context.Context, followerID primitive.ObjectID, followeeID primitive.ObjectID) (bool, error) {
+	result, e := s.Collection.DeleteOne(ctx, bson.M{"followerId": followerID, "followeeId": followeeID})
+	if e != nil {
+		return false, e
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (s *MongoFollowStore) list(ctx context.Context, filter bson.M, follower bool) ([]primitive.ObjectID, error) {
+	ids := []primitive.ObjectID{}
+
+	cur, e := s.Collection.Find(ctx, filter, options.Find().SetSort(bson.M{"createdAt": -1}))
+	if e != nil {
+		return nil, e
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var follow models.Follow
+		if e := cur.Decode(&follow); e != nil {
+			return nil, e
+		}
+		if follower {
+			ids = append(ids, follow.FollowerId)
+		} else {
+			ids = append(ids, follow.FolloweeId)
+		}
+	}
+	return ids, cur.Err()
+}
+
+// Followers returns the ids of the users that follow a user, with the most recent first
+func (s *MongoFollowStore) Followers(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	return s.list(ctx, bson.M{"followeeId": userID}, true)
+}
+
+// Following returns the ids of the users that a user follows, with the most recent first
+func (s *MongoFollowStore) Following(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	return s.list(ctx, bson.M{"followerId": userID}, false)
+}
+
+// followKey identifies a follow, the same way as the unique index of MongoFollowStore
+type followKey struct {
+	FollowerId primitive.ObjectID
+	FolloweeId primitive.ObjectID
+}
+
+// MemoryFollowStore is a thread-safe FollowStore that keeps the follows in memory
+type MemoryFollowStore struct {
+	mu      sync.RWMutex
+	follows map[followKey]models.Follow
+}
+
+// NewMemoryFollowStore returns an empty MemoryFollowStore
+func NewMemoryFollowStore() *MemoryFollowStore {
+	return &MemoryFollowStore{follows: map[followKey]models.Follow{}}
+}
+
+// Insert stores a follow, unless the follower already follows the followee, and reports if it was stored
+func (s *MemoryFollowStore) Insert(ctx context.Context, follow models.Follow) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := followKey{follow.FollowerId, follow.FolloweeId}
+	if _, ok := s.follows[k]; ok {
+		return false, nil
+	}
+	s.follows[k] = follow
+	return true, nil
+}
+
+// Delete removes a follow and reports if it existed
+func (s *MemoryFollowStore) Delete(ctx context.Context, followerID primitive.ObjectID, followeeID primitive.ObjectID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := followKey{followerID, followeeID}
+	if _, ok := s.follows[k]; !ok {
+		return false, nil
+	}
+	delete(s.follows, k)
+	return true, nil
+}
+
+func (s *MemoryFollowStore) list(match func(models.Follow) bool, follower bool) []primitive.ObjectID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	follows := []models.Follow{}
+	for _, follow := range s.follows {
+		if match(follow) {
+			follows = append(follows, follow)
+		}
+	}
+	sort.Slice(follows, func(i, j int) bool {
+		return follows[i].CreatedAt.After(follows[j].CreatedAt)
+	})
+
+	ids := make([]primitive.ObjectID, len(follows))
+	for i, follow := range follows {
+		if follower {
+			ids[i] = follow.FollowerId
+		} else {
+			ids[i] = follow.FolloweeId
+		}
+	}
+	return ids
+}
+
+// Followers returns the ids of the users that follow a user, with the most recent first
+func (s *MemoryFollowStore) Followers(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	return s.list(func(f models.Follow) bool { return f.FolloweeId == userID }, true), nil
+}
+
+// Following returns the ids of the users that a user follows, with the most recent first
+func (s *MemoryFollowStore) Following(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	return s.list(func(f models.Follow) bool { return f.FollowerId == userID }, false), nil
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LikeStore is an interface that describes the operations performed on the likes collection
+type LikeStore interface {
+	// Insert stores a like, unless the user already likes the target, and reports if it was stored
+	Insert(ctx context.Context, like models.Like) (bool, error)
+	// Delete removes the like of a user and reports if it existed
+	Delete(ctx context.Context, userID primitive.ObjectID, targetType string, targetID primitive.ObjectID) (bool, error)
+	// LikedBy returns which of the given targets are liked by a user
+	LikedBy(ctx context.Context, userID primitive.ObjectID, targetType string, targetIDs []primitive.ObjectID) (map[primitive.ObjectID]bool, error)
+	// DeleteByTarget removes the likes of a post or a photo
+	DeleteByTarget(ctx context.Context, targetType string, targetID primitive.ObjectID) error
+}
+
+// MongoLikeStore is a LikeStore backed by a MongoDB collection
+type MongoLikeStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoLikeStore returns a MongoLikeStore that uses the likes collection of a database
+func NewMongoLikeStore(db *mongo.Database) *MongoLikeStore {
+	return &MongoLikeStore{db.Collection(models.Likes{}.Name())}
+}
+
+// EnsureIndexes creates a unique index on the user and the target, so that a target is liked once by each user
+func (s *MongoLikeStore) EnsureIndexes(ctx context.Context) error {
+	_, e := s.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "targetType", Value: 1}, {Key: "targetId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "targetType", Value: 1}, {Key: "targetId", Value: 1}}},
+	})
+	return e
+}
+
+// Insert stores a like, unless the user already likes the target, and reports if it was stored
+func (s *MongoLikeStore) Insert(ctx context.Context, like models.Like) (bool, error) {
+	if _, e := s.Collection.InsertOne(ctx, like); e != nil {
+		if isDuplicateKey(e) {
+			return false, nil
+		}
+		return false, e
+	}
+	return true, nil
+}
+
+// Delete removes the like of a user and reports if it existed
+func (s *MongoLikeStore) Delete(ctx context.Context, userID primitive.ObjectID, targetType string, targetID primitive.ObjectID) (bool, error) {
+	result, e := s.Collection.DeleteOne(ctx, bson.M{"userId": userID, "targetType": targetType, "targetId": targetID})
+	if e != nil {
+		return false, e
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// LikedBy returns which of the given targets are liked by a user
+func (s *MongoLikeStore) LikedBy(ctx context.Context, userID primitive.ObjectID, targetType string, targetIDs []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	liked := map[primitive.ObjectID]bool{}
+	if len(targetIDs) == 0 {
+		return liked, nil
+	}
+
+	cur, e := s.Collection.Find(ctx, bson.M{"userId": userID, "targetType": targetType, "targetId": bson.M{"$in": targetIDs}})
+	if e != nil {
+		return nil, e
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var like models.Like
+		if e := cur.Decode(&like); e != nil {
+			return nil, e
+		}
+		liked[like.TargetId] = true
+	}
+	return liked, cur.Err()
+}
+
+// DeleteByTarget removes the likes of a post or a photo
+func (s *MongoLikeStore) DeleteByTarget(ctx context.Context, targetType string, targetID primitive.ObjectID) error {
+	_, e := s.Collection.DeleteMany(ctx, bson.M{"targetType": targetType, "targetId": targetID})
+	return e
+}
+
+// likeKey identifies the like of a user, the same way as the unique index of MongoLikeStore
+type likeKey struct {
+	UserId     primitive.ObjectID
+	TargetType string
+	TargetId   primitive.ObjectID
+}
+
+// MemoryLikeStore is a thread-safe LikeStore that keeps the likes in memory
+type MemoryLikeStore struct {
+	mu    sync.RWMutex
+	likes map[likeKey]models.Like
+}
+
+// NewMemoryLikeStore returns an empty MemoryLikeStore
+func NewMemoryLikeStore() *MemoryLikeStore {
+	return &MemoryLikeStore{likes: map[likeKey]models.Like{}}
+}
+
+// Insert stores a like, unless the user already likes the target, and reports if it was stored
+func (s *MemoryLikeStore) Insert(ctx context.Context, like models.Like) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := likeKey{like.UserId, like.TargetType, like.TargetId}
+	if _, ok := s.likes[k]; ok {
+		return false, nil
+	}
+	s.likes[k] = like
+	return true, nil
+}
+
+// Delete removes the like of a user and reports if it existed
+func (s *MemoryLikeStore) Delete(ctx context.Context, userID primitive.ObjectID, targetType string, targetID primitive.ObjectID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := likeKey{userID, targetType, targetID}
+	if _, ok := s.likes[k]; !ok {
+		return false, nil
+	}
+	delete(s.likes, k)
+	return true, nil
+}
+
+// LikedBy returns which of the given targets are liked by a user
+func (s *MemoryLikeStore) LikedBy(ctx context.Context, userID primitive.ObjectID, targetType string, targetIDs []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	liked := map[primitive.ObjectID]bool{}
+	for _, id := range targetIDs {
+		if _, ok := s.likes[likeKey{userID, targetType, id}]; ok {
+			liked[id] = true
+		}
+	}
+	return liked, nil
+}
+
+// DeleteByTarget removes the likes of a post or a photo
+func (s *MemoryLikeStore) DeleteByTarget(ctx context.Context, targetType string, targetID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k := range s.likes {
+		if k.TargetType == targetType && k.TargetId == targetID {
+			delete(s.likes, k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PhotoStore is an interface that describes the operations performed on the photos collection
+type PhotoStore interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Photo, error)
+	// FindMany returns the photos with the given ids in no particular order. Unknown ids are ignored.
+	FindMany(ctx context.Context, ids []primitive.ObjectID) ([]models.Photo, error)
+	// ListRecent returns up to limit photos that match a filter and are listed after a cursor, with the newest first. A
+	// nil cursor starts from the newest photo. Private photos are never listed.
+	ListRecent(ctx context.Context, filter ContentFilter, after *Cursor, limit int64) ([]models.Photo, error)
+	// ListPending returns up to limit photos whose renditions are pending, with the oldest first
+	ListPending(ctx context.Context, limit int64) ([]models.Photo, error)
+	Insert(ctx context.Context, photo models.Photo) (primitive.ObjectID, error)
+	Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) (*models.Photo, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// IncrementLikes adds delta to the like count of a photo and returns the new count
+	IncrementLikes(ctx context.Context, id primitive.ObjectID, delta int64) (int64, error)
+}
+
+// MongoPhotoStore is a PhotoStore backed by a MongoDB collection
+type MongoPhotoStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoPhotoStore returns a MongoPhotoStore that uses the photos collection of a database
+func NewMongoPhotoStore(db *mongo.Database) *MongoPhotoStore {
+	return &MongoPhotoStore{db.Collection(models.Photos{}.Name())}
+}
+
+// EnsureIndexes creates the indexes used to list the photos of an owner and the photos with a tag, with the newest
+// first, and the photos whose renditions are pending
+func (s *MongoPhotoStore) EnsureIndexes(ctx context.Context) error {
+	_, e := s.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "ownerId", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "renditionStatus", Value: 1}, {Key: "createdAt", Value: 1}}},
+	})
+	return e
+}
+
+// FindByID returns the photo with the given id
+func (s *MongoPhotoStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Photo, error) {
+	var photo models.Photo
+	if e := s.Collection.FindOne(ctx, bson.M{"_id": id}).Decode(&photo); e != nil {
+		if e == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, e
+	}
+	return &photo, nil
+}
+
+// FindMany returns the photos with the given ids in no particular order
+func (s *MongoPhotoStore) FindMany(ctx context.Context, ids []primitive.ObjectID) ([]models.Photo, error) {
+	if len(ids) == 0 {
+		return []models.Photo{}, nil
+	}
+	return s.find(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Find())
+}
+
+// ListRecent returns up to limit photos that match a filter and are listed after a cursor
+func (s *MongoPhotoStore) ListRecent(ctx context.Context, filter ContentFilter, after *Cursor, limit int64) ([]models.Photo, error) {
+	q := filter.query("ownerId")
+	q["private"] = bson.M{"$ne": true}
+	if after != nil {
+		q["$and"] = bson.A{after.query("createdAt")}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(limit)
+	return s.find(ctx, q, opts)
+}
+
+// ListPending returns up to limit photos whose renditions are pending, with the oldest first
+func (s *MongoPhotoStore) ListPending(ctx context.Context, limit int64) ([]models.Photo, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(limit)
+	return s.find(ctx, bson.M{"renditionStatus": models.RenditionsPending}, opts)
+}
+
+func (s *MongoPhotoStore) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.Photo, error) {
+	photos := []models.Photo{}
+
+	cur, e := s.Collection.Find(ctx, filter, opts)
+	if e != nil {
+		return nil, e
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var photo models.Photo
+		if e := cur.Decode(&photo); e != nil {
+			return nil, e
+		}
+		photos = append(photos, photo)
+	}
+	return photos, cur.Err()
+}
+
+// Insert stores a photo and returns its id
+func (s *MongoPhotoStore) Insert(ctx context.Context, photo models.Photo) (primitive.ObjectID, error) {
+	result, e := s.Collection.InsertOne(ctx, photo)
+	if e != nil {
+		return primitive.NilObjectID, e
+	}
+	oid, _ := result.InsertedID.(primitive.ObjectID)
+	return oid, nil
+}
+
+// Update sets the given fields of a photo and returns the updated document
+func (s *MongoPhotoStore) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) (*models.Photo, error) {
+	result, e := s.Collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": fields})
+	if e != nil {
+		return nil, e
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrNotFound
+	}
+	return s.FindByID(ctx, id)
+}
+
+// Delete removes the photo with the given id
+func (s *MongoPhotoStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, e := s.Collection.DeleteOne(ctx, bson.M{"_id": id})
+	if e != nil {
+		return e
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// IncrementLikes adds delta to the like count of a photo and returns the new count
+func (s *MongoPhotoStore) IncrementLikes(ctx context.Context, id primitive.ObjectID, delta int64) (int64, error) {
+	var photo models.Photo
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if e := s.Collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"likeCount": delta}}, opts).Decode(&photo); e != nil {
+		if e == mongo.ErrNoDocuments {
+			return 0, ErrNotFound
+		}
+		return 0, e
+	}
+	return photo.LikeCount, nil
+}
+
+// MemoryPhotoStore is a thread-safe PhotoStore that keeps the photos in memory
+type MemoryPhotoStore struct {
+	mu     sync.RWMutex
+	photos map[primitive.ObjectID]models.Photo
+}
+
+// NewMemoryPhotoStore returns an empty MemoryPhotoStore
+func NewMemoryPhotoStore() *MemoryPhotoStore {
+	return &MemoryPhotoStore{photos: map[primitive.ObjectID]models.Photo{}}
+}
+
+// FindByID returns the photo with the given id
+func (s *MemoryPhotoStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Photo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	photo, ok := s.photos[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &photo, nil
+}
+
+// FindMany returns the photos with the given ids in no particular order
+func (s *MemoryPhotoStore) FindMany(ctx context.Context, ids []primitive.ObjectID) ([]models.Photo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	photos := []models.Photo{}
+	for _, id := range ids {
+		if photo, ok := s.photos[id]; ok {
+			photos = append(photos, photo)
+		}
+	}
+	return photos, nil
+}
+
+// ListRecent returns up to limit photos that match a filter and are listed after a cursor
+func (s *MemoryPhotoStore) ListRecent(ctx context.Context, filter ContentFilter, after *Cursor, limit int64) ([]models.Photo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	photos := []models.Photo{}
+	for _, photo := range s.photos {
+		if !photo.Private && filter.match(photo.OwnerId, photo.Tags) && (after == nil || after.Includes(photo.CreatedAt, *photo.Id)) {
+			photos = append(photos, photo)
+		}
+	}
+	sort.Slice(photos, func(i, j int) bool {
+		c := Cursor{photos[i].CreatedAt, *photos[i].Id}
+		return c.Includes(photos[j].CreatedAt, *photos[j].Id)
+	})
+	if int64(len(photos)) > limit {
+		photos = photos[:limit]
+	}
+	return photos, nil
+}
+
+// ListPending returns up to limit photos whose renditions are pending, with the oldest first
+func (s *MemoryPhotoStore) ListPending(ctx context.Context, limit int64) ([]models.Photo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	photos := []models.Photo{}
+	for _, photo := range s.photos {
+		if photo.RenditionStatus == models.RenditionsPending {
+			photos = append(photos, photo)
+		}
+	}
+	sort.Slice(photos, func(i, j int) bool {
+		return photos[i].CreatedAt.Before(photos[j].CreatedAt)
+	})
+	if int64(len(photos)) > limit {
+		photos = photos[:limit]
+	}
+	return photos, nil
+}
+
+// Insert stores a photo and returns its id. A new id is generated when the photo does not have one.
+func (s *MemoryPhotoStore) Insert(ctx context.Context, photo models.Photo) (primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if photo.Id == nil {
+		id := primitive.NewObjectID()
+		photo.Id = &id
+	}
+	if _, ok := s.photos[*photo.Id]; ok {
+		return primitive.NilObjectID, ErrDuplicate
+	}
+	s.photos[*photo.Id] = photo
+	return *photo.Id, nil
+}
+
+// Update sets the given fields of a photo and returns the updated document
+func (s *MemoryPhotoStore) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) (*models.Photo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	photo, ok := s.photos[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var updated models.Photo
+	if e := setFields(photo, fields, &updated); e != nil {
+		return nil, e
+	}
+	s.photos[id] = updated
+	return &updated, nil
+}
+
+// Delete removes the photo with the given id
+func (s *MemoryPhotoStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.photos[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.photos, id)
+	return nil
+}
+
+// IncrementLikes adds delta to the like count of a photo and returns the new count
+func (s *MemoryPhotoStore) IncrementLikes(ctx context.Context, id primitive.ObjectID, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	photo, ok := s.photos[id]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	photo.LikeCount += delta
+	s.photos[id] = photo
+	return photo.LikeCount, nil
+}
@@ -0,0 +1,367 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PostFilter is a custom type used to filter the posts returned by a PostStore. Zero values are ignored.
+type PostFilter struct {
+	AuthorId *primitive.ObjectID
+	Status   string
+	// VisibleTo limits the posts to the published ones and the posts of the given author
+	VisibleTo *primitive.ObjectID
+}
+
+func (f PostFilter) match(p models.Post) bool {
+	if f.AuthorId != nil && p.AuthorId != *f.AuthorId {
+		return false
+	}
+	if f.Status != "" && p.Status != f.Status {
+		return false
+	}
+	if f.VisibleTo != nil && p.Status != models.PostPublished && p.AuthorId != *f.VisibleTo {
+		return false
+	}
+	return true
+}
+
+func (f PostFilter) query() bson.M {
+	q := bson.M{}
+	if f.AuthorId != nil {
+		q["authorId"] = *f.AuthorId
+	}
+	if f.Status != "" {
+		q["status"] = f.Status
+	}
+	if f.VisibleTo != nil {
+		q["$or"] = bson.A{bson.M{"status": models.PostPublished}, bson.M{"authorId": *f.VisibleTo}}
+	}
+	return q
+}
+
+// PostStore is an interface that describes the operations performed on the posts collection
+type PostStore interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Post, error)
+	FindBySlug(ctx context.Context, slug string) (*models.Post, error)
+	// FindMany returns the posts with the given ids in no particular order. Unknown ids are ignored.
+	FindMany(ctx context.Context, ids []primitive.ObjectID) ([]models.Post, error)
+	// List returns up to limit posts that match a filter and are listed after a cursor, with the newest first. A nil
+	// cursor starts from the newest post.
+	List(ctx context.Context, filter PostFilter, after *Cursor, limit int64) ([]models.Post, error)
+	// ListPublished returns up to limit published posts that match a filter and are listed after a cursor, with the
+	// most recently published first. A nil cursor starts from the newest post.
+	ListPublished(ctx context.Context, filter ContentFilter, after *Cursor, limit int64) ([]models.Post, error)
+	Insert(ctx context.Context, post models.Post) (primitive.ObjectID, error)
+	Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) (*models.Post, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// IncrementLikes adds delta to the like count of a post and returns the new count
+	IncrementLikes(ctx context.Context, id primitive.ObjectID, delta int64) (int64, error)
+}
+
+// MongoPostStore is a PostStore backed by a MongoDB collection
+type MongoPostStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoPostStore returns a MongoPostStore that uses the posts collection of a database
+func NewMongoPostStore(db *mongo.Database) *MongoPostStore {
+	return &MongoPostStore{db.Collection(models.Posts{}.Name())}
+}
+
+// EnsureIndexes creates a unique index on the slug and the indexes used to list the posts
+func (s *MongoPostStore) EnsureIndexes(ctx context.Context) error {
+	_, e := s.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"slug": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "authorId", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "authorId", Value: 1}, {Key: "publishedAt", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}, {Key: "publishedAt", Value: -1}, {Key: "_id", Value: -1}}},
+	})
+	return e
+}
+
+func (s *MongoPostStore) findOne(ctx context.Context, filter bson.M) (*models.Post, error) {
+	var post models.Post
+	if e := s.Collection.FindOne(ctx, filter).Decode(&post); e != nil {
+		if e == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, e
+	}
+	return &post, nil
+}
+
+// FindByID returns the post with the given id
+func (s *MongoPostStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Post, error) {
+	return s.findOne(ctx, bson.M{"_id": id})
+}
+
+// FindBySlug returns the post with the given slug
+func (s *MongoPostStore) FindBySlug(ctx context.Context, slug string) (*models.Post, error) {
+	return s.findOne(ctx, bson.M{"slug": slug})
+}
+
+// FindMany returns the posts with the given ids in no particular order
+func (s *MongoPostStore) FindMany(ctx context.Context, ids []primitive.ObjectID) ([]models.Post, error) {
+	if len(ids) == 0 {
+		return []models.Post{}, nil
+	}
+	return s.find(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Find())
+}
+
+// List returns the posts that match a filter, with the newest first
+func (s *MongoPostStore) List(ctx context.Context, filter PostFilter, after *Cursor, limit int64) ([]models.Post, error) {
+	q := filter.query()
+	if after != nil {
+		q["$and"] = bson.A{after.query("createdAt")}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(limit)
+	return s.find(ctx, q, opts)
+}
+
+// ListPublished returns up to limit published posts that match a filter and are listed after a cursor
+func (s *MongoPostStore) ListPublished(ctx context.Context, filter ContentFilter, after *Cursor, limit int64) ([]models.Post, error) {
+	q := filter.query("authorId")
+	q["status"] = models.PostPublished
+	if after != nil {
+		q["$and"] = bson.A{after.query("publishedAt")}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "publishedAt", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(limit)
+	return s.find(ctx, q, opts)
+}
+
+func (s *MongoPostStore) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.Post, error) {
+	posts := []models.Post{}
+
+	cur, e := s.Collection.Find(ctx, filter, opts)
+	if e != nil {
+		return nil, e
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var post models.Post
+		if e := cur.Decode(&post); e != nil {
+			return nil, e
+		}
+		posts = append(posts, post)
+	}
+	return posts, cur.Err()
+}
+
+// Insert stores a post and returns its id
+func (s *MongoPostStore) Insert(ctx context.Context, post models.Post) (primitive.ObjectID, error) {
+	result, e := s.Collection.InsertOne(ctx, post)
+	if e != nil {
+		if isDuplicateKey(e) {
+			return primitive.NilObjectID, ErrDuplicate
+		}
+		return primitive.NilObjectID, e
+	}
+	oid, _ := result.InsertedID.(primitive.ObjectID)
+	return oid, nil
+}
+
+// Update sets the given fields of a post and returns the updated document
+func (s *MongoPostStore) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) (*models.Post, error) {
+	result, e := s.Collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": fields})
+	if e != nil {
+		return nil, e
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrNotFound
+	}
+	return s.FindByID(ctx, id)
+}
+
+// Delete removes the post with the given id
+func (s *MongoPostStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, e := s.Collection.DeleteOne(ctx, bson.M{"_id": id})
+	if e != nil {
+		return e
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// IncrementLikes adds delta to the like count of a post and returns the new count
+func (s *MongoPostStore) IncrementLikes(ctx context.Context, id primitive.ObjectID, delta int64) (int64, error) {
+	var post models.Post
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if e := s.Collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"likeCount": delta}}, opts).Decode(&post); e != nil {
+		if e == mongo.ErrNoDocuments {
+			return 0, ErrNotFound
+		}
+		return 0, e
+	}
+	return post.LikeCount, nil
+}
+
+// MemoryPostStore is a thread-safe PostStore that keeps the posts in memory
+type MemoryPostStore struct {
+	mu    sync.RWMutex
+	posts map[primitive.ObjectID]models.Post
+}
+
+// NewMemoryPostStore returns an empty MemoryPostStore
+func NewMemoryPostStore() *MemoryPostStore {
+	return &MemoryPostStore{posts: map[primitive.ObjectID]models.Post{}}
+}
+
+// FindByID returns the post with the given id
+func (s *MemoryPostStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	post, ok := s.posts[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &post, nil
+}
+
+// FindBySlug returns the post with the given slug
+func (s *MemoryPostStore) FindBySlug(ctx context.Context, slug string) (*models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, post := range s.posts {
+		if post.Slug == slug {
+			return &post, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// FindMany returns the posts with the given ids in no particular order
+func (s *MemoryPostStore) FindMany(ctx context.Context, ids []primitive.ObjectID) ([]models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	posts := []models.Post{}
+	for _, id := range ids {
+		if post, ok := s.posts[id]; ok {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+// List returns the posts that match a filter, with the newest first
+func (s *MemoryPostStore) List(ctx context.Context, filter PostFilter, after *Cursor, limit int64) ([]models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	posts := []models.Post{}
+	for _, post := range s.posts {
+		if filter.match(post) && (after == nil || after.Includes(post.CreatedAt, *post.Id)) {
+			posts = append(posts, post)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		c := Cursor{posts[i].CreatedAt, *posts[i].Id}
+		return c.Includes(posts[j].CreatedAt, *posts[j].Id)
+	})
+	if int64(len(posts)) > limit {
+		posts = posts[:limit]
+	}
+	return posts, nil
+}
+
+// ListPublished returns up to limit published posts that match a filter and are listed after a cursor
+func (s *MemoryPostStore) ListPublished(ctx context.Context, filter ContentFilter, after *Cursor, limit int64) ([]models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	posts := []models.Post{}
+	for _, post := range s.posts {
+		if post.Status != models.PostPublished || post.PublishedAt == nil || !filter.match(post.AuthorId, post.Tags) {
+			continue
+		}
+		if after != nil && !after.Includes(*post.PublishedAt, *post.Id) {
+			continue
+		}
+		posts = append(posts, post)
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		c := Cursor{*posts[i].PublishedAt, *posts[i].Id}
+		return c.Includes(*posts[j].PublishedAt, *posts[j].Id)
+	})
+	if int64(len(posts)) > limit {
+		posts = posts[:limit]
+	}
+	return posts, nil
+}
+
+// Insert stores a post and returns its id. Posts with the same slug are rejected.
+func (s *MemoryPostStore) Insert(ctx context.Context, post models.Post) (primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if post.Id == nil {
+		id := primitive.NewObjectID()
+		post.Id = &id
+	}
+	for _, p := range s.posts {
+		if p.Slug == post.Slug || *p.Id == *post.Id {
+			return primitive.NilObjectID, ErrDuplicate
+		}
+	}
+	s.posts[*post.Id] = post
+	return *post.Id, nil
+}
+
+// Update sets the given fields of a post and returns the updated document
+func (s *MemoryPostStore) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) (*models.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.posts[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var updated models.Post
+	if e := setFields(post, fields, &updated); e != nil {
+		return nil, e
+	}
+	s.posts[id] = updated
+	return &updated, nil
+}
+
+// Delete removes the post with the given id
+func (s *MemoryPostStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.posts, id)
+	return nil
+}
+
+// IncrementLikes adds delta to the like count of a post and returns the new count
+func (s *MemoryPostStore) IncrementLikes(ctx context.Context, id primitive.ObjectID, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.posts[id]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	post.LikeCount += delta
+	s.posts[id] = post
+	return post.LikeCount, nil
+}
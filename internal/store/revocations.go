@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RevocationStore is an interface that describes the operations performed on the revoked access tokens.
+// Entries are only kept until the tokens they revoke would have expired.
+type RevocationStore interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	RevokeUser(ctx context.Context, userID primitive.ObjectID, revokedAt time.Time, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string, userID primitive.ObjectID, issuedAt time.Time) (bool, error)
+}
+
+// MongoRevocationStore is a RevocationStore backed by a MongoDB collection
+type MongoRevocationStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoRevocationStore returns a MongoRevocationStore that uses the revokedTokens collection of a database
+func NewMongoRevocationStore(db *mongo.Database) *MongoRevocationStore {
+	return &MongoRevocationStore{db.Collection(models.RevokedTokens{}.Name())}
+}
+
+// EnsureIndexes creates the lookup indexes and a TTL index that removes entries once the revoked tokens expire
+func (s *MongoRevocationStore) EnsureIndexes(ctx context.Context) error {
+	_, e := s.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"jti": 1}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.M{"userId": 1}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.M{"expiresAt": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return e
+}
+
+// Revoke revokes a single access token
+func (s *MongoRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, e := s.Collection.InsertOne(ctx, models.RevokedToken{JTI: jti, RevokedAt: time.Now(), ExpiresAt: expiresAt})
+	return e
+}
+
+// RevokeUser revokes every access token of a user issued before or at revokedAt
+func (s *MongoRevocationStore) RevokeUser(ctx context.Context, userID primitive.ObjectID, revokedAt time.Time, expiresAt time.Time) error {
+	_, e := s.Collection.InsertOne(ctx, models.RevokedToken{UserId: &userID, RevokedAt: revokedAt, ExpiresAt: expiresAt})
+	return e
+}
+
+// IsRevoked checks if an access token has been revoked, either by its jti or through its user
+func (s *MongoRevocationStore) IsRevoked(ctx context.Context, jti string, userID primitive.ObjectID, issuedAt time.Time) (bool, error) {
+	filters := bson.A{bson.M{"userId": userID, "revokedAt": bson.M{"$gte": issuedAt}}}
+	if jti != "" {
+		filters = append(filters, bson.M{"jti": jti})
+	}
+
+	n, e := s.Collection.CountDocuments(ctx, bson.M{"$or": filters})
+	if e != nil {
+		return false, e
+	}
+	return n > 0, nil
+}
+
+// MemoryRevocationStore is a thread-safe RevocationStore that keeps the revoked tokens in memory
+type MemoryRevocationStore struct {
+	mu    sync.RWMutex
+	jtis  map[string]time.Time
+	users map[primitive.ObjectID]models.RevokedToken
+}
+
+// NewMemoryRevocationStore returns an empty MemoryRevocationStore
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{jtis: map[string]time.Time{}, users: map[primitive.ObjectID]models.RevokedToken{}}
+}
+
+// Revoke revokes a single access token
+func (s *MemoryRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jtis[jti] = expiresAt
+	return nil
+}
+
+// RevokeUser revokes every access token of a user issued before or at revokedAt
+func (s *MemoryRevocationStore) RevokeUser(ctx context.Context, userID primitive.ObjectID, revokedAt time.Time, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rt, ok := s.users[userID]; ok && rt.RevokedAt.After(revokedAt) {
+		return nil
+	}
+	s.users[userID] = models.RevokedToken{UserId: &userID, RevokedAt: revokedAt, ExpiresAt: expiresAt}
+	return nil
+}
+
+// IsRevoked checks if an access token has been revoked, either by its jti or through its user. Expired entries are
+// ignored, the same way the TTL index removes them in MongoDB.
+func (s *MemoryRevocationStore) IsRevoked(ctx context.Context, jti string, userID primitive.ObjectID, issuedAt time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	if exp, ok := s.jtis[jti]; ok && jti != "" && now.Before(exp) {
+		return true, nil
+	}
+	if rt, ok := s.users[userID]; ok && now.Before(rt.ExpiresAt) && !rt.RevokedAt.Before(issuedAt) {
+		return true, nil
+	}
+	return false, nil
+}
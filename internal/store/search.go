@@ -0,0 +1,272 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Kinds of documents that can be searched
+const (
+	SearchUsers  = "users"
+	SearchPosts  = "posts"
+	SearchPhotos = "photos"
+)
+
+// Weights of the fields of a SearchDocument when the results are ranked
+const (
+	titleWeight = 10
+	tagsWeight  = 5
+	bodyWeight  = 1
+)
+
+// SearchDocument is a custom type used to represent the searchable content of a user, a post or a photo
+type SearchDocument struct {
+	Kind    string
+	Id      primitive.ObjectID
+	OwnerId primitive.ObjectID
+	// Public reports if the document is visible to users other than its owner
+	Public bool
+	Title  string
+	Tags   []string
+	Body   string
+}
+
+// SearchQuery is a custom type used to describe a search on the documents of a kind
+type SearchQuery struct {
+	Kind string
+	Text string
+	// VisibleTo limits the results to the public documents and the documents owned by the given user
+	VisibleTo primitive.ObjectID
+	Limit     int64
+}
+
+// SearchHit is a custom type used to represent a document that matched a search, along with its relevance
+type SearchHit struct {
+	Id    primitive.ObjectID `bson:"_id"`
+	Score float64            `bson:"score"`
+}
+
+// SearchIndex is an interface that describes a full-text index over the users, the posts and the photos
+type SearchIndex interface {
+	// Index adds a document to the index, replacing any previous version of it
+	Index(ctx context.Context, doc SearchDocument) error
+	// Remove removes a document from the index. Unknown documents are ignored.
+	Remove(ctx context.Context, kind string, id primitive.ObjectID) error
+	// Search returns up to limit documents that match a query, with the most relevant first
+	Search(ctx context.Context, query SearchQuery) ([]SearchHit, error)
+}
+
+// MongoSearchIndex is a SearchIndex backed by the text indexes of the users, posts and photos collections. The
+// indexes are kept up to date by MongoDB, so Index and Remove do nothing.
+type MongoSearchIndex struct {
+	Collections map[string]*mongo.Collection
+}
+
+// NewMongoSearchIndex returns a MongoSearchIndex that searches the collections of a database
+func NewMongoSearchIndex(db *mongo.Database) *MongoSearchIndex {
+	return &MongoSearchIndex{map[string]*mongo.Collection{
+		SearchUsers:  db.Collection(models.Users{}.Name()),
+		SearchPosts:  db.Collection(models.Posts{}.Name()),
+		SearchPhotos: db.Collection(models.Photos{}.Name()),
+	}}
+}
+
+// EnsureIndexes creates a text index on each collection. The email of a user is left out, since it is private.
+func (s *MongoSearchIndex) EnsureIndexes(ctx context.Context) error {
+	weights := map[string]bson.D{
+		SearchUsers:  {{Key: "username", Value: titleWeight}},
+		SearchPosts:  {{Key: "title", Value: titleWeight}, {Key: "tags", Value: tagsWeight}, {Key: "body", Value: bodyWeight}},
+		SearchPhotos: {{Key: "caption", Value: titleWeight}, {Key: "tags", Value: tagsWeight}},
+	}
+	for kind, fields := range weights {
+		keys := bson.D{}
+		for _, field := range fields {
+			keys = append(keys, bson.E{Key: field.Key, Value: "text"})
+		}
+		model := mongo.IndexModel{Keys: keys, Options: options.Index().SetName("search").SetWeights(fields)}
+		if _, e := s.Collections[kind].Indexes().CreateOne(ctx, model); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// Index does nothing, since MongoDB updates the text indexes along with the documents
+func (s *MongoSearchIndex) Index(ctx context.Context, doc SearchDocument) error {
+	return nil
+}
+
+// Remove does nothing, since MongoDB updates the text indexes along with the documents
+func (s *MongoSearchIndex) Remove(ctx context.Context, kind string, id primitive.ObjectID) error {
+	return nil
+}
+
+// Search returns up to limit documents that match a query, ranked by their text score
+func (s *MongoSearchIndex) Search(ctx context.Context, query SearchQuery) ([]SearchHit, error) {
+	hits := []SearchHit{}
+	collection, ok := s.Collections[query.Kind]
+	if !ok {
+		return hits, nil
+	}
+
+	filter := bson.M{"$text": bson.M{"$search": query.Text}}
+	if query.Kind == SearchPosts {
+		filter["$or"] = bson.A{bson.M{"status": models.PostPublished}, bson.M{"authorId": query.VisibleTo}}
+	}
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"_id": 1, "score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: -1}}).
+		SetLimit(query.Limit)
+
+	cur, e := collection.Find(ctx, filter, opts)
+	if e != nil {
+		return nil, e
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var hit SearchHit
+		if e := cur.Decode(&hit); e != nil {
+			return nil, e
+		}
+		hits = append(hits, hit)
+	}
+	return hits, cur.Err()
+}
+
+// tokenize splits text to lowercase words. Unlike MongoDB, words are not stemmed.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+type indexedDocument struct {
+	ownerId primitive.ObjectID
+	public  bool
+	// terms holds the weighted frequency of each term of the document
+	terms map[string]float64
+}
+
+// MemorySearchIndex is a thread-safe SearchIndex that keeps an inverted index in memory. Documents are ranked by the
+// weighted frequency of the query terms, scaled by how rare each term is.
+type MemorySearchIndex struct {
+	mu       sync.RWMutex
+	docs     map[string]map[primitive.ObjectID]indexedDocument
+	postings map[string]map[string]map[primitive.ObjectID]bool
+}
+
+// NewMemorySearchIndex returns an empty MemorySearchIndex
+func NewMemorySearchIndex() *MemorySearchIndex {
+	return &MemorySearchIndex{
+		docs:     map[string]map[primitive.ObjectID]indexedDocument{},
+		postings: map[string]map[string]map[primitive.ObjectID]bool{},
+	}
+}
+
+// Index adds a document to the index, replacing any previous version of it
+func (s *MemorySearchIndex) Index(ctx context.Context, doc SearchDocument) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(doc.Kind, doc.Id)
+
+	terms := map[string]float64{}
+	for _, term := range tokenize(doc.Title) {
+		terms[term] += titleWeight
+	}
+	for _, term := range tokenize(strings.Join(doc.Tags, " ")) {
+		terms[term] += tagsWeight
+	}
+	for _, term := range tokenize(doc.Body) {
+		terms[term] += bodyWeight
+	}
+
+	if s.docs[doc.Kind] == nil {
+		s.docs[doc.Kind] = map[primitive.ObjectID]indexedDocument{}
+		s.postings[doc.Kind] = map[string]map[primitive.ObjectID]bool{}
+	}
+	s.docs[doc.Kind][doc.Id] = indexedDocument{ownerId: doc.OwnerId, public: doc.Public, terms: terms}
+	for term := range terms {
+		if s.postings[doc.Kind][term] == nil {
+			s.postings[doc.Kind][term] = map[primitive.ObjectID]bool{}
+		}
+		s.postings[doc.Kind][term][doc.Id] = true
+	}
+	return nil
+}
+
+// Remove removes a document from the index
+func (s *MemorySearchIndex) Remove(ctx context.Context, kind string, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(kind, id)
+	return nil
+}
+
+func (s *MemorySearchIndex) remove(kind string, id primitive.ObjectID) {
+	doc, ok := s.docs[kind][id]
+	if !ok {
+		return
+	}
+	for term := range doc.terms {
+		delete(s.postings[kind][term], id)
+		if len(s.postings[kind][term]) == 0 {
+			delete(s.postings[kind], term)
+		}
+	}
+	delete(s.docs[kind], id)
+}
+
+// Search returns up to limit documents that match any of the query terms, with the most relevant first
+func (s *MemorySearchIndex) Search(ctx context.Context, query SearchQuery) ([]SearchHit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	docs := s.docs[query.Kind]
+	scores := map[primitive.ObjectID]float64{}
+	seen := map[string]bool{}
+	for _, term := range tokenize(query.Text) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		ids := s.postings[query.Kind][term]
+		idf := math.Log(1 + float64(len(docs))/float64(len(ids)))
+		for id := range ids {
+			if doc := docs[id]; doc.public || doc.ownerId == query.VisibleTo {
+				scores[id] += doc.terms[term] * idf
+			}
+		}
+	}
+
+	hits := []SearchHit{}
+	for id, score := range scores {
+		hits = append(hits, SearchHit{Id: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return bytes.Compare(hits[i].Id[:], hits[j].Id[:]) > 0
+	})
+	if int64(len(hits)) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+	return hits, nil
+}
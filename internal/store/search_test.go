@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func searchIds(s *MemorySearchIndex, query SearchQuery, t *testing.T) []primitive.ObjectID {
+	hits, e := s.Search(context.Background(), query)
+	if e != nil {
+		t.Fatalf("Should have searched the index rather than returning %v", e)
+	}
+	ids := []primitive.ObjectID{}
+	for _, hit := range hits {
+		ids = append(ids, hit.Id)
+	}
+	return ids
+}
+
+func TestMemorySearchIndexRanking(t *testing.T) {
+	s := NewMemorySearchIndex()
+	owner := primitive.NewObjectID()
+	inTitle, inTags, inBody := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	for _, doc := range []SearchDocument{
+		{Kind: SearchPosts, Id: inBody, OwnerId: owner, Public: true, Title: "Weekend", Body: "A walk by the river Thames"},
+		{Kind: SearchPosts, Id: inTitle, OwnerId: owner, Public: true, Title: "The Thames at night"},
+		{Kind: SearchPosts, Id: inTags, OwnerId: owner, Public: true, Title: "Bridges", Tags: []string{"thames"}},
+		{Kind: SearchPhotos, Id: primitive.NewObjectID(), OwnerId: owner, Public: true, Title: "Thames"},
+	} {
+		s.Index(context.Background(), doc)
+	}
+
+	ids := searchIds(s, SearchQuery{Kind: SearchPosts, Text: "THAMES", Limit: 10}, t)
+	if len(ids) != 3 || ids[0] != inTitle || ids[1] != inTags || ids[2] != inBody {
+		t.Errorf("Should have ranked the title before the tags and the body rather than %v", ids)
+	}
+	if ids := searchIds(s, SearchQuery{Kind: SearchPosts, Text: "thames", Limit: 1}, t); len(ids) != 1 {
+		t.Errorf("Should have limited the results rather than returning %v", ids)
+	}
+
+	s.Index(context.Background(), SearchDocument{Kind: SearchPosts, Id: inTitle, OwnerId: owner, Public: true, Title: "Tower Bridge"})
+	if ids := searchIds(s, SearchQuery{Kind: SearchPosts, Text: "thames", Limit: 10}, t); len(ids) != 2 {
+		t.Errorf("Should have replaced the previous version of a document rather than returning %v", ids)
+	}
+	s.Remove(context.Background(), SearchPosts, inTags)
+	if ids := searchIds(s, SearchQuery{Kind: SearchPosts, Text: "thames", Limit: 10}, t); len(ids) != 1 || ids[0] != inBody {
+		t.Errorf("Should have removed the document from the index rather than returning %v", ids)
+	}
+}
+
+func TestMemorySearchIndexVisibility(t *testing.T) {
+	s := NewMemorySearchIndex()
+	owner, other := primitive.NewObjectID(), primitive.NewObjectID()
+	draft := primitive.NewObjectID()
+	s.Index(context.Background(), SearchDocument{Kind: SearchPosts, Id: draft, OwnerId: owner, Title: "Secret plans"})
+
+	if ids := searchIds(s, SearchQuery{Kind: SearchPosts, Text: "secret", VisibleTo: other, Limit: 10}, t); len(ids) != 0 {
+		t.Errorf("Should not have returned a private document to other users rather than %v", ids)
+	}
+	if ids := searchIds(s, SearchQuery{Kind: SearchPosts, Text: "secret", VisibleTo: owner, Limit: 10}, t); len(ids) != 1 {
+		t.Errorf("Should have returned a private document to its owner rather than %v", ids)
+	}
+}
@@ -0,0 +1,205 @@
+// Package store contains storage-agnostic interfaces for the collections of the API, along with
+// their MongoDB and in-memory implementations.
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound is returned when a document does not exist within a store
+var ErrNotFound = errors.New("store: document not found")
+
+// ErrDuplicate is returned when a document conflicts with an existing one
+var ErrDuplicate = errors.New("store: duplicate document")
+
+// ErrConflict is returned when a document has changed since it was read, so a conditional update is not applied
+var ErrConflict = errors.New("store: document has changed")
+
+// indexer is implemented by stores that need indexes to be created before they are used
+type indexer interface {
+	EnsureIndexes(ctx context.Context) error
+}
+
+// setFields applies fields keyed by their BSON names to a copy of doc, the same way a MongoDB $set would, and decodes
+// the result into out. It is used by the in-memory stores.
+func setFields(doc interface{}, fields map[string]interface{}, out interface{}) error {
+	m := bson.M{}
+	raw, e := bson.Marshal(doc)
+	if e != nil {
+		return e
+	}
+	if e := bson.Unmarshal(raw, &m); e != nil {
+		return e
+	}
+	for k, v := range fields {
+		m[k] = v
+	}
+
+	raw, e = bson.Marshal(m)
+	if e != nil {
+		return e
+	}
+	return bson.Unmarshal(raw, out)
+}
+
+// Cursor is a position within a list of documents that are sorted by a time and their id, with the newest first
+type Cursor struct {
+	Time time.Time
+	Id   primitive.ObjectID
+}
+
+// Includes reports if a document sorted by t and id is listed after the cursor
+func (c *Cursor) Includes(t time.Time, id primitive.ObjectID) bool {
+	return t.Before(c.Time) || (t.Equal(c.Time) && bytes.Compare(id[:], c.Id[:]) < 0)
+}
+
+// query returns the filter of the documents listed after the cursor, when their time is kept in field
+func (c *Cursor) query(field string) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{"$lt": c.Time}},
+		bson.M{field: c.Time, "_id": bson.M{"$lt": c.Id}},
+	}}
+}
+
+// ContentFilter is a custom type used to filter the posts and photos of a paginated list
+type ContentFilter struct {
+	// OwnerIds limits the content to the given owners when it is not nil
+	OwnerIds []primitive.ObjectID
+	// Tag limits the content to the content with the given tag when it is not empty
+	Tag string
+}
+
+func (f ContentFilter) match(ownerID primitive.ObjectID, tags []string) bool {
+	if f.OwnerIds != nil {
+		found := false
+		for _, id := range f.OwnerIds {
+			found = found || id == ownerID
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Tag != "" {
+		found := false
+		for _, tag := range tags {
+			found = found || tag == f.Tag
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (f ContentFilter) query(ownerField string) bson.M {
+	q := bson.M{}
+	if f.OwnerIds != nil {
+		q[ownerField] = bson.M{"$in": f.OwnerIds}
+	}
+	if f.Tag != "" {
+		q["tags"] = f.Tag
+	}
+	return q
+}
+
+// isDuplicateKey checks if a MongoDB error was caused by a unique index
+func isDuplicateKey(e error) bool {
+	if we, ok := e.(mongo.WriteException); ok {
+		for _, err := range we.WriteErrors {
+			if err.Code == 11000 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Store is a custom type that groups the stores used by the API
+type Store struct {
+	Users         UserStore
+	RefreshTokens RefreshTokenStore
+	UserTokens    UserTokenStore
+	Revocations   RevocationStore
+	LoginAttempts LoginAttemptStore
+	Clients       ClientStore
+	AuthCodes     AuthorizationCodeStore
+	Consents      ConsentStore
+	Audit         AuditStore
+	Photos        PhotoStore
+	Blobs         BlobStore
+	Posts         PostStore
+	Albums        AlbumStore
+	Comments      CommentStore
+	Likes         LikeStore
+	Follows       FollowStore
+	Tags          TagStore
+	Search        SearchIndex
+}
+
+// NewMongoStore returns a Store whose collections are persisted in a MongoDB database, while binary content is kept
+// in a blob store
+func NewMongoStore(db *mongo.Database, blobs BlobStore) *Store {
+	return &Store{
+		Users:         NewMongoUserStore(db),
+		RefreshTokens: NewMongoRefreshTokenStore(db),
+		UserTokens:    NewMongoUserTokenStore(db),
+		Revocations:   NewMongoRevocationStore(db),
+		LoginAttempts: NewMongoLoginAttemptStore(db),
+		Clients:       NewMongoClientStore(db),
+		AuthCodes:     NewMongoAuthorizationCodeStore(db),
+		Consents:      NewMongoConsentStore(db),
+		Audit:         NewMongoAuditStore(db),
+		Photos:        NewMongoPhotoStore(db),
+		Blobs:         blobs,
+		Posts:         NewMongoPostStore(db),
+		Albums:        NewMongoAlbumStore(db),
+		Comments:      NewMongoCommentStore(db),
+		Likes:         NewMongoLikeStore(db),
+		Follows:       NewMongoFollowStore(db),
+		Tags:          NewMongoTagStore(db),
+		Search:        NewMongoSearchIndex(db),
+	}
+}
+
+// NewMemoryStore returns a Store whose collections are kept in memory. It is used in tests and local demos.
+func NewMemoryStore() *Store {
+	return &Store{
+		Users:         NewMemoryUserStore(),
+		RefreshTokens: NewMemoryRefreshTokenStore(),
+		UserTokens:    NewMemoryUserTokenStore(),
+		Revocations:   NewMemoryRevocationStore(),
+		LoginAttempts: NewMemoryLoginAttemptStore(),
+		Clients:       NewMemoryClientStore(),
+		AuthCodes:     NewMemoryAuthorizationCodeStore(),
+		Consents:      NewMemoryConsentStore(),
+		Audit:         NewMemoryAuditStore(),
+		Photos:        NewMemoryPhotoStore(),
+		Blobs:         NewMemoryBlobStore(),
+		Posts:         NewMemoryPostStore(),
+		Albums:        NewMemoryAlbumStore(),
+		Comments:      NewMemoryCommentStore(),
+		Likes:         NewMemoryLikeStore(),
+		Follows:       NewMemoryFollowStore(),
+		Tags:          NewMemoryTagStore(),
+		Search:        NewMemorySearchIndex(),
+	}
+}
+
+// EnsureIndexes creates the indexes of every store that requires them
+func (s *Store) EnsureIndexes(ctx context.Context) error {
+	for _, v := range []interface{}{s.Users, s.RefreshTokens, s.UserTokens, s.Revocations, s.LoginAttempts, s.AuthCodes, s.Consents, s.Audit, s.Photos, s.Posts, s.Albums, s.Comments, s.Likes, s.Follows, s.Tags, s.Search} {
+		if i, ok := v.(indexer); ok {
+			if e := i.EnsureIndexes(ctx); e != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TagStore is an interface that describes the operations performed on the tags collection, which keeps the number of
+// posts and photos with each tag
+type TagStore interface {
+	// Adjust increments the counts of the added tags and decrements the counts of the removed ones. Tags that are no
+	// longer used are removed.
+	Adjust(ctx context.Context, added []string, removed []string) error
+	// Popular returns up to limit tags with the highest counts
+	Popular(ctx context.Context, limit int64) ([]models.TagCount, error)
+}
+
+// MongoTagStore is a TagStore backed by a MongoDB collection
+type MongoTagStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoTagStore returns a MongoTagStore that uses the tags collection of a database
+func NewMongoTagStore(db *mongo.Database) *MongoTagStore {
+	return &MongoTagStore{db.Collection(models.TagCounts{}.Name())}
+}
+
+// EnsureIndexes creates an index used to list the popular tags
+func (s *MongoTagStore) EnsureIndexes(ctx context.Context) error {
+	_, e := s.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}})
+	return e
+}
+
+// Adjust increments the counts of the added tags and decrements the counts of the removed ones
+func (s *MongoTagStore) Adjust(ctx context.Context, added []string, removed []string) error {
+	var writes []mongo.WriteModel
+	for _, tag := range added {
+		writes = append(writes, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": tag}).SetUpdate(bson.M{"$inc": bson.M{"count": 1}}).SetUpsert(true))
+	}
+	for _, tag := range removed {
+		writes = append(writes, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": tag}).SetUpdate(bson.M{"$inc": bson.M{"count": -1}}))
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	if _, e := s.Collection.BulkWrite(ctx, writes); e != nil {
+		return e
+	}
+	if len(removed) > 0 {
+		_, e := s.Collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": removed}, "count": bson.M{"$lte": 0}})
+		return e
+	}
+	return nil
+}
+
+// Popular returns up to limit tags with the highest counts
+func (s *MongoTagStore) Popular(ctx context.Context, limit int64) ([]models.TagCount, error) {
+	tags := []models.TagCount{}
+
+	opts := options.Find().SetSort(bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}).SetLimit(limit)
+	cur, e := s.Collection.Find(ctx, bson.M{"count": bson.M{"$gt": 0}}, opts)
+	if e != nil {
+		return nil, e
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var tag models.TagCount
+		if e := cur.Decode(&tag); e != nil {
+			return nil, e
+		}
+		tags = append(tags, tag)
+	}
+	return tags, cur.Err()
+}
+
+// MemoryTagStore is a thread-safe TagStore that keeps the tag counts in memory
+type MemoryTagStore struct {
+	mu     sync.RWMutex
+	counts map[string]int64
+}
+
+// NewMemoryTagStore returns an empty MemoryTagStore
+func NewMemoryTagStore() *MemoryTagStore {
+	return &MemoryTagStore{counts: map[string]int64{}}
+}
+
+// Adjust increments the counts of the added tags and decrements the counts of the removed ones
+func (s *MemoryTagStore) Adjust(ctx context.Context, added []string, removed []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tag := range added {
+		s.counts[tag]++
+	}
+	for _, tag := range removed {
+		if s.counts[tag]--; s.counts[tag] <= 0 {
+			delete(s.counts, tag)
+		}
+	}
+	return nil
+}
+
+// Popular returns up to limit tags with the highest counts
+func (s *MemoryTagStore) Popular(ctx context.Context, limit int64) ([]models.TagCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tags := []models.TagCount{}
+	for tag, count := range s.counts {
+		tags = append(tags, models.TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	if int64(len(tags)) > limit {
+		tags = tags[:limit]
+	}
+	return tags, nil
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RefreshTokenStore is an interface that describes the operations performed on the refresh tokens collection
+type RefreshTokenStore interface {
+	Insert(ctx context.Context, token models.RefreshToken) (primitive.ObjectID, error)
+	FindByHash(ctx context.Context, hash string) (*models.RefreshToken, error)
+	// MarkUsed flags a token as exchanged. It returns false when the token had already been used,
+	// which allows callers to detect concurrent reuse.
+	MarkUsed(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error)
+	RevokeFamily(ctx context.Context, family primitive.ObjectID) error
+	RevokeUser(ctx context.Context, userID primitive.ObjectID) error
+}
+
+// MongoRefreshTokenStore is a RefreshTokenStore backed by a MongoDB collection
+type MongoRefreshTokenStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoRefreshTokenStore returns a MongoRefreshTokenStore that uses the refreshTokens collection of a database
+func NewMongoRefreshTokenStore(db *mongo.Database) *MongoRefreshTokenStore {
+	return &MongoRefreshTokenStore{db.Collection(models.RefreshTokens{}.Name())}
+}
+
+// EnsureIndexes creates a unique index on the token hash and a TTL index that removes expired tokens
+func (s *MongoRefreshTokenStore) EnsureIndexes(ctx context.Context) error {
+	_, e := s.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"hash": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"family": 1}},
+		{Keys: bson.M{"userId": 1}},
+		{Keys: bson.M{"expiresAt": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return e
+}
+
+// Insert stores a refresh token and returns its id
+func (s *MongoRefreshTokenStore) Insert(ctx context.Context, token models.RefreshToken) (primitive.ObjectID, error) {
+	result, e := s.Collection.InsertOne(ctx, token)
+	if e != nil {
+		return primitive.NilObjectID, e
+	}
+	oid, _ := result.InsertedID.(primitive.ObjectID)
+	return oid, nil
+}
+
+// FindByHash returns the refresh token with the given hash
+func (s *MongoRefreshTokenStore) FindByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if e := s.Collection.FindOne(ctx, bson.M{"hash": hash}).Decode(&token); e != nil {
+		if e == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, e
+	}
+	return &token, nil
+}
+
+// MarkUsed flags a token as exchanged, only if it has not been used before
+func (s *MongoRefreshTokenStore) MarkUsed(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	filter := bson.M{"_id": id, "usedAt": bson.M{"$exists": false}}
+	result, e := s.Collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"usedAt": at}})
+	if e != nil {
+		return false, e
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// RevokeFamily revokes every token that belongs to a family
+func (s *MongoRefreshTokenStore) RevokeFamily(ctx context.Context, family primitive.ObjectID) error {
+	_, e := s.Collection.UpdateMany(ctx, bson.M{"family": family}, bson.M{"$set": bson.M{"revoked": true}})
+	return e
+}
+
+// RevokeUser revokes every token of a user
+func (s *MongoRefreshTokenStore) RevokeUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, e := s.Collection.UpdateMany(ctx, bson.M{"userId": userID}, bson.M{"$set": bson.M{"revoked": true}})
+	return e
+}
+
+// MemoryRefreshTokenStore is a thread-safe RefreshTokenStore that keeps the tokens in memory
+type MemoryRefreshTokenStore struct {
+	mu     sync.Mutex
+	tokens map[primitive.ObjectID]models.RefreshToken
+}
+
+// NewMemoryRefreshTokenStore returns an empty MemoryRefreshTokenStore
+func NewMemoryRefreshTokenStore() *MemoryRefreshTokenStore {
+	return &MemoryRefreshTokenStore{tokens: map[primitive.ObjectID]models.RefreshToken{}}
+}
+
+// Insert stores a refresh token and returns its id
+func (s *MemoryRefreshTokenStore) Insert(ctx context.Context, token models.RefreshToken) (primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tokens {
+		if t.Hash == token.Hash {
+			return primitive.NilObjectID, ErrDuplicate
+		}
+	}
+	id := primitive.NewObjectID()
+	token.Id = &id
+	s.tokens[id] = token
+	return id, nil
+}
+
+// FindByHash returns the refresh token with the given hash
+func (s *MemoryRefreshTokenStore) FindByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tokens {
+		if t.Hash == hash {
+			return &t, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// MarkUsed flags a token as exchanged, only if it has not been used before
+func (s *MemoryRefreshTokenStore) MarkUsed(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[id]
+	if !ok || t.IsUsed() {
+		return false, nil
+	}
+	t.UsedAt = &at
+	s.tokens[id] = t
+	return true, nil
+}
+
+// RevokeFamily revokes every token that belongs to a family
+func (s *MemoryRefreshTokenStore) RevokeFamily(ctx context.Context, family primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, t := range s.tokens {
+		if t.Family == family {
+			t.Revoked = true
+			s.tokens[id] = t
+		}
+	}
+	return nil
+}
+
+// RevokeUser revokes every token of a user
+func (s *MemoryRefreshTokenStore) RevokeUser(ctx context.Context, userID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, t := range s.tokens {
+		if t.UserId == userID {
+			t.Revoked = true
+			s.tokens[id] = t
+		}
+	}
+	return nil
+}
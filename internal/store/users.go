@@ -0,0 +1,495 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Fields that a page of users can be sorted by
+const (
+	UserSortId       = "_id"
+	UserSortUsername = "username"
+)
+
+// UserFilter is a custom type used to filter the users returned by a UserStore. Zero values are ignored.
+type UserFilter struct {
+	Role           string
+	UsernamePrefix string
+}
+
+func (f UserFilter) match(u models.User) bool {
+	if f.Role != "" && u.Role != f.Role {
+		return false
+	}
+	return strings.HasPrefix(u.Username, f.UsernamePrefix)
+}
+
+func (f UserFilter) query() bson.M {
+	q := bson.M{}
+	if f.Role != "" {
+		q["role"] = f.Role
+	}
+	if f.UsernamePrefix != "" {
+		q["username"] = bson.M{"$regex": "^" + regexp.QuoteMeta(f.UsernamePrefix)}
+	}
+	return q
+}
+
+// UserCursor is a position within a sorted list of users
+type UserCursor struct {
+	Username string
+	Id       primitive.ObjectID
+}
+
+// UserPage is a custom type used to describe a page of a sorted list of users. Users are sorted by their id unless
+// SortBy is UserSortUsername, in which case ties are broken by the id. The page starts after the cursor when one is
+// given, and then skips Offset users.
+type UserPage struct {
+	Filter     UserFilter
+	SortBy     string
+	Descending bool
+	After      *UserCursor
+	Offset     int64
+	Limit      int64
+}
+
+// precedes reports if a user with the given username and id is listed before another one
+func (p UserPage) precedes(username string, id primitive.ObjectID, other string, otherID primitive.ObjectID) bool {
+	cmp := bytes.Compare(id[:], otherID[:])
+	if p.SortBy == UserSortUsername && username != other {
+		cmp = strings.Compare(username, other)
+	}
+	if p.Descending {
+		cmp = -cmp
+	}
+	return cmp < 0
+}
+
+func (p UserPage) query() bson.M {
+	q := p.Filter.query()
+	if p.After == nil {
+		return q
+	}
+
+	op := "$gt"
+	if p.Descending {
+		op = "$lt"
+	}
+	after := bson.M{"_id": bson.M{op: p.After.Id}}
+	if p.SortBy == UserSortUsername {
+		after = bson.M{"$or": bson.A{
+			bson.M{"username": bson.M{op: p.After.Username}},
+			bson.M{"username": p.After.Username, "_id": bson.M{op: p.After.Id}},
+		}}
+	}
+	q["$and"] = bson.A{after}
+	return q
+}
+
+func (p UserPage) sort() bson.D {
+	dir := 1
+	if p.Descending {
+		dir = -1
+	}
+	if p.SortBy == UserSortUsername {
+		return bson.D{{Key: "username", Value: dir}, {Key: "_id", Value: dir}}
+	}
+	return bson.D{{Key: "_id", Value: dir}}
+}
+
+// UserStore is an interface that describes the operations performed on the users collection
+type UserStore interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	List(ctx context.Context) ([]models.User, error)
+	// ListPage returns a page of the users that match a filter
+	ListPage(ctx context.Context, page UserPage) ([]models.User, error)
+	// FindMany returns the users with the given ids in no particular order. Unknown ids are ignored.
+	FindMany(ctx context.Context, ids []primitive.ObjectID) ([]models.User, error)
+	Insert(ctx context.Context, user models.User) (primitive.ObjectID, error)
+	Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) (*models.User, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// UseTOTPStep records the step of an accepted TOTP code. It returns false when the TOTP of the user is not enabled
+	// or a code of the same or a later step has already been used, which prevents replays.
+	UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) (bool, error)
+	// UseRecoveryCode removes the hash of a recovery code from a user. It returns false when the user does not have it.
+	UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) (bool, error)
+}
+
+// MongoUserStore is a UserStore backed by a MongoDB collection
+type MongoUserStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoUserStore returns a MongoUserStore that uses the users collection of a database
+func NewMongoUserStore(db *mongo.Database) *MongoUserStore {
+	return &MongoUserStore{db.Collection(models.Users{}.Name())}
+}
+
+// EnsureIndexes creates the indexes used to sort and filter the pages of users, along with the one that keeps
+// their emails unique
+func (s *MongoUserStore) EnsureIndexes(ctx context.Context) error {
+	_, e := s.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"email": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "username", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "role", Value: 1}, {Key: "_id", Value: 1}}},
+	})
+	return e
+}
+
+func (s *MongoUserStore) findOne(ctx context.Context, filter bson.M) (*models.User, error) {
+	var user models.User
+	if e := s.Collection.FindOne(ctx, filter).Decode(&user); e != nil {
+		if e == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, e
+	}
+	return &user, nil
+}
+
+// FindByID returns the user with the given id
+func (s *MongoUserStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return s.findOne(ctx, bson.M{"_id": id})
+}
+
+// FindByEmail returns the user with the given email, which is normalized the way it was stored
+func (s *MongoUserStore) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.findOne(ctx, bson.M{"email": models.NormalizeEmail(email)})
+}
+
+// List returns the whole collection of users
+func (s *MongoUserStore) List(ctx context.Context) ([]models.User, error) {
+	return s.find(ctx, bson.M{})
+}
+
+// ListPage returns a page of the users that match a filter
+func (s *MongoUserStore) ListPage(ctx context.Context, page UserPage) ([]models.User, error) {
+	return s.find(ctx, page.query(), options.Find().SetSort(page.sort()).SetSkip(page.Offset).SetLimit(page.Limit))
+}
+
+// FindMany returns the users with the given ids in no particular order
+func (s *MongoUserStore) FindMany(ctx context.Context, ids []primitive.ObjectID) ([]models.User, error) {
+	if len(ids) == 0 {
+		return []models.User{}, nil
+	}
+	return s.find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+}
+
+func (s *MongoUserStore) find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]models.User, error) {
+	var users []models.User
+
+	cur, e := s.Collection.Find(ctx, filter, opts...)
+	if e != nil {
+		return nil, e
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var user models.User
+		if e := cur.Decode(&user); e != nil {
+			return nil, e
+		}
+		users = append(users, user)
+	}
+	return users, cur.Err()
+}
+
+// Insert stores a user with a normalized email and returns its id. ErrDuplicate is returned when the email belongs to
+// another user.
+func (s *MongoUserStore) Insert(ctx context.Context, user models.User) (primitive.ObjectID, error) {
+	user.Email = models.NormalizeEmail(user.Email)
+	result, e := s.Collection.InsertOne(ctx, user)
+	if e != nil {
+		if isDuplicateKey(e) {
+			return primitive.NilObjectID, ErrDuplicate
+		}
+		return primitive.NilObjectID, e
+	}
+	oid, _ := result.InsertedID.(primitive.ObjectID)
+	return oid, nil
+}
+
+// Update sets the given fields of a user and returns the updated document. An email is normalized before it is set.
+// ErrDuplicate is returned when the email belongs to another user.
+func (s *MongoUserStore) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) (*models.User, error) {
+	result, e := s.Collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": normalizeEmailField(fields)})
+	if e != nil {
+		if isDuplicateKey(e) {
+			return nil, ErrDuplicate
+		}
+		return nil, e
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrNotFound
+	}
+	return s.FindByID(ctx, id)
+}
+
+// Delete removes the user with the given id
+func (s *MongoUserStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, e := s.Collection.DeleteOne(ctx, bson.M{"_id": id})
+	if e != nil {
+		return e
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UseTOTPStep records the step of an accepted TOTP code, only if it is later than the last one
+func (s *MongoUserStore) UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) (bool, error) {
+	filter := bson.M{"_id": id, "totp.enabled": true, "totp.lastStep": bson.M{"$lt": step}}
+	result, e := s.Collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"totp.lastStep": step}})
+	if e != nil {
+		return false, e
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// UseRecoveryCode removes the hash of a recovery code from a user
+func (s *MongoUserStore) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) (bool, error) {
+	filter := bson.M{"_id": id, "totp.enabled": true, "totp.recoveryCodes": hash}
+	result, e := s.Collection.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"totp.recoveryCodes": hash}})
+	if e != nil {
+		return false, e
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// MemoryUserStore is a thread-safe UserStore that keeps the users in memory
+type MemoryUserStore struct {
+	mu    sync.RWMutex
+	users []models.User
+}
+
+// NewMemoryUserStore returns an empty MemoryUserStore
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{}
+}
+
+func (s *MemoryUserStore) indexOf(id primitive.ObjectID) int {
+	for i, u := range s.users {
+		if u.Id != nil && *u.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// normalizeEmailField returns a copy of the fields of an update whose email is normalized
+func normalizeEmailField(fields map[string]interface{}) map[string]interface{} {
+	email, ok := fields["email"].(string)
+	if !ok {
+		return fields
+	}
+	normalized := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		normalized[k] = v
+	}
+	normalized["email"] = models.NormalizeEmail(email)
+	return normalized
+}
+
+// hasEmail checks if a user other than the given one is stored with an email
+func (s *MemoryUserStore) hasEmail(email string, except primitive.ObjectID) bool {
+	for _, u := range s.users {
+		if u.Email == email && (u.Id == nil || *u.Id != except) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyUser returns a copy of a user so callers can not mutate the stored document
+func copyUser(u models.User) *models.User {
+	if u.Id != nil {
+		id := *u.Id
+		u.Id = &id
+	}
+	if u.TOTP != nil {
+		totp := *u.TOTP
+		totp.RecoveryCodes = append([]string(nil), totp.RecoveryCodes...)
+		u.TOTP = &totp
+	}
+	return &u
+}
+
+// FindByID returns the user with the given id
+func (s *MemoryUserStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if i := s.indexOf(id); i >= 0 {
+		return copyUser(s.users[i]), nil
+	}
+	return nil, ErrNotFound
+}
+
+// FindByEmail returns the user with the given email, which is normalized the way it was stored
+func (s *MemoryUserStore) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	email = models.NormalizeEmail(email)
+	for _, u := range s.users {
+		if u.Email == email {
+			return copyUser(u), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// List returns the whole collection of users in insertion order
+func (s *MemoryUserStore) List(ctx context.Context) ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]models.User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, *copyUser(u))
+	}
+	return users, nil
+}
+
+// ListPage returns a page of the users that match a filter
+func (s *MemoryUserStore) ListPage(ctx context.Context, page UserPage) ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := []models.User{}
+	for _, u := range s.users {
+		if !page.Filter.match(u) {
+			continue
+		}
+		if page.After != nil && !page.precedes(page.After.Username, page.After.Id, u.Username, *u.Id) {
+			continue
+		}
+		users = append(users, *copyUser(u))
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return page.precedes(users[i].Username, *users[i].Id, users[j].Username, *users[j].Id)
+	})
+
+	if page.Offset >= int64(len(users)) {
+		return []models.User{}, nil
+	}
+	users = users[page.Offset:]
+	if int64(len(users)) > page.Limit {
+		users = users[:page.Limit]
+	}
+	return users, nil
+}
+
+// FindMany returns the users with the given ids in no particular order
+func (s *MemoryUserStore) FindMany(ctx context.Context, ids []primitive.ObjectID) ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := []models.User{}
+	for _, id := range ids {
+		if i := s.indexOf(id); i >= 0 {
+			users = append(users, *copyUser(s.users[i]))
+		}
+	}
+	return users, nil
+}
+
+// Insert stores a user with a normalized email and returns its id. A new id is generated when the user does not have
+// one. ErrDuplicate is returned when the id or the email belongs to another user.
+func (s *MemoryUserStore) Insert(ctx context.Context, user models.User) (primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user.Email = models.NormalizeEmail(user.Email)
+	if user.Id == nil {
+		id := primitive.NewObjectID()
+		user.Id = &id
+	}
+	if s.indexOf(*user.Id) >= 0 || s.hasEmail(user.Email, *user.Id) {
+		return primitive.NilObjectID, ErrDuplicate
+	}
+	stored := copyUser(user)
+	s.users = append(s.users, *stored)
+	return *stored.Id, nil
+}
+
+// Update sets the given fields of a user and returns the updated document. Fields are keyed by their
+// BSON names, the same way they would be for a MongoDB $set. An email is normalized before it is set. ErrDuplicate is
+// returned when the email belongs to another user.
+func (s *MemoryUserStore) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+
+	var user models.User
+	if e := setFields(s.users[i], normalizeEmailField(fields), &user); e != nil {
+		return nil, e
+	}
+	if s.hasEmail(user.Email, id) {
+		return nil, ErrDuplicate
+	}
+	s.users[i] = *copyUser(user)
+	return copyUser(user), nil
+}
+
+// Delete removes the user with the given id
+func (s *MemoryUserStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.users = append(s.users[:i], s.users[i+1:]...)
+	return nil
+}
+
+// UseTOTPStep records the step of an accepted TOTP code, only if it is later than the last one
+func (s *MemoryUserStore) UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 || !s.users[i].MFAEnabled() || s.users[i].TOTP.LastStep >= step {
+		return false, nil
+	}
+	s.users[i].TOTP.LastStep = step
+	return true, nil
+}
+
+// UseRecoveryCode removes the hash of a recovery code from a user
+func (s *MemoryUserStore) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 || !s.users[i].MFAEnabled() {
+		return false, nil
+	}
+	codes := s.users[i].TOTP.RecoveryCodes
+	for j, h := range codes {
+		if h == hash {
+			s.users[i].TOTP.RecoveryCodes = append(codes[:j:j], codes[j+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newMemoryUsers(t *testing.T) (*MemoryUserStore, primitive.ObjectID) {
+	s := NewMemoryUserStore()
+	id, e := s.Insert(context.Background(), models.User{Username: "paul", Email: "paul@gmail.com", Role: "BASIC"})
+	if e != nil {
+		t.Fatalf("Should have inserted the user rather than returning %v", e)
+	}
+	return s, id
+}
+
+func TestMemoryUserStoreInsertGeneratesId(t *testing.T) {
+	_, id := newMemoryUsers(t)
+	if id.IsZero() {
+		t.Errorf("Should have generated an id for the inserted user")
+	}
+}
+
+func TestMemoryUserStoreInsertDuplicate(t *testing.T) {
+	s, id := newMemoryUsers(t)
+	if _, e := s.Insert(context.Background(), models.User{Id: &id, Email: "john@gmail.com"}); e != ErrDuplicate {
+		t.Errorf("Should have returned %v rather than %v", ErrDuplicate, e)
+	}
+}
+
+func TestMemoryUserStoreDuplicateEmail(t *testing.T) {
+	s, id := newMemoryUsers(t)
+	if _, e := s.Insert(context.Background(), models.User{Username: "paul37", Email: " Paul@Gmail.com"}); e != ErrDuplicate {
+		t.Errorf("Should have returned %v for an email in use rather than %v", ErrDuplicate, e)
+	}
+
+	other, _ := s.Insert(context.Background(), models.User{Username: "john", Email: "john@gmail.com"})
+	if _, e := s.Update(context.Background(), other, map[string]interface{}{"email": "PAUL@gmail.com"}); e != ErrDuplicate {
+		t.Errorf("Should have returned %v for an email in use rather than %v", ErrDuplicate, e)
+	}
+	if _, e := s.Update(context.Background(), id, map[string]interface{}{"email": "paul@gmail.com", "username": "paul37"}); e != nil {
+		t.Errorf("Should have kept the email of the same user rather than returning %v", e)
+	}
+	if user, _ := s.FindByID(context.Background(), other); user.Email != "john@gmail.com" {
+		t.Errorf("Should have not changed the email of the rejected update rather than %v", user.Email)
+	}
+	if user, e := s.FindByEmail(context.Background(), "John@Gmail.com "); e != nil || *user.Id != other {
+		t.Errorf("Should have found the user by an email of another case rather than %v (%v)", user, e)
+	}
+}
+
+func TestMemoryUserStoreFind(t *testing.T) {
+	s, id := newMemoryUsers(t)
+	user, e := s.FindByID(context.Background(), id)
+	if e != nil || user.Email != "paul@gmail.com" {
+		t.Errorf("Should have returned paul rather than %v (%v)", user, e)
+	}
+	user, e = s.FindByEmail(context.Background(), "paul@gmail.com")
+	if e != nil || user.Id.Hex() != id.Hex() {
+		t.Errorf("Should have returned the user with id %v rather than %v (%v)", id.Hex(), user, e)
+	}
+	if _, e := s.FindByEmail(context.Background(), "unknown@gmail.com"); e != ErrNotFound {
+		t.Errorf("Should have returned %v rather than %v", ErrNotFound, e)
+	}
+}
+
+func TestMemoryUserStoreReturnsCopies(t *testing.T) {
+	s, id := newMemoryUsers(t)
+	user, _ := s.FindByID(context.Background(), id)
+	user.Email = "changed@gmail.com"
+	if stored, _ := s.FindByID(context.Background(), id); stored.Email != "paul@gmail.com" {
+		t.Errorf("Should not have mutated the stored user")
+	}
+}
+
+func TestMemoryUserStoreUpdate(t *testing.T) {
+	s, id := newMemoryUsers(t)
+	user, e := s.Update(context.Background(), id, map[string]interface{}{"username": "paul37"})
+	if e != nil {
+		t.Fatalf("Should have updated the user rather than returning %v", e)
+	}
+	if user.Username != "paul37" || user.Email != "paul@gmail.com" {
+		t.Errorf("Should have only updated the username rather than returning %v", user)
+	}
+	if _, e := s.Update(context.Background(), primitive.NewObjectID(), nil); e != ErrNotFound {
+		t.Errorf("Should have returned %v rather than %v", ErrNotFound, e)
+	}
+}
+
+func TestMemoryUserStoreDelete(t *testing.T) {
+	s, id := newMemoryUsers(t)
+	if e := s.Delete(context.Background(), id); e != nil {
+		t.Errorf("Should have deleted the user rather than returning %v", e)
+	}
+	if users, _ := s.List(context.Background()); len(users) != 0 {
+		t.Errorf("Should have returned an empty list rather than %v", users)
+	}
+	if e := s.Delete(context.Background(), id); e != ErrNotFound {
+		t.Errorf("Should have returned %v rather than %v", ErrNotFound, e)
+	}
+}
+
+func TestMemoryUserStoreUseTOTPStep(t *testing.T) {
+	s, id := newMemoryUsers(t)
+	ctx := context.Background()
+	if used, _ := s.UseTOTPStep(ctx, id, 10); used {
+		t.Errorf("Should have not accepted a step without an enabled TOTP")
+	}
+
+	s.Update(ctx, id, map[string]interface{}{"totp": models.TOTP{Secret: "secret", Enabled: true, LastStep: 10}})
+	if used, _ := s.UseTOTPStep(ctx, id, 10); used {
+		t.Errorf("Should have not accepted the last step twice")
+	}
+	if used, _ := s.UseTOTPStep(ctx, id, 11); !used {
+		t.Errorf("Should have accepted a later step")
+	}
+	if user, _ := s.FindByID(ctx, id); user.TOTP.LastStep != 11 {
+		t.Errorf("Should have recorded the step rather than %v", user.TOTP.LastStep)
+	}
+}
+
+func TestMemoryUserStoreUseRecoveryCode(t *testing.T) {
+	s, id := newMemoryUsers(t)
+	ctx := context.Background()
+	s.Update(ctx, id, map[string]interface{}{"totp": models.TOTP{Enabled: true, RecoveryCodes: []string{"a", "b"}}})
+
+	if used, _ := s.UseRecoveryCode(ctx, id, "a"); !used {
+		t.Errorf("Should have used a known recovery code")
+	}
+	if used, _ := s.UseRecoveryCode(ctx, id, "a"); used {
+		t.Errorf("Should have not used a recovery code twice")
+	}
+	if user, _ := s.FindByID(ctx, id); len(user.TOTP.RecoveryCodes) != 1 || user.TOTP.RecoveryCodes[0] != "b" {
+		t.Errorf("Should have kept the other recovery codes rather than %v", user.TOTP.RecoveryCodes)
+	}
+}
+
+func TestMemoryUserStoreListPage(t *testing.T) {
+	s := NewMemoryUserStore()
+	ids := []primitive.ObjectID{}
+	for _, username := range []string{"ringo", "george", "john", "paul"} {
+		id, _ := s.Insert(context.Background(), models.User{Username: username, Email: username + "@gmail.com", Role: models.RoleBasic})
+		ids = append(ids, id)
+	}
+
+	users, e := s.ListPage(context.Background(), UserPage{Descending: true, After: &UserCursor{Id: ids[2]}, Limit: 10})
+	if e != nil || len(users) != 2 || *users[0].Id != ids[1] || *users[1].Id != ids[0] {
+		t.Errorf("Should have returned the users after the cursor in descending order rather than %v (%v)", users, e)
+	}
+
+	users, _ = s.ListPage(context.Background(), UserPage{SortBy: UserSortUsername, Filter: UserFilter{UsernamePrefix: "r"}, Limit: 10})
+	if len(users) != 1 || users[0].Username != "ringo" {
+		t.Errorf("Should have filtered the users by the prefix of their username rather than %v", users)
+	}
+	users, _ = s.ListPage(context.Background(), UserPage{SortBy: UserSortUsername, Offset: 1, Limit: 2})
+	if len(users) != 2 || users[0].Username != "john" || users[1].Username != "paul" {
+		t.Errorf("Should have skipped the offset of the sorted users rather than %v", users)
+	}
+}
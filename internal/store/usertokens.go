@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UserTokenStore is an interface that describes the operations performed on the user tokens collection
+type UserTokenStore interface {
+	Insert(ctx context.Context, token models.UserToken) (primitive.ObjectID, error)
+	FindByHash(ctx context.Context, hash string) (*models.UserToken, error)
+	// MarkUsed flags a token as used. It returns false when the token had already been used, which allows callers to
+	// detect concurrent use.
+	MarkUsed(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error)
+	// DeleteByUser removes the tokens of a user that were issued for a purpose
+	DeleteByUser(ctx context.Context, userID primitive.ObjectID, purpose string) error
+}
+
+// MongoUserTokenStore is a UserTokenStore backed by a MongoDB collection
+type MongoUserTokenStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoUserTokenStore returns a MongoUserTokenStore that uses the userTokens collection of a database
+func NewMongoUserTokenStore(db *mongo.Database) *MongoUserTokenStore {
+	return &MongoUserTokenStore{db.Collection(models.UserTokens{}.Name())}
+}
+
+// EnsureIndexes creates a unique index on the token hash and a TTL index that removes expired tokens
+func (s *MongoUserTokenStore) EnsureIndexes(ctx context.Context) error {
+	_, e := s.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"hash": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "purpose", Value: 1}}},
+		{Keys: bson.M{"expiresAt": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return e
+}
+
+// Insert stores a user token and returns its id
+func (s *MongoUserTokenStore) Insert(ctx context.Context, token models.UserToken) (primitive.ObjectID, error) {
+	result, e := s.Collection.InsertOne(ctx, token)
+	if e != nil {
+		if isDuplicateKey(e) {
+			return primitive.NilObjectID, ErrDuplicate
+		}
+		return primitive.NilObjectID, e
+	}
+	oid, _ := result.InsertedID.(primitive.ObjectID)
+	return oid, nil
+}
+
+// FindByHash returns the user token with the given hash
+func (s *MongoUserTokenStore) FindByHash(ctx context.Context, hash string) (*models.UserToken, error) {
+	var token models.UserToken
+	if e := s.Collection.FindOne(ctx, bson.M{"hash": hash}).Decode(&token); e != nil {
+		if e == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, e
+	}
+	return &token, nil
+}
+
+// MarkUsed flags a token as used, only if it has not been used before
+func (s *MongoUserTokenStore) MarkUsed(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	filter := bson.M{"_id": id, "usedAt": bson.M{"$exists": false}}
+	result, e := s.Collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"usedAt": at}})
+	if e != nil {
+		return false, e
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// DeleteByUser removes the tokens of a user that were issued for a purpose
+func (s *MongoUserTokenStore) DeleteByUser(ctx context.Context, userID primitive.ObjectID, purpose string) error {
+	_, e := s.Collection.DeleteMany(ctx, bson.M{"userId": userID, "purpose": purpose})
+	return e
+}
+
+// MemoryUserTokenStore is a thread-safe UserTokenStore that keeps the tokens in memory
+type MemoryUserTokenStore struct {
+	mu     sync.Mutex
+	tokens map[primitive.ObjectID]models.UserToken
+}
+
+// NewMemoryUserTokenStore returns an empty MemoryUserTokenStore
+func NewMemoryUserTokenStore() *MemoryUserTokenStore {
+	return &MemoryUserTokenStore{tokens: map[primitive.ObjectID]models.UserToken{}}
+}
+
+// Insert stores a user token and returns its id
+func (s *MemoryUserTokenStore) Insert(ctx context.Context, token models.UserToken) (primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tokens {
+		if t.Hash == token.Hash {
+			return primitive.NilObjectID, ErrDuplicate
+		}
+	}
+	id := primitive.NewObjectID()
+	token.Id = &id
+	s.tokens[id] = token
+	return id, nil
+}
+
+// FindByHash returns the user token with the given hash
+func (s *MemoryUserTokenStore) FindByHash(ctx context.Context, hash string) (*models.UserToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tokens {
+		if t.Hash == hash {
+			return &t, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// MarkUsed flags a token as used, only if it has not been used before
+func (s *MemoryUserTokenStore) MarkUsed(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[id]
+	if !ok || t.UsedAt != nil {
+		return false, nil
+	}
+	t.UsedAt = &at
+	s.tokens[id] = t
+	return true, nil
+}
+
+// DeleteByUser removes the tokens of a user that were issued for a purpose
+func (s *MemoryUserTokenStore) DeleteByUser(ctx context.Context, userID primitive.ObjectID, purpose string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, t := range s.tokens {
+		if t.UserId == userID && t.Purpose == purpose {
+			delete(s.tokens, id)
+		}
+	}
+	return nil
+}
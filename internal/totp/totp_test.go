@@ -0,0 +1,55 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+	for unix, expected := range map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	} {
+		if code := generate(key, uint64(Step(time.Unix(unix, 0))), 8); code != expected {
+			t.Errorf("Should have returned %v at %v rather than %v", expected, unix, code)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret, e := GenerateSecret()
+	if e != nil {
+		t.Fatal(e)
+	}
+	now := time.Now()
+	code, _ := Code(secret, now.Add(-Period))
+
+	step, ok := Verify(secret, code, now)
+	if !ok || step != Step(now)-1 {
+		t.Errorf("Should have accepted the code of the previous period rather than returning %v, %v", step, ok)
+	}
+	if _, ok := Verify(secret, code, now.Add(2*Period)); ok {
+		t.Errorf("Should have rejected a code that is older than the allowed skew")
+	}
+	if _, ok := Verify(secret, "12345", now); ok {
+		t.Errorf("Should have rejected a code with fewer digits")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("authAPI", "paul@gmail.com", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/authAPI:paul@gmail.com?") {
+		t.Errorf("Should have labelled the secret with the issuer and the account rather than %v", uri)
+	}
+	for _, param := range []string{"secret=JBSWY3DPEHPK3PXP", "issuer=authAPI", "digits=6", "period=30"} {
+		if !strings.Contains(uri, param) {
+			t.Errorf("Should have included %v in %v", param, uri)
+		}
+	}
+}
@@ -9,11 +9,21 @@ import (
 
 // Representation is a custom type used to represent the state of the API when a response is returnned
 type Representation struct {
-	Status  int         `json:"status"`
-	Success bool        `json:"success"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
-	Token   interface{} `json:"token,omitempty"`
+	Status       int         `json:"status"`
+	Success      bool        `json:"success"`
+	Message      string      `json:"message,omitempty"`
+	Data         interface{} `json:"data,omitempty"`
+	Token        interface{} `json:"token,omitempty"`
+	RefreshToken interface{} `json:"refreshToken,omitempty"`
+	Pagination   *Pagination `json:"pagination,omitempty"`
+}
+
+// Pagination is a custom type used to describe the page of a list that is returned within a Representation
+type Pagination struct {
+	Limit      int64  `json:"limit"`
+	Offset     int64  `json:"offset,omitempty"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	HasMore    bool   `json:"hasMore"`
 }
 
 // BadRequest returns a representation of the state of the API with an HTTP/x.x 400 Bad Request code
@@ -61,6 +71,24 @@ func (r Representation) NotAcceptable() Representation {
 	}
 }
 
+// Conflict returns a representation of the state of the API with an HTTP/x.x 409 Conflict code
+func (r Representation) Conflict() Representation {
+	return Representation{
+		Status:  409,
+		Success: false,
+		Message: r.Message,
+	}
+}
+
+// PayloadTooLarge returns a representation of the state of the API with an HTTP/x.x 413 Payload Too Large code
+func (r Representation) PayloadTooLarge() Representation {
+	return Representation{
+		Status:  413,
+		Success: false,
+		Message: r.Message,
+	}
+}
+
 // UnsupportedMediaType returns a representation of the state of the API with an HTTP/x.x 415 Unsupported Media Type code
 func (r Representation) UnsupportedMediaType() Representation {
 	return Representation{
@@ -70,6 +98,15 @@ func (r Representation) UnsupportedMediaType() Representation {
 	}
 }
 
+// TooManyRequests returns a representation of the state of the API with an HTTP/x.x 429 Too Many Requests code
+func (r Representation) TooManyRequests() Representation {
+	return Representation{
+		Status:  429,
+		Success: false,
+		Message: r.Message,
+	}
+}
+
 // InternalServerError returns a representation of the state of the API with an HTTP/x.x 500 Internal Server Error code
 func (r Representation) InternalServerError() Representation {
 	return Representation{
@@ -82,22 +119,34 @@ func (r Representation) InternalServerError() Representation {
 // Ok returns a representation of the state of the API with an HTTP/x.x 200 Ok code
 func (r Representation) Ok() Representation {
 	return Representation{
-		Status:  200,
-		Success: true,
-		Message: r.Message,
-		Data:    r.Data,
-		Token:   r.Token,
+		Status:       200,
+		Success:      true,
+		Message:      r.Message,
+		Data:         r.Data,
+		Token:        r.Token,
+		RefreshToken: r.RefreshToken,
+		Pagination:   r.Pagination,
 	}
 }
 
 // Created returns a representation of the state of the API with an HTTP/x.x 201 Created code
 func (r Representation) Created() Representation {
 	return Representation{
-		Status:  201,
+		Status:       201,
+		Success:      true,
+		Message:      r.Message,
+		Data:         r.Data,
+		Token:        r.Token,
+		RefreshToken: r.RefreshToken,
+	}
+}
+
+// Accepted returns a representation of the state of the API with an HTTP/x.x 202 Accepted code
+func (r Representation) Accepted() Representation {
+	return Representation{
+		Status:  202,
 		Success: true,
 		Message: r.Message,
-		Data:    r.Data,
-		Token:   r.Token,
 	}
 }
 
@@ -41,11 +41,26 @@ func TestNotFound(t *testing.T) {
 	checkStatusCode(&r, 404, t)
 	checkSuccess(&r, false, t)
 }
+func TestConflict(t *testing.T) {
+	r := repr.Conflict()
+	checkStatusCode(&r, 409, t)
+	checkSuccess(&r, false, t)
+}
+func TestPayloadTooLarge(t *testing.T) {
+	r := repr.PayloadTooLarge()
+	checkStatusCode(&r, 413, t)
+	checkSuccess(&r, false, t)
+}
 func TestUnsupportedMediaType(t *testing.T) {
 	r := repr.UnsupportedMediaType()
 	checkStatusCode(&r, 415, t)
 	checkSuccess(&r, false, t)
 }
+func TestTooManyRequests(t *testing.T) {
+	r := repr.TooManyRequests()
+	checkStatusCode(&r, 429, t)
+	checkSuccess(&r, false, t)
+}
 
 func TestInternalServerError(t *testing.T) {
 	r := repr.InternalServerError()
@@ -62,3 +77,8 @@ func TestCreated(t *testing.T) {
 	checkStatusCode(&r, 201, t)
 	checkSuccess(&r, true, t)
 }
+func TestAccepted(t *testing.T) {
+	r := repr.Accepted()
+	checkStatusCode(&r, 202, t)
+	checkSuccess(&r, true, t)
+}
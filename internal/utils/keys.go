@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/gbrlsnchs/jwt/v3"
+)
+
+// minRSABits is the smallest RSA key that is accepted, as required by RFC 7518 for RS256
+const minRSABits = 2048
+
+// edDSA is the Ed25519 algorithm, named EdDSA as RFC 8037 requires rather than after the curve
+type edDSA struct {
+	*jwt.Ed25519
+}
+
+// Name returns the name of the algorithm within the JOSE header
+func (edDSA) Name() string {
+	return "EdDSA"
+}
+
+// Key is a custom type used to represent a key that signs or verifies JWT tokens. HMAC keys are secret, so they are
+// never published, while the public part of an asymmetric key is published within the JWKS of the API.
+type Key struct {
+	Id     string
+	alg    jwt.Algorithm
+	public crypto.PublicKey
+}
+
+// Algorithm returns the name of the algorithm of the key, such as RS256
+func (k *Key) Algorithm() string {
+	return k.alg.Name()
+}
+
+// NewHMACKey returns an HS256 key of a secret
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{Id: id, alg: jwt.NewHS256(secret)}
+}
+
+// NewRSAKey returns an RS256 key that signs tokens with a private key. The id defaults to the thumbprint of the key.
+func NewRSAKey(id string, priv *rsa.PrivateKey) (*Key, error) {
+	if priv.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("an RSA key needs at least %v bits", minRSABits)
+	}
+	return newKey(id, jwt.NewRS256(jwt.RSAPrivateKey(priv)), &priv.PublicKey)
+}
+
+// NewEd25519Key returns an EdDSA key that signs tokens with a private key. The id defaults to the thumbprint of the key.
+func NewEd25519Key(id string, priv ed25519.PrivateKey) (*Key, error) {
+	return newKey(id, edDSA{jwt.NewEd25519(jwt.Ed25519PrivateKey(priv))}, priv.Public())
+}
+
+// NewPublicKey returns a key that only verifies tokens, such as the key of a signing key that has been rotated out. The
+// id defaults to the thumbprint of the key.
+func NewPublicKey(id string, pub crypto.PublicKey) (*Key, error) {
+	switch pk := pub.(type) {
+	case *rsa.PublicKey:
+		if pk.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("an RSA key needs at least %v bits", minRSABits)
+		}
+		return newKey(id, jwt.NewRS256(jwt.RSAPublicKey(pk)), pk)
+	case ed25519.PublicKey:
+		return newKey(id, edDSA{jwt.NewEd25519(jwt.Ed25519PublicKey(pk))}, pk)
+	}
+	return nil, fmt.Errorf("unsupported public key of type %T", pub)
+}
+
+func newKey(id string, alg jwt.Algorithm, pub crypto.PublicKey) (*Key, error) {
+	k := &Key{Id: id, alg: alg, public: pub}
+	if k.Id == "" {
+		k.Id = k.Thumbprint()
+	}
+	return k, nil
+}
+
+// ParsePrivateKey parses a PEM encoded RSA or Ed25519 private key, in either the PKCS #1 or the PKCS #8 form
+func ParsePrivateKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("the private key is not PEM encoded")
+	}
+
+	var priv interface{}
+	var e error
+	if block.Type == "RSA PRIVATE KEY" {
+		priv, e = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		priv, e = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if e != nil {
+		return nil, e
+	}
+
+	switch pk := priv.(type) {
+	case *rsa.PrivateKey:
+		return NewRSAKey(id, pk)
+	case ed25519.PrivateKey:
+		return NewEd25519Key(id, pk)
+	}
+	return nil, fmt.Errorf("unsupported private key of type %T", priv)
+}
+
+// ParsePublicKey parses a PEM encoded RSA or Ed25519 public key in the PKIX form
+func ParsePublicKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("the public key is not PEM encoded")
+	}
+	pub, e := x509.ParsePKIXPublicKey(block.Bytes)
+	if e != nil {
+		return nil, e
+	}
+	return NewPublicKey(id, pub)
+}
+
+// JWK is a custom type used to represent the public part of a key as a JSON Web Key of RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	// N and E are the modulus and the exponent of an RSA key
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Crv and X are the curve and the public key of an Ed25519 key
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is a custom type used to represent a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK returns the public part of the key. It returns false for HMAC keys, which can not be published.
+func (k *Key) JWK() (JWK, bool) {
+	enc := base64.RawURLEncoding
+	switch pk := k.public.(type) {
+	case *rsa.PublicKey:
+		e := big.NewInt(int64(pk.E)).Bytes()
+		return JWK{Kty: "RSA", Use: "sig", Alg: k.Algorithm(), Kid: k.Id, N: enc.EncodeToString(pk.N.Bytes()), E: enc.EncodeToString(e)}, true
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Use: "sig", Alg: k.Algorithm(), Kid: k.Id, Crv: "Ed25519", X: enc.EncodeToString(pk)}, true
+	}
+	return JWK{}, false
+}
+
+// Thumbprint returns the JWK thumbprint of RFC 7638 of the key, which is the SHA-256 hash of its required members in
+// lexicographic order. It returns an empty string for HMAC keys.
+func (k *Key) Thumbprint() string {
+	jwk, ok := k.JWK()
+	if !ok {
+		return ""
+	}
+
+	var members []byte
+	if jwk.Kty == "RSA" {
+		members, _ = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N})
+	} else {
+		members, _ = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X})
+	}
+	sum := sha256.Sum256(members)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// KeySet is a custom type used to represent the keys of the JWT tokens. New tokens are signed by a single key, while
+// a token is verified by the key of its kid header, so that the keys can be rotated without invalidating the tokens
+// that were signed by a previous key.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+	ordered []*Key
+}
+
+// NewKeySet returns a KeySet that signs tokens with a key, and verifies them with the same key or any of the given ones
+func NewKeySet(signing *Key, verification ...*Key) *KeySet {
+	ks := &KeySet{signing: signing, keys: map[string]*Key{}}
+	for _, k := range append([]*Key{signing}, verification...) {
+		if _, ok := ks.keys[k.Id]; !ok {
+			ks.keys[k.Id] = k
+			ks.ordered = append(ks.ordered, k)
+		}
+	}
+	return ks
+}
+
+// Sign signs a payload with the signing key, whose id is set as the kid header
+func (ks *KeySet) Sign(payload interface{}) ([]byte, error) {
+	var opts []jwt.SignOption
+	if ks.signing.Id != "" {
+		opts = append(opts, jwt.KeyID(ks.signing.Id))
+	}
+	return jwt.Sign(payload, ks.signing.alg, opts...)
+}
+
+// Verify verifies a token with the key of its kid header, rejecting tokens whose alg header does not match the key
+func (ks *KeySet) Verify(token []byte, payload interface{}, opts ...jwt.VerifyOption) error {
+	hd, e := decodeHeader(token)
+	if e != nil {
+		return e
+	}
+	k, ok := ks.keys[hd.KeyID]
+	if !ok {
+		return fmt.Errorf("unknown key %q", hd.KeyID)
+	}
+	_, e = jwt.Verify(token, k.alg, payload, append([]jwt.VerifyOption{jwt.ValidateHeader}, opts...)...)
+	return e
+}
+
+// Algorithm returns the name of the algorithm of the signing key, such as RS256
+func (ks *KeySet) Algorithm() string {
+	return ks.signing.Algorithm()
+}
+
+// JWKS returns the public keys of the set
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range ks.ordered {
+		if jwk, ok := k.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// decodeHeader returns the JOSE header of a token without verifying it
+func decodeHeader(token []byte) (jwt.Header, error) {
+	var hd jwt.Header
+	parts := strings.SplitN(string(token), ".", 2)
+	if len(parts) != 2 {
+		return hd, jwt.ErrMalformed
+	}
+	raw, e := base64.RawURLEncoding.DecodeString(parts[0])
+	if e != nil {
+		return hd, e
+	}
+	return hd, json.Unmarshal(raw, &hd)
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTestUser() models.User {
+	userId, _ := primitive.ObjectIDFromHex("5db5b5b06507b38887bedc87")
+	return models.User{Id: &userId, Email: "paul@gmail.com"}
+}
+
+func newEd25519Key(t *testing.T) *Key {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	k, e := NewEd25519Key("", priv)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return k
+}
+
+func tokenHeader(token []byte, t *testing.T) string {
+	b, e := base64.RawURLEncoding.DecodeString(strings.Split(string(token), ".")[0])
+	if e != nil {
+		t.Fatal(e)
+	}
+	return string(b)
+}
+
+func TestIssueTokenWithRSAKey(t *testing.T) {
+	priv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	k, e := NewRSAKey("rsa-1", priv)
+	if e != nil {
+		t.Fatal(e)
+	}
+	ku := Utils{Keys: NewKeySet(k)}
+
+	token, ok := ku.IssueToken(newTestUser(), time.Hour)
+	if !ok {
+		t.Fatalf("Should have generated a token")
+	}
+	if hd := tokenHeader(token, t); !strings.Contains(hd, `"alg":"RS256"`) || !strings.Contains(hd, `"kid":"rsa-1"`) {
+		t.Errorf("The header should have included the algorithm and the key id rather than %v", hd)
+	}
+	if payload, ok := ku.ParseToken(token); !ok || payload.Email != "paul@gmail.com" {
+		t.Errorf("Should have verified the token")
+	}
+}
+
+func TestIssueTokenWithEd25519Key(t *testing.T) {
+	k := newEd25519Key(t)
+	ku := Utils{Keys: NewKeySet(k)}
+
+	token, _ := ku.IssueToken(newTestUser(), time.Hour)
+	if hd := tokenHeader(token, t); !strings.Contains(hd, `"alg":"EdDSA"`) || !strings.Contains(hd, `"kid":"`+k.Id+`"`) {
+		t.Errorf("The header should have included the algorithm and the key id rather than %v", hd)
+	}
+	if _, ok := ku.ParseToken(token); !ok {
+		t.Errorf("Should have verified the token")
+	}
+}
+
+func TestParseTokenAfterKeyRotation(t *testing.T) {
+	old := newEd25519Key(t)
+	token, _ := Utils{Keys: NewKeySet(old)}.IssueToken(newTestUser(), time.Hour)
+
+	retired, _ := NewPublicKey("", old.public)
+	current := newEd25519Key(t)
+	if _, ok := (Utils{Keys: NewKeySet(current, retired)}).ParseToken(token); !ok {
+		t.Errorf("The public key of the previous key should have verified its token")
+	}
+	if _, ok := (Utils{Keys: NewKeySet(current)}).ParseToken(token); ok {
+		t.Errorf("A token of an unknown key should have been rejected")
+	}
+}
+
+func TestParseTokenOfSecretAfterRollout(t *testing.T) {
+	secret := NewHMACKey("", []byte("secret"))
+	token, _ := Utils{Keys: NewKeySet(secret)}.IssueToken(newTestUser(), time.Hour)
+
+	current := newEd25519Key(t)
+	ku := Utils{Keys: NewKeySet(current, secret)}
+	if _, ok := ku.ParseToken(token); !ok {
+		t.Errorf("The secret should have verified the token it signed before the rollout")
+	}
+	issued, _ := ku.IssueToken(newTestUser(), time.Hour)
+	if hd := tokenHeader(issued, t); !strings.Contains(hd, `"alg":"EdDSA"`) {
+		t.Errorf("New tokens should have been signed by the private key rather than %v", hd)
+	}
+	if set := ku.Keys.JWKS(); len(set.Keys) != 1 || set.Keys[0].Kid != current.Id {
+		t.Errorf("The secret should not have been published rather than %+v", set.Keys)
+	}
+}
+
+func TestParseTokenWithMismatchingAlgorithm(t *testing.T) {
+	k := newEd25519Key(t)
+	token, _ := Utils{Keys: NewKeySet(NewHMACKey(k.Id, []byte("secret")))}.IssueToken(newTestUser(), time.Hour)
+	if _, ok := (Utils{Keys: NewKeySet(k)}).ParseToken(token); ok {
+		t.Errorf("A token whose algorithm does not match its key should have been rejected")
+	}
+}
+
+func TestPublicKeyCanNotSign(t *testing.T) {
+	pub, _ := NewPublicKey("", newEd25519Key(t).public)
+	if _, ok := (Utils{Keys: NewKeySet(pub)}).IssueToken(newTestUser(), time.Hour); ok {
+		t.Errorf("A public key should not have signed a token")
+	}
+}
+
+func TestNewRSAKeyTooSmall(t *testing.T) {
+	priv, _ := rsa.GenerateKey(rand.Reader, 1024)
+	if _, e := NewRSAKey("", priv); e == nil {
+		t.Errorf("Should have rejected an RSA key of 1024 bits")
+	}
+}
+
+func TestParsePrivateAndPublicKey(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(priv)
+	k, e := ParsePrivateKey("", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if e != nil {
+		t.Fatal(e)
+	}
+	der, _ = x509.MarshalPKIXPublicKey(pub)
+	pk, e := ParsePublicKey("", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if k.Id != pk.Id || k.Algorithm() != "EdDSA" {
+		t.Errorf("The private and the public key should have shared a thumbprint rather than %v and %v", k.Id, pk.Id)
+	}
+
+	rsaPriv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	k, e = ParsePrivateKey("rsa", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaPriv)}))
+	if e != nil || k.Algorithm() != "RS256" || k.Id != "rsa" {
+		t.Errorf("Should have parsed a PKCS #1 RSA key rather than %v", e)
+	}
+	if _, e := ParsePrivateKey("", []byte("not a key")); e == nil {
+		t.Errorf("Should have rejected a key that is not PEM encoded")
+	}
+}
+
+func TestThumbprint(t *testing.T) {
+	// the example of RFC 8037, Appendix A.3
+	x, _ := base64.RawURLEncoding.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+	k, _ := NewPublicKey("", ed25519.PublicKey(x))
+	if expected := "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"; k.Id != expected {
+		t.Errorf("Should have returned a thumbprint of %v rather than %v", expected, k.Id)
+	}
+	if NewHMACKey("", []byte("secret")).Thumbprint() != "" {
+		t.Errorf("An HMAC key should not have a thumbprint")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	priv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaKey, _ := NewRSAKey("rsa-1", priv)
+	edKey := newEd25519Key(t)
+	set := NewKeySet(rsaKey, edKey, NewHMACKey("hmac", []byte("secret"))).JWKS()
+
+	if len(set.Keys) != 2 {
+		t.Fatalf("Should have published the two public keys rather than %v", len(set.Keys))
+	}
+	if jwk := set.Keys[0]; jwk.Kty != "RSA" || jwk.Kid != "rsa-1" || jwk.Alg != "RS256" || jwk.E != "AQAB" || jwk.N == "" {
+		t.Errorf("Should have published the RSA key rather than %+v", jwk)
+	}
+	if jwk := set.Keys[1]; jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.Alg != "EdDSA" || jwk.Kid != edKey.Id || jwk.X == "" {
+		t.Errorf("Should have published the Ed25519 key rather than %+v", jwk)
+	}
+	if set := NewKeySet(NewHMACKey("", []byte("secret"))).JWKS(); set.Keys == nil || len(set.Keys) != 0 {
+		t.Errorf("Should have published an empty set of keys rather than %v", set.Keys)
+	}
+}
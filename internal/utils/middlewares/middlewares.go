@@ -5,11 +5,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"io/ioutil"
+	"mime"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 
 	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
 	"github.com/MarioSimou/authAPI/internal/utils"
 	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
 
@@ -26,9 +28,13 @@ func Handler(next MiddlewareHandler) httprouter.Handle {
 	}
 }
 
-// Middleware is a custom type that accepts the Utilities type from Utilities package
+// Middleware is a custom type that accepts the Utilities type from Utilities package and the stores of the API
 type Middleware struct {
 	Utils *utils.Utils
+	Store *store.Store
+	// Limits are the rate limits of the routes, keyed by the names given to RateLimit
+	Limits  map[string]Limit
+	Limiter Limiter
 }
 
 // ValidateCreateUser validates the request body when a user is created
@@ -53,7 +59,9 @@ func (m Middleware) ValidateCreateUser(next MiddlewareHandler) MiddlewareHandler
 			return
 		}
 		body.Password = m.Utils.HashPassword(body.Password)
-		body.ValidateRole()
+		// the role of a user can only be changed by an admin, while the email is verified through the mailed link
+		body.Role = models.RoleBasic
+		body.EmailVerified = false
 
 		// updates the content of the request body
 		nB, _ := json.Marshal(body)
@@ -63,6 +71,81 @@ func (m Middleware) ValidateCreateUser(next MiddlewareHandler) MiddlewareHandler
 	}
 }
 
+// ValidateCreatePost validates the request body when a post is created
+func (m Middleware) ValidateCreatePost(next MiddlewareHandler) MiddlewareHandler {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+		var body models.Post
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if t := body.ValidateTitle(); !t {
+			// HTTP/x.x 400 Bad Request
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Title"}.BadRequest())
+			return
+		}
+		if t := body.ValidateStatus(); !t {
+			// HTTP/x.x 400 Bad Request
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Status"}.BadRequest())
+			return
+		}
+		if t := body.ValidateTags(); !t {
+			// HTTP/x.x 400 Bad Request
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Tags"}.BadRequest())
+			return
+		}
+
+		// updates the content of the request body
+		nB, _ := json.Marshal(body)
+		r.Body = ioutil.NopCloser(bytes.NewBuffer(nB))
+		r.Body.Close()
+		next(w, r, p, other...)
+	}
+}
+
+// ValidateCreateAlbum validates the request body when an album is created
+func (m Middleware) ValidateCreateAlbum(next MiddlewareHandler) MiddlewareHandler {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+		var body models.Album
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if t := body.ValidateTitle(); !t {
+			// HTTP/x.x 400 Bad Request
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Title"}.BadRequest())
+			return
+		}
+		if t := body.ValidateVisibility(); !t {
+			// HTTP/x.x 400 Bad Request
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Visibility"}.BadRequest())
+			return
+		}
+
+		// updates the content of the request body
+		nB, _ := json.Marshal(body)
+		r.Body = ioutil.NopCloser(bytes.NewBuffer(nB))
+		r.Body.Close()
+		next(w, r, p, other...)
+	}
+}
+
+// ValidateCreateComment validates the request body when a comment is created
+func (m Middleware) ValidateCreateComment(next MiddlewareHandler) MiddlewareHandler {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+		var body models.Comment
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if t := body.ValidateBody(); !t {
+			// HTTP/x.x 400 Bad Request
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Comment"}.BadRequest())
+			return
+		}
+
+		// updates the content of the request body
+		nB, _ := json.Marshal(body)
+		r.Body = ioutil.NopCloser(bytes.NewBuffer(nB))
+		r.Body.Close()
+		next(w, r, p, other...)
+	}
+}
+
 // ValidateSignIn checks the credentials of a user. If the calidation failsm it returns an HTTP 401 Unauthorized.
 func (m Middleware) ValidateSignIn(next MiddlewareHandler) MiddlewareHandler {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
@@ -83,6 +166,147 @@ func (m Middleware) ValidateSignIn(next MiddlewareHandler) MiddlewareHandler {
 	}
 }
 
+// ValidateMFASignIn checks that a pending token and a code are included in the request body. If the validation fails it
+// returns an HTTP 400 Bad Request.
+func (m Middleware) ValidateMFASignIn(next MiddlewareHandler) MiddlewareHandler {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+		var body models.MFASignIn
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if !body.Validate() {
+			// HTTP/x.x 400 Bad Request
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Request Body"}.BadRequest())
+			return
+		}
+
+		j, _ := json.Marshal(body)
+		r.Body = ioutil.NopCloser(bytes.NewBuffer(j))
+		r.Body.Close()
+		next(w, r, p)
+	}
+}
+
+// ValidateTOTPConfirmation checks that a code is included in the request body. If the validation fails it returns an
+// HTTP 400 Bad Request.
+func (m Middleware) ValidateTOTPConfirmation(next MiddlewareHandler) MiddlewareHandler {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+		var body models.TOTPConfirmation
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if !body.Validate() {
+			// HTTP/x.x 400 Bad Request
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Request Body"}.BadRequest())
+			return
+		}
+
+		j, _ := json.Marshal(body)
+		r.Body = ioutil.NopCloser(bytes.NewBuffer(j))
+		r.Body.Close()
+		next(w, r, p, other...)
+	}
+}
+
+// ValidateChangePassword checks that the current and a valid new password are included in the request body. If the
+// validation fails it returns an HTTP 400 Bad Request.
+func (m Middleware) ValidateChangePassword(next MiddlewareHandler) MiddlewareHandler {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+		var body models.PasswordChange
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if !body.Validate() {
+			// HTTP/x.x 400 Bad Request
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Request Body"}.BadRequest())
+			return
+		}
+
+		j, _ := json.Marshal(body)
+		r.Body = ioutil.NopCloser(bytes.NewBuffer(j))
+		r.Body.Close()
+		next(w, r, p, other...)
+	}
+}
+
+// ValidateCreateClient checks that a name and valid redirect URIs are included in the request body of a client
+// registration. If the validation fails it returns an HTTP 400 Bad Request.
+func (m Middleware) ValidateCreateClient(next MiddlewareHandler) MiddlewareHandler {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+		var body models.ClientRegistration
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if !body.Validate() {
+			// HTTP/x.x 400 Bad Request
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Request Body"}.BadRequest())
+			return
+		}
+
+		j, _ := json.Marshal(body)
+		r.Body = ioutil.NopCloser(bytes.NewBuffer(j))
+		r.Body.Close()
+		next(w, r, p, other...)
+	}
+}
+
+// ValidateForgotPassword checks that a valid email is included in the request body. If the validation fails it returns
+// an HTTP 400 Bad Request.
+func (m Middleware) ValidateForgotPassword(next MiddlewareHandler) MiddlewareHandler {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+		var body models.ForgotPassword
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if !body.ValidateEmail() {
+			// HTTP/x.x 400 Bad Request
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Email"}.BadRequest())
+			return
+		}
+
+		j, _ := json.Marshal(body)
+		r.Body = ioutil.NopCloser(bytes.NewBuffer(j))
+		r.Body.Close()
+		next(w, r, p)
+	}
+}
+
+// ValidateResetPassword checks that a token and a valid password are included in the request body. If the validation
+// fails it returns an HTTP 400 Bad Request.
+func (m Middleware) ValidateResetPassword(next MiddlewareHandler) MiddlewareHandler {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+		var body models.ResetPassword
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if !body.Validate() {
+			// HTTP/x.x 400 Bad Request
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Request Body"}.BadRequest())
+			return
+		}
+
+		j, _ := json.Marshal(body)
+		r.Body = ioutil.NopCloser(bytes.NewBuffer(j))
+		r.Body.Close()
+		next(w, r, p)
+	}
+}
+
+// ValidateRefreshToken checks that a refresh token is included in the request body. If the validation fails it returns
+// an HTTP 401 Unauthorized.
+func (m Middleware) ValidateRefreshToken(next MiddlewareHandler) MiddlewareHandler {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+		var body models.RefreshRequest
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if body.ValidateRefreshToken() {
+			j, _ := json.Marshal(body)
+			r.Body = ioutil.NopCloser(bytes.NewBuffer(j))
+			r.Body.Close()
+			next(w, r, p)
+			return
+		}
+
+		// HTTP/x.x 401 Unauthorized
+		httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid Request Body"}.Unauthorized())
+		return
+	}
+}
+
 // ValidateRequest checks the Request Headers(Accept and Content-Type) of a request, which shows that the API
 // either accept or returns data in a JSON format
 func (m Middleware) ValidateRequest(next MiddlewareHandler) MiddlewareHandler {
@@ -104,8 +328,38 @@ func (m Middleware) ValidateRequest(next MiddlewareHandler) MiddlewareHandler {
 	}
 }
 
-// Authorization checks the credentials of a user. A user needs to use a valid JWT token.
+// ValidateUpload checks the Request Headers(Accept and Content-Type) of a request that uploads a file, which shows
+// that the API accepts a multipart/form-data body and returns data in a JSON format
+func (m Middleware) ValidateUpload(next MiddlewareHandler) MiddlewareHandler {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+		// HTTP/x.x 406 Not Acceptable
+		if a := r.Header.Get("Accept"); a != "*/*" && a != "application/json" {
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Only JSON representations are supported"}.NotAcceptable())
+			return
+		}
+		// HTTP/x.x 415 Unsupported Media Type
+		if mt, params, e := mime.ParseMediaType(r.Header.Get("Content-Type")); e != nil || mt != "multipart/form-data" || params["boundary"] == "" {
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "A MIME type of multipart/form-data is only accepted"}.UnsupportedMediaType())
+			return
+		}
+
+		next(w, r, p, other...)
+	}
+}
+
+// Authorization checks the credentials of a user. A user needs to use a valid JWT token. The access tokens of OpenID
+// Connect clients are rejected, since they only grant the scopes of the client.
 func (m Middleware) Authorization(next MiddlewareHandler) MiddlewareHandler {
+	return m.authorize(false, next)
+}
+
+// ClientAuthorization checks the credentials of a user like Authorization, while accepting the access tokens of OpenID
+// Connect clients as well. The next handler needs to limit its response to the scopes of such tokens.
+func (m Middleware) ClientAuthorization(next MiddlewareHandler) MiddlewareHandler {
+	return m.authorize(true, next)
+}
+
+func (m Middleware) authorize(clients bool, next MiddlewareHandler) MiddlewareHandler {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
 		auth := r.Header.Get("Authorization")
 		t := strings.Replace(auth, "Bearer ", "", 1)
@@ -115,14 +369,65 @@ func (m Middleware) Authorization(next MiddlewareHandler) MiddlewareHandler {
 			return
 		}
 
-		if _, ok := m.Utils.VerifyToken([]byte(t), os.Getenv("JWT_SECRET")); ok {
-			// custom header that includes the JWT token
-			payload := m.Utils.ExtractPayload(t)
-			next(w, r, p, payload)
-		} else {
+		payload, ok := m.Utils.ParseToken([]byte(t))
+		if !ok || payload.Id == nil || (payload.IsClientToken() && !clients) {
 			// HTTP/x.x 401 Unauthorized
 			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid user token"}.Unauthorized())
 			return
 		}
+
+		// tokens of users that signed out, changed their password or deleted their account are rejected
+		var iat time.Time
+		if payload.IssuedAt != nil {
+			iat = payload.IssuedAt.Time
+		}
+		revoked, e := m.Store.Revocations.IsRevoked(r.Context(), payload.JWTID, *payload.Id, iat)
+		if e != nil {
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to verify the user token"}.InternalServerError())
+			return
+		}
+		if revoked {
+			// HTTP/x.x 401 Unauthorized
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Invalid user token"}.Unauthorized())
+			return
+		}
+
+		next(w, r, p, payload)
+	}
+}
+
+// OptionalAuthorization authorizes the requests that send a user token like Authorization, which passes its payload
+// to the next handler, while the requests without one are passed to the next handler without a payload
+func (m Middleware) OptionalAuthorization(next MiddlewareHandler) MiddlewareHandler {
+	authorized := m.Authorization(next)
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+		if r.Header.Get("Authorization") == "" {
+			next(w, r, p, other...)
+			return
+		}
+		authorized(w, r, p, other...)
+	}
+}
+
+// RequireRole checks that the user of a request has one of the given roles. It needs to be wrapped by Authorization,
+// which passes the payload of the JWT token to the next handler.
+func (m Middleware) RequireRole(roles ...string) func(MiddlewareHandler) MiddlewareHandler {
+	return func(next MiddlewareHandler) MiddlewareHandler {
+		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+			if len(other) > 0 {
+				if payload, ok := other[0].(*utils.Payload); ok {
+					for _, role := range roles {
+						if payload.Role == role {
+							next(w, r, p, other...)
+							return
+						}
+					}
+				}
+			}
+
+			// HTTP/x.x 403 Forbidden
+			httpcodes.ResponseError(w, httpcodes.Representation{Message: "Insufficient privileges for the operation"}.Forbidden())
+			return
+		}
 	}
 }
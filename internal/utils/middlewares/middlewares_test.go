@@ -13,6 +13,7 @@ import (
 
 	"github.com/MarioSimou/authAPI/internal/controllers"
 	"github.com/MarioSimou/authAPI/internal/models"
+	"github.com/MarioSimou/authAPI/internal/store"
 	"github.com/MarioSimou/authAPI/internal/utils"
 	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
 
@@ -50,9 +51,9 @@ func parseResponseBody(res *http.Response) *httpcodes.Representation {
 func init() {
 	u = utils.Utils{}
 	u.LoadDotEnv("../../../configs/.test.env")
-	m = Middleware{Utils: &u}
-	mcli := u.ConnectDatabase(os.Getenv("MONGO_URI"), os.Getenv("DB_NAME"))
-	c = controllers.NewController(mcli, &u)
+	s := store.NewMemoryStore()
+	m = Middleware{Utils: &u, Store: s}
+	c = controllers.NewController(s, &u, nil)
 }
 
 func TestCreateUser(t *testing.T) {
@@ -338,3 +339,173 @@ func TestAuthorizationValidToken(t *testing.T) {
 	checkStatusCode(res, 200, t)
 	checkHeader(w, "Content-Type", "application/json", t)
 }
+
+func TestAuthorizationClientToken(t *testing.T) {
+	userId, _ := primitive.ObjectIDFromHex("5db5b5b06507b38887bedc87")
+	user := models.User{Id: &userId, Email: "paul@gmail.com"}
+	token, _ := u.IssueClientToken(user, "http://localhost:8080", "5db5b5b06507b38887bedc90", []string{models.ScopeOpenID}, time.Hour)
+
+	// the token of a client only grants its scopes, so it can not be used on the routes of the API
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/users", nil)
+	r.Header.Set("Authorization", "Bearer "+string(token))
+	m.Authorization(customRoute)(w, r, nil)
+	checkStatusCode(w.Result(), 401, t)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/userinfo", nil)
+	r.Header.Set("Authorization", "Bearer "+string(token))
+	m.ClientAuthorization(customRoute)(w, r, nil)
+	checkStatusCode(w.Result(), 200, t)
+}
+
+func TestOptionalAuthorization(t *testing.T) {
+	w := httptest.NewRecorder()
+	m.OptionalAuthorization(customRoute)(w, httptest.NewRequest("GET", "/api/v1/photos/1/original", nil), nil)
+	checkStatusCode(w.Result(), 200, t)
+
+	// a token that is sent along must still be valid
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/photos/1/original", nil)
+	r.Header.Set("Authorization", "Bearer invalid")
+	m.OptionalAuthorization(customRoute)(w, r, nil)
+	checkStatusCode(w.Result(), 401, t)
+}
+
+func TestAuthorizationRevokedToken(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/users", nil)
+	userId, _ := primitive.ObjectIDFromHex("5db5b5b06507b38887bedc87")
+	user := models.User{Id: &userId, Email: "paul@gmail.com"}
+	token, _ := u.GenerateToken(user, os.Getenv("JWT_SECRET"), time.Hour)
+	payload, _ := u.VerifyToken(token, os.Getenv("JWT_SECRET"))
+	m.Store.Revocations.Revoke(r.Context(), payload.JWTID, time.Now().Add(time.Hour))
+	r.Header.Set("Authorization", "Bearer "+string(token))
+	m.Authorization(customRoute)(w, r, nil)
+
+	res := w.Result()
+	checkStatusCode(res, 401, t)
+	repr := parseResponseBody(res)
+	if repr.Message != "Invalid user token" {
+		t.Errorf("Should have returned an error message of %v rather than %v", "Invalid user token", repr.Message)
+	}
+}
+
+func TestAuthorizationRevokedUser(t *testing.T) {
+	userId, _ := primitive.ObjectIDFromHex("5db5b5b06507b38887bedc89")
+	user := models.User{Id: &userId, Email: "john@gmail.com"}
+	token, _ := u.GenerateToken(user, os.Getenv("JWT_SECRET"), time.Hour)
+	time.Sleep(1 * time.Second)
+	now := time.Now().Truncate(time.Second)
+	m.Store.Revocations.RevokeUser(httptest.NewRequest("GET", "/", nil).Context(), userId, now, now.Add(time.Hour))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/users", nil)
+	r.Header.Set("Authorization", "Bearer "+string(token))
+	m.Authorization(customRoute)(w, r, nil)
+	checkStatusCode(w.Result(), 401, t)
+
+	// a token issued within the second of the revocation is rejected as well, while a token of a later second is
+	// accepted
+	token, _ = u.GenerateToken(user, os.Getenv("JWT_SECRET"), time.Hour)
+	payload, _ := u.VerifyToken(token, os.Getenv("JWT_SECRET"))
+	now = payload.IssuedAt.Time
+	m.Store.Revocations.RevokeUser(httptest.NewRequest("GET", "/", nil).Context(), userId, now, now.Add(time.Hour))
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/api/v1/users", nil)
+	r.Header.Set("Authorization", "Bearer "+string(token))
+	m.Authorization(customRoute)(w, r, nil)
+	checkStatusCode(w.Result(), 401, t)
+
+	time.Sleep(time.Until(now.Add(time.Second)))
+	token, _ = u.GenerateToken(user, os.Getenv("JWT_SECRET"), time.Hour)
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/api/v1/users", nil)
+	r.Header.Set("Authorization", "Bearer "+string(token))
+	m.Authorization(customRoute)(w, r, nil)
+	checkStatusCode(w.Result(), 200, t)
+}
+
+func TestRequireRole(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/users", nil)
+	m.RequireRole(models.RoleAdmin)(customRoute)(w, r, nil, &utils.Payload{Role: models.RoleAdmin})
+
+	checkStatusCode(w.Result(), 200, t)
+}
+
+func TestRequireRoleInsufficientRole(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/users", nil)
+	m.RequireRole(models.RoleAdmin)(customRoute)(w, r, nil, &utils.Payload{Role: models.RoleBasic})
+
+	res := w.Result()
+	checkStatusCode(res, 403, t)
+	checkHeader(w, "Content-Type", "application/json", t)
+	repr := parseResponseBody(res)
+	if repr.Message != "Insufficient privileges for the operation" {
+		t.Errorf("Should have returned an error message of %v rather than %v", "Insufficient privileges for the operation", repr.Message)
+	}
+}
+
+func TestRequireRoleWithoutPayload(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/users", nil)
+	m.RequireRole(models.RoleAdmin)(customRoute)(w, r, nil)
+
+	checkStatusCode(w.Result(), 403, t)
+}
+
+func TestAuthorizationWithRequireRole(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/users", nil)
+	userId, _ := primitive.ObjectIDFromHex("5db5b5b06507b38887bedc87")
+	user := models.User{Id: &userId, Email: "paul@gmail.com", Role: models.RoleAdmin}
+	token, _ := u.GenerateToken(user, os.Getenv("JWT_SECRET"), time.Hour)
+	r.Header.Set("Authorization", "Bearer "+string(token))
+	m.Authorization(m.RequireRole(models.RoleAdmin)(customRoute))(w, r, nil)
+
+	checkStatusCode(w.Result(), 200, t)
+}
+
+func TestCreateUserAdminRole(t *testing.T) {
+	var role string
+	w := httptest.NewRecorder()
+	body := []byte(`{"username": "paul","email":"paul@gmail.com","password":"12345678","role":"ADMIN"}`)
+	r := httptest.NewRequest("POST", "/api/v1/users", bytes.NewBuffer(body))
+	m.ValidateCreateUser(func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+		var user models.User
+		json.NewDecoder(r.Body).Decode(&user)
+		role = user.Role
+	})(w, r, nil)
+
+	if role != models.RoleBasic {
+		t.Errorf("Should have created a user with a role of %v rather than %v", models.RoleBasic, role)
+	}
+}
+
+func TestValidateUpload(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/photos", nil)
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Content-Type", "multipart/form-data; boundary=xyz")
+	m.ValidateUpload(customRoute)(w, r, nil)
+
+	checkStatusCode(w.Result(), 200, t)
+}
+
+func TestValidateUploadContentType(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/photos", nil)
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Content-Type", "application/json")
+	m.ValidateUpload(customRoute)(w, r, nil)
+
+	res := w.Result()
+	checkStatusCode(res, 415, t)
+	checkHeader(w, "Content-Type", "application/json", t)
+	repr := parseResponseBody(res)
+	if repr.Message != "A MIME type of multipart/form-data is only accepted" {
+		t.Errorf("Should have returned an error message of %v rather than %v", "A MIME type of multipart/form-data is only accepted", repr.Message)
+	}
+}
@@ -0,0 +1,199 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/utils"
+	"github.com/MarioSimou/authAPI/internal/utils/httpcodes"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Limit is a custom type used to describe the rate limit of a route. A principal can make a burst of Requests, while
+// its tokens are refilled evenly over Per.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// rate returns the tokens that are refilled per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// ParseLimit parses a limit written as requests/duration, such as 10/1m
+func ParseLimit(s string) (Limit, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", s)
+	}
+	requests, e := strconv.Atoi(parts[0])
+	if e != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("invalid requests of rate limit %q", s)
+	}
+	per, e := time.ParseDuration(parts[1])
+	if e != nil || per <= 0 {
+		return Limit{}, fmt.Errorf("invalid duration of rate limit %q", s)
+	}
+	return Limit{Requests: requests, Per: per}, nil
+}
+
+// ParseLimits parses the limits of a list of routes, written as route=requests/duration and separated by commas
+func ParseLimits(s string) (map[string]Limit, error) {
+	limits := map[string]Limit{}
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid rate limit entry %q", entry)
+		}
+		limit, e := ParseLimit(kv[1])
+		if e != nil {
+			return nil, e
+		}
+		limits[kv[0]] = limit
+	}
+	return limits, nil
+}
+
+// Quota is a custom type used to describe the state of a bucket after a request has taken a token from it
+type Quota struct {
+	Allowed   bool
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next token is available, when the request was not allowed
+	RetryAfter time.Duration
+}
+
+// Limiter is an interface that describes the backend that keeps the token buckets of the rate limits
+type Limiter interface {
+	// Take removes a token from the bucket of a key, if one is available
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Quota, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full is the time that the bucket is full again, after which it can be forgotten
+	full time.Time
+}
+
+// limiterSweepInterval is how often a MemoryLimiter forgets the buckets that are full
+const limiterSweepInterval = time.Minute
+
+// MemoryLimiter is a thread-safe Limiter that keeps the token buckets in memory. Every instance of the API keeps its
+// own buckets.
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	sweptAt time.Time
+}
+
+// NewMemoryLimiter returns a MemoryLimiter without any buckets
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: map[string]*bucket{}}
+}
+
+// Take removes a token from the bucket of a key, if one is available
+func (l *MemoryLimiter) Take(ctx context.Context, key string, limit Limit, now time.Time) (Quota, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.sweptAt) >= limiterSweepInterval {
+		for k, b := range l.buckets {
+			if !now.Before(b.full) {
+				delete(l.buckets, k)
+			}
+		}
+		l.sweptAt = now
+	}
+
+	capacity := float64(limit.Requests)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		l.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*limit.rate())
+		b.updated = now
+	}
+
+	q := Quota{}
+	if b.tokens >= 1 {
+		b.tokens--
+		q.Allowed = true
+	} else {
+		q.RetryAfter = seconds((1 - b.tokens) / limit.rate())
+	}
+	q.Remaining = int(b.tokens)
+	q.Reset = seconds((capacity - b.tokens) / limit.rate())
+	b.full = now.Add(q.Reset)
+	return q, nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// ceilSeconds returns a duration as the number of whole seconds, rounded up, that the headers use
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// principal returns the key of the client of a request. Authenticated requests are keyed by their user, while anonymous
+// requests are keyed by their IP address.
+func (m Middleware) principal(r *http.Request, other []interface{}) string {
+	if len(other) > 0 {
+		if payload, ok := other[0].(*utils.Payload); ok && payload.Id != nil {
+			return "user:" + payload.Id.Hex()
+		}
+	}
+	return "ip:" + m.Utils.ClientIP(r)
+}
+
+// RateLimit limits the requests that a client can make to a route, using the limit of the route within Limits. Routes
+// without a limit are not limited. It needs to be wrapped by Authorization to key the requests by their user rather
+// than by their IP address. The state of the limit is sent in the RateLimit-* headers, while an exceeded limit returns
+// an HTTP 429 Too Many Requests.
+func (m Middleware) RateLimit(route string) func(MiddlewareHandler) MiddlewareHandler {
+	return func(next MiddlewareHandler) MiddlewareHandler {
+		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, other ...interface{}) {
+			limit, ok := m.Limits[route]
+			if !ok || m.Limiter == nil {
+				next(w, r, p, other...)
+				return
+			}
+
+			q, e := m.Limiter.Take(r.Context(), route+"|"+m.principal(r, other), limit, time.Now())
+			if e != nil {
+				httpcodes.ResponseError(w, httpcodes.Representation{Message: "Unable to check the rate limit"}.InternalServerError())
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+			h.Set("RateLimit-Remaining", strconv.Itoa(q.Remaining))
+			h.Set("RateLimit-Reset", ceilSeconds(q.Reset))
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%v", limit.Requests, ceilSeconds(limit.Per)))
+			if !q.Allowed {
+				// HTTP/x.x 429 Too Many Requests
+				h.Set("Retry-After", ceilSeconds(q.RetryAfter))
+				httpcodes.ResponseError(w, httpcodes.Representation{Message: "Too many requests"}.TooManyRequests())
+				return
+			}
+
+			next(w, r, p, other...)
+		}
+	}
+}
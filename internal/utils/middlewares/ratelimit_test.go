@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MarioSimou/authAPI/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func limitedMiddleware(limits map[string]Limit) Middleware {
+	return Middleware{Utils: &u, Store: m.Store, Limits: limits, Limiter: NewMemoryLimiter()}
+}
+
+func limitedRequest(lm Middleware, route string, ip string, other ...interface{}) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/v1/search", nil)
+	r.RemoteAddr = ip + ":1234"
+	lm.RateLimit(route)(customRoute)(w, r, nil, other...)
+	return w
+}
+
+func TestRateLimit(t *testing.T) {
+	lm := limitedMiddleware(map[string]Limit{"search": Limit{Requests: 2, Per: time.Minute}})
+
+	w := limitedRequest(lm, "search", "198.51.100.1")
+	checkStatusCode(w.Result(), 200, t)
+	checkHeader(w, "RateLimit-Limit", "2", t)
+	checkHeader(w, "RateLimit-Remaining", "1", t)
+	checkHeader(w, "RateLimit-Reset", "30", t)
+	checkHeader(w, "RateLimit-Policy", "2;w=60", t)
+
+	checkStatusCode(limitedRequest(lm, "search", "198.51.100.1").Result(), 200, t)
+	w = limitedRequest(lm, "search", "198.51.100.1")
+	checkStatusCode(w.Result(), 429, t)
+	checkHeader(w, "RateLimit-Remaining", "0", t)
+	checkHeader(w, "Retry-After", "30", t)
+
+	// every IP address has its own bucket
+	checkStatusCode(limitedRequest(lm, "search", "198.51.100.2").Result(), 200, t)
+}
+
+func TestRateLimitByUser(t *testing.T) {
+	lm := limitedMiddleware(map[string]Limit{"search": Limit{Requests: 1, Per: time.Minute}})
+	id := primitive.NewObjectID()
+	payload := &utils.Payload{Id: &id}
+
+	checkStatusCode(limitedRequest(lm, "search", "198.51.100.1", payload).Result(), 200, t)
+	// the user is limited from any IP address, while anonymous requests of the same IP address are not
+	checkStatusCode(limitedRequest(lm, "search", "198.51.100.2", payload).Result(), 429, t)
+	checkStatusCode(limitedRequest(lm, "search", "198.51.100.1").Result(), 200, t)
+}
+
+func TestRateLimitPerRoute(t *testing.T) {
+	lm := limitedMiddleware(map[string]Limit{"signin": Limit{Requests: 1, Per: time.Minute}})
+
+	checkStatusCode(limitedRequest(lm, "signin", "198.51.100.1").Result(), 200, t)
+	checkStatusCode(limitedRequest(lm, "signin", "198.51.100.1").Result(), 429, t)
+	// routes without a limit are not limited
+	w := limitedRequest(lm, "search", "198.51.100.1")
+	checkStatusCode(w.Result(), 200, t)
+	checkHeader(w, "RateLimit-Limit", "", t)
+}
+
+func TestMemoryLimiterRefill(t *testing.T) {
+	l := NewMemoryLimiter()
+	limit := Limit{Requests: 2, Per: 2 * time.Second}
+	now := time.Now()
+
+	l.Take(context.Background(), "key", limit, now)
+	l.Take(context.Background(), "key", limit, now)
+	if q, _ := l.Take(context.Background(), "key", limit, now.Add(500*time.Millisecond)); q.Allowed || q.RetryAfter != 500*time.Millisecond {
+		t.Errorf("Should have waited for the next token rather than returning %+v", q)
+	}
+	if q, _ := l.Take(context.Background(), "key", limit, now.Add(time.Second)); !q.Allowed || q.Remaining != 0 {
+		t.Errorf("Should have refilled a token after a second rather than returning %+v", q)
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, e := ParseLimits("signin=10/1m, search=60/1h")
+	if e != nil || limits["signin"] != (Limit{Requests: 10, Per: time.Minute}) || limits["search"] != (Limit{Requests: 60, Per: time.Hour}) {
+		t.Errorf("Should have parsed the limits rather than returning %v, %v", limits, e)
+	}
+	for _, s := range []string{"signin", "signin=10", "signin=0/1m", "signin=10/0s", "=10/1m", "signin=ten/1m"} {
+		if _, e := ParseLimits(s); e == nil {
+			t.Errorf("Should have rejected %q", s)
+		}
+	}
+}
@@ -3,10 +3,15 @@ package utils
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"log"
+	"net"
+	"net/http"
+	"os"
 	"strings"
 	"time"
 
@@ -25,6 +30,29 @@ type Payload struct {
 	jwt.Payload
 	Email string              `json:"email,omitempty"`
 	Id    *primitive.ObjectID `json:"id,omitempty"`
+	Role  string              `json:"role,omitempty"`
+	// Scope are the space separated scopes granted to the OpenID Connect client of the token, which is its audience
+	Scope string `json:"scope,omitempty"`
+}
+
+// IsClientToken is a method used to check if the token was issued to an OpenID Connect client rather than to the API
+func (p *Payload) IsClientToken() bool {
+	return len(p.Audience) > 0
+}
+
+// Scopes is a method used to return the scopes granted to the client of the token
+func (p *Payload) Scopes() []string {
+	return strings.Fields(p.Scope)
+}
+
+// IsAdmin is a method used to check if the token belongs to a user with the ADMIN role
+func (p *Payload) IsAdmin() bool {
+	return p.Role == models.RoleAdmin
+}
+
+// CanAccess is a method used to check if the token belongs to the owner of a resource or to an admin
+func (p *Payload) CanAccess(id string) bool {
+	return p.Id.Hex() == id || p.IsAdmin()
 }
 
 // MongoClient is a custom type used to map a TCP connection to Mongodb
@@ -36,7 +64,8 @@ type MongoClient struct {
 
 // Connect is a method that initiates a tcp connection to mongodb, returning the result of the operation
 func (mcli *MongoClient) Connect() (*mongo.Client, error) {
-	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 	client, e := mongo.Connect(ctx, options.Client().ApplyURI(mcli.URI))
 	if e != nil {
 		return nil, e
@@ -52,7 +81,10 @@ func (mcli *MongoClient) Connect() (*mongo.Client, error) {
 }
 
 // Utils is a custom type used to represent a utilities object
-type Utils struct{}
+type Utils struct {
+	// Keys are the keys of the JWT tokens. When nil, tokens are signed by the JWT_SECRET environment variable.
+	Keys *KeySet
+}
 
 // LoadDotEnv is used to load the environment variables
 func (u Utils) LoadDotEnv(fnames ...string) {
@@ -76,46 +108,109 @@ func (u Utils) ConnectDatabase(uri string, dbName string) *MongoClient {
 func (u Utils) HashPassword(pwd string) string {
 	hpwd, e := bcrypt.GenerateFromPassword([]byte(pwd), bcrypt.MinCost)
 	if e != nil {
-		errors.New("Unable to hash password")
+		log.Println("Unable to hash password")
 	}
 	return string(hpwd)
 }
 
-// GenerateToken is used to generate a JWT token
+// GenerateToken is used to generate a JWT token signed by an HS256 secret
 func (u Utils) GenerateToken(user models.User, s string, maxAge time.Duration) ([]byte, bool) {
+	return Utils{Keys: NewKeySet(NewHMACKey("", []byte(s)))}.IssueToken(user, maxAge)
+}
+
+// VerifyToken is used to verify a JWT token signed by an HS256 secret
+func (u Utils) VerifyToken(t []byte, s string) (*Payload, bool) {
+	return Utils{Keys: NewKeySet(NewHMACKey("", []byte(s)))}.ParseToken(t)
+}
+
+// IssueToken is used to generate a JWT token signed by the signing key of the API
+func (u Utils) IssueToken(user models.User, maxAge time.Duration) ([]byte, bool) {
+	return u.issueAccessToken(user, jwt.Payload{}, "", maxAge)
+}
+
+// IssueClientToken is used to generate an access token of an OpenID Connect client, whose audience is the client and
+// whose scope claim holds the scopes granted to it. Such tokens are only accepted by the endpoints of the client.
+func (u Utils) IssueClientToken(user models.User, issuer string, clientID string, scopes []string, maxAge time.Duration) ([]byte, bool) {
+	return u.issueAccessToken(user, jwt.Payload{Issuer: issuer, Audience: jwt.Audience{clientID}}, strings.Join(scopes, " "), maxAge)
+}
+
+func (u Utils) issueAccessToken(user models.User, claims jwt.Payload, scope string, maxAge time.Duration) ([]byte, bool) {
 	if !(user.ValidateEmail() && user.Id != nil) {
 		return nil, false
 	}
 
-	hs := jwt.NewHS256([]byte(s))
 	now := time.Now()
+	claims.ExpirationTime = jwt.NumericDate(now.Add(maxAge))
+	claims.IssuedAt = jwt.NumericDate(now)
+	claims.JWTID = primitive.NewObjectID().Hex()
 	pl := Payload{
-		Email: user.Email,
-		Id:    user.Id,
-		Payload: jwt.Payload{
-			ExpirationTime: jwt.NumericDate(now.Add(maxAge)),
-		},
+		Email:   user.Email,
+		Id:      user.Id,
+		Role:    user.Role,
+		Scope:   scope,
+		Payload: claims,
 	}
-	if token, e := jwt.Sign(pl, hs); e == nil {
+	if token, e := u.SigningKeys().Sign(pl); e == nil {
 		return token, true
 	}
 	return nil, false
 }
 
-// VerifyToken is used to verify a JWT token
-func (u Utils) VerifyToken(t []byte, s string) (*Payload, bool) {
+// ParseToken is used to verify a JWT token with the key of its kid header
+func (u Utils) ParseToken(t []byte) (*Payload, bool) {
 	var pl Payload
-	hs := jwt.NewHS256([]byte(s))
 	now := time.Now()
 	expValidator := jwt.ExpirationTimeValidator(now)
 	validatePayload := jwt.ValidatePayload(&pl.Payload, expValidator)
 
-	if _, e := jwt.Verify(t, hs, &pl, validatePayload); e == nil {
+	if e := u.SigningKeys().Verify(t, &pl, validatePayload); e == nil {
 		return &pl, true
 	}
 	return nil, false
 }
 
+// IDToken is a custom type used to represent the payload of an OpenID Connect ID token
+type IDToken struct {
+	jwt.Payload
+	AuthTime int64  `json:"auth_time,omitempty"`
+	Nonce    string `json:"nonce,omitempty"`
+	models.UserClaims
+}
+
+// IssueIDToken is used to generate an OpenID Connect ID token, which tells a client which user signed in. The claims
+// of the user are limited to the scopes that were granted to the client.
+func (u Utils) IssueIDToken(user models.User, issuer string, clientID string, nonce string, authTime time.Time, scopes []string, maxAge time.Duration) ([]byte, bool) {
+	if user.Id == nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	pl := IDToken{
+		Payload: jwt.Payload{
+			Issuer:         issuer,
+			Subject:        user.Id.Hex(),
+			Audience:       jwt.Audience{clientID},
+			ExpirationTime: jwt.NumericDate(now.Add(maxAge)),
+			IssuedAt:       jwt.NumericDate(now),
+		},
+		AuthTime:   authTime.Unix(),
+		Nonce:      nonce,
+		UserClaims: user.Claims(scopes),
+	}
+	if token, e := u.SigningKeys().Sign(pl); e == nil {
+		return token, true
+	}
+	return nil, false
+}
+
+// SigningKeys returns the keys of the JWT tokens, which default to an HS256 key of the JWT_SECRET environment variable
+func (u Utils) SigningKeys() *KeySet {
+	if u.Keys != nil {
+		return u.Keys
+	}
+	return NewKeySet(NewHMACKey("", []byte(os.Getenv("JWT_SECRET"))))
+}
+
 // ExtractPayload is used to extract the payload of a JWT token (header.payload.signature)
 func (u Utils) ExtractPayload(t string) *Payload {
 	var p Payload
@@ -126,3 +221,29 @@ func (u Utils) ExtractPayload(t string) *Payload {
 
 	return &p
 }
+
+// GenerateRefreshToken is used to generate an opaque refresh token, returning the token along with its hash
+func (u Utils) GenerateRefreshToken() (string, string, bool) {
+	b := make([]byte, 32)
+	if _, e := rand.Read(b); e != nil {
+		return "", "", false
+	}
+	t := base64.RawURLEncoding.EncodeToString(b)
+	return t, u.HashToken(t), true
+}
+
+// ClientIP is used to return the IP address of the client of a request. Forwarding headers are ignored, since they can
+// be set by the client.
+func (u Utils) ClientIP(r *http.Request) string {
+	host, _, e := net.SplitHostPort(r.RemoteAddr)
+	if e != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// HashToken is used to hash an opaque token before it is stored
+func (u Utils) HashToken(t string) string {
+	h := sha256.Sum256([]byte(t))
+	return hex.EncodeToString(h[:])
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -64,6 +65,19 @@ func TestVerifyToken(t *testing.T) {
 	}
 }
 
+func TestVerifyTokenRole(t *testing.T) {
+	userId, _ := primitive.ObjectIDFromHex("5db5b5b06507b38887bedc87")
+	user := models.User{Id: &userId, Email: "paul@gmail.com", Role: models.RoleAdmin}
+	token, _ := u.GenerateToken(user, "secret", time.Hour)
+	payload, _ := u.VerifyToken([]byte(token), "secret")
+	if payload.Role != models.RoleAdmin || !payload.IsAdmin() {
+		t.Errorf("The payload should have included a role of %v rather than %v", models.RoleAdmin, payload.Role)
+	}
+	if !payload.CanAccess("5db5b5b06507b38887bedc88") {
+		t.Errorf("An admin should have been able to access the resources of other users")
+	}
+}
+
 func TestVerifyTokenWithInvalidSecret(t *testing.T) {
 	userId, _ := primitive.ObjectIDFromHex("5db5b5b06507b38887bedc87")
 	user := models.User{Id: &userId, Email: "paul@gmail.com"}
@@ -86,3 +100,25 @@ func TestExtractPayload(t *testing.T) {
 		t.Errorf("The payload should have included an Id of %v rather than %v", userId, payload.Id)
 	}
 }
+
+func TestGenerateRefreshToken(t *testing.T) {
+	token, hash, ok := u.GenerateRefreshToken()
+	if !ok || token == "" {
+		t.Errorf("Should have generated a refresh token")
+	}
+	if hash != u.HashToken(token) {
+		t.Errorf("Should have returned the hash of the token rather than %v", hash)
+	}
+	if other, _, _ := u.GenerateRefreshToken(); other == token {
+		t.Errorf("Should have generated a different token on every call")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "[2001:db8::1]:4321"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	if ip := u.ClientIP(r); ip != "2001:db8::1" {
+		t.Errorf("Should have returned the host of the remote address rather than %v", ip)
+	}
+}